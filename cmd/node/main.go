@@ -14,7 +14,8 @@ import (
 
 var (
 	configPath = flag.String("config", "", "Path to configuration file")
-	version    = "1.0.0"
+	verify     = flag.Bool("verify", false, "Re-validate every stored block before starting; exit non-zero on the first inconsistency")
+	version    = node.Version
 )
 
 func main() {
@@ -40,6 +41,9 @@ func main() {
 	if err != nil {
 		logger.Fatalf("Failed to load configuration: %v", err)
 	}
+	if *verify {
+		config.VerifyChainOnStartup = true
+	}
 
 	// Create node
 	logger.Info("Creating blockchain node...")
@@ -66,10 +70,15 @@ func main() {
 	logger.Info("Podoru Chain node is running")
 	logger.Infof("Press Ctrl+C to stop")
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal, reloading the config on SIGHUP
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		reloadConfig(n, logger)
+	}
 
 	logger.Info("Shutting down...")
 
@@ -88,6 +97,19 @@ func main() {
 	logger.Info("Goodbye!")
 }
 
+// reloadConfig re-reads the config file and applies its hot-reloadable settings
+func reloadConfig(n *node.Node, logger *logrus.Logger) {
+	logger.Infof("Reloading configuration from %s...", *configPath)
+	config, err := node.LoadConfig(*configPath)
+	if err != nil {
+		logger.Errorf("Failed to reload configuration: %v", err)
+		return
+	}
+	if err := n.Reload(config); err != nil {
+		logger.Errorf("Failed to apply configuration: %v", err)
+	}
+}
+
 func printBanner() {
 	banner := `
 ╔═══════════════════════════════════════╗
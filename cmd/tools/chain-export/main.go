@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/storage"
+)
+
+func main() {
+	dataDir := flag.String("data", "", "Data directory of the node to export (the node must be stopped)")
+	genesisPath := flag.String("genesis", "", "Genesis file the chain was created with")
+	outputPath := flag.String("output", "", "Output path for the export (\"-\" writes to stdout)")
+	flag.Parse()
+
+	if *dataDir == "" || *genesisPath == "" || *outputPath == "" {
+		fmt.Fprintf(os.Stderr, "Usage: %s -data <dir> -genesis <file> -output <file>\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	genesisConfig, err := blockchain.LoadGenesisConfig(*genesisPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading genesis: %v\n", err)
+		os.Exit(1)
+	}
+
+	store, err := storage.NewBadgerStore(*dataDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening storage: %v\n", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	chain := blockchain.NewChainWithConfig(store, genesisConfig.Authorities,
+		genesisConfig.GetGasConfig(), genesisConfig.TokenConfig)
+	if err := chain.LoadFromStorage(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading chain: %v\n", err)
+		os.Exit(1)
+	}
+	if err := chain.VerifyGenesis(genesisConfig); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var out io.Writer = os.Stdout
+	if *outputPath != "-" {
+		file, err := os.Create(*outputPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if err := chain.Export(out); err != nil {
+		fmt.Fprintf(os.Stderr, "Error exporting chain: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d blocks\n", chain.GetHeight()+1)
+}
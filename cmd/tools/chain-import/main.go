@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/storage"
+)
+
+func main() {
+	dataDir := flag.String("data", "", "Empty data directory to import into")
+	genesisPath := flag.String("genesis", "", "Genesis file the exported chain was created with")
+	inputPath := flag.String("input", "", "Path of the export to import (\"-\" reads from stdin)")
+	chainID := flag.String("chain-id", "", "Chain ID of the exported chain, if the node overrides the genesis chain_id")
+	flag.Parse()
+
+	if *dataDir == "" || *genesisPath == "" || *inputPath == "" {
+		fmt.Fprintf(os.Stderr, "Usage: %s -data <dir> -genesis <file> -input <file>\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	genesisConfig, err := blockchain.LoadGenesisConfig(*genesisPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading genesis: %v\n", err)
+		os.Exit(1)
+	}
+
+	var in io.Reader = os.Stdin
+	if *inputPath != "-" {
+		file, err := os.Open(*inputPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening input file: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	store, err := storage.NewBadgerStore(*dataDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening storage: %v\n", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	chain := blockchain.NewChainWithConfig(store, genesisConfig.Authorities,
+		genesisConfig.GetGasConfig(), genesisConfig.TokenConfig)
+	chain.SetConsensusParams(genesisConfig.ConsensusParams)
+	chain.SetKeyPolicy(genesisConfig.KeyPolicy)
+	chain.SetChainID(genesisConfig.ChainID)
+	if *chainID != "" {
+		chain.SetChainID(*chainID)
+	}
+	if err := chain.Import(in); err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing chain: %v\n", err)
+		os.Exit(1)
+	}
+	if err := chain.VerifyGenesis(genesisConfig); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Imported %d blocks (height: %d, state root: 0x%x)\n",
+		chain.GetHeight()+1, chain.GetHeight(), chain.GetCurrentBlock().Header.StateRoot)
+}
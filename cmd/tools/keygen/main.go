@@ -1,8 +1,10 @@
 package main
 
 import (
+	"crypto/ecdsa"
 	"flag"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 
@@ -12,15 +14,52 @@ import (
 func main() {
 	outputPath := flag.String("output", "", "Output path for the private key file")
 	showAddress := flag.Bool("address", true, "Show the derived address")
+	passphrase := flag.String("passphrase", "", "Encrypt the saved key as keystore JSON with this passphrase")
+	mnemonic := flag.String("mnemonic", "", "Derive the key from this BIP39 mnemonic (\"new\" generates one)")
+	index := flag.Uint("index", 0, "Account index for mnemonic derivation (m/44'/60'/0'/0/index)")
 	flag.Parse()
 
-	// Generate key pair
-	privateKey, err := crypto.GenerateKeyPair()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error generating key pair: %v\n", err)
+	if *passphrase != "" && *outputPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: -passphrase requires -output")
+		os.Exit(1)
+	}
+
+	if *index > math.MaxUint32 {
+		fmt.Fprintln(os.Stderr, "Error: -index out of range")
 		os.Exit(1)
 	}
 
+	var (
+		privateKey *ecdsa.PrivateKey
+		err        error
+	)
+
+	if *mnemonic != "" {
+		// Derive key deterministically from a mnemonic
+		if *mnemonic == "new" {
+			*mnemonic, err = crypto.NewMnemonic()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error generating mnemonic: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Mnemonic: %s\n", *mnemonic)
+		}
+
+		privateKey, err = crypto.DeriveKey(*mnemonic, uint32(*index))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error deriving key: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Derivation Path: %s\n", crypto.DerivationPath(uint32(*index)))
+	} else {
+		// Generate key pair
+		privateKey, err = crypto.GenerateKeyPair()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating key pair: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Get address
 	address, err := crypto.AddressFromPrivateKey(privateKey)
 	if err != nil {
@@ -37,13 +76,20 @@ func main() {
 			os.Exit(1)
 		}
 
-		// Save private key
-		if err := crypto.SavePrivateKeyToFile(privateKey, *outputPath); err != nil {
-			fmt.Fprintf(os.Stderr, "Error saving private key: %v\n", err)
-			os.Exit(1)
+		// Save private key (encrypted if a passphrase was given)
+		if *passphrase != "" {
+			if err := crypto.SaveEncryptedPrivateKey(privateKey, *outputPath, *passphrase); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving encrypted private key: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Encrypted private key saved to: %s\n", *outputPath)
+		} else {
+			if err := crypto.SavePrivateKeyToFile(privateKey, *outputPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving private key: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Private key saved to: %s\n", *outputPath)
 		}
-
-		fmt.Printf("Private key saved to: %s\n", *outputPath)
 	} else {
 		// Print private key in hex
 		keyBytes := crypto.PrivateKeyToBytes(privateKey)
@@ -1,8 +1,34 @@
 package rest
 
 import (
-	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
+)
+
+const (
+	// maxBatchKeys is the maximum number of keys in a batch state request
+	maxBatchKeys = 100
+
+	// defaultPrefixQueryLimit is the result limit used when none is given
+	defaultPrefixQueryLimit = 100
+
+	// maxPrefixQueryLimit is the maximum result limit for prefix queries
+	maxPrefixQueryLimit = 1000
+
+	// defaultHistoryLimit is the result limit for index queries when none is given
+	defaultHistoryLimit = 50
+
+	// maxHistoryLimit is the maximum result limit for index queries
+	maxHistoryLimit = 500
+)
+
+const (
+	// defaultReceiptWait is how long wait_for_receipt waits when no timeout is given
+	defaultReceiptWait = 30 * time.Second
+
+	// maxReceiptWait is the longest timeout wait_for_receipt accepts
+	maxReceiptWait = 120 * time.Second
 )
 
 // BatchStateRequest represents a batch state query request
@@ -19,8 +45,7 @@ type BatchStateResponse struct {
 func (s *Server) handleBatchGetState(w http.ResponseWriter, r *http.Request) {
 	var req BatchStateRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if !decodeJSONBody(w, r, &req) {
 		return
 	}
 
@@ -30,8 +55,8 @@ func (s *Server) handleBatchGetState(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Limit batch size to prevent abuse
-	if len(req.Keys) > 100 {
-		writeError(w, http.StatusBadRequest, "maximum 100 keys per batch request")
+	if len(req.Keys) > maxBatchKeys {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("maximum %d keys per batch request", maxBatchKeys))
 		return
 	}
 
@@ -47,7 +72,7 @@ func (s *Server) handleBatchGetState(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	writeSuccess(w, results)
+	writeStreamedObject(w, s.node.GetConfig().APIMaxResponseBytes, "results", results, nil)
 }
 
 // PrefixQueryRequest represents a prefix query request
@@ -61,8 +86,7 @@ type PrefixQueryRequest struct {
 func (s *Server) handleQueryByPrefix(w http.ResponseWriter, r *http.Request) {
 	var req PrefixQueryRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if !decodeJSONBody(w, r, &req) {
 		return
 	}
 
@@ -72,8 +96,8 @@ func (s *Server) handleQueryByPrefix(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Default limit
-	if req.Limit == 0 || req.Limit > 1000 {
-		req.Limit = 100
+	if req.Limit == 0 || req.Limit > maxPrefixQueryLimit {
+		req.Limit = defaultPrefixQueryLimit
 	}
 
 	results, err := s.node.GetChain().QueryStateByPrefix(req.Prefix, req.Limit)
@@ -82,9 +106,11 @@ func (s *Server) handleQueryByPrefix(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeSuccess(w, map[string]interface{}{
-		"prefix":  req.Prefix,
-		"count":   len(results),
-		"results": results,
-	})
+	values := make(map[string]interface{}, len(results))
+	for key, value := range results {
+		values[key] = value
+	}
+
+	writeStreamedObject(w, s.node.GetConfig().APIMaxResponseBytes, "results", values,
+		map[string]interface{}{"prefix": req.Prefix})
 }
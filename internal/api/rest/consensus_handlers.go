@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+// ProofStep is one sibling hash on a Merkle path, ordered from the leaf up
+type ProofStep struct {
+	Hash     string `json:"hash"`
+	Position string `json:"position"` // "left" or "right" of the running hash
+}
+
+// handleGetAuthorityProof returns the authority set committed in state with a Merkle
+// proof against the current state root
+func (s *Server) handleGetAuthorityProof(w http.ResponseWriter, r *http.Request) {
+	proof, block, err := s.node.GetChain().ProveState(blockchain.AuthoritySetKey)
+	if err != nil {
+		if errors.Is(err, blockchain.ErrKeyNotFound) {
+			writeError(w, http.StatusNotFound, "authority set is not committed in state on this chain")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	authorities, err := blockchain.DecodeAuthorities(proof.Value)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	steps := make([]ProofStep, len(proof.Steps))
+	for i, step := range proof.Steps {
+		position := "right"
+		if step.Left {
+			position = "left"
+		}
+		steps[i] = ProofStep{
+			Hash:     fmt.Sprintf("0x%x", step.Hash),
+			Position: position,
+		}
+	}
+
+	writeSuccess(w, map[string]interface{}{
+		"height":        block.Header.Height,
+		"block_hash":    fmt.Sprintf("0x%x", block.Hash()),
+		"block_version": block.Header.Version,
+		"state_root":    fmt.Sprintf("0x%x", block.Header.StateRoot),
+		"key":           proof.Key,
+		"value":         string(proof.Value),
+		"authorities":   authorities,
+		"proof":         steps,
+	})
+}
@@ -1,15 +1,20 @@
 package rest
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/podoru/podoru-chain/internal/blockchain"
 	"github.com/podoru/podoru-chain/internal/crypto"
+	"github.com/podoru/podoru-chain/internal/node"
 )
 
 // Response represents a standard API response
@@ -53,6 +58,47 @@ func (s *Server) handleGetChainInfo(w http.ResponseWriter, r *http.Request) {
 	writeSuccess(w, info)
 }
 
+// ChainStatsResponse represents aggregate chain statistics and the mempool size
+type ChainStatsResponse struct {
+	*blockchain.ChainStats
+	MempoolSize int `json:"mempool_size"`
+}
+
+// handleGetChainStats returns aggregate chain statistics
+func (s *Server) handleGetChainStats(w http.ResponseWriter, r *http.Request) {
+	writeSuccess(w, ChainStatsResponse{
+		ChainStats:  s.node.GetChain().GetStats(),
+		MempoolSize: s.node.GetMempool().Count(),
+	})
+}
+
+// ChainLimitsResponse represents the limits enforced by this node
+type ChainLimitsResponse struct {
+	*blockchain.ProtocolLimits
+	BlockSizeSoftLimit  int `json:"block_size_soft_limit"`
+	MaxMempoolSize      int `json:"max_mempool_size"`
+	MaxMempoolTxSize    int `json:"max_mempool_tx_size"`
+	MaxBatchKeys        int `json:"max_batch_keys"`
+	MaxPrefixQueryLimit int `json:"max_prefix_query_limit"`
+	MaxTxBodyBytes      int `json:"max_tx_body_bytes"`    // 0 = unlimited
+	MaxQueryBodyBytes   int `json:"max_query_body_bytes"` // 0 = unlimited
+}
+
+// handleGetChainLimits returns the active protocol and API limits
+func (s *Server) handleGetChainLimits(w http.ResponseWriter, r *http.Request) {
+	maxMempoolSize, maxMempoolTxSize := s.node.GetMempool().Limits()
+	writeSuccess(w, ChainLimitsResponse{
+		ProtocolLimits:      blockchain.GetProtocolLimits(s.node.GetChain().GetConsensusParams()),
+		BlockSizeSoftLimit:  s.node.GetConfig().BlockSizeSoftLimit,
+		MaxMempoolSize:      maxMempoolSize,
+		MaxMempoolTxSize:    maxMempoolTxSize,
+		MaxBatchKeys:        maxBatchKeys,
+		MaxPrefixQueryLimit: maxPrefixQueryLimit,
+		MaxTxBodyBytes:      s.node.GetConfig().APIMaxTxBodyBytes,
+		MaxQueryBodyBytes:   s.node.GetConfig().APIMaxQueryBodyBytes,
+	})
+}
+
 // handleGetBlockByHash returns a block by its hash
 func (s *Server) handleGetBlockByHash(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -91,6 +137,10 @@ func (s *Server) handleGetBlockByHeight(w http.ResponseWriter, r *http.Request)
 
 	block, err := s.node.GetChain().GetBlockByHeight(height)
 	if err != nil {
+		if errors.Is(err, blockchain.ErrBlockPruned) {
+			writeError(w, http.StatusGone, "block has been pruned")
+			return
+		}
 		writeError(w, http.StatusNotFound, "block not found")
 		return
 	}
@@ -104,6 +154,47 @@ func (s *Server) handleGetLatestBlock(w http.ResponseWriter, r *http.Request) {
 	writeSuccess(w, block)
 }
 
+// submitAndWaitForReceipt submits a transaction and responds with its receipt once mined,
+// or 202 with the pending hash if it isn't mined within the timeout
+func (s *Server) submitAndWaitForReceipt(w http.ResponseWriter, r *http.Request, tx *blockchain.Transaction) {
+	timeout := defaultReceiptWait
+	if seconds, err := strconv.Atoi(r.URL.Query().Get("timeout")); err == nil && seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+		if timeout > maxReceiptWait {
+			timeout = maxReceiptWait
+		}
+	}
+
+	// The wait can outlast the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + 5*time.Second))
+
+	receipt, err := s.node.SubmitTransactionAndWait(r.Context(), tx, timeout)
+	switch {
+	case err == nil:
+		writeSuccess(w, receipt)
+	case errors.Is(err, node.ErrReceiptTimeout):
+		writeJSON(w, http.StatusAccepted, Response{
+			Success: true,
+			Data: map[string]string{
+				"transaction_hash": fmt.Sprintf("0x%x", tx.ID),
+				"status":           "pending",
+			},
+		})
+	case errors.Is(err, context.Canceled):
+		// Client went away
+	default:
+		writeError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+// TransactionResponse is a transaction with where the canonical chain included it.
+// The location is omitted for a transaction whose block was reverted or pruned.
+type TransactionResponse struct {
+	*blockchain.Transaction
+	BlockHeight *uint64 `json:"block_height,omitempty"`
+	Index       *int    `json:"index,omitempty"` // Position in the block
+}
+
 // handleGetTransaction returns a transaction by hash
 func (s *Server) handleGetTransaction(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -120,13 +211,168 @@ func (s *Server) handleGetTransaction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tx, err := s.node.GetChain().GetTransaction(hash)
+	chain := s.node.GetChain()
+	tx, err := chain.GetTransaction(hash)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "transaction not found")
 		return
 	}
 
-	writeSuccess(w, tx)
+	response := TransactionResponse{Transaction: tx}
+	height, index, err := chain.GetTransactionLocation(hash)
+	if err == nil {
+		response.BlockHeight = &height
+		response.Index = &index
+	} else if !errors.Is(err, blockchain.ErrReceiptNotFound) {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeSuccess(w, response)
+}
+
+// ReceiptResponse is the outcome of a transaction included in a block
+type ReceiptResponse struct {
+	TransactionHash string `json:"transaction_hash"`
+	BlockHeight     uint64 `json:"block_height"`
+	BlockHash       string `json:"block_hash"`
+	Index           int    `json:"index"`
+	GasFee          string `json:"gas_fee"` // In wei
+	Success         bool   `json:"success"`
+	Error           string `json:"error,omitempty"`
+}
+
+// handleGetReceipt returns the receipt of a transaction in the canonical chain
+func (s *Server) handleGetReceipt(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	hashStr := vars["hash"]
+
+	// Remove 0x prefix if present
+	if len(hashStr) > 2 && hashStr[:2] == "0x" {
+		hashStr = hashStr[2:]
+	}
+
+	hash, err := hex.DecodeString(hashStr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid hash format")
+		return
+	}
+
+	receipt, err := s.node.GetChain().GetReceipt(hash)
+	if err != nil {
+		if errors.Is(err, blockchain.ErrReceiptNotFound) {
+			writeError(w, http.StatusNotFound, "receipt not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeSuccess(w, ReceiptResponse{
+		TransactionHash: fmt.Sprintf("0x%x", receipt.TransactionHash),
+		BlockHeight:     receipt.BlockHeight,
+		BlockHash:       fmt.Sprintf("0x%x", receipt.BlockHash),
+		Index:           receipt.Index,
+		GasFee:          receipt.GasFee.String(),
+		Success:         receipt.Success,
+		Error:           receipt.Error,
+	})
+}
+
+// parseLimit reads the optional "limit" query parameter, clamped to maxLimit
+func parseLimit(r *http.Request, defaultLimit, maxLimit int) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > maxLimit {
+		return defaultLimit
+	}
+	return limit
+}
+
+// decodeJSONBody decodes the request body into v. It writes 413 if the body is over
+// the route's size limit or 400 if it isn't valid JSON, and returns false in both cases.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", maxBytesErr.Limit))
+		return false
+	}
+
+	writeError(w, http.StatusBadRequest, "invalid request body")
+	return false
+}
+
+// writeTransactionList streams a list of transactions, capped at api_max_response_bytes
+func (s *Server) writeTransactionList(w http.ResponseWriter, transactions []*blockchain.Transaction, meta map[string]interface{}) {
+	writeStreamedList(w, s.node.GetConfig().APIMaxResponseBytes, "transactions", len(transactions),
+		func(i int) interface{} { return transactions[i] }, meta)
+}
+
+// writeIndexError writes the error response for a failed index query
+func writeIndexError(w http.ResponseWriter, err error, index string) {
+	if errors.Is(err, blockchain.ErrIndexingDisabled) {
+		writeError(w, http.StatusServiceUnavailable, index+" indexing disabled on this node")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+// handleGetKeyTransactions returns transactions that touched a state key
+func (s *Server) handleGetKeyTransactions(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	limit := parseLimit(r, defaultHistoryLimit, maxHistoryLimit)
+
+	transactions, err := s.node.GetChain().GetTransactionsByKey(key, limit)
+	if err != nil {
+		writeIndexError(w, err, "key")
+		return
+	}
+
+	s.writeTransactionList(w, transactions, map[string]interface{}{"key": key})
+}
+
+// handleGetAccountTransactions returns the transactions sent from an address
+func (s *Server) handleGetAccountTransactions(w http.ResponseWriter, r *http.Request) {
+	address := mux.Vars(r)["address"]
+
+	if !crypto.IsValidAddress(address) {
+		writeError(w, http.StatusBadRequest, "invalid address format")
+		return
+	}
+
+	limit := parseLimit(r, defaultHistoryLimit, maxHistoryLimit)
+
+	transactions, err := s.node.GetChain().GetTransactionsByAddress(address, limit)
+	if err != nil {
+		writeIndexError(w, err, "sender")
+		return
+	}
+
+	s.writeTransactionList(w, transactions, map[string]interface{}{"address": address})
+}
+
+// handleGetAccountTransfers returns the transfer history of an address
+func (s *Server) handleGetAccountTransfers(w http.ResponseWriter, r *http.Request) {
+	address := mux.Vars(r)["address"]
+
+	if !crypto.IsValidAddress(address) {
+		writeError(w, http.StatusBadRequest, "invalid address format")
+		return
+	}
+
+	limit := parseLimit(r, defaultHistoryLimit, maxHistoryLimit)
+
+	transactions, err := s.node.GetChain().GetTransfersByAddress(address, limit)
+	if err != nil {
+		writeIndexError(w, err, "transfer")
+		return
+	}
+
+	s.writeTransactionList(w, transactions, map[string]interface{}{"address": address})
 }
 
 // SubmitTransactionRequest represents a transaction submission request
@@ -138,8 +384,7 @@ type SubmitTransactionRequest struct {
 func (s *Server) handleSubmitTransaction(w http.ResponseWriter, r *http.Request) {
 	var req SubmitTransactionRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if !decodeJSONBody(w, r, &req) {
 		return
 	}
 
@@ -148,6 +393,11 @@ func (s *Server) handleSubmitTransaction(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if r.URL.Query().Get("wait_for_receipt") == "true" {
+		s.submitAndWaitForReceipt(w, r, req.Transaction)
+		return
+	}
+
 	if err := s.node.SubmitTransaction(req.Transaction); err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
@@ -178,18 +428,23 @@ func (s *Server) handleGetState(w http.ResponseWriter, r *http.Request) {
 
 // NodeInfo represents node information
 type NodeInfo struct {
-	Version string `json:"version"`
-	Type    string `json:"type"`
-	Address string `json:"address"`
-	Peers   int    `json:"peers"`
+	Version   string `json:"version"`
+	Type      string `json:"type"`    // full, producer or light
+	Address   string `json:"address"` // Producer address, empty for other nodes
+	Height    uint64 `json:"height"`
+	Producing bool   `json:"producing"` // Whether the block production loop is running
+	Peers     int    `json:"peers"`
 }
 
 // handleGetNodeInfo returns node information
 func (s *Server) handleGetNodeInfo(w http.ResponseWriter, r *http.Request) {
 	info := NodeInfo{
-		Version: "1.0.0",
-		Type:    "podoru-chain",
-		Peers:   s.node.GetP2PServer().PeerCount(),
+		Version:   node.Version,
+		Type:      s.node.NodeType().String(),
+		Address:   s.node.Address(),
+		Height:    s.node.Height(),
+		Producing: s.node.IsProducing(),
+		Peers:     s.node.GetP2PServer().PeerCount(),
 	}
 
 	writeSuccess(w, info)
@@ -212,8 +467,22 @@ func (s *Server) handleGetPeers(w http.ResponseWriter, r *http.Request) {
 
 // handleHealthCheck returns node health status
 func (s *Server) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
-	writeSuccess(w, map[string]string{
-		"status": "healthy",
+	startup := s.node.GetStartupStatus()
+	if !startup.Ready {
+		writeJSON(w, http.StatusServiceUnavailable, Response{
+			Success: false,
+			Error:   "node not ready",
+			Data: map[string]interface{}{
+				"status":  "starting",
+				"startup": startup,
+			},
+		})
+		return
+	}
+
+	writeSuccess(w, map[string]interface{}{
+		"status":  "healthy",
+		"startup": startup,
 	})
 }
 
@@ -221,20 +490,18 @@ func (s *Server) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleGetMempool(w http.ResponseWriter, r *http.Request) {
 	transactions := s.node.GetMempool().GetAllPendingTransactions()
 
-	writeSuccess(w, map[string]interface{}{
-		"count":        len(transactions),
-		"transactions": transactions,
-	})
+	s.writeTransactionList(w, transactions, nil)
 }
 
 // BalanceResponse represents a balance response
 type BalanceResponse struct {
-	Address          string `json:"address"`
-	Balance          string `json:"balance"`
-	BalanceFormatted string `json:"balance_formatted"`
+	Address          string  `json:"address"`
+	Balance          string  `json:"balance"`
+	BalanceFormatted string  `json:"balance_formatted"`
+	Height           *uint64 `json:"height,omitempty"` // Set when a historical height was requested
 }
 
-// handleGetBalance returns the balance for an address
+// handleGetBalance returns the balance for an address, currently or as of the "height" query parameter
 func (s *Server) handleGetBalance(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	address := vars["address"]
@@ -245,25 +512,135 @@ func (s *Server) handleGetBalance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	balance, err := s.node.GetChain().GetBalance(address)
+	chain := s.node.GetChain()
+
+	var height *uint64
+	if value := r.URL.Query().Get("height"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid height")
+			return
+		}
+		height = &parsed
+	}
+
+	var balance *big.Int
+	var err error
+	if height != nil {
+		balance, err = chain.GetBalanceAtHeight(address, *height)
+	} else {
+		balance, err = chain.GetBalance(address)
+	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		switch {
+		case errors.Is(err, blockchain.ErrHeightAboveTip):
+			writeError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, blockchain.ErrBlockPruned):
+			writeError(w, http.StatusGone, "block has been pruned")
+		default:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
 		return
 	}
 
 	writeSuccess(w, BalanceResponse{
 		Address:          address,
 		Balance:          balance.String(),
-		BalanceFormatted: blockchain.FormatBalance(balance),
+		BalanceFormatted: blockchain.FormatBalanceFull(balance),
+		Height:           height,
 	})
 }
 
+// NonceResponse represents the next nonce for an address
+type NonceResponse struct {
+	Address string `json:"address"`
+	Nonce   uint64 `json:"nonce"`
+	Pending bool   `json:"pending"`
+}
+
+// handleGetAccountNonce returns the next nonce for an address, optionally past its pending transactions
+func (s *Server) handleGetAccountNonce(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	address := vars["address"]
+
+	// Validate address format
+	if !crypto.IsValidAddress(address) {
+		writeError(w, http.StatusBadRequest, "invalid address format")
+		return
+	}
+
+	pending := false
+	if value := r.URL.Query().Get("pending"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid pending parameter")
+			return
+		}
+		pending = parsed
+	}
+
+	nonce := s.node.GetChain().GetNonce(address)
+	if pending {
+		nonce = s.node.GetMempool().GetPendingNonce(address, nonce)
+	}
+
+	writeSuccess(w, NonceResponse{
+		Address: address,
+		Nonce:   nonce,
+		Pending: pending,
+	})
+}
+
+// ProducerFeesResponse represents what a block producer has earned to date
+type ProducerFeesResponse struct {
+	Address               string `json:"address"`
+	Fees                  string `json:"fees"` // In wei
+	FeesFormatted         string `json:"fees_formatted"`
+	BlockRewards          string `json:"block_rewards,omitempty"` // Only when block rewards are enabled
+	BlockRewardsFormatted string `json:"block_rewards_formatted,omitempty"`
+}
+
+// handleGetProducerFees returns the fees and block rewards a producer has earned
+func (s *Server) handleGetProducerFees(w http.ResponseWriter, r *http.Request) {
+	address := mux.Vars(r)["address"]
+
+	// Validate address format
+	if !crypto.IsValidAddress(address) {
+		writeError(w, http.StatusBadRequest, "invalid address format")
+		return
+	}
+
+	chain := s.node.GetChain()
+	fees, rewards, err := chain.GetProducerEarnings(address)
+	if err != nil {
+		if errors.Is(err, blockchain.ErrEarningsNotTracked) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp := ProducerFeesResponse{
+		Address:       address,
+		Fees:          fees.String(),
+		FeesFormatted: blockchain.FormatBalanceFull(fees),
+	}
+	if reward := chain.GetTokenConfig().BlockReward; reward != nil && reward.Sign() > 0 {
+		resp.BlockRewards = rewards.String()
+		resp.BlockRewardsFormatted = blockchain.FormatBalanceFull(rewards)
+	}
+
+	writeSuccess(w, resp)
+}
+
 // TokenInfoResponse represents token information
 type TokenInfoResponse struct {
 	Name        string `json:"name"`
 	Symbol      string `json:"symbol"`
 	Decimals    int    `json:"decimals"`
 	TotalSupply string `json:"total_supply,omitempty"`
+	BlockReward string `json:"block_reward,omitempty"`
 }
 
 // handleGetTokenInfo returns token information
@@ -281,11 +658,22 @@ func (s *Server) handleGetTokenInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	totalSupply := tokenConfig.InitialSupply
+	if tracked := chain.GetTotalSupply(); tracked.Sign() > 0 {
+		totalSupply = tracked.String()
+	}
+
+	blockReward := "0"
+	if tokenConfig.BlockReward != nil {
+		blockReward = tokenConfig.BlockReward.String()
+	}
+
 	writeSuccess(w, TokenInfoResponse{
 		Name:        tokenConfig.Name,
 		Symbol:      tokenConfig.Symbol,
 		Decimals:    tokenConfig.Decimals,
-		TotalSupply: tokenConfig.InitialSupply,
+		TotalSupply: totalSupply,
+		BlockReward: blockReward,
 	})
 }
 
@@ -296,11 +684,11 @@ type GasEstimateRequest struct {
 
 // GasEstimateResponse represents a gas estimate response
 type GasEstimateResponse struct {
-	TransactionSize int    `json:"transaction_size"`
-	BaseFee         string `json:"base_fee"`
-	PerByteFee      string `json:"per_byte_fee"`
-	SizeFee         string `json:"size_fee"`
-	TotalFee        string `json:"total_fee"`
+	TransactionSize   int    `json:"transaction_size"`
+	BaseFee           string `json:"base_fee"`
+	PerByteFee        string `json:"per_byte_fee"`
+	SizeFee           string `json:"size_fee"`
+	TotalFee          string `json:"total_fee"`
 	TotalFeeFormatted string `json:"total_fee_formatted"`
 }
 
@@ -308,8 +696,7 @@ type GasEstimateResponse struct {
 func (s *Server) handleEstimateGas(w http.ResponseWriter, r *http.Request) {
 	var req GasEstimateRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if !decodeJSONBody(w, r, &req) {
 		return
 	}
 
@@ -321,21 +708,55 @@ func (s *Server) handleEstimateGas(w http.ResponseWriter, r *http.Request) {
 	chain := s.node.GetChain()
 	estimate := chain.EstimateGasFee(req.TransactionSize)
 
+	perByteFee := "0"
+	if gasConfig := chain.GetGasConfig(); gasConfig != nil {
+		perByteFee = gasConfig.PerByteFee.String()
+	}
+
 	writeSuccess(w, GasEstimateResponse{
 		TransactionSize:   estimate.TransactionSize,
 		BaseFee:           estimate.BaseFee.String(),
-		PerByteFee:        chain.GetGasConfig().PerByteFee.String(),
+		PerByteFee:        perByteFee,
 		SizeFee:           estimate.SizeFee.String(),
 		TotalFee:          estimate.TotalFee.String(),
 		TotalFeeFormatted: blockchain.FormatBalance(estimate.TotalFee),
 	})
 }
 
+// GasSuggestResponse represents suggested fees taken from the pending transactions
+type GasSuggestResponse struct {
+	Low                 string `json:"low"`
+	Medium              string `json:"medium"`
+	High                string `json:"high"`
+	BaseFee             string `json:"base_fee"`
+	PendingTransactions int    `json:"pending_transactions"`
+}
+
+// handleSuggestGas suggests low, medium and high fees from the mempool
+func (s *Server) handleSuggestGas(w http.ResponseWriter, r *http.Request) {
+	gasConfig := s.node.GetChain().GetGasConfig()
+	suggestion := s.node.GetMempool().FeePercentiles(gasConfig)
+
+	baseFee := "0"
+	if gasConfig != nil {
+		baseFee = gasConfig.BaseFee.String()
+	}
+
+	writeSuccess(w, GasSuggestResponse{
+		Low:                 suggestion.Low.String(),
+		Medium:              suggestion.Medium.String(),
+		High:                suggestion.High.String(),
+		BaseFee:             baseFee,
+		PendingTransactions: suggestion.Samples,
+	})
+}
+
 // GasConfigResponse represents gas configuration
 type GasConfigResponse struct {
 	Enabled    bool   `json:"enabled"`
 	BaseFee    string `json:"base_fee"`
 	PerByteFee string `json:"per_byte_fee"`
+	BurnFees   bool   `json:"burn_fees"`
 }
 
 // handleGetGasConfig returns gas configuration
@@ -356,5 +777,6 @@ func (s *Server) handleGetGasConfig(w http.ResponseWriter, r *http.Request) {
 		Enabled:    !gasConfig.IsZeroFee(),
 		BaseFee:    gasConfig.BaseFee.String(),
 		PerByteFee: gasConfig.PerByteFee.String(),
+		BurnFees:   gasConfig.BurnFees,
 	})
 }
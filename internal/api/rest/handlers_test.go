@@ -0,0 +1,704 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/crypto"
+	"github.com/podoru/podoru-chain/internal/node"
+	"github.com/sirupsen/logrus"
+)
+
+// freePort returns a local TCP port that was free a moment ago
+func freePort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// newChainServer starts a full node on memory storage with the given genesis and
+// extra YAML config lines, and its API server on a free local port
+func newChainServer(t *testing.T, genesis *blockchain.GenesisConfig, extra string) *Server {
+	t.Helper()
+
+	return newNodeServer(t, genesis, fmt.Sprintf("node_type: full\nauthorities: [%q]\n", genesis.Authorities[0])+extra)
+}
+
+// newNodeServer starts a node on memory storage with the given genesis and node
+// type YAML config lines, and its API server on a free local port
+func newNodeServer(t *testing.T, genesis *blockchain.GenesisConfig, nodeYAML string) *Server {
+	t.Helper()
+	dir := t.TempDir()
+
+	data, err := json.Marshal(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesisPath := filepath.Join(dir, "genesis.json")
+	if err := os.WriteFile(genesisPath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	content := fmt.Sprintf("genesis_path: %s\n"+
+		"storage_backend: memory\n"+
+		"data_dir: %s\n"+
+		"p2p_bind_addr: 127.0.0.1\n"+
+		"p2p_port: %d\n"+
+		"api_bind_addr: 127.0.0.1\n"+
+		"api_port: %d\n",
+		genesisPath, dir, freePort(t), freePort(t))
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte(nodeYAML+content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := node.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	n, err := node.NewNode(config)
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	if err := n.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := NewServer(n, config.APIBindAddr, config.APIPort, logger)
+	if err := s.Start(); err != nil {
+		t.Fatalf("starting API server: %v", err)
+	}
+	t.Cleanup(func() {
+		s.Stop()
+		n.Stop()
+	})
+	return s
+}
+
+// addChainBlock builds a block with txs on top of the server's chain, signs it with
+// producer and adds it, as a block received from the authority would be
+func addChainBlock(t *testing.T, s *Server, producer *crypto.KeySigner, txs ...*blockchain.Transaction) {
+	t.Helper()
+
+	chain := s.node.GetChain()
+	parent := chain.GetCurrentBlock()
+	blockchain.SortTransactions(txs)
+	stateRoot, err := chain.CalculateStateRootWithTransactions(txs, producer.Address())
+	if err != nil {
+		t.Fatalf("CalculateStateRootWithTransactions: %v", err)
+	}
+
+	version := chain.GetConsensusParams().BlockVersion
+	block := blockchain.NewBlock(&blockchain.BlockHeader{
+		Version:      version,
+		Height:       parent.Header.Height + 1,
+		PreviousHash: parent.Hash(),
+		Timestamp:    parent.Header.Timestamp + 1,
+		MerkleRoot:   blockchain.CalculateMerkleRoot(txs, version),
+		StateRoot:    stateRoot,
+		ProducerAddr: producer.Address(),
+		ChainID:      chain.GetChainID(),
+	}, txs)
+	if err := block.Sign(producer); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.AddBlock(block); err != nil {
+		t.Fatalf("AddBlock: %v", err)
+	}
+}
+
+func TestGetChainLimits(t *testing.T) {
+	genesis := &blockchain.GenesisConfig{
+		Timestamp:   time.Now().Add(-time.Hour).Unix(),
+		Authorities: []string{"0x0000000000000000000000000000000000000001"},
+		ConsensusParams: &blockchain.ConsensusParams{
+			MaxBlockSize:                100000,
+			MaxTransactionsPerBlock:     50,
+			MaxMempoolSize:              200,
+			MaxOperationsPerTransaction: 8,
+		},
+	}
+	// The soft limit exceeds the genesis max_block_size, so the node lowers it
+	s := newChainServer(t, genesis, "block_size_soft_limit: 500000\napi_max_tx_body_bytes: 4096\n")
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/chain/limits", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	var resp struct {
+		Data struct {
+			MaxBlockSize                int `json:"max_block_size"`
+			MaxTransactionsPerBlock     int `json:"max_transactions_per_block"`
+			MaxOperationsPerTransaction int `json:"max_operations_per_transaction"`
+			BlockSizeSoftLimit          int `json:"block_size_soft_limit"`
+			MaxMempoolSize              int `json:"max_mempool_size"`
+			MaxMempoolTxSize            int `json:"max_mempool_tx_size"`
+			MaxBatchKeys                int `json:"max_batch_keys"`
+			MaxTxBodyBytes              int `json:"max_tx_body_bytes"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	got := resp.Data
+	checks := []struct {
+		name      string
+		got, want int
+	}{
+		{"max_block_size", got.MaxBlockSize, 100000},
+		{"max_transactions_per_block", got.MaxTransactionsPerBlock, 50},
+		{"max_operations_per_transaction", got.MaxOperationsPerTransaction, 8},
+		{"block_size_soft_limit", got.BlockSizeSoftLimit, 90000},
+		{"max_mempool_size", got.MaxMempoolSize, 200},
+		{"max_mempool_tx_size", got.MaxMempoolTxSize, 100000},
+		{"max_batch_keys", got.MaxBatchKeys, maxBatchKeys},
+		{"max_tx_body_bytes", got.MaxTxBodyBytes, 4096},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %d, want %d", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestSubmitTransactionWaitForReceiptTimeout(t *testing.T) {
+	key, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := crypto.AddressFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis := &blockchain.GenesisConfig{
+		Timestamp:   time.Now().Add(-time.Hour).Unix(),
+		Authorities: []string{"0x0000000000000000000000000000000000000001"},
+	}
+	// A full node never mines, so the wait always times out
+	s := newChainServer(t, genesis, "")
+
+	tx := blockchain.NewTransaction(address, time.Now().Unix(), &blockchain.TransactionData{
+		Operations: []*blockchain.KVOperation{{Type: blockchain.OpTypeSet, Key: "key", Value: []byte("v")}},
+	}, 0)
+	if err := tx.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(SubmitTransactionRequest{Transaction: tx})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/transaction?wait_for_receipt=true&timeout=1", bytes.NewReader(body))
+	start := time.Now()
+	s.router.ServeHTTP(rec, req)
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("responded after %s, before the timeout", elapsed)
+	}
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body)
+	}
+
+	var resp struct {
+		Data map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.Data["status"] != "pending" {
+		t.Errorf("status = %q, want pending", resp.Data["status"])
+	}
+	if err := tx.SetID(); err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("0x%x", tx.ID); resp.Data["transaction_hash"] != want {
+		t.Errorf("transaction_hash = %q, want %q", resp.Data["transaction_hash"], want)
+	}
+}
+
+func TestGetBalanceSnapshotPages(t *testing.T) {
+	genesis := &blockchain.GenesisConfig{
+		Timestamp:   time.Now().Add(-time.Hour).Unix(),
+		Authorities: []string{"0x0000000000000000000000000000000000000001"},
+		TokenConfig: &blockchain.TokenConfig{
+			Name:          "Podoru",
+			Symbol:        "PDR",
+			Decimals:      18,
+			InitialSupply: "600",
+			Accounting:    true,
+		},
+		InitialBalances: map[string]string{
+			"0x000000000000000000000000000000000000000a": "100",
+			"0x000000000000000000000000000000000000000b": "200",
+			"0x000000000000000000000000000000000000000c": "300",
+		},
+	}
+	s := newChainServer(t, genesis, "")
+
+	type page struct {
+		Data struct {
+			Balances      []BalanceSnapshotEntry `json:"balances"`
+			Height        uint64                 `json:"height"`
+			TotalAccounts int                    `json:"total_accounts"`
+			Next          string                 `json:"next"`
+		} `json:"data"`
+	}
+	get := func(query string) (int, page) {
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/snapshot/balances"+query, nil))
+		var p page
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+		}
+		return rec.Code, p
+	}
+
+	var got []BalanceSnapshotEntry
+	query := "?height=0&limit=2"
+	for {
+		code, p := get(query)
+		if code != http.StatusOK {
+			t.Fatalf("GET %s: status %d", query, code)
+		}
+		if len(p.Data.Balances) > 2 {
+			t.Errorf("page has %d entries over the limit of 2", len(p.Data.Balances))
+		}
+		if p.Data.TotalAccounts < 3 {
+			t.Errorf("total_accounts = %d, want at least 3", p.Data.TotalAccounts)
+		}
+		got = append(got, p.Data.Balances...)
+		if p.Data.Next == "" {
+			break
+		}
+		query = "?height=0&limit=2&after=" + p.Data.Next
+	}
+
+	balances := map[string]string{}
+	for i, entry := range got {
+		if i > 0 && entry.Address <= got[i-1].Address {
+			t.Errorf("page entries out of order: %s after %s", entry.Address, got[i-1].Address)
+		}
+		balances[entry.Address] = entry.Balance
+	}
+	for address, want := range genesis.InitialBalances {
+		if balances[address] != want {
+			t.Errorf("balance of %s = %q, want %q", address, balances[address], want)
+		}
+	}
+
+	if code, _ := get("?height=5"); code != http.StatusBadRequest {
+		t.Errorf("height above the tip: status %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestRouteBodyLimits(t *testing.T) {
+	genesis := &blockchain.GenesisConfig{
+		Timestamp:   time.Now().Add(-time.Hour).Unix(),
+		Authorities: []string{"0x0000000000000000000000000000000000000001"},
+	}
+	s := newChainServer(t, genesis, "api_max_tx_body_bytes: 2048\napi_max_query_body_bytes: 256\n")
+
+	// A body between the two limits fits a transaction but not a query
+	keys := make([]string, 20)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("some:longer:key:%d", i)
+	}
+	query, err := json.Marshal(BatchStateRequest{Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(query) <= 256 || len(query) >= 2048 {
+		t.Fatalf("query body is %d bytes, want between the limits", len(query))
+	}
+	padded := `{"transaction":{"padding":"` + strings.Repeat("x", 4096) + `"}}`
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"query over its limit", "/api/v1/state/batch", string(query), http.StatusRequestEntityTooLarge},
+		{"prefix query over its limit", "/api/v1/state/query/prefix", string(query), http.StatusRequestEntityTooLarge},
+		{"transaction within its limit", "/api/v1/transaction", `{"transaction":` + string(query) + `}`, http.StatusBadRequest},
+		{"transaction over its limit", "/api/v1/transaction", padded, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestGetBalanceAtHeight(t *testing.T) {
+	producerKey, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer, err := crypto.NewKeySigner(producerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userKey, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := crypto.AddressFromPrivateKey(userKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const recipient = "0x000000000000000000000000000000000000000a"
+
+	genesis := &blockchain.GenesisConfig{
+		Timestamp:   time.Now().Add(-time.Hour).Unix(),
+		Authorities: []string{producer.Address()},
+		TokenConfig: &blockchain.TokenConfig{
+			Name:          "Podoru",
+			Symbol:        "PDR",
+			Decimals:      18,
+			InitialSupply: "1000",
+			Accounting:    true,
+		},
+		InitialBalances: map[string]string{user: "1000"},
+	}
+	s := newChainServer(t, genesis, "")
+
+	// The recipient gets 100 at height 1 and 200 more at height 2
+	for nonce, value := range []int64{100, 200} {
+		tx := blockchain.NewTransaction(user, time.Now().Unix(), &blockchain.TransactionData{
+			Operations: []*blockchain.KVOperation{blockchain.NewTransferOperation(recipient, big.NewInt(value).Bytes())},
+		}, uint64(nonce))
+		if err := tx.Sign(userKey); err != nil {
+			t.Fatal(err)
+		}
+		addChainBlock(t, s, producer, tx)
+	}
+
+	tests := []struct {
+		query   string
+		code    int
+		balance string
+	}{
+		{"", http.StatusOK, "300"},
+		{"?height=0", http.StatusOK, "0"},
+		{"?height=1", http.StatusOK, "100"},
+		{"?height=2", http.StatusOK, "300"},
+		{"?height=3", http.StatusBadRequest, ""},
+		{"?height=latest", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/balance/"+recipient+tt.query, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%q: status = %d, want %d: %s", tt.query, rec.Code, tt.code, rec.Body)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+
+		var resp struct {
+			Data BalanceResponse `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if resp.Data.Balance != tt.balance {
+			t.Errorf("%q: balance = %s, want %s", tt.query, resp.Data.Balance, tt.balance)
+		}
+		if (resp.Data.Height != nil) != (tt.query != "") {
+			t.Errorf("%q: height = %v, want it set only for a historical query", tt.query, resp.Data.Height)
+		}
+	}
+}
+
+func TestGetReceipt(t *testing.T) {
+	producerKey, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer, err := crypto.NewKeySigner(producerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis := &blockchain.GenesisConfig{
+		Timestamp:   time.Now().Add(-time.Hour).Unix(),
+		Authorities: []string{producer.Address()},
+	}
+	s := newChainServer(t, genesis, "")
+
+	tx := blockchain.NewTransaction(producer.Address(), time.Now().Unix(), &blockchain.TransactionData{
+		Operations: []*blockchain.KVOperation{{Type: blockchain.OpTypeSet, Key: "key", Value: []byte("v")}},
+	}, 0)
+	if err := tx.Sign(producerKey); err != nil {
+		t.Fatal(err)
+	}
+	addChainBlock(t, s, producer, tx)
+	block := s.node.GetChain().GetCurrentBlock()
+
+	tests := []struct {
+		name string
+		hash string
+		code int
+	}{
+		{"mined", tx.HashString(), http.StatusOK},
+		{"without prefix", strings.TrimPrefix(tx.HashString(), "0x"), http.StatusOK},
+		{"unknown", "0x" + strings.Repeat("ab", 32), http.StatusNotFound},
+		{"invalid", "0xzz", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/transaction/"+tt.hash+"/receipt", nil))
+		if rec.Code != tt.code {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, rec.Code, tt.code, rec.Body)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+
+		var resp struct {
+			Data ReceiptResponse `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		want := ReceiptResponse{
+			TransactionHash: tx.HashString(),
+			BlockHeight:     1,
+			BlockHash:       block.HashString(),
+			GasFee:          "0",
+			Success:         true,
+		}
+		if resp.Data != want {
+			t.Errorf("%s: receipt = %+v, want %+v", tt.name, resp.Data, want)
+		}
+	}
+}
+
+func TestGetChainStats(t *testing.T) {
+	producerKey, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer, err := crypto.NewKeySigner(producerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis := &blockchain.GenesisConfig{
+		Timestamp:   time.Now().Add(-time.Hour).Unix(),
+		Authorities: []string{producer.Address()},
+	}
+	s := newChainServer(t, genesis, "")
+
+	// Two mined transactions over two blocks, and one still pending
+	for nonce := range uint64(3) {
+		tx := blockchain.NewTransaction(producer.Address(), time.Now().Unix(), &blockchain.TransactionData{
+			Operations: []*blockchain.KVOperation{{Type: blockchain.OpTypeSet, Key: "key", Value: []byte(fmt.Sprint(nonce))}},
+		}, nonce)
+		if err := tx.Sign(producerKey); err != nil {
+			t.Fatal(err)
+		}
+		if nonce == 2 {
+			if err := s.node.SubmitTransaction(tx); err != nil {
+				t.Fatalf("SubmitTransaction: %v", err)
+			}
+			continue
+		}
+		addChainBlock(t, s, producer, tx)
+	}
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/chain/stats", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var resp struct {
+		Data ChainStatsResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	want := blockchain.ChainStats{
+		Height:                  2,
+		TotalTransactions:       2,
+		AvgTransactionsPerBlock: 1,
+		AvgBlockInterval:        1,
+		TotalFees:               "0",
+	}
+	if resp.Data.ChainStats == nil || *resp.Data.ChainStats != want {
+		t.Errorf("stats = %+v, want %+v", resp.Data.ChainStats, want)
+	}
+	if resp.Data.MempoolSize != 1 {
+		t.Errorf("mempool size = %d, want 1", resp.Data.MempoolSize)
+	}
+}
+
+func TestGetProducerFees(t *testing.T) {
+	producerKey, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer, err := crypto.NewKeySigner(producerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const idle = "0x000000000000000000000000000000000000000a"
+
+	genesis := &blockchain.GenesisConfig{
+		Timestamp:   time.Now().Add(-time.Hour).Unix(),
+		Authorities: []string{producer.Address()},
+		TokenConfig: &blockchain.TokenConfig{
+			Name:                  "Podoru",
+			Symbol:                "PDR",
+			Decimals:              18,
+			InitialSupply:         "1000000",
+			Accounting:            true,
+			BlockReward:           big.NewInt(5),
+			TrackProducerEarnings: true,
+		},
+		GasConfig:       &blockchain.GasConfigJSON{BaseFee: "1000"},
+		InitialBalances: map[string]string{producer.Address(): "1000000"},
+	}
+	s := newChainServer(t, genesis, "")
+
+	// Two blocks, the first with a transaction paying a fee
+	tx := blockchain.NewTransaction(producer.Address(), time.Now().Unix(), &blockchain.TransactionData{
+		Operations: []*blockchain.KVOperation{{Type: blockchain.OpTypeSet, Key: "key", Value: []byte("v")}},
+	}, 0)
+	if err := tx.Sign(producerKey); err != nil {
+		t.Fatal(err)
+	}
+	addChainBlock(t, s, producer, tx)
+	addChainBlock(t, s, producer)
+	fee := s.node.GetChain().GetGasConfig().CalculateGasFee(tx.Size())
+
+	tests := []struct {
+		address string
+		code    int
+		fees    string
+		rewards string
+	}{
+		{producer.Address(), http.StatusOK, fee.String(), "10"},
+		{idle, http.StatusOK, "0", "0"},
+		{"not-an-address", http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/producer/"+tt.address+"/fees", nil))
+		if rec.Code != tt.code {
+			t.Errorf("%s: status = %d, want %d: %s", tt.address, rec.Code, tt.code, rec.Body)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+
+		var resp struct {
+			Data ProducerFeesResponse `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if resp.Data.Fees != tt.fees || resp.Data.BlockRewards != tt.rewards {
+			t.Errorf("%s: fees = %s, rewards = %s; want %s and %s", tt.address, resp.Data.Fees, resp.Data.BlockRewards, tt.fees, tt.rewards)
+		}
+	}
+}
+
+func TestGetNodeInfo(t *testing.T) {
+	key, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer, err := crypto.NewKeySigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "producer.key")
+	if err := crypto.SavePrivateKeyToFile(key, keyPath); err != nil {
+		t.Fatal(err)
+	}
+	genesis := &blockchain.GenesisConfig{
+		Timestamp:   time.Now().Add(-time.Hour).Unix(),
+		Authorities: []string{producer.Address()},
+	}
+
+	nodeInfo := func(t *testing.T, s *Server) NodeInfo {
+		t.Helper()
+
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/node/info", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+		var resp struct {
+			Data NodeInfo `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		return resp.Data
+	}
+
+	t.Run("producer", func(t *testing.T) {
+		s := newNodeServer(t, genesis, fmt.Sprintf("node_type: producer\n"+
+			"address: %q\n"+
+			"private_key: %s\n"+
+			"authorities: [%q]\n"+
+			"block_time: 1s\n",
+			producer.Address(), keyPath, producer.Address()))
+
+		// The production loop starts in the background
+		deadline := time.Now().Add(5 * time.Second)
+		for !s.node.IsProducing() && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		info := nodeInfo(t, s)
+		if info.Type != "producer" || info.Address != producer.Address() || !info.Producing {
+			t.Errorf("node info = %+v, want a producing producer node at %s", info, producer.Address())
+		}
+		if info.Version != node.Version {
+			t.Errorf("version = %q, want %q", info.Version, node.Version)
+		}
+	})
+
+	t.Run("full", func(t *testing.T) {
+		s := newChainServer(t, genesis, "")
+		addChainBlock(t, s, producer)
+
+		info := nodeInfo(t, s)
+		if info.Type != "full" || info.Address != "" || info.Producing {
+			t.Errorf("node info = %+v, want a full node without an address", info)
+		}
+		if info.Height != 1 {
+			t.Errorf("height = %d, want 1", info.Height)
+		}
+	})
+}
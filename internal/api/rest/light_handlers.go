@@ -0,0 +1,125 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/crypto"
+)
+
+// setupLightRoutes registers the routes a light node can serve from its header chain
+// and from state proofs requested from full peers
+func (s *Server) setupLightRoutes() {
+	// Chain endpoints
+	s.router.HandleFunc("/api/v1/chain/info", s.handleGetLightChainInfo).Methods("GET")
+	s.router.HandleFunc("/api/v1/header/height/{height}", s.handleGetHeaderByHeight).Methods("GET")
+	s.router.HandleFunc("/api/v1/header/latest", s.handleGetLatestHeader).Methods("GET")
+
+	// Proven state endpoints
+	s.router.HandleFunc("/api/v1/state/{key}", s.handleGetProvenState).Methods("GET")
+	s.router.HandleFunc("/api/v1/balance/{address}", s.handleGetProvenBalance).Methods("GET")
+
+	// Node endpoints
+	s.router.HandleFunc("/api/v1/node/info", s.handleGetNodeInfo).Methods("GET")
+	s.router.HandleFunc("/api/v1/node/peers", s.handleGetPeers).Methods("GET")
+}
+
+// LightChainInfo describes the tip of a light node's header chain
+type LightChainInfo struct {
+	ChainID   string `json:"chain_id,omitempty"`
+	Height    uint64 `json:"height"`
+	Hash      string `json:"hash"`
+	StateRoot string `json:"state_root"`
+}
+
+// handleGetLightChainInfo returns the tip of the header chain
+func (s *Server) handleGetLightChainInfo(w http.ResponseWriter, r *http.Request) {
+	tip := s.node.GetHeaderChain().Tip()
+	writeSuccess(w, LightChainInfo{
+		ChainID:   s.node.GetChainID(),
+		Height:    tip.Header.Height,
+		Hash:      fmt.Sprintf("0x%x", tip.Hash()),
+		StateRoot: fmt.Sprintf("0x%x", tip.Header.StateRoot),
+	})
+}
+
+// handleGetHeaderByHeight returns a signed header by its height
+func (s *Server) handleGetHeaderByHeight(w http.ResponseWriter, r *http.Request) {
+	height, err := strconv.ParseUint(mux.Vars(r)["height"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid height format")
+		return
+	}
+
+	header, err := s.node.GetHeaderChain().GetHeader(height)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "header not found")
+		return
+	}
+
+	writeSuccess(w, header)
+}
+
+// handleGetLatestHeader returns the signed header at the tip
+func (s *Server) handleGetLatestHeader(w http.ResponseWriter, r *http.Request) {
+	writeSuccess(w, s.node.GetHeaderChain().Tip())
+}
+
+// handleGetProvenState returns a state value proven against the header chain
+func (s *Server) handleGetProvenState(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+
+	value, height, err := s.node.ProveState(key)
+	if err != nil {
+		if errors.Is(err, blockchain.ErrKeyNotFound) {
+			writeError(w, http.StatusNotFound, "key not found")
+			return
+		}
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	writeSuccess(w, map[string]interface{}{
+		"key":    key,
+		"value":  value,
+		"height": height,
+	})
+}
+
+// handleGetProvenBalance returns a balance proven against the header chain. Proofs
+// only cover keys that exist, so an account without a balance key reads as zero
+// without a proof.
+func (s *Server) handleGetProvenBalance(w http.ResponseWriter, r *http.Request) {
+	address := mux.Vars(r)["address"]
+
+	// Validate address format
+	if !crypto.IsValidAddress(address) {
+		writeError(w, http.StatusBadRequest, "invalid address format")
+		return
+	}
+
+	balance := blockchain.NewBalance(nil)
+	value, height, err := s.node.ProveState(blockchain.BalanceKey(address))
+	switch {
+	case err == nil:
+		balance, err = blockchain.BalanceFromBytes(value)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+	case !errors.Is(err, blockchain.ErrKeyNotFound):
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	writeSuccess(w, BalanceResponse{
+		Address:          address,
+		Balance:          balance.String(),
+		BalanceFormatted: blockchain.FormatBalanceFull(balance.Amount),
+		Height:           &height,
+	})
+}
@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiterIdleTTL is how long an idle client's bucket is kept
+const rateLimiterIdleTTL = 5 * time.Minute
+
+// tokenBucket tracks the tokens available to one client
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// rateLimiter is a per-client token bucket rate limiter
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64 // Tokens added per second
+	burst     float64 // Bucket capacity
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+// newRateLimiter creates a rate limiter allowing rate requests per second with the given burst
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = int(math.Ceil(rate))
+	}
+
+	return &rateLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		buckets:   make(map[string]*tokenBucket),
+		lastPrune: time.Now(),
+	}
+}
+
+// allow takes a token for client, returning how long to wait if none is available
+func (rl *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.prune(now)
+
+	bucket, exists := rl.buckets[client]
+	if !exists {
+		bucket = &tokenBucket{tokens: rl.burst, lastSeen: now}
+		rl.buckets[client] = bucket
+	} else {
+		elapsed := now.Sub(bucket.lastSeen).Seconds()
+		bucket.tokens = math.Min(rl.burst, bucket.tokens+elapsed*rl.rate)
+		bucket.lastSeen = now
+	}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / rl.rate * float64(time.Second))
+	return false, wait
+}
+
+// prune drops buckets of clients that have been idle for a while (caller must hold rl.mu)
+func (rl *rateLimiter) prune(now time.Time) {
+	if now.Sub(rl.lastPrune) < rateLimiterIdleTTL {
+		return
+	}
+
+	for client, bucket := range rl.buckets {
+		if now.Sub(bucket.lastSeen) > rateLimiterIdleTTL {
+			delete(rl.buckets, client)
+		}
+	}
+	rl.lastPrune = now
+}
+
+// clientIP returns the client address used as the rate limit key
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			// Only the last entry was added by the proxy; the ones before it come from the client
+			// and can be anything, so keying on them would let a client pick a fresh bucket
+			last := forwarded[strings.LastIndex(forwarded, ",")+1:]
+			if ip := strings.TrimSpace(last); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimitMiddleware rejects clients that exceed the configured request rate with 429
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	config := s.node.GetConfig()
+	if config.RateLimitPerSecond <= 0 {
+		return next
+	}
+
+	limiter := newRateLimiter(config.RateLimitPerSecond, config.RateLimitBurst)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, wait := limiter.allow(clientIP(r, config.TrustProxy), time.Now())
+		if !allowed {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,104 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/node"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	const burst = 3
+	s := newTestServer(t, &node.Config{RateLimitPerSecond: 0.5, RateLimitBurst: burst})
+	handler := s.rateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/chain/info", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < burst; i++ {
+		if rec := send("192.0.2.1:1000"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want %d", i+1, rec.Code, http.StatusOK)
+		}
+	}
+
+	rec := send("192.0.2.1:1001")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("request %d: status = %d, want %d", burst+1, rec.Code, http.StatusTooManyRequests)
+	}
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 {
+		t.Errorf("Retry-After = %q, want a positive number of seconds", rec.Header().Get("Retry-After"))
+	}
+
+	// Other clients have buckets of their own
+	if rec := send("192.0.2.2:1000"); rec.Code != http.StatusOK {
+		t.Errorf("other client: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	s := newTestServer(t, &node.Config{RateLimitPerSecond: 0.5, RateLimitBurst: 1, TrustProxy: true})
+	handler := s.rateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// The proxy appends the client address it saw; everything before it comes from the client
+	send := func(spoofed string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/chain/info", nil)
+		req.RemoteAddr = "10.0.0.1:8080"
+		forwarded := "198.51.100.7"
+		if spoofed != "" {
+			forwarded = spoofed + ", " + forwarded
+		}
+		req.Header.Set("X-Forwarded-For", forwarded)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := send(""); code != http.StatusOK {
+		t.Fatalf("first request: status = %d, want %d", code, http.StatusOK)
+	}
+	for _, spoofed := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3, 203.0.113.4"} {
+		if code := send(spoofed); code != http.StatusTooManyRequests {
+			t.Errorf("X-Forwarded-For %q: status = %d, want %d", spoofed, code, http.StatusTooManyRequests)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		forwarded  string
+		trustProxy bool
+		want       string
+	}{
+		{name: "remote address", want: "192.0.2.1"},
+		{name: "header ignored without trust", forwarded: "203.0.113.1", want: "192.0.2.1"},
+		{name: "single entry", forwarded: "203.0.113.1", trustProxy: true, want: "203.0.113.1"},
+		{name: "last entry", forwarded: "203.0.113.9, 203.0.113.1", trustProxy: true, want: "203.0.113.1"},
+		{name: "empty last entry", forwarded: "203.0.113.9, ", trustProxy: true, want: "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := clientIP(req, tt.trustProxy); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package rest
 
 import (
+	"compress/gzip"
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -19,6 +22,8 @@ type Server struct {
 	httpServer *http.Server
 	wsServer   *websocket.Server
 	logger     *logrus.Logger
+	snapshots  *balanceSnapshotCache
+	streamed   map[string]bool // Path templates of the routes that stream their response
 }
 
 // NewServer creates a new REST API server
@@ -28,10 +33,12 @@ func NewServer(n *node.Node, bindAddr string, port int, logger *logrus.Logger) *
 	}
 
 	server := &Server{
-		node:     n,
-		router:   mux.NewRouter(),
-		wsServer: websocket.NewServer(logger),
-		logger:   logger,
+		node:      n,
+		router:    mux.NewRouter(),
+		wsServer:  websocket.NewServer(logger),
+		logger:    logger,
+		snapshots: &balanceSnapshotCache{},
+		streamed:  make(map[string]bool),
 	}
 
 	// Setup routes
@@ -47,7 +54,11 @@ func NewServer(n *node.Node, bindAddr string, port int, logger *logrus.Logger) *
 		IdleTimeout:  60 * time.Second,
 	}
 
+	server.wsServer.SetAllowedOrigins(n.GetConfig().WSAllowedOrigins)
+	server.wsServer.SetClientLimits(int64(n.GetConfig().WSMaxMessageSize), n.GetConfig().WSSendBuffer)
+
 	// Connect WebSocket hub to node for event broadcasting
+	server.wsServer.GetHub().SetBlockSource(n.GetChain())
 	n.SetWebSocketHub(server.wsServer.GetHub())
 
 	return server
@@ -55,59 +66,123 @@ func NewServer(n *node.Node, bindAddr string, port int, logger *logrus.Logger) *
 
 // setupRoutes sets up all API routes
 func (s *Server) setupRoutes() {
+	config := s.node.GetConfig()
+
+	if config.APIRESTEnabled {
+		if config.IsLight() {
+			s.setupLightRoutes()
+		} else {
+			s.setupRESTRoutes()
+		}
+	}
+
+	// Health check is always available
+	s.router.HandleFunc("/api/v1/node/health", s.handleHealthCheck).Methods("GET")
+
+	// WebSocket endpoint
+	if config.APIWebSocketEnabled {
+		s.router.HandleFunc("/api/v1/ws", s.wsServer.HandleWebSocket)
+	}
+
+	// Prometheus metrics
+	if config.MetricsEnabled {
+		s.router.Handle("/metrics", s.node.MetricsHandler()).Methods("GET")
+	}
+
+	// Handle CORS preflight requests on any path. Matching only preflights keeps a
+	// request for an unregistered path a 404 instead of a 405.
+	s.router.Methods("OPTIONS").Headers("Access-Control-Request-Method", "").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// Add middlewares (order matters: CORS -> rate limit -> gzip -> signing -> logging)
+	s.router.Use(s.corsMiddleware)
+	s.router.Use(s.rateLimitMiddleware)
+	s.router.Use(s.gzipMiddleware)
+	if signer := s.node.ResponseSigner(); signer != nil {
+		s.router.Use(s.signMiddleware(signer))
+	}
+	s.router.Use(s.loggingMiddleware)
+}
+
+// setupRESTRoutes registers the REST query and submission routes
+func (s *Server) setupRESTRoutes() {
+	txBodyLimit := s.node.GetConfig().APIMaxTxBodyBytes
+	queryBodyLimit := s.node.GetConfig().APIMaxQueryBodyBytes
+
 	// Chain endpoints
 	s.router.HandleFunc("/api/v1/chain/info", s.handleGetChainInfo).Methods("GET")
+	s.router.HandleFunc("/api/v1/chain/limits", s.handleGetChainLimits).Methods("GET")
+	s.router.HandleFunc("/api/v1/chain/stats", s.handleGetChainStats).Methods("GET")
+	s.router.HandleFunc("/api/v1/block/latest", s.handleGetLatestBlock).Methods("GET") // Before {hash}, which would match it
 	s.router.HandleFunc("/api/v1/block/{hash}", s.handleGetBlockByHash).Methods("GET")
 	s.router.HandleFunc("/api/v1/block/height/{height}", s.handleGetBlockByHeight).Methods("GET")
-	s.router.HandleFunc("/api/v1/block/latest", s.handleGetLatestBlock).Methods("GET")
 
 	// Transaction endpoints
 	s.router.HandleFunc("/api/v1/transaction/{hash}", s.handleGetTransaction).Methods("GET")
-	s.router.HandleFunc("/api/v1/transaction", s.handleSubmitTransaction).Methods("POST")
+	s.router.HandleFunc("/api/v1/transaction/{hash}/receipt", s.handleGetReceipt).Methods("GET")
+	s.router.HandleFunc("/api/v1/transaction", s.requireAuth(limitBody(txBodyLimit, s.handleSubmitTransaction))).Methods("POST")
 
 	// State endpoints
 	s.router.HandleFunc("/api/v1/state/{key}", s.handleGetState).Methods("GET")
-	s.router.HandleFunc("/api/v1/state/batch", s.handleBatchGetState).Methods("POST")
-	s.router.HandleFunc("/api/v1/state/query/prefix", s.handleQueryByPrefix).Methods("POST")
+	s.handleStreamed("/api/v1/state/{key}/transactions", s.handleGetKeyTransactions).Methods("GET")
+	s.handleStreamed("/api/v1/state/batch", s.requireAuth(limitBody(queryBodyLimit, s.handleBatchGetState))).Methods("POST")
+	s.handleStreamed("/api/v1/state/query/prefix", limitBody(queryBodyLimit, s.handleQueryByPrefix)).Methods("POST")
 
 	// Node endpoints
 	s.router.HandleFunc("/api/v1/node/info", s.handleGetNodeInfo).Methods("GET")
 	s.router.HandleFunc("/api/v1/node/peers", s.handleGetPeers).Methods("GET")
-	s.router.HandleFunc("/api/v1/node/health", s.handleHealthCheck).Methods("GET")
 
 	// Mempool endpoints
-	s.router.HandleFunc("/api/v1/mempool", s.handleGetMempool).Methods("GET")
+	s.handleStreamed("/api/v1/mempool", s.handleGetMempool).Methods("GET")
 
 	// Balance and Token endpoints
 	s.router.HandleFunc("/api/v1/balance/{address}", s.handleGetBalance).Methods("GET")
+	s.router.HandleFunc("/api/v1/account/{address}/nonce", s.handleGetAccountNonce).Methods("GET")
+	s.handleStreamed("/api/v1/account/{address}/transactions", s.handleGetAccountTransactions).Methods("GET")
+	s.handleStreamed("/api/v1/account/{address}/transfers", s.handleGetAccountTransfers).Methods("GET")
 	s.router.HandleFunc("/api/v1/token/info", s.handleGetTokenInfo).Methods("GET")
+	s.router.HandleFunc("/api/v1/producer/{address}/fees", s.handleGetProducerFees).Methods("GET")
+	s.handleStreamed("/api/v1/snapshot/balances", s.handleGetBalanceSnapshot).Methods("GET")
+
+	// Consensus endpoints
+	s.router.HandleFunc("/api/v1/consensus/authorities/proof", s.handleGetAuthorityProof).Methods("GET")
 
 	// Gas endpoints
 	s.router.HandleFunc("/api/v1/gas/config", s.handleGetGasConfig).Methods("GET")
-	s.router.HandleFunc("/api/v1/gas/estimate", s.handleEstimateGas).Methods("POST")
-
-	// WebSocket endpoint
-	s.router.HandleFunc("/api/v1/ws", s.wsServer.HandleWebSocket)
-
-	// Handle all OPTIONS requests for CORS preflight
-	s.router.Methods("OPTIONS").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	})
+	s.router.HandleFunc("/api/v1/gas/suggest", s.handleSuggestGas).Methods("GET")
+	s.router.HandleFunc("/api/v1/gas/estimate", s.requireAuth(limitBody(queryBodyLimit, s.handleEstimateGas))).Methods("POST")
+}
 
-	// Add middlewares (order matters: CORS -> logging)
-	s.router.Use(s.corsMiddleware)
-	s.router.Use(s.loggingMiddleware)
+// handleStreamed registers a route whose handler streams its response, which
+// response signing leaves out
+func (s *Server) handleStreamed(path string, handler http.HandlerFunc) *mux.Route {
+	s.streamed[path] = true
+	return s.router.HandleFunc(path, handler)
 }
 
-// Start starts the API server
+// Start starts the API server, serving HTTPS (and wss://) when a TLS cert and key are configured
 func (s *Server) Start() error {
-	s.logger.Infof("Starting REST API server on %s", s.httpServer.Addr)
+	config := s.node.GetConfig()
+	useTLS := config.TLSEnabled()
+
+	if useTLS {
+		s.logger.Infof("Starting REST API server on %s (TLS)", s.httpServer.Addr)
+	} else {
+		s.logger.Infof("Starting REST API server on %s", s.httpServer.Addr)
+	}
 
 	// Start WebSocket server
 	s.wsServer.Start()
 
 	go func() {
-		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if useTLS {
+			err = s.httpServer.ListenAndServeTLS(config.TLSCertFile, config.TLSKeyFile)
+		} else {
+			err = s.httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			s.logger.Errorf("REST API server error: %v", err)
 		}
 	}()
@@ -133,14 +208,24 @@ func (s *Server) Stop() error {
 	return nil
 }
 
-// corsMiddleware adds CORS headers to allow browser access
+// corsMiddleware adds CORS headers to allow browser access. Origins in
+// cors_allowed_origins are echoed back and may send credentials; any other origin
+// gets a wildcard, which browsers only honor for requests without credentials.
 func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Allow all origins in development (restrict in production)
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		allowed := s.node.GetConfig().CORSAllowedOrigins
+		if origin := r.Header.Get("Origin"); origin != "" && isAllowedOrigin(origin, allowed) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+		if len(allowed) > 0 {
+			// The response depends on the origin, so caches must not share it across origins
+			w.Header().Add("Vary", "Origin")
+		}
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Upgrade, Connection, Sec-WebSocket-Key, Sec-WebSocket-Version, Sec-WebSocket-Protocol")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		// Handle preflight requests
 		if r.Method == "OPTIONS" {
@@ -152,6 +237,54 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// isAllowedOrigin reports whether origin is in the allowlist, ignoring case and a trailing slash
+func isAllowedOrigin(origin string, allowed []string) bool {
+	for _, a := range allowed {
+		if strings.EqualFold(strings.TrimSuffix(a, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// requireAuth requires "Authorization: Bearer <api_auth_token>" when a token is configured
+func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := s.node.GetConfig().APIAuthToken
+		if token == "" {
+			next(w, r)
+			return
+		}
+
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// limitBody caps the request body at maxBytes (0 = unlimited). A request whose
+// Content-Length is already over the cap is rejected with 413 without reading it.
+func limitBody(maxBytes int, next http.HandlerFunc) http.HandlerFunc {
+	if maxBytes <= 0 {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > int64(maxBytes) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", maxBytes))
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+		next(w, r)
+	}
+}
+
 // loggingMiddleware logs HTTP requests
 func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -160,3 +293,45 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 		s.logger.Infof("%s %s %s", r.Method, r.RequestURI, time.Since(start))
 	})
 }
+
+// gzipResponseWriter compresses the response body written through it
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz *gzip.Writer
+}
+
+// WriteHeader drops any precomputed Content-Length, which no longer matches the compressed body
+func (w *gzipResponseWriter) WriteHeader(statusCode int) {
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Write compresses b into the response
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	return w.gz.Write(b)
+}
+
+// gzipMiddleware compresses responses for clients that accept gzip
+func (s *Server) gzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// WebSocket upgrades need the raw connection
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+			!strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+
+		next.ServeHTTP(&gzipResponseWriter{ResponseWriter: w, gz: gz}, r)
+	})
+}
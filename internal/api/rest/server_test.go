@@ -0,0 +1,507 @@
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	gorillaws "github.com/gorilla/websocket"
+	"github.com/podoru/podoru-chain/internal/api/websocket"
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/crypto"
+	"github.com/podoru/podoru-chain/internal/node"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestServer returns a server for testing middlewares and handlers that only read the config
+func newTestServer(t *testing.T, config *node.Config) *Server {
+	t.Helper()
+
+	if config.NodeType == "" {
+		config.NodeType = node.NodeTypeFull
+	}
+	n, err := node.NewNode(config)
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return &Server{node: n, router: mux.NewRouter(), logger: logger, streamed: make(map[string]bool)}
+}
+
+// echoKeys decodes a JSON object body, as the POST handlers do
+func echoKeys(w http.ResponseWriter, r *http.Request) {
+	var req map[string]string
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	writeSuccess(w, req)
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	s := newTestServer(t, &node.Config{CORSAllowedOrigins: []string{"https://wallet.example.com/"}})
+	handler := s.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name            string
+		origin          string
+		wantOrigin      string
+		wantCredentials bool
+	}{
+		{name: "allowed origin", origin: "https://wallet.example.com", wantOrigin: "https://wallet.example.com", wantCredentials: true},
+		{name: "allowed origin in another case", origin: "https://Wallet.Example.com", wantOrigin: "https://Wallet.Example.com", wantCredentials: true},
+		{name: "disallowed origin", origin: "https://evil.example.com", wantOrigin: "*"},
+		{name: "no origin", wantOrigin: "*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/chain/info", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials") == "true"; got != tt.wantCredentials {
+				t.Errorf("credentials allowed = %v, want %v", got, tt.wantCredentials)
+			}
+			if rec.Header().Get("Vary") != "Origin" {
+				t.Errorf("Vary = %q, want Origin with an allowlist", rec.Header().Get("Vary"))
+			}
+		})
+	}
+}
+
+func TestCORSMiddlewareWithoutAllowlist(t *testing.T) {
+	s := newTestServer(t, &node.Config{})
+	handler := s.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/chain/info", nil)
+	req.Header.Set("Origin", "https://wallet.example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q, want none", got)
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	tests := []struct {
+		name   string
+		token  string // Configured token
+		header string // Authorization header sent
+		want   int
+	}{
+		{name: "missing token", token: "secret", want: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", header: "Bearer wrong", want: http.StatusUnauthorized},
+		{name: "wrong scheme", token: "secret", header: "Basic secret", want: http.StatusUnauthorized},
+		{name: "correct token", token: "secret", header: "Bearer secret", want: http.StatusOK},
+		{name: "no token configured", want: http.StatusOK},
+		{name: "no token configured ignores header", header: "Bearer anything", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, &node.Config{APIAuthToken: tt.token})
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			s.requireAuth(ok)(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("WWW-Authenticate = %q, want Bearer", rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	const limit = 64
+	large := `{"key":"` + strings.Repeat("x", 2*limit) + `"}`
+
+	tests := []struct {
+		name    string
+		body    string
+		chunked bool // Send without a Content-Length, so only the reader catches it
+		want    int
+	}{
+		{name: "within limit", body: `{"key":"value"}`, want: http.StatusOK},
+		{name: "content length over limit", body: large, want: http.StatusRequestEntityTooLarge},
+		{name: "streamed body over limit", body: large, chunked: true, want: http.StatusRequestEntityTooLarge},
+		{name: "invalid json", body: `{`, want: http.StatusBadRequest},
+	}
+
+	handler := limitBody(limit, echoKeys)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.chunked {
+				body = io.MultiReader(body) // Hides the length from httptest
+			}
+			req := httptest.NewRequest(http.MethodPost, "/", body)
+			rec := httptest.NewRecorder()
+
+			handler(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestLimitBodyUnlimited(t *testing.T) {
+	large := `{"key":"` + strings.Repeat("x", 1<<20) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(large))
+	rec := httptest.NewRecorder()
+
+	limitBody(0, echoKeys)(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d with no limit, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestRESTDisabledKeepsWebSocket(t *testing.T) {
+	genesis := &blockchain.GenesisConfig{
+		Timestamp:    time.Now().Add(-time.Hour).Unix(),
+		Authorities:  []string{"0x0000000000000000000000000000000000000001"},
+		InitialState: map[string]string{"greeting": "hello"},
+	}
+	s := newChainServer(t, genesis, "api_rest_enabled: false\n")
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+
+	for path, want := range map[string]int{
+		"/api/v1/state/greeting": http.StatusNotFound,
+		"/api/v1/chain/info":     http.StatusNotFound,
+		"/api/v1/node/health":    http.StatusOK,
+	} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET %s = %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+
+	// CORS preflights are still answered on any path
+	preflight, err := http.NewRequest(http.MethodOptions, server.URL+"/api/v1/state/greeting", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	preflight.Header.Set("Origin", "https://example.com")
+	preflight.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	resp, err := http.DefaultClient.Do(preflight)
+	if err != nil {
+		t.Fatalf("preflight: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("preflight = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	conn, resp, err := gorillaws.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/v1/ws", nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial with REST disabled: %v", err)
+	}
+	defer conn.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("WebSocket upgrade status = %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+}
+
+func TestGzipMiddleware(t *testing.T) {
+	genesis := &blockchain.GenesisConfig{
+		Timestamp:    time.Now().Add(-time.Hour).Unix(),
+		Authorities:  []string{"0x0000000000000000000000000000000000000001"},
+		InitialState: map[string]string{"greeting": "hello"},
+	}
+	s := newChainServer(t, genesis, "")
+
+	get := func(acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/block/latest", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+		return rec
+	}
+
+	plain := get("")
+	if plain.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Content-Encoding = %q without Accept-Encoding", plain.Header().Get("Content-Encoding"))
+	}
+
+	compressed := get("gzip, deflate")
+	if compressed.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", compressed.Header().Get("Content-Encoding"))
+	}
+	reader, err := gzip.NewReader(compressed.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("decompressing: %v", err)
+	}
+	if !bytes.Equal(body, plain.Body.Bytes()) {
+		t.Errorf("decompressed body differs from the plain one:\n%s\n%s", body, plain.Body)
+	}
+
+	// WebSocket upgrades are left uncompressed
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+	conn, _, err := gorillaws.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/v1/ws",
+		http.Header{"Accept-Encoding": {"gzip"}})
+	if err != nil {
+		t.Fatalf("WebSocket dial accepting gzip: %v", err)
+	}
+	conn.Close()
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	genesis := &blockchain.GenesisConfig{
+		Timestamp:   time.Now().Add(-time.Hour).Unix(),
+		Authorities: []string{"0x0000000000000000000000000000000000000001"},
+	}
+
+	for _, enabled := range []bool{true, false} {
+		s := newChainServer(t, genesis, fmt.Sprintf("metrics_enabled: %t\n", enabled))
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		if !enabled {
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("metrics disabled: status = %d, want %d", rec.Code, http.StatusNotFound)
+			}
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			t.Fatalf("metrics enabled: status = %d, want %d", rec.Code, http.StatusOK)
+		}
+		if !strings.Contains(rec.Body.String(), "podoru_chain_height 0\n") {
+			t.Errorf("metrics missing the chain height:\n%s", rec.Body)
+		}
+	}
+}
+
+// selfSignedCert writes a self-signed certificate for 127.0.0.1 and its key to dir,
+// returning their paths and a pool that trusts the certificate
+func selfSignedCert(t *testing.T, dir string) (certPath, keyPath string, pool *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath = filepath.Join(dir, "cert.pem")
+	keyPath = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certPath, keyPath, pool
+}
+
+func TestServerTLS(t *testing.T) {
+	certPath, keyPath, pool := selfSignedCert(t, t.TempDir())
+	genesis := &blockchain.GenesisConfig{
+		Timestamp:   time.Now().Add(-time.Hour).Unix(),
+		Authorities: []string{"0x0000000000000000000000000000000000000001"},
+	}
+	s := newChainServer(t, genesis, fmt.Sprintf("tls_cert_file: %s\ntls_key_file: %s\n", certPath, keyPath))
+	addr := s.httpServer.Addr
+	tlsConfig := &tls.Config{RootCAs: pool}
+
+	// The server starts listening in the background, so retry until it accepts
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}, Timeout: time.Second}
+	var resp *http.Response
+	var err error
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if resp, err = client.Get("https://" + addr + "/api/v1/node/health"); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("HTTPS request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("health status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	// Plain HTTP is not served on the TLS port
+	if resp, err := (&http.Client{Timeout: time.Second}).Get("http://" + addr + "/api/v1/node/health"); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Error("plain HTTP request succeeded against the TLS server")
+		}
+	}
+
+	dialer := gorillaws.Dialer{TLSClientConfig: tlsConfig, HandshakeTimeout: time.Second}
+	conn, _, err := dialer.Dial("wss://"+addr+"/api/v1/ws", nil)
+	if err != nil {
+		t.Fatalf("wss dial: %v", err)
+	}
+	conn.Close()
+}
+
+// subscribeEvents connects a WebSocket client to s and subscribes it to events. The
+// subscription includes new_block replayed from the tip, and the replayed block is
+// read before returning, so the subscription is in place once it returns.
+func subscribeEvents(t *testing.T, s *Server, events ...websocket.EventType) *gorillaws.Conn {
+	t.Helper()
+
+	server := httptest.NewServer(s.router)
+	t.Cleanup(server.Close)
+	conn, _, err := gorillaws.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/v1/ws", nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	tip := s.node.GetChain().GetHeight()
+	if err := conn.WriteJSON(&websocket.SubscribeMessage{
+		Action:     "subscribe",
+		Events:     append(events, websocket.EventNewBlock),
+		FromHeight: &tip,
+	}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if event := readEvent(t, conn, websocket.EventNewBlock); event == nil {
+		t.Fatal("replayed block never arrived")
+	}
+	return conn
+}
+
+// readEvent returns the data of the next event of type want, skipping others, or
+// nil if none arrives in time
+func readEvent(t *testing.T, conn *gorillaws.Conn, want websocket.EventType) json.RawMessage {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var event struct {
+			Type websocket.EventType `json:"type"`
+			Data json.RawMessage     `json:"data"`
+		}
+		if err := conn.ReadJSON(&event); err != nil {
+			return nil
+		}
+		if event.Type == want {
+			return event.Data
+		}
+	}
+}
+
+func TestMempoolUpdateEvent(t *testing.T) {
+	key, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := crypto.AddressFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis := &blockchain.GenesisConfig{
+		Timestamp:   time.Now().Add(-time.Hour).Unix(),
+		Authorities: []string{"0x0000000000000000000000000000000000000001"},
+	}
+	s := newChainServer(t, genesis, "")
+	conn := subscribeEvents(t, s, websocket.EventMempoolUpdate)
+
+	tx := blockchain.NewTransaction(address, time.Now().Unix(), &blockchain.TransactionData{
+		Operations: []*blockchain.KVOperation{{Type: blockchain.OpTypeSet, Key: "key", Value: []byte("v")}},
+	}, 0)
+	if err := tx.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.node.SubmitTransaction(tx); err != nil {
+		t.Fatalf("SubmitTransaction: %v", err)
+	}
+
+	data := readEvent(t, conn, websocket.EventMempoolUpdate)
+	if data == nil {
+		t.Fatal("no mempool_update event after submitting a transaction")
+	}
+	var update websocket.MempoolUpdateEvent
+	if err := json.Unmarshal(data, &update); err != nil {
+		t.Fatalf("decoding event: %v", err)
+	}
+	if update.Count != 1 {
+		t.Errorf("count = %d, want 1", update.Count)
+	}
+	if len(update.RecentHashes) != 1 || update.RecentHashes[0] != tx.HashString() {
+		t.Errorf("recent hashes = %v, want [%s]", update.RecentHashes, tx.HashString())
+	}
+}
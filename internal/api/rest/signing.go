@@ -0,0 +1,103 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/podoru/podoru-chain/internal/crypto"
+)
+
+// Response signature headers set when sign_responses is enabled
+const (
+	// SignatureHeader carries the 0x-prefixed 65-byte signature of the ResponseHash of the response
+	SignatureHeader = "X-Podoru-Signature"
+
+	// SignerHeader carries the address of the key that signed the response
+	SignerHeader = "X-Podoru-Signer"
+)
+
+// ResponseHash returns the hash a response's signature is made over: SHA-256 of the
+// request method, a space, the request path with its query, a newline and the body.
+// Binding the request stops a signed answer being passed off as the answer to another
+// request, such as another address's balance.
+func ResponseHash(method, requestURI string, body []byte) []byte {
+	hasher := sha256.New()
+	hasher.Write([]byte(method + " " + requestURI + "\n"))
+	hasher.Write(body)
+	return hasher.Sum(nil)
+}
+
+// signingResponseWriter holds back the response so its body can be signed before
+// the headers are sent
+type signingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code until the response is signed
+func (w *signingResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *signingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Write buffers b into the response body
+func (w *signingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+// signMiddleware signs each response with the node's key, so a client can check
+// the answer came from a known authority. It sits inside the gzip middleware, so the
+// signature covers the uncompressed body. Streamed routes are left unsigned, as
+// signing would mean buffering the whole response.
+func (s *Server) signMiddleware(signer crypto.Signer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// WebSocket upgrades need the raw connection
+			if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || s.isStreamedRoute(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			sw := &signingResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+
+			signature, err := signer.Sign(ResponseHash(r.Method, r.URL.RequestURI(), sw.body.Bytes()))
+			if err != nil {
+				s.logger.Errorf("Failed to sign response: %v", err)
+				writeError(w, http.StatusInternalServerError, "failed to sign response")
+				return
+			}
+
+			w.Header().Set(SignatureHeader, "0x"+hex.EncodeToString(signature))
+			w.Header().Set(SignerHeader, signer.Address())
+			if sw.status != 0 {
+				w.WriteHeader(sw.status)
+			}
+			w.Write(sw.body.Bytes())
+		})
+	}
+}
+
+// isStreamedRoute reports whether r matched a route registered with handleStreamed
+func (s *Server) isStreamedRoute(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false
+	}
+	template, err := route.GetPathTemplate()
+	return err == nil && s.streamed[template]
+}
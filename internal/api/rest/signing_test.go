@@ -0,0 +1,71 @@
+package rest
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/crypto"
+	"github.com/podoru/podoru-chain/internal/node"
+)
+
+func TestSignedBalanceResponse(t *testing.T) {
+	key, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := crypto.NewKeySigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(t, &node.Config{})
+	s.router.HandleFunc("/api/v1/balance/{address}", func(w http.ResponseWriter, r *http.Request) {
+		writeSuccess(w, BalanceResponse{Address: "0x1", Balance: "1000", BalanceFormatted: "0.000000000000001"})
+	}).Methods("GET")
+	s.handleStreamed("/api/v1/mempool", func(w http.ResponseWriter, r *http.Request) {
+		writeStreamedList(w, 0, "transactions", 0, nil, nil)
+	}).Methods("GET")
+	s.router.Use(s.signMiddleware(signer))
+
+	const uri = "/api/v1/balance/0x1?height=5"
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, uri, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get(SignerHeader); got != signer.Address() {
+		t.Errorf("%s = %q, want %q", SignerHeader, got, signer.Address())
+	}
+
+	signature, err := hex.DecodeString(strings.TrimPrefix(rec.Header().Get(SignatureHeader), "0x"))
+	if err != nil {
+		t.Fatalf("decoding %s: %v", SignatureHeader, err)
+	}
+	body := rec.Body.Bytes()
+	recovered, err := crypto.RecoverAddress(ResponseHash(http.MethodGet, uri, body), signature)
+	if err != nil {
+		t.Fatalf("RecoverAddress: %v", err)
+	}
+	if crypto.NormalizeAddress(recovered) != crypto.NormalizeAddress(signer.Address()) {
+		t.Errorf("recovered signer %s, want %s", recovered, signer.Address())
+	}
+
+	// The same body presented as the answer to another request doesn't verify
+	other, err := crypto.RecoverAddress(ResponseHash(http.MethodGet, "/api/v1/balance/0x2", body), signature)
+	if err == nil && crypto.NormalizeAddress(other) == crypto.NormalizeAddress(signer.Address()) {
+		t.Error("signature verifies for a different request path")
+	}
+
+	// Streamed responses are passed through unsigned
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/mempool", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("streamed route: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec.Header().Get(SignatureHeader) != "" {
+		t.Error("streamed route response was signed")
+	}
+}
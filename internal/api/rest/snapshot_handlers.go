@@ -0,0 +1,121 @@
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+const (
+	// defaultSnapshotLimit is the page size of balance snapshots when none is given
+	defaultSnapshotLimit = 100
+
+	// maxSnapshotLimit is the largest page size of balance snapshots
+	maxSnapshotLimit = 1000
+)
+
+// BalanceSnapshotEntry is one account in a balance snapshot
+type BalanceSnapshotEntry struct {
+	Address string `json:"address"`
+	Balance string `json:"balance"` // In wei
+}
+
+// balanceSnapshotCache keeps the last balance snapshot so paging through it doesn't
+// replay the chain for every page. Snapshots are built one at a time.
+type balanceSnapshotCache struct {
+	mu        sync.Mutex
+	blockHash []byte
+	balances  []blockchain.AccountBalance
+}
+
+// get returns the balances as of block, reusing the cached snapshot if it is for the same block
+func (c *balanceSnapshotCache) get(chain *blockchain.Chain, block *blockchain.Block) ([]blockchain.AccountBalance, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hash := block.Hash()
+	if c.balances != nil && bytes.Equal(c.blockHash, hash) {
+		return c.balances, nil
+	}
+
+	balances, err := chain.BalancesAtHeight(block.Header.Height)
+	if err != nil {
+		return nil, err
+	}
+	if balances == nil {
+		balances = []blockchain.AccountBalance{}
+	}
+
+	c.blockHash = hash
+	c.balances = balances
+	return balances, nil
+}
+
+// handleGetBalanceSnapshot returns all account balances as of a height, sorted by
+// address and paginated with "limit" and "after" (the last address of the previous page)
+func (s *Server) handleGetBalanceSnapshot(w http.ResponseWriter, r *http.Request) {
+	chain := s.node.GetChain()
+	query := r.URL.Query()
+
+	height := chain.GetHeight()
+	if value := query.Get("height"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid height")
+			return
+		}
+		if parsed > height {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("height %d is above the current height %d", parsed, height))
+			return
+		}
+		height = parsed
+	}
+
+	block, err := chain.GetBlockByHeight(height)
+	if err != nil {
+		if errors.Is(err, blockchain.ErrBlockPruned) {
+			writeError(w, http.StatusGone, "block has been pruned")
+			return
+		}
+		writeError(w, http.StatusNotFound, "block not found")
+		return
+	}
+
+	balances, err := s.snapshots.get(chain, block)
+	if err != nil {
+		if errors.Is(err, blockchain.ErrHeightAboveTip) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	after := strings.ToLower(query.Get("after"))
+	start := sort.Search(len(balances), func(i int) bool { return balances[i].Address > after })
+	end := min(start+parseLimit(r, defaultSnapshotLimit, maxSnapshotLimit), len(balances))
+	page := balances[start:end]
+
+	next := ""
+	if end < len(balances) {
+		next = page[len(page)-1].Address
+	}
+
+	writeStreamedList(w, s.node.GetConfig().APIMaxResponseBytes, "balances", len(page),
+		func(i int) interface{} {
+			return BalanceSnapshotEntry{Address: page[i].Address, Balance: page[i].Balance.String()}
+		},
+		map[string]interface{}{
+			"height":         height,
+			"block_hash":     fmt.Sprintf("0x%x", block.Hash()),
+			"total_accounts": len(balances),
+			"next":           next,
+		})
+}
@@ -0,0 +1,154 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// streamWriter writes a success response whose data holds one large list or object,
+// encoding entries one at a time instead of buffering the whole body.
+//
+// "success" is written last, so a response cut off at the byte cap still ends as
+// valid JSON with "success": false and an error (the status stays 200).
+type streamWriter struct {
+	w        http.ResponseWriter
+	maxBytes int
+	written  int
+	err      error
+	exceeded bool
+}
+
+// newStreamWriter starts a streamed response limited to maxBytes (0 = unlimited)
+func newStreamWriter(w http.ResponseWriter, maxBytes int) *streamWriter {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return &streamWriter{w: w, maxBytes: maxBytes}
+}
+
+// write writes raw bytes, ignoring further writes after an error
+func (sw *streamWriter) write(b []byte) {
+	if sw.err != nil {
+		return
+	}
+	n, err := sw.w.Write(b)
+	sw.written += n
+	sw.err = err
+}
+
+// writeEntry encodes v (prefixed by key when streaming an object) unless it would
+// push the response past the byte cap. Returns false once the cap is hit.
+func (sw *streamWriter) writeEntry(first bool, key string, v interface{}, isObject bool) bool {
+	if sw.exceeded || sw.err != nil {
+		return false
+	}
+
+	value, err := json.Marshal(v)
+	if err != nil {
+		sw.err = err
+		return false
+	}
+
+	var entry []byte
+	if !first {
+		entry = append(entry, ',')
+	}
+	if isObject {
+		keyBytes, _ := json.Marshal(key)
+		entry = append(entry, keyBytes...)
+		entry = append(entry, ':')
+	}
+	entry = append(entry, value...)
+
+	// Reserve room for the closing fields
+	if sw.maxBytes > 0 && sw.written+len(entry)+streamTrailerReserve > sw.maxBytes {
+		sw.exceeded = true
+		return false
+	}
+
+	sw.write(entry)
+	return true
+}
+
+// streamTrailerReserve is the room kept for the fields written after the entries
+const streamTrailerReserve = 256
+
+// finish closes the collection, writes the remaining data fields and the outcome
+func (sw *streamWriter) finish(closer string, count int, meta map[string]interface{}) {
+	sw.write([]byte(closer))
+
+	fields := map[string]interface{}{"count": count}
+	for k, v := range meta {
+		fields[k] = v
+	}
+	for _, k := range sortedKeys(fields) {
+		key, _ := json.Marshal(k)
+		value, _ := json.Marshal(fields[k])
+		sw.write([]byte(","))
+		sw.write(key)
+		sw.write([]byte(":"))
+		sw.write(value)
+	}
+	sw.write([]byte("}"))
+
+	if sw.exceeded {
+		msg, _ := json.Marshal(fmt.Sprintf("response exceeds maximum size of %d bytes; narrow the query or lower the limit", sw.maxBytes))
+		sw.write([]byte(`,"success":false,"error":`))
+		sw.write(msg)
+		sw.write([]byte("}\n"))
+		return
+	}
+
+	sw.write([]byte(`,"success":true}` + "\n"))
+}
+
+// writeStreamedList streams {"data":{<field>:[items...],"count":n,...meta},"success":true}
+func writeStreamedList(w http.ResponseWriter, maxBytes int, field string, count int, item func(i int) interface{}, meta map[string]interface{}) {
+	sw := newStreamWriter(w, maxBytes)
+
+	fieldKey, _ := json.Marshal(field)
+	sw.write([]byte(`{"data":{`))
+	sw.write(fieldKey)
+	sw.write([]byte(":["))
+
+	written := 0
+	for i := 0; i < count; i++ {
+		if !sw.writeEntry(written == 0, "", item(i), false) {
+			break
+		}
+		written++
+	}
+
+	sw.finish("]", written, meta)
+}
+
+// writeStreamedObject streams {"data":{<field>:{key:value...},"count":n,...meta},"success":true} with sorted keys
+func writeStreamedObject(w http.ResponseWriter, maxBytes int, field string, values map[string]interface{}, meta map[string]interface{}) {
+	sw := newStreamWriter(w, maxBytes)
+
+	fieldKey, _ := json.Marshal(field)
+	sw.write([]byte(`{"data":{`))
+	sw.write(fieldKey)
+	sw.write([]byte(":{"))
+
+	written := 0
+	for _, key := range sortedKeys(values) {
+		if !sw.writeEntry(written == 0, key, values[key], true) {
+			break
+		}
+		written++
+	}
+
+	sw.finish("}", written, meta)
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -2,6 +2,7 @@ package websocket
 
 import (
 	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -17,9 +18,24 @@ const (
 
 	// Send pings to peer with this period (must be less than pongWait)
 	pingPeriod = (pongWait * 9) / 10
+)
+
+// Client limits
+const (
+	// DefaultMaxMessageSize is the default size limit of a message from a client. Clients
+	// only send subscription requests; this leaves room for every event type with
+	// filters and a replay height.
+	DefaultMaxMessageSize = 4096
+
+	// MinMaxMessageSize is the smallest allowed message size limit
+	MinMaxMessageSize = 512
 
-	// Maximum message size allowed from peer
-	maxMessageSize = 512
+	// DefaultSendBuffer is the default number of events queued for a client before it
+	// is dropped as too slow
+	DefaultSendBuffer = 256
+
+	// MinSendBuffer is the smallest allowed send buffer, which must hold a block replay
+	MinSendBuffer = MaxReplayBlocks
 )
 
 // Client represents a WebSocket client connection
@@ -32,31 +48,45 @@ type Client struct {
 	// Buffered channel of outbound messages
 	send chan []byte
 
-	// Subscribed event types
-	subscriptions map[EventType]bool
+	// Size limit of a message from the client
+	maxMessageSize int64
+
+	// Subscribed event types and the filter for each (guarded by mu)
+	subscriptions map[EventType]EventFilter
+	mu            sync.RWMutex
+
+	// Height of the last replayed block; live block events up to it are skipped
+	// (hub goroutine only)
+	replayedTo uint64
+	replayed   bool
 
 	logger *logrus.Logger
 }
 
-// NewClient creates a new WebSocket client
-func NewClient(hub *Hub, conn *websocket.Conn, logger *logrus.Logger) *Client {
+// NewClient creates a new WebSocket client queueing up to sendBuffer events and
+// accepting messages of up to maxMessageSize bytes
+func NewClient(hub *Hub, conn *websocket.Conn, sendBuffer int, maxMessageSize int64, logger *logrus.Logger) *Client {
 	return &Client{
-		hub:           hub,
-		conn:          conn,
-		send:          make(chan []byte, 256),
-		subscriptions: make(map[EventType]bool),
-		logger:        logger,
+		hub:            hub,
+		conn:           conn,
+		send:           make(chan []byte, sendBuffer),
+		maxMessageSize: maxMessageSize,
+		subscriptions:  make(map[EventType]EventFilter),
+		logger:         logger,
 	}
 }
 
 // readPump pumps messages from the websocket connection to the hub
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.stopChan:
+		}
 		c.conn.Close()
 	}()
 
-	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadLimit(c.maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
 		c.conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -95,28 +125,22 @@ func (c *Client) writePump() {
 	for {
 		select {
 		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				// The hub closed the channel
+				c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
-
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
+			if err := c.write(message); err != nil {
 				return
 			}
-			w.Write(message)
 
-			// Add queued messages to the current websocket message
+			// Send the queued messages too, each in its own frame
 			n := len(c.send)
 			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
-			}
-
-			if err := w.Close(); err != nil {
-				return
+				if err := c.write(<-c.send); err != nil {
+					return
+				}
 			}
 
 		case <-ticker.C:
@@ -128,14 +152,38 @@ func (c *Client) writePump() {
 	}
 }
 
+// write sends one event as its own text message, with its own write deadline
+func (c *Client) write(message []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.conn.WriteMessage(websocket.TextMessage, message)
+}
+
 // handleSubscription processes subscription/unsubscription requests
 func (c *Client) handleSubscription(msg *SubscribeMessage) {
+	if c.updateSubscriptions(msg) && msg.FromHeight != nil {
+		c.hub.requestReplay(c, *msg.FromHeight)
+	}
+}
+
+// updateSubscriptions applies a subscription request and reports whether it
+// subscribed to new_block events
+func (c *Client) updateSubscriptions(msg *SubscribeMessage) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	switch msg.Action {
 	case "subscribe":
+		if err := msg.Filters.Validate(); err != nil {
+			c.logger.Warnf("Rejected subscription: %v", err)
+			return false
+		}
+		blocks := false
 		for _, eventType := range msg.Events {
-			c.subscriptions[eventType] = true
+			c.subscriptions[eventType] = msg.Filters
 			c.logger.Debugf("Client subscribed to %s", eventType)
+			blocks = blocks || eventType == EventNewBlock
 		}
+		return blocks
 	case "unsubscribe":
 		for _, eventType := range msg.Events {
 			delete(c.subscriptions, eventType)
@@ -144,13 +192,30 @@ func (c *Client) handleSubscription(msg *SubscribeMessage) {
 	default:
 		c.logger.Warnf("Unknown subscription action: %s", msg.Action)
 	}
+	return false
 }
 
-// isSubscribed checks if the client is subscribed to an event type
-func (c *Client) isSubscribed(eventType EventType) bool {
+// isSubscribed checks if the client is subscribed to an event and its filter matches
+func (c *Client) isSubscribed(event *Event) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	// If no subscriptions, send all events
 	if len(c.subscriptions) == 0 {
 		return true
 	}
-	return c.subscriptions[eventType]
+
+	filter, subscribed := c.subscriptions[event.Type]
+	return subscribed && filter.Matches(event)
+}
+
+// alreadyReplayed reports whether a block event was already sent to the client by
+// a replay (hub goroutine only)
+func (c *Client) alreadyReplayed(event *Event) bool {
+	if !c.replayed || event.Type != EventNewBlock {
+		return false
+	}
+
+	block, ok := event.Data.(*BlockEvent)
+	return ok && block.Height <= c.replayedTo
 }
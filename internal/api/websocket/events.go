@@ -1,7 +1,10 @@
 package websocket
 
 import (
+	"fmt"
+
 	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/crypto"
 )
 
 // EventType defines the type of event being broadcast
@@ -55,8 +58,46 @@ type MempoolUpdateEvent struct {
 
 // SubscribeMessage represents a subscription request from client
 type SubscribeMessage struct {
-	Action string      `json:"action"` // "subscribe" or "unsubscribe"
-	Events []EventType `json:"events"`
+	Action  string      `json:"action"` // "subscribe" or "unsubscribe"
+	Events  []EventType `json:"events"`
+	Filters EventFilter `json:"filters,omitempty"` // Only deliver matching events, e.g. {"from": "0x..."}
+
+	// FromHeight replays new_block events from this height to the tip before live
+	// events, up to MaxReplayBlocks
+	FromHeight *uint64 `json:"from_height,omitempty"`
+}
+
+// FilterFrom matches transaction events sent from an address
+const FilterFrom = "from"
+
+// EventFilter restricts a subscription to events matching every entry. An empty
+// filter matches all events.
+type EventFilter map[string]string
+
+// Validate checks that every filter field is supported
+func (f EventFilter) Validate() error {
+	for field := range f {
+		if field != FilterFrom {
+			return fmt.Errorf("unsupported filter: %s", field)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether an event passes the filter. Events without a filtered
+// field don't match.
+func (f EventFilter) Matches(event *Event) bool {
+	if len(f) == 0 {
+		return true
+	}
+
+	if from, ok := f[FilterFrom]; ok {
+		tx, isTx := event.Data.(*TransactionEvent)
+		if !isTx || crypto.NormalizeAddress(tx.From) != crypto.NormalizeAddress(from) {
+			return false
+		}
+	}
+	return true
 }
 
 // NewBlockEvent creates a block event from a blockchain block
@@ -5,9 +5,26 @@ import (
 	"sync"
 	"time"
 
+	"github.com/podoru/podoru-chain/internal/blockchain"
 	"github.com/sirupsen/logrus"
 )
 
+// MaxReplayBlocks is the most historical blocks replayed for one subscription.
+// It stays below the client send buffer so a replay is queued in one go.
+const MaxReplayBlocks = 100
+
+// BlockSource provides stored blocks for replaying history to new subscribers
+type BlockSource interface {
+	GetHeight() uint64
+	GetBlockByHeight(height uint64) (*blockchain.Block, error)
+}
+
+// replayRequest asks the hub to replay block events to a client
+type replayRequest struct {
+	client     *Client
+	fromHeight uint64
+}
+
 // Hub maintains the set of active clients and broadcasts messages to the clients
 type Hub struct {
 	// Registered clients
@@ -22,6 +39,12 @@ type Hub struct {
 	// Unregister requests from clients
 	unregister chan *Client
 
+	// Block replay requests from clients
+	replay chan *replayRequest
+
+	// Source of historical blocks for replays (nil disables them)
+	blockSource BlockSource
+
 	// Mutex for thread-safe operations
 	mu sync.RWMutex
 
@@ -38,6 +61,7 @@ func NewHub(logger *logrus.Logger) *Hub {
 		broadcast:  make(chan *Event, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		replay:     make(chan *replayRequest),
 		logger:     logger,
 		stopChan:   make(chan struct{}),
 	}
@@ -56,17 +80,15 @@ func (h *Hub) Run() {
 			h.logger.Debugf("Client connected (total: %d)", len(h.clients))
 
 		case client := <-h.unregister:
-			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-			}
-			h.mu.Unlock()
-			h.logger.Debugf("Client disconnected (total: %d)", len(h.clients))
+			h.removeClient(client)
+			h.logger.Debugf("Client disconnected (total: %d)", h.GetClientCount())
 
 		case event := <-h.broadcast:
 			h.broadcastEvent(event)
 
+		case req := <-h.replay:
+			h.replayBlocks(req.client, req.fromHeight)
+
 		case <-h.stopChan:
 			h.logger.Info("WebSocket hub stopping")
 			h.closeAllClients()
@@ -89,25 +111,106 @@ func (h *Hub) broadcastEvent(event *Event) {
 		return
 	}
 
+	// Send to all subscribed clients, never waiting on a slow one
+	var slow []*Client
 	h.mu.RLock()
-	defer h.mu.RUnlock()
-
-	// Send to all subscribed clients
 	for client := range h.clients {
-		if client.isSubscribed(event.Type) {
+		if client.isSubscribed(event) && !client.alreadyReplayed(event) {
 			select {
 			case client.send <- message:
 				// Message sent successfully
 			default:
-				// Client's send buffer is full, close the connection
-				h.logger.Warnf("Client buffer full, closing connection")
-				go func(c *Client) {
-					h.unregister <- c
-					c.conn.Close()
-				}(client)
+				slow = append(slow, client)
 			}
 		}
 	}
+	h.mu.RUnlock()
+
+	// Drop the clients that fell behind. This runs on the hub goroutine, so the
+	// clients are gone before the next event is broadcast.
+	for _, client := range slow {
+		h.logger.Warnf("Client buffer full, closing connection")
+		if h.removeClient(client) {
+			client.conn.Close()
+		}
+	}
+}
+
+// removeClient unregisters a client and closes its send channel, which ends its
+// write pump. Returns false if the client was already removed.
+func (h *Hub) removeClient(client *Client) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.clients[client]; !ok {
+		return false
+	}
+	delete(h.clients, client)
+	close(client.send)
+	return true
+}
+
+// SetBlockSource sets where replayed blocks are read from. Call it before Run.
+func (h *Hub) SetBlockSource(source BlockSource) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.blockSource = source
+}
+
+// requestReplay asks the hub to replay block events from a height to a client
+func (h *Hub) requestReplay(client *Client, fromHeight uint64) {
+	select {
+	case h.replay <- &replayRequest{client: client, fromHeight: fromHeight}:
+	case <-h.stopChan:
+	}
+}
+
+// replayBlocks queues block events from fromHeight to the tip for a client, at most
+// MaxReplayBlocks of them ending at the tip. It runs on the hub goroutine, so no
+// live event is sent to the client until the replay is queued.
+func (h *Hub) replayBlocks(client *Client, fromHeight uint64) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.blockSource == nil || !h.clients[client] {
+		return
+	}
+
+	tip := h.blockSource.GetHeight()
+	if fromHeight > tip {
+		return
+	}
+	if tip-fromHeight >= MaxReplayBlocks {
+		fromHeight = tip - MaxReplayBlocks + 1
+	}
+
+	for height := fromHeight; height <= tip; height++ {
+		block, err := h.blockSource.GetBlockByHeight(height)
+		if err != nil {
+			h.logger.Debugf("Stopping block replay at height %d: %v", height, err)
+			return
+		}
+
+		event := NewBlockEvent(block)
+		if !client.isSubscribed(event) {
+			continue
+		}
+
+		message, err := json.Marshal(event)
+		if err != nil {
+			h.logger.Errorf("Failed to marshal event: %v", err)
+			return
+		}
+
+		select {
+		case client.send <- message:
+			client.replayedTo = height
+			client.replayed = true
+		default:
+			h.logger.Warnf("Client buffer full during block replay, stopping it")
+			return
+		}
+	}
 }
 
 // closeAllClients closes all client connections
@@ -0,0 +1,373 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+// testServer starts a WebSocket server behind an HTTP test server and returns it
+// with the URL clients dial
+func testServer(t *testing.T) (*Server, string) {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := NewServer(logger)
+	s.Start()
+	httpServer := httptest.NewServer(http.HandlerFunc(s.HandleWebSocket))
+	t.Cleanup(func() {
+		httpServer.Close()
+		s.Stop()
+	})
+	return s, "ws" + strings.TrimPrefix(httpServer.URL, "http")
+}
+
+// subscribe dials url and sends a subscription request
+func subscribe(t *testing.T, url string, msg *SubscribeMessage) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	return conn
+}
+
+// waitSubscribed waits until the hub has n clients that all have a subscription
+func waitSubscribed(t *testing.T, hub *Hub, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		subscribed := 0
+		hub.mu.RLock()
+		for client := range hub.clients {
+			client.mu.RLock()
+			if len(client.subscriptions) > 0 {
+				subscribed++
+			}
+			client.mu.RUnlock()
+		}
+		hub.mu.RUnlock()
+
+		if subscribed == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d clients subscribed", subscribed, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// readFroms reads transaction events from conn until none arrives for a while,
+// returning their senders
+func readFroms(t *testing.T, conn *websocket.Conn) []string {
+	t.Helper()
+
+	var froms []string
+	for {
+		conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+		var event struct {
+			Type EventType        `json:"type"`
+			Data TransactionEvent `json:"data"`
+		}
+		if err := conn.ReadJSON(&event); err != nil {
+			return froms
+		}
+		if event.Type != EventNewTransaction {
+			t.Errorf("received a %s event", event.Type)
+			continue
+		}
+		froms = append(froms, event.Data.From)
+	}
+}
+
+func TestSubscriptionFilterByAddress(t *testing.T) {
+	const (
+		watched = "0x000000000000000000000000000000000000000A"
+		other   = "0x000000000000000000000000000000000000000b"
+	)
+	s, url := testServer(t)
+
+	filtered := subscribe(t, url, &SubscribeMessage{
+		Action:  "subscribe",
+		Events:  []EventType{EventNewTransaction},
+		Filters: EventFilter{FilterFrom: strings.ToLower(watched)},
+	})
+	unfiltered := subscribe(t, url, &SubscribeMessage{Action: "subscribe", Events: []EventType{EventNewTransaction}})
+	waitSubscribed(t, s.GetHub(), 2)
+
+	for _, from := range []string{watched, other, watched} {
+		s.GetHub().Broadcast(&Event{Type: EventNewTransaction, Data: &TransactionEvent{Hash: "0x01", From: from}})
+	}
+	// Other event types are not delivered to transaction subscribers
+	s.GetHub().Broadcast(NewMempoolUpdateEvent(1, nil))
+
+	if got := readFroms(t, filtered); len(got) != 2 || got[0] != watched || got[1] != watched {
+		t.Errorf("filtered client received transactions from %v, want only the watched address twice", got)
+	}
+	if got := readFroms(t, unfiltered); len(got) != 3 {
+		t.Errorf("unfiltered client received %d transactions, want 3", len(got))
+	}
+}
+
+func TestLongSubscriptionAndEventBurst(t *testing.T) {
+	const (
+		watched = "0x000000000000000000000000000000000000000a"
+		burst   = 150
+	)
+	s, url := testServer(t)
+
+	// A pretty-printed subscription to every event type is over the old 512 byte limit
+	fromHeight := uint64(0)
+	msg, err := json.MarshalIndent(&SubscribeMessage{
+		Action:     "subscribe",
+		Events:     []EventType{EventNewBlock, EventNewTransaction, EventChainUpdate, EventMempoolUpdate},
+		Filters:    EventFilter{FilterFrom: watched},
+		FromHeight: &fromHeight,
+	}, "", strings.Repeat(" ", 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg) <= MinMaxMessageSize || len(msg) > DefaultMaxMessageSize {
+		t.Fatalf("subscription is %d bytes, want between %d and %d", len(msg), MinMaxMessageSize, DefaultMaxMessageSize)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	waitSubscribed(t, s.GetHub(), 1)
+
+	// A burst queued faster than it is written still arrives one event per frame
+	for i := range burst {
+		s.GetHub().Broadcast(&Event{Type: EventNewTransaction, Data: &TransactionEvent{Hash: fmt.Sprint(i), From: watched}})
+	}
+	received := 0
+	for received < burst {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("received %d of %d events: %v", received, burst, err)
+		}
+		var event struct {
+			Type EventType        `json:"type"`
+			Data TransactionEvent `json:"data"`
+		}
+		if err := json.Unmarshal(frame, &event); err != nil {
+			t.Fatalf("frame %d is not a single event: %v", received, err)
+		}
+		if event.Data.Hash != fmt.Sprint(received) {
+			t.Fatalf("event %d is transaction %s", received, event.Data.Hash)
+		}
+		received++
+	}
+}
+
+// stalledClient connects a client to hub whose write pump never runs, so its send
+// buffer fills up, and returns it with the dialing side of the connection
+func stalledClient(t *testing.T, hub *Hub) (*Client, *websocket.Conn) {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	clients := make(chan *Client, 1)
+	upgrader := websocket.Upgrader{}
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := NewClient(hub, conn, MinSendBuffer, DefaultMaxMessageSize, logger)
+		hub.register <- client
+		clients <- client
+	}))
+	t.Cleanup(httpServer.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return <-clients, conn
+}
+
+func TestSlowClientDropped(t *testing.T) {
+	const events = MinSendBuffer + 50
+	s, url := testServer(t)
+	hub := s.GetHub()
+
+	stalled, stalledConn := stalledClient(t, hub)
+	healthy := subscribe(t, url, &SubscribeMessage{Action: "subscribe", Events: []EventType{EventNewTransaction}})
+	waitSubscribed(t, hub, 1)
+	if hub.GetClientCount() != 2 {
+		t.Fatalf("%d clients connected, want 2", hub.GetClientCount())
+	}
+
+	// The flood overruns the stalled client's buffer while the healthy one keeps up
+	for i := range events {
+		hub.Broadcast(&Event{Type: EventNewTransaction, Data: &TransactionEvent{Hash: fmt.Sprint(i)}})
+	}
+	for i := range events {
+		healthy.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var event struct {
+			Data TransactionEvent `json:"data"`
+		}
+		if err := healthy.ReadJSON(&event); err != nil {
+			t.Fatalf("healthy client received %d of %d events: %v", i, events, err)
+		}
+		if event.Data.Hash != fmt.Sprint(i) {
+			t.Fatalf("event %d is transaction %s", i, event.Data.Hash)
+		}
+	}
+
+	// The stalled client is removed: its queue is closed after the events it held,
+	// and its connection is closed
+	if hub.GetClientCount() != 1 {
+		t.Fatalf("%d clients connected, want only the healthy one", hub.GetClientCount())
+	}
+	queued := 0
+	for range stalled.send {
+		queued++
+	}
+	if queued != MinSendBuffer {
+		t.Errorf("stalled client held %d events, want a full buffer of %d", queued, MinSendBuffer)
+	}
+	stalledConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := stalledConn.ReadMessage(); err == nil {
+		t.Error("stalled client's connection is still open")
+	} else if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
+		t.Error("stalled client's connection was not closed")
+	}
+}
+
+func TestSubscriptionRejectsUnknownFilter(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	client := NewClient(NewHub(logger), nil, DefaultSendBuffer, DefaultMaxMessageSize, logger)
+
+	client.updateSubscriptions(&SubscribeMessage{
+		Action:  "subscribe",
+		Events:  []EventType{EventNewTransaction},
+		Filters: EventFilter{"to": "0x000000000000000000000000000000000000000a"},
+	})
+	if len(client.subscriptions) != 0 {
+		t.Error("subscribed with an unsupported filter")
+	}
+}
+
+// testBlockSource serves blocks 0 to tip, one per height
+type testBlockSource []*blockchain.Block
+
+func newTestBlockSource(tip uint64) testBlockSource {
+	source := make(testBlockSource, tip+1)
+	for height := range source {
+		source[height] = blockchain.NewBlock(&blockchain.BlockHeader{Height: uint64(height), Timestamp: int64(height)}, nil)
+	}
+	return source
+}
+
+func (s testBlockSource) GetHeight() uint64 {
+	return uint64(len(s) - 1)
+}
+
+func (s testBlockSource) GetBlockByHeight(height uint64) (*blockchain.Block, error) {
+	if height >= uint64(len(s)) {
+		return nil, fmt.Errorf("no block at height %d", height)
+	}
+	return s[height], nil
+}
+
+// readHeights returns the heights of up to limit block events, fewer if the
+// connection goes quiet first
+func readHeights(t *testing.T, conn *websocket.Conn, limit int) []uint64 {
+	t.Helper()
+
+	var heights []uint64
+	for len(heights) < limit {
+		conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+		var event struct {
+			Type EventType  `json:"type"`
+			Data BlockEvent `json:"data"`
+		}
+		if err := conn.ReadJSON(&event); err != nil {
+			return heights
+		}
+		if event.Type != EventNewBlock {
+			t.Errorf("received a %s event", event.Type)
+			continue
+		}
+		heights = append(heights, event.Data.Height)
+	}
+	return heights
+}
+
+func TestReplayPrecedesLiveBlocks(t *testing.T) {
+	const tip = MaxReplayBlocks + 20
+	source := newTestBlockSource(tip + 1)
+	s, url := testServer(t)
+	s.GetHub().SetBlockSource(source[:tip+1])
+
+	// Replaying from genesis is capped to the last MaxReplayBlocks blocks
+	fromHeight := uint64(0)
+	conn := subscribe(t, url, &SubscribeMessage{
+		Action:     "subscribe",
+		Events:     []EventType{EventNewBlock},
+		FromHeight: &fromHeight,
+	})
+	heights := readHeights(t, conn, MaxReplayBlocks)
+	if len(heights) != MaxReplayBlocks {
+		t.Fatalf("replayed %d blocks, want %d", len(heights), MaxReplayBlocks)
+	}
+
+	// Live events follow the replay in order, without repeating the replayed tip
+	s.GetHub().Broadcast(NewBlockEvent(source[tip]))
+	s.GetHub().Broadcast(NewBlockEvent(source[tip+1]))
+	heights = append(heights, readHeights(t, conn, 2)...)
+
+	first := uint64(tip - MaxReplayBlocks + 1)
+	if len(heights) != MaxReplayBlocks+1 {
+		t.Fatalf("received %d block events, want %d", len(heights), MaxReplayBlocks+1)
+	}
+	for i, height := range heights {
+		if height != first+uint64(i) {
+			t.Fatalf("event %d is block %d, want %d", i, height, first+uint64(i))
+		}
+	}
+}
+
+func TestReplayFromHeightAboveTip(t *testing.T) {
+	s, url := testServer(t)
+	s.GetHub().SetBlockSource(newTestBlockSource(5))
+
+	fromHeight := uint64(6)
+	conn := subscribe(t, url, &SubscribeMessage{
+		Action:     "subscribe",
+		Events:     []EventType{EventNewBlock},
+		FromHeight: &fromHeight,
+	})
+	waitSubscribed(t, s.GetHub(), 1)
+	if heights := readHeights(t, conn, 1); len(heights) != 0 {
+		t.Errorf("replayed blocks %v from above the tip", heights)
+	}
+}
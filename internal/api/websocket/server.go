@@ -2,34 +2,72 @@ package websocket
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// Allow all origins for development
-		// In production, you should check r.Origin
-		return true
-	},
-}
-
 // Server handles WebSocket connections
 type Server struct {
-	hub    *Hub
-	logger *logrus.Logger
+	hub            *Hub
+	upgrader       websocket.Upgrader
+	allowedOrigins []string // Browser origins allowed to connect (empty = any)
+	maxMessageSize int64    // Size limit of a message from a client
+	sendBuffer     int      // Events queued per client
+	logger         *logrus.Logger
 }
 
 // NewServer creates a new WebSocket server
 func NewServer(logger *logrus.Logger) *Server {
 	hub := NewHub(logger)
-	return &Server{
-		hub:    hub,
-		logger: logger,
+	s := &Server{
+		hub:            hub,
+		maxMessageSize: DefaultMaxMessageSize,
+		sendBuffer:     DefaultSendBuffer,
+		logger:         logger,
+	}
+	s.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     s.checkOrigin,
+	}
+	return s
+}
+
+// SetAllowedOrigins restricts which browser origins may open a connection, such as
+// "https://explorer.example.com". An empty list allows any origin.
+func (s *Server) SetAllowedOrigins(origins []string) {
+	s.allowedOrigins = origins
+}
+
+// SetClientLimits sets the size limit of client messages and how many events are
+// queued per client (0 keeps the default)
+func (s *Server) SetClientLimits(maxMessageSize int64, sendBuffer int) {
+	if maxMessageSize > 0 {
+		s.maxMessageSize = maxMessageSize
 	}
+	if sendBuffer > 0 {
+		s.sendBuffer = sendBuffer
+	}
+}
+
+// checkOrigin reports whether a connection request's Origin header is allowed.
+// Requests without one don't come from a browser page and are always allowed.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || len(s.allowedOrigins) == 0 {
+		return true
+	}
+
+	for _, allowed := range s.allowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+
+	s.logger.Warnf("Rejected WebSocket connection from origin %s", origin)
+	return false
 }
 
 // Start starts the WebSocket server (runs the hub)
@@ -45,14 +83,14 @@ func (s *Server) Stop() {
 // HandleWebSocket handles WebSocket connection requests
 func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Upgrade HTTP connection to WebSocket
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		s.logger.Errorf("Failed to upgrade connection: %v", err)
 		return
 	}
 
 	// Create new client
-	client := NewClient(s.hub, conn, s.logger)
+	client := NewClient(s.hub, conn, s.sendBuffer, s.maxMessageSize, s.logger)
 
 	// Register client
 	s.hub.register <- client
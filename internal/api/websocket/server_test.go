@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{name: "any origin without an allowlist", origin: "https://anywhere.example.com", want: true},
+		{name: "allowed origin", allowed: []string{"https://explorer.example.com"}, origin: "https://explorer.example.com", want: true},
+		{name: "allowed origin with trailing slash and case", allowed: []string{"https://Explorer.example.com/"}, origin: "https://explorer.example.com", want: true},
+		{name: "rejected origin", allowed: []string{"https://explorer.example.com"}, origin: "https://evil.example.com", want: false},
+		{name: "no origin header", allowed: []string{"https://explorer.example.com"}, want: true},
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(logger)
+			s.SetAllowedOrigins(tt.allowed)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/ws", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if got := s.checkOrigin(req); got != tt.want {
+				t.Errorf("checkOrigin = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientMessageSizeLimit(t *testing.T) {
+	// A pretty-printed subscription of about 1 KB
+	msg, err := json.MarshalIndent(&SubscribeMessage{
+		Action: "subscribe",
+		Events: []EventType{EventNewBlock, EventNewTransaction, EventChainUpdate, EventMempoolUpdate},
+	}, "", strings.Repeat(" ", 128))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg) <= MinMaxMessageSize || len(msg) > DefaultMaxMessageSize {
+		t.Fatalf("subscription is %d bytes, want between %d and %d", len(msg), MinMaxMessageSize, DefaultMaxMessageSize)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	for _, tt := range []struct {
+		name     string
+		limit    int64
+		accepted bool
+	}{
+		{name: "minimum limit", limit: MinMaxMessageSize},
+		{name: "raised limit", limit: DefaultMaxMessageSize, accepted: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(logger)
+			s.SetClientLimits(tt.limit, 0)
+			s.Start()
+			httpServer := httptest.NewServer(http.HandlerFunc(s.HandleWebSocket))
+			t.Cleanup(func() {
+				httpServer.Close()
+				s.Stop()
+			})
+
+			conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), nil)
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer conn.Close()
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				t.Fatalf("subscribe: %v", err)
+			}
+
+			if tt.accepted {
+				waitSubscribed(t, s.GetHub(), 1)
+				return
+			}
+			// The server closes a connection whose message is over the limit
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			_, _, err = conn.ReadMessage()
+			if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+				t.Errorf("read after an oversized message: err = %v, want a message too big close", err)
+			}
+		})
+	}
+}
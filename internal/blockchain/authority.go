@@ -0,0 +1,109 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/podoru/podoru-chain/internal/crypto"
+)
+
+// AuthoritySet is an immutable set of authority addresses. Addresses are normalized
+// once when the set is built, so lookups are a single map access regardless of the
+// casing or 0x prefix of the address being checked.
+type AuthoritySet struct {
+	addresses []string       // Addresses as configured, in rotation order
+	index     map[string]int // Normalized address -> position in addresses
+}
+
+// NewAuthoritySet builds a set from the configured authority addresses. Addresses
+// that differ only in casing count as the same authority; later duplicates are ignored.
+func NewAuthoritySet(addresses []string) *AuthoritySet {
+	set := &AuthoritySet{
+		addresses: make([]string, 0, len(addresses)),
+		index:     make(map[string]int, len(addresses)),
+	}
+
+	for _, addr := range addresses {
+		normalized := crypto.NormalizeAddress(addr)
+		if _, exists := set.index[normalized]; exists {
+			continue
+		}
+		set.index[normalized] = len(set.addresses)
+		set.addresses = append(set.addresses, addr)
+	}
+
+	return set
+}
+
+// Contains reports whether address is an authority
+func (s *AuthoritySet) Contains(address string) bool {
+	_, exists := s.Index(address)
+	return exists
+}
+
+// Index returns the position of address in the rotation order
+func (s *AuthoritySet) Index(address string) (int, bool) {
+	if s == nil {
+		return 0, false
+	}
+
+	i, exists := s.index[crypto.NormalizeAddress(address)]
+	return i, exists
+}
+
+// Addresses returns a copy of the authority addresses in rotation order
+func (s *AuthoritySet) Addresses() []string {
+	if s == nil {
+		return nil
+	}
+
+	return append([]string{}, s.addresses...)
+}
+
+// Len returns the number of authorities
+func (s *AuthoritySet) Len() int {
+	if s == nil {
+		return 0
+	}
+
+	return len(s.addresses)
+}
+
+// Get returns the authority at position i in the rotation order
+func (s *AuthoritySet) Get(i int) string {
+	return s.addresses[i]
+}
+
+// FindDuplicateAuthority returns the first address that repeats an earlier one
+// once normalized, or "" if there is none
+func FindDuplicateAuthority(addresses []string) string {
+	seen := make(map[string]bool, len(addresses))
+	for _, addr := range addresses {
+		normalized := crypto.NormalizeAddress(addr)
+		if seen[normalized] {
+			return addr
+		}
+		seen[normalized] = true
+	}
+	return ""
+}
+
+// AuthoritySetKey is the state key holding the genesis authority list, so the
+// set is committed in the state root and can be proven to light clients
+const AuthoritySetKey = ReservedKeyPrefix + "authorities"
+
+// EncodeAuthorities returns the state value stored under AuthoritySetKey: the
+// addresses as configured, in rotation order, as a compact JSON array
+func EncodeAuthorities(addresses []string) []byte {
+	data, _ := json.Marshal(addresses)
+	return data
+}
+
+// DecodeAuthorities parses a state value stored under AuthoritySetKey
+func DecodeAuthorities(data []byte) ([]string, error) {
+	var addresses []string
+	if err := json.Unmarshal(data, &addresses); err != nil {
+		return nil, fmt.Errorf("failed to decode authority set: %w", err)
+	}
+	return addresses, nil
+}
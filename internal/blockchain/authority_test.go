@@ -0,0 +1,114 @@
+package blockchain_test
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+func TestAuthoritySetProof(t *testing.T) {
+	for _, version := range []uint32{blockchain.DefaultBlockVersion, blockchain.MerkleTreeVersion} {
+		authority, other := newTestKey(t), newTestKey(t)
+		config := testGenesis(authority)
+		config.Authorities = append(config.Authorities, other.address)
+		config.ConsensusParams = &blockchain.ConsensusParams{BlockVersion: version}
+		chain := newTestChain(t, config)
+		addBlock(t, chain, authority, newTestTx(t, other, "", 0, setOp("k", "v")))
+
+		proof, tip, err := chain.ProveState(blockchain.AuthoritySetKey)
+		if err != nil {
+			t.Fatalf("version %d: ProveState: %v", version, err)
+		}
+		if !bytes.Equal(tip.Header.StateRoot, chain.GetStateRoot()) {
+			t.Fatalf("version %d: proof is against %x, not the current state root", version, tip.Header.StateRoot)
+		}
+		if !blockchain.VerifyStateProof(tip.Header, proof) {
+			t.Errorf("version %d: authority proof does not verify", version)
+		}
+
+		authorities, err := blockchain.DecodeAuthorities(proof.Value)
+		if err != nil {
+			t.Fatalf("version %d: %v", version, err)
+		}
+		if !slices.Equal(authorities, config.Authorities) {
+			t.Errorf("version %d: proven authorities = %v, want %v", version, authorities, config.Authorities)
+		}
+
+		tampered := *proof
+		tampered.Value = blockchain.EncodeAuthorities(append(authorities, newTestKey(t).address))
+		if blockchain.VerifyStateProof(tip.Header, &tampered) {
+			t.Errorf("version %d: proof with an added authority verifies", version)
+		}
+		tampered.Value = blockchain.EncodeAuthorities(authorities[:1])
+		if blockchain.VerifyStateProof(tip.Header, &tampered) {
+			t.Errorf("version %d: proof with a removed authority verifies", version)
+		}
+	}
+}
+
+func TestAuthoritySetLookups(t *testing.T) {
+	const (
+		first  = "0xAbCdEf0000000000000000000000000000000001"
+		second = "0x00000000000000000000000000000000000000Ff"
+	)
+	set := blockchain.NewAuthoritySet([]string{first, second, strings.ToLower(first)})
+
+	if set.Len() != 2 {
+		t.Fatalf("Len = %d, want 2: a casing variant was kept as a separate authority", set.Len())
+	}
+	if got := set.Addresses(); !slices.Equal(got, []string{first, second}) {
+		t.Errorf("Addresses = %v, want the configured spelling in rotation order", got)
+	}
+
+	for _, tt := range []struct {
+		address string
+		index   int
+	}{
+		{first, 0},
+		{strings.ToLower(first), 0},
+		{strings.ToUpper(first[2:]), 0}, // Without the 0x prefix
+		{" " + first + " ", 0},
+		{second, 1},
+		{strings.ToUpper(second), 1},
+	} {
+		index, ok := set.Index(tt.address)
+		if !ok || index != tt.index {
+			t.Errorf("Index(%q) = %d, %v, want %d", tt.address, index, ok, tt.index)
+		}
+		if !set.Contains(tt.address) {
+			t.Errorf("Contains(%q) = false", tt.address)
+		}
+	}
+	if set.Contains("0x0000000000000000000000000000000000000003") {
+		t.Error("Contains reports an address that is not an authority")
+	}
+
+	var empty *blockchain.AuthoritySet
+	if empty.Contains(first) || empty.Len() != 0 {
+		t.Error("nil set reports authorities")
+	}
+
+	if dup := blockchain.FindDuplicateAuthority([]string{first, second, strings.ToUpper(first)}); dup != strings.ToUpper(first) {
+		t.Errorf("FindDuplicateAuthority = %q, want the casing variant", dup)
+	}
+	if dup := blockchain.FindDuplicateAuthority([]string{first, second}); dup != "" {
+		t.Errorf("FindDuplicateAuthority = %q for distinct authorities", dup)
+	}
+}
+
+func TestChainIsAuthorityIgnoresCasing(t *testing.T) {
+	authority := newTestKey(t)
+	chain := newTestChain(t, testGenesis(authority))
+
+	for _, address := range []string{authority.address, strings.ToLower(authority.address), strings.ToUpper(authority.address[2:])} {
+		if !chain.IsAuthority(address) {
+			t.Errorf("IsAuthority(%q) = false", address)
+		}
+	}
+	if chain.IsAuthority(newTestKey(t).address) {
+		t.Error("IsAuthority reports an address that is not an authority")
+	}
+}
@@ -0,0 +1,86 @@
+package blockchain
+
+import "fmt"
+
+// BlockCommit is what committing a block writes to storage in one batch: the block,
+// its transactions with their index entries and receipts, and its state changes
+type BlockCommit struct {
+	Block    *Block
+	Receipts []*TransactionReceipt
+	Sets     map[string][]byte // State keys set by the block
+	Deletes  []string          // State keys deleted by the block
+}
+
+// stateWrites collects changes to the live state so they can be written to storage
+// in one batch per block instead of one storage transaction per key
+type stateWrites struct {
+	sets    map[string][]byte
+	deletes map[string]struct{}
+}
+
+// newStateWrites creates an empty set of pending writes
+func newStateWrites() *stateWrites {
+	return &stateWrites{
+		sets:    make(map[string][]byte),
+		deletes: make(map[string]struct{}),
+	}
+}
+
+// set records a key's new value, replacing any earlier pending write for it
+func (w *stateWrites) set(key string, value []byte) {
+	delete(w.deletes, key)
+	w.sets[key] = value
+}
+
+// delete records a key's removal, replacing any earlier pending write for it
+func (w *stateWrites) delete(key string) {
+	delete(w.sets, key)
+	w.deletes[key] = struct{}{}
+}
+
+// isDeleted reports whether a key's removal is pending
+func (w *stateWrites) isDeleted(key string) bool {
+	_, deleted := w.deletes[key]
+	return deleted
+}
+
+// flushState writes the pending live state changes to storage in one batch (caller must hold c.mu)
+func (c *Chain) flushState() error {
+	if len(c.writes.sets) == 0 && len(c.writes.deletes) == 0 {
+		return nil
+	}
+
+	deletes := make([]string, 0, len(c.writes.deletes))
+	for key := range c.writes.deletes {
+		deletes = append(deletes, key)
+	}
+
+	if err := c.storage.SaveStateBatch(c.writes.sets, deletes); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	c.writes = newStateWrites()
+	return nil
+}
+
+// writeBlock writes a block with its transactions, index entries and receipts to
+// storage together with the pending live state changes, in one batch (caller must hold c.mu)
+func (c *Chain) writeBlock(block *Block, outcomes []txOutcome) error {
+	deletes := make([]string, 0, len(c.writes.deletes))
+	for key := range c.writes.deletes {
+		deletes = append(deletes, key)
+	}
+
+	commit := &BlockCommit{
+		Block:    block,
+		Receipts: newReceipts(block, outcomes),
+		Sets:     c.writes.sets,
+		Deletes:  deletes,
+	}
+	if err := c.storage.CommitBlock(commit); err != nil {
+		return fmt.Errorf("failed to save block: %w", err)
+	}
+
+	c.writes = newStateWrites()
+	return nil
+}
@@ -1,7 +1,6 @@
 package blockchain
 
 import (
-	"crypto/ecdsa"
 	"crypto/sha256"
 	"encoding/json"
 	"errors"
@@ -15,11 +14,12 @@ type BlockHeader struct {
 	Version      uint32 `json:"version"`
 	Height       uint64 `json:"height"`
 	PreviousHash []byte `json:"previous_hash"`
-	Timestamp    int64  `json:"timestamp"`      // Unix timestamp
-	MerkleRoot   []byte `json:"merkle_root"`    // Root of tx merkle tree
-	StateRoot    []byte `json:"state_root"`     // Root hash of KV state
-	ProducerAddr string `json:"producer_addr"`  // Block producer address
-	Nonce        uint64 `json:"nonce"`          // Can be used for ordering
+	Timestamp    int64  `json:"timestamp"`          // Unix timestamp
+	MerkleRoot   []byte `json:"merkle_root"`        // Root of tx merkle tree
+	StateRoot    []byte `json:"state_root"`         // Root hash of KV state
+	ProducerAddr string `json:"producer_addr"`      // Block producer address
+	Nonce        uint64 `json:"nonce"`              // Can be used for ordering
+	ChainID      string `json:"chain_id,omitempty"` // Chain the block is signed for (omitted when unset so existing hashes are unchanged)
 }
 
 // Block represents a single block in the blockchain
@@ -39,7 +39,12 @@ func NewBlock(header *BlockHeader, transactions []*Transaction) *Block {
 
 // Hash calculates the block hash (hash of the header)
 func (b *Block) Hash() []byte {
-	headerBytes, err := json.Marshal(b.Header)
+	return b.Header.Hash()
+}
+
+// Hash calculates the hash of the header, which is the hash of its block
+func (h *BlockHeader) Hash() []byte {
+	headerBytes, err := json.Marshal(h)
 	if err != nil {
 		panic(fmt.Sprintf("failed to marshal block header: %v", err))
 	}
@@ -48,11 +53,15 @@ func (b *Block) Hash() []byte {
 	return hash[:]
 }
 
-// Sign signs the block with a private key
-func (b *Block) Sign(privateKey *ecdsa.PrivateKey) error {
+// Sign signs the block with a signer (local key or remote/HSM)
+func (b *Block) Sign(signer crypto.Signer) error {
+	if signer == nil {
+		return errors.New("signer is nil")
+	}
+
 	hash := b.Hash()
 
-	signature, err := crypto.Sign(hash, privateKey)
+	signature, err := signer.Sign(hash)
 	if err != nil {
 		return fmt.Errorf("failed to sign block: %w", err)
 	}
@@ -91,48 +100,91 @@ func (b *Block) Verify() error {
 	return nil
 }
 
-// CalculateMerkleRoot calculates the merkle root of transactions
-func CalculateMerkleRoot(transactions []*Transaction) []byte {
+// Merkle tree domain separation: leaves and internal nodes are hashed with different
+// prefixes, so an internal node can never be passed off as a leaf
+const (
+	merkleLeafPrefix byte = 0x00
+	merkleNodePrefix byte = 0x01
+)
+
+// MerkleTreeVersion is the first block version whose transaction and state roots use
+// the domain-separated merkle tree. Blocks of earlier versions keep the tree chains
+// were created with: unprefixed leaves and nodes, with an odd node hashed with itself.
+const MerkleTreeVersion uint32 = 3
+
+// legacyMerkle reports whether blocks of version use the legacy merkle tree
+func legacyMerkle(version uint32) bool {
+	return version < MerkleTreeVersion
+}
+
+// CalculateMerkleRoot calculates the merkle root of transactions in a block of the given version
+func CalculateMerkleRoot(transactions []*Transaction, version uint32) []byte {
 	if len(transactions) == 0 {
 		return make([]byte, 32) // Empty hash
 	}
 
-	// Get transaction hashes
+	// Get transaction leaves
 	hashes := make([][]byte, len(transactions))
 	for i, tx := range transactions {
-		hashes[i] = tx.Hash()
+		if legacyMerkle(version) {
+			hashes[i] = tx.Hash()
+		} else {
+			hashes[i] = merkleLeaf(tx.Hash())
+		}
 	}
 
 	// Build merkle tree bottom-up
-	return buildMerkleTree(hashes)
+	return buildMerkleTree(hashes, version)
 }
 
-// buildMerkleTree builds a merkle tree from a list of hashes
-func buildMerkleTree(hashes [][]byte) []byte {
-	if len(hashes) == 0 {
-		return make([]byte, 32)
-	}
+// merkleLeaf returns the hash of a leaf of the domain-separated tree holding data
+func merkleLeaf(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleLeafPrefix})
+	h.Write(data)
+	return h.Sum(nil)
+}
 
-	if len(hashes) == 1 {
-		return hashes[0]
+// merkleNode returns the hash of an internal merkle node with the given children
+func merkleNode(left, right []byte, version uint32) []byte {
+	h := sha256.New()
+	if !legacyMerkle(version) {
+		h.Write([]byte{merkleNodePrefix})
 	}
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
 
-	var nextLevel [][]byte
+// nextMerkleLevel pairs up the nodes of one tree level. With an odd number of nodes
+// the domain-separated tree promotes the last one unchanged; hashing it with itself,
+// as the legacy tree does, gives a list and the same list with its tail repeated the
+// same root.
+func nextMerkleLevel(hashes [][]byte, version uint32) [][]byte {
+	nextLevel := make([][]byte, 0, (len(hashes)+1)/2)
 	for i := 0; i < len(hashes); i += 2 {
-		if i+1 < len(hashes) {
-			// Hash pair together
-			combined := append(hashes[i], hashes[i+1]...)
-			hash := sha256.Sum256(combined)
-			nextLevel = append(nextLevel, hash[:])
-		} else {
-			// Odd number, hash with itself
-			combined := append(hashes[i], hashes[i]...)
-			hash := sha256.Sum256(combined)
-			nextLevel = append(nextLevel, hash[:])
+		switch {
+		case i+1 < len(hashes):
+			nextLevel = append(nextLevel, merkleNode(hashes[i], hashes[i+1], version))
+		case legacyMerkle(version):
+			nextLevel = append(nextLevel, merkleNode(hashes[i], hashes[i], version))
+		default:
+			nextLevel = append(nextLevel, hashes[i])
 		}
 	}
+	return nextLevel
+}
 
-	return buildMerkleTree(nextLevel)
+// buildMerkleTree builds a merkle tree from a list of leaf hashes
+func buildMerkleTree(hashes [][]byte, version uint32) []byte {
+	if len(hashes) == 0 {
+		return make([]byte, 32)
+	}
+
+	for len(hashes) > 1 {
+		hashes = nextMerkleLevel(hashes, version)
+	}
+	return hashes[0]
 }
 
 // Size returns the approximate size of the block in bytes
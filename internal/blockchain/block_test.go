@@ -0,0 +1,37 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestBuildMerkleTreeLeavesInputsUnmodified(t *testing.T) {
+	// Each hash is a window into one buffer, so its spare capacity is the next hash:
+	// appending to one in place would overwrite its neighbour
+	const count = 5
+	buf := make([]byte, 0, count*sha256.Size)
+	for i := range count {
+		sum := sha256.Sum256([]byte{byte(i)})
+		buf = append(buf, sum[:]...)
+	}
+	shared := make([][]byte, count)
+	separate := make([][]byte, count)
+	for i := range shared {
+		shared[i] = buf[i*sha256.Size : (i+1)*sha256.Size]
+		separate[i] = bytes.Clone(shared[i])
+	}
+	original := bytes.Clone(buf)
+
+	for _, version := range []uint32{1, MerkleTreeVersion} {
+		want := buildMerkleTree(separate, version)
+		for run := range 2 {
+			if got := buildMerkleTree(shared, version); !bytes.Equal(got, want) {
+				t.Errorf("version %d run %d: root %x, want %x", version, run, got, want)
+			}
+		}
+	}
+	if !bytes.Equal(buf, original) {
+		t.Error("buildMerkleTree modified its input hashes")
+	}
+}
@@ -0,0 +1,68 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrCASMismatch is returned when a CAS operation's key doesn't hold the expected value
+var ErrCASMismatch = errors.New("compare-and-set expectation failed")
+
+// NewCASOperation creates a CAS operation that sets key to value only if it currently
+// holds expected. An empty expected value requires the key to be absent.
+func NewCASOperation(key string, expected, value []byte) *KVOperation {
+	return &KVOperation{
+		Type:     OpTypeCAS,
+		Key:      key,
+		Value:    value,
+		Expected: expected,
+	}
+}
+
+// HasCASOperations returns true if the transaction contains any CAS operations
+func (tx *Transaction) HasCASOperations() bool {
+	if tx.Data == nil {
+		return false
+	}
+	for _, op := range tx.Data.Operations {
+		if op.Type == OpTypeCAS {
+			return true
+		}
+	}
+	return false
+}
+
+// applyCASOperation sets the key if it holds the expected value
+func (c *Chain) applyCASOperation(state *State, op *KVOperation) error {
+	current, exists := state.Get(op.Key)
+	if len(op.Expected) == 0 {
+		if exists {
+			return fmt.Errorf("%w: key %s exists", ErrCASMismatch, op.Key)
+		}
+	} else if !exists || !bytes.Equal(current, op.Expected) {
+		return fmt.Errorf("%w: key %s", ErrCASMismatch, op.Key)
+	}
+
+	return c.setState(state, op.Key, op.Value)
+}
+
+// hasConditionalOperations reports whether the transaction has operations whose
+// success depends on the state they run against (CAS and INCREMENT)
+func (tx *Transaction) hasConditionalOperations() bool {
+	if tx.Data == nil {
+		return false
+	}
+	for _, op := range tx.Data.Operations {
+		if op.Type == OpTypeCAS || op.Type == OpTypeIncrement {
+			return true
+		}
+	}
+	return false
+}
+
+// isConditionFailure reports whether err is a CAS or INCREMENT failure, which fails
+// the transaction rather than the block
+func isConditionFailure(err error) bool {
+	return errors.Is(err, ErrCASMismatch) || errors.Is(err, ErrCounterUnderflow) || errors.Is(err, ErrInvalidCounter)
+}
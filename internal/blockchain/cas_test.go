@@ -0,0 +1,91 @@
+package blockchain_test
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+// TestFailedConditionIsIncludedAndCharged checks that a transaction whose CAS or
+// INCREMENT fails is included but reverted: it pays its fee and uses its nonce, its
+// receipt reports the failure, and none of its operations are applied.
+func TestFailedConditionIsIncludedAndCharged(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	config := tokenGenesis(authority, user, true)
+	chain := newTestChain(t, config)
+
+	staleCAS := newTestTx(t, user, "", 0,
+		setOp("before", "v"),
+		blockchain.NewCASOperation("greeting", []byte("stale"), []byte("bye")),
+	)
+	underflow := newTestTx(t, user, "", 1,
+		setOp("counted", "v"),
+		blockchain.NewIncrementOperation("counter", big.NewInt(-1)),
+	)
+	applied := newTestTx(t, user, "", 2,
+		blockchain.NewCASOperation("greeting", []byte("hello"), []byte("bye")),
+	)
+
+	before := balanceOf(t, chain, user.address)
+	block := addBlock(t, chain, authority, staleCAS, underflow, applied)
+	if len(block.Transactions) != 3 {
+		t.Fatalf("block has %d transactions, want 3", len(block.Transactions))
+	}
+
+	tests := []struct {
+		name    string
+		tx      *blockchain.Transaction
+		success bool
+		err     string
+	}{
+		{name: "stale CAS", tx: staleCAS, err: blockchain.ErrCASMismatch.Error()},
+		{name: "counter underflow", tx: underflow, err: blockchain.ErrCounterUnderflow.Error()},
+		{name: "matching CAS", tx: applied, success: true},
+	}
+
+	paid := new(big.Int)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receipt, err := chain.GetReceipt(tt.tx.ID)
+			if err != nil {
+				t.Fatalf("GetReceipt: %v", err)
+			}
+			if receipt.Success != tt.success {
+				t.Errorf("success = %v, want %v", receipt.Success, tt.success)
+			}
+			if !strings.Contains(receipt.Error, tt.err) {
+				t.Errorf("error = %q, want it to mention %q", receipt.Error, tt.err)
+			}
+			if receipt.GasFee.Sign() <= 0 {
+				t.Errorf("gas fee = %s, want a fee charged", receipt.GasFee)
+			}
+			paid.Add(paid, receipt.GasFee)
+		})
+	}
+
+	if spent := new(big.Int).Sub(before, balanceOf(t, chain, user.address)); spent.Cmp(paid) != 0 {
+		t.Errorf("sender spent %s, want the %s in fees", spent, paid)
+	}
+	if chain.GetNonce(user.address) != 3 {
+		t.Errorf("nonce = %d, want 3", chain.GetNonce(user.address))
+	}
+	for _, key := range []string{"before", "counted", "counter"} {
+		if _, err := chain.GetState(key); err == nil {
+			t.Errorf("%s was written by a failed transaction", key)
+		}
+	}
+	if value, err := chain.GetState("greeting"); err != nil || string(value) != "bye" {
+		t.Errorf("greeting = %q, %v; want the matching CAS applied", value, err)
+	}
+
+	// A peer replaying the block reaches the same state
+	peer := newTestChain(t, config)
+	if err := peer.AddBlock(block); err != nil {
+		t.Fatalf("peer rejected the block: %v", err)
+	}
+	if receipt, err := peer.GetReceipt(staleCAS.ID); err != nil || receipt.Success {
+		t.Errorf("peer receipt = %+v, %v; want a failed receipt", receipt, err)
+	}
+}
@@ -2,29 +2,40 @@ package blockchain
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"errors"
 	"fmt"
+	"maps"
 	"math/big"
 	"sort"
-	"strings"
 	"sync"
 )
 
 // Storage interface for blockchain data persistence
 type Storage interface {
-	SaveBlock(block *Block) error
+	CommitBlock(commit *BlockCommit) error
 	GetBlock(hash []byte) (*Block, error)
 	GetBlockByHeight(height uint64) (*Block, error)
-	SaveTransaction(tx *Transaction) error
 	GetTransaction(hash []byte) (*Transaction, error)
+	UnindexTransaction(tx *Transaction, height uint64) error
+	GetTransactionsByKey(key string, limit int) ([]*Transaction, error)
+	GetTransfersByAddress(address string, limit int) ([]*Transaction, error)
+	GetTransactionsByAddress(address string, limit int) ([]*Transaction, error)
 	SaveState(key string, value []byte) error
+	SaveStateBatch(sets map[string][]byte, deletes []string) error
 	GetState(key string) ([]byte, error)
 	DeleteState(key string) error
 	GetLatestBlockHeight() (uint64, error)
 	SaveBlockHeight(height uint64) error
+	DeleteBlockHeight(height uint64) error
+	PruneBelow(height uint64) error
+	SaveStateSnapshot(data []byte) error
+	GetStateSnapshot() ([]byte, error)
+	SaveBlockWeight(hash []byte, weight uint64) error
+	GetBlockWeight(hash []byte) (uint64, error)
 	ScanStateByPrefix(prefix string, limit int) (map[string][]byte, error)
 	GetAllStateKeys(limit int) ([]string, error)
+	GetReceipt(hash []byte) (*TransactionReceipt, error)
+	DeleteReceipt(hash []byte) error
 	Close() error
 }
 
@@ -63,8 +74,8 @@ func (s *State) Delete(key string) {
 	delete(s.data, key)
 }
 
-// CalculateRoot calculates the merkle root of the state
-func (s *State) CalculateRoot() []byte {
+// CalculateRoot calculates the merkle root of the state with the tree of blocks of version
+func (s *State) CalculateRoot(version uint32) []byte {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -82,12 +93,10 @@ func (s *State) CalculateRoot() []byte {
 	// Create merkle tree of state entries
 	hashes := make([][]byte, len(keys))
 	for i, k := range keys {
-		entry := append([]byte(k), s.data[k]...)
-		hash := sha256.Sum256(entry)
-		hashes[i] = hash[:]
+		hashes[i] = stateLeaf(k, s.data[k], version)
 	}
 
-	return buildMerkleTree(hashes)
+	return buildMerkleTree(hashes, version)
 }
 
 // Clone creates a deep copy of the state
@@ -108,11 +117,38 @@ type Chain struct {
 	storage      Storage
 	currentBlock *Block
 	height       uint64
+	weight       uint64        // Cumulative weight of the canonical chain
+	counters     chainCounters // Running totals for GetStats
 	state        *State
-	authorities  []string
+	authorities  *AuthoritySet
 	nonces       map[string]uint64 // Track nonces per address
 	gasConfig    *GasConfig        // Gas fee configuration (nil for legacy chains)
 	tokenConfig  *TokenConfig      // Token configuration (nil for legacy chains)
+	keyPolicy    *KeyPolicy        // Who may write which keys (nil allows anyone)
+	chainID      string            // Chain ID blocks and transactions must carry
+	params       *ConsensusParams  // Block limits from the genesis file
+
+	// onCommit is called after a block is added, outside the lock
+	onCommit func(block *Block)
+
+	// validateProducer checks a block's producer against the consensus schedule, under the lock
+	validateProducer func(block *Block, previous *Block) error
+
+	// Blocks on competing branches by hash, and the reorg callback (called outside the lock)
+	sideBlocks map[string]*sideBlock
+	onReorg    func(reorg *Reorg)
+
+	// Recent state snapshots, oldest first, used to revert without replaying from genesis
+	snapshots []*stateSnapshot
+
+	// Saved snapshot replacing the pruned blocks below its height (nil if none are pruned)
+	base *stateSnapshot
+
+	// Live state changes not yet written to storage, flushed once per block
+	writes *stateWrites
+
+	// Set while rebuildState replays blocks, when storage holds a later state than the live one
+	rebuilding bool
 }
 
 // NewChain creates a new blockchain
@@ -120,8 +156,12 @@ func NewChain(storage Storage, authorities []string) *Chain {
 	return &Chain{
 		storage:     storage,
 		state:       NewState(),
-		authorities: authorities,
+		authorities: NewAuthoritySet(authorities),
 		nonces:      make(map[string]uint64),
+		counters:    newChainCounters(),
+		params:      DefaultConsensusParams(),
+		sideBlocks:  make(map[string]*sideBlock),
+		writes:      newStateWrites(),
 	}
 }
 
@@ -130,10 +170,14 @@ func NewChainWithConfig(storage Storage, authorities []string, gasConfig *GasCon
 	return &Chain{
 		storage:     storage,
 		state:       NewState(),
-		authorities: authorities,
+		authorities: NewAuthoritySet(authorities),
 		nonces:      make(map[string]uint64),
+		counters:    newChainCounters(),
 		gasConfig:   gasConfig,
 		tokenConfig: tokenConfig,
+		params:      DefaultConsensusParams(),
+		sideBlocks:  make(map[string]*sideBlock),
+		writes:      newStateWrites(),
 	}
 }
 
@@ -144,13 +188,53 @@ func (c *Chain) SetGasConfig(config *GasConfig) {
 	c.gasConfig = config
 }
 
-// GetGasConfig returns the gas configuration
+// GetGasConfig returns the gas configuration in effect, or nil if the chain charges
+// no fees (including when its token config doesn't enable accounting)
 func (c *Chain) GetGasConfig() *GasConfig {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
+	return c.feeConfig()
+}
+
+// feeConfig returns the gas configuration blocks are charged under: nil unless
+// accounting is enabled, since chains without it never charged fees (caller must
+// hold c.mu)
+func (c *Chain) feeConfig() *GasConfig {
+	if !c.tokenAccounting() {
+		return nil
+	}
 	return c.gasConfig
 }
 
+// tokenAccounting reports whether the chain's token config enables accounting
+// (caller must hold c.mu)
+func (c *Chain) tokenAccounting() bool {
+	return c.tokenConfig != nil && c.tokenConfig.Accounting
+}
+
+// SetBlockCommitHandler sets a callback invoked after each block is added to the chain
+func (c *Chain) SetBlockCommitHandler(handler func(block *Block)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onCommit = handler
+}
+
+// SetReorgHandler sets a callback invoked when the chain switches to a competing branch.
+// It runs before the block commit handler is called for the branch's blocks.
+func (c *Chain) SetReorgHandler(handler func(reorg *Reorg)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onReorg = handler
+}
+
+// SetProducerValidator sets a check that a new block was produced by the authority
+// whose turn it is. It is called with the chain lock held and must not call back into the chain.
+func (c *Chain) SetProducerValidator(validator func(block *Block, previous *Block) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.validateProducer = validator
+}
+
 // SetTokenConfig sets the token configuration
 func (c *Chain) SetTokenConfig(config *TokenConfig) {
 	c.mu.Lock()
@@ -169,7 +253,8 @@ func (c *Chain) GetTokenConfig() *TokenConfig {
 func (c *Chain) HasGasFees() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.gasConfig != nil && !c.gasConfig.IsZeroFee()
+	gasConfig := c.feeConfig()
+	return gasConfig != nil && !gasConfig.IsZeroFee()
 }
 
 // Initialize initializes the chain with a genesis block
@@ -194,28 +279,30 @@ func (c *Chain) Initialize(genesisBlock *Block) error {
 	}
 
 	// Update state root in genesis block
-	genesisBlock.Header.StateRoot = c.state.CalculateRoot()
+	genesisBlock.Header.StateRoot = c.state.CalculateRoot(genesisBlock.Header.Version)
 
-	// Save genesis block
-	if err := c.storage.SaveBlock(genesisBlock); err != nil {
+	// Save genesis block, its transactions and the state they set
+	if err := c.writeBlock(genesisBlock, nil); err != nil {
 		return fmt.Errorf("failed to save genesis block: %w", err)
 	}
 
-	// Save transactions
-	for _, tx := range genesisBlock.Transactions {
-		if err := c.storage.SaveTransaction(tx); err != nil {
-			return fmt.Errorf("failed to save genesis transaction: %w", err)
-		}
+	// Save genesis weight
+	weight := BlockWeight(genesisBlock)
+	if err := c.storage.SaveBlockWeight(genesisBlock.Hash(), weight); err != nil {
+		return fmt.Errorf("failed to save genesis weight: %w", err)
 	}
 
 	// Update chain state
 	c.currentBlock = genesisBlock
 	c.height = 0
+	c.weight = weight
+	c.counters = newChainCounters()
 
 	if err := c.storage.SaveBlockHeight(0); err != nil {
 		return fmt.Errorf("failed to save block height: %w", err)
 	}
 
+	c.snapshotState(c.height)
 	return nil
 }
 
@@ -239,27 +326,119 @@ func (c *Chain) LoadFromStorage() error {
 	c.currentBlock = block
 	c.height = height
 
-	// Rebuild state from genesis to current height
-	// For now, we'll need to replay all blocks
-	// In a production system, you'd want to store state snapshots
+	// Rebuild state from genesis, or from the saved snapshot if blocks were pruned
+	if err := c.loadBaseSnapshot(); err != nil {
+		return fmt.Errorf("failed to load state snapshot: %w", err)
+	}
 	return c.rebuildState()
 }
 
-// rebuildState rebuilds the state by replaying all blocks
+// rebuildState rebuilds the state by replaying all blocks, starting from the saved
+// snapshot if blocks were pruned
+// It also recomputes cumulative block weights, backfilling any that are missing
 func (c *Chain) rebuildState() error {
+	c.rebuilding = true
+	defer func() { c.rebuilding = false }()
+
 	c.state = NewState()
 	c.nonces = make(map[string]uint64)
+	c.weight = 0
+	c.counters = newChainCounters()
+	c.snapshots = nil
+	c.writes = newStateWrites()
+
+	start := uint64(0)
+	if c.base != nil {
+		base := c.base.clone()
+		c.state, c.nonces, c.weight, c.counters = base.state, base.nonces, base.weight, base.counters
+		start = base.height + 1
+	}
 
-	// Replay all blocks from genesis to current height
-	for h := uint64(0); h <= c.height; h++ {
+	// Replay the blocks up to the current height
+	for h := start; h <= c.height; h++ {
 		block, err := c.storage.GetBlockByHeight(h)
 		if err != nil {
 			return fmt.Errorf("failed to load block at height %d: %w", h, err)
 		}
 
-		if err := c.applyTransactions(block.Transactions); err != nil {
+		fees, err := c.ApplyTransactionsWithFees(c.state, block.Transactions, block.Header.ProducerAddr)
+		if err != nil {
 			return fmt.Errorf("failed to apply transactions at height %d: %w", h, err)
 		}
+		if err := c.flushState(); err != nil {
+			return err
+		}
+
+		c.weight += BlockWeight(block)
+		c.counters.add(block, fees)
+		if err := c.storage.SaveBlockWeight(block.Hash(), c.weight); err != nil {
+			return fmt.Errorf("failed to save block weight at height %d: %w", h, err)
+		}
+
+		c.snapshotState(h)
+	}
+
+	return nil
+}
+
+// ChainVerificationError reports the first stored block that failed verification
+type ChainVerificationError struct {
+	Height uint64
+	Err    error
+}
+
+func (e *ChainVerificationError) Error() string {
+	return fmt.Sprintf("chain verification failed at height %d: %v", e.Height, e.Err)
+}
+
+func (e *ChainVerificationError) Unwrap() error {
+	return e.Err
+}
+
+// VerifyChain re-validates every stored block from genesis to the tip: height and
+// linkage, producer, signature, transactions and merkle root. It stops at the first
+// inconsistency and returns it as a *ChainVerificationError.
+func (c *Chain) VerifyChain() error {
+	c.mu.RLock()
+	height := c.height
+	authorities := c.authorities
+	chainID := c.chainID
+	params := c.params
+	start := uint64(0)
+	if c.base != nil {
+		start = c.base.height
+	}
+	c.mu.RUnlock()
+
+	// Blocks below the saved snapshot are pruned, so verification starts at its block
+	var previous *Block
+	if start > 0 {
+		block, err := c.storage.GetBlockByHeight(start)
+		if err != nil {
+			return &ChainVerificationError{Height: start, Err: fmt.Errorf("failed to load block: %w", err)}
+		}
+		previous = block
+		start++
+	}
+
+	for h := start; h <= height; h++ {
+		block, err := c.storage.GetBlockByHeight(h)
+		if err != nil {
+			return &ChainVerificationError{Height: h, Err: fmt.Errorf("failed to load block: %w", err)}
+		}
+
+		if block.Header == nil || block.Header.Height != h {
+			return &ChainVerificationError{Height: h, Err: errors.New("stored block has the wrong height")}
+		}
+
+		if err := ValidateBlock(block, previous, authorities, params); err != nil {
+			return &ChainVerificationError{Height: h, Err: err}
+		}
+		if err := checkBlockChainID(block, chainID); err != nil {
+			return &ChainVerificationError{Height: h, Err: err}
+		}
+
+		previous = block
 	}
 
 	return nil
@@ -267,49 +446,107 @@ func (c *Chain) rebuildState() error {
 
 // AddBlock adds a validated block to the chain
 func (c *Chain) AddBlock(block *Block) error {
+	if err := c.addBlock(block); err != nil {
+		return err
+	}
+
+	c.notifyCommit(block)
+	return nil
+}
+
+// notifyCommit calls the block commit handler for each newly canonical block
+func (c *Chain) notifyCommit(blocks ...*Block) {
+	c.mu.RLock()
+	onCommit := c.onCommit
+	c.mu.RUnlock()
+
+	if onCommit == nil {
+		return
+	}
+	for _, block := range blocks {
+		onCommit(block)
+	}
+}
+
+// addBlock validates a block and applies it to the state and storage
+func (c *Chain) addBlock(block *Block) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	return c.appendBlock(block)
+}
+
+// appendBlock validates a block on top of the current tip and applies it to the
+// state and storage (caller must hold c.mu)
+func (c *Chain) appendBlock(block *Block) error {
 	// Validate block
-	if err := ValidateBlock(block, c.currentBlock, c.authorities); err != nil {
-		return fmt.Errorf("block validation failed: %w", err)
+	if err := ValidateBlock(block, c.currentBlock, c.authorities, c.params); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBlock, err)
+	}
+	if err := checkBlockChainID(block, c.chainID); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBlock, err)
+	}
+
+	if c.validateProducer != nil {
+		if err := c.validateProducer(block, c.currentBlock); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidBlock, err)
+		}
+	}
+
+	// A transaction is applied at most once
+	if err := c.checkDuplicateTransactions(block, c.height, make(map[string]bool)); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBlock, err)
 	}
 
 	// Validate state root by applying transactions to a temporary state
 	tempState := c.state.Clone()
-	if err := c.applyTransactionsToState(tempState, block.Transactions); err != nil {
-		return fmt.Errorf("failed to apply transactions: %w", err)
+	if _, err := c.ApplyTransactionsWithFees(tempState, block.Transactions, block.Header.ProducerAddr); err != nil {
+		return fmt.Errorf("%w: failed to apply transactions: %w", ErrInvalidBlock, err)
 	}
 
-	calculatedStateRoot := tempState.CalculateRoot()
+	calculatedStateRoot := tempState.CalculateRoot(block.Header.Version)
 	if !bytes.Equal(calculatedStateRoot, block.Header.StateRoot) {
-		return errors.New("invalid state root")
+		return fmt.Errorf("%w: invalid state root", ErrInvalidBlock)
+	}
+
+	if err := c.commitBlock(block); err != nil {
+		return err
 	}
 
+	c.pruneSideBlocks()
+	return nil
+}
+
+// commitBlock applies a validated block to the live state, stores it and makes it
+// the tip (caller must hold c.mu)
+func (c *Chain) commitBlock(block *Block) error {
 	// Apply transactions to actual state
-	if err := c.applyTransactions(block.Transactions); err != nil {
+	fees, outcomes, err := c.applyTransactionsWithFees(c.state, block.Transactions, block.Header.ProducerAddr)
+	if err != nil {
 		return fmt.Errorf("failed to apply transactions: %w", err)
 	}
 
-	// Save block and transactions
-	if err := c.storage.SaveBlock(block); err != nil {
-		return fmt.Errorf("failed to save block: %w", err)
+	// Save block, transactions, their index entries and receipts, and the state changes
+	if err := c.writeBlock(block, outcomes); err != nil {
+		return err
 	}
 
-	for _, tx := range block.Transactions {
-		if err := c.storage.SaveTransaction(tx); err != nil {
-			return fmt.Errorf("failed to save transaction: %w", err)
-		}
+	weight := c.weight + BlockWeight(block)
+	if err := c.storage.SaveBlockWeight(block.Hash(), weight); err != nil {
+		return fmt.Errorf("failed to save block weight: %w", err)
 	}
 
 	// Update chain state
 	c.currentBlock = block
 	c.height = block.Header.Height
+	c.weight = weight
+	c.counters.add(block, fees)
 
 	if err := c.storage.SaveBlockHeight(c.height); err != nil {
 		return fmt.Errorf("failed to save block height: %w", err)
 	}
 
+	c.snapshotState(c.height)
 	return nil
 }
 
@@ -322,35 +559,8 @@ func (c *Chain) applyTransactions(transactions []*Transaction) error {
 func (c *Chain) applyTransactionsToState(state *State, transactions []*Transaction) error {
 	for _, tx := range transactions {
 		for _, op := range tx.Data.Operations {
-			switch op.Type {
-			case OpTypeSet:
-				state.Set(op.Key, op.Value)
-				// Also persist to storage
-				if state == c.state {
-					if err := c.storage.SaveState(op.Key, op.Value); err != nil {
-						return fmt.Errorf("failed to save state: %w", err)
-					}
-				}
-			case OpTypeDelete:
-				state.Delete(op.Key)
-				// Also delete from storage
-				if state == c.state {
-					if err := c.storage.DeleteState(op.Key); err != nil {
-						return fmt.Errorf("failed to delete state: %w", err)
-					}
-				}
-			case OpTypeMint:
-				// MINT operation: add amount to existing balance
-				if err := c.applyMintOperation(state, op); err != nil {
-					return err
-				}
-			case OpTypeTransfer:
-				// TRANSFER operation: deduct from sender and add to recipient
-				if err := c.applyTransferOperation(state, tx.From, op); err != nil {
-					return err
-				}
-			default:
-				return fmt.Errorf("unknown operation type: %s", op.Type)
+			if err := c.applyOperation(state, tx, op); err != nil {
+				return err
 			}
 		}
 
@@ -363,31 +573,112 @@ func (c *Chain) applyTransactionsToState(state *State, transactions []*Transacti
 	return nil
 }
 
-// applyMintOperation applies a MINT operation to state
-func (c *Chain) applyMintOperation(state *State, op *KVOperation) error {
-	// Get current balance
-	currentData, _ := state.Get(op.Key)
-	currentBalance, err := BalanceFromBytes(currentData)
+// applyOperation applies a single operation of a transaction to state
+func (c *Chain) applyOperation(state *State, tx *Transaction, op *KVOperation) error {
+	switch op.Type {
+	case OpTypeSet:
+		return c.setState(state, op.Key, op.Value)
+	case OpTypeDelete:
+		state.Delete(op.Key)
+		// Also delete from storage when the block is flushed
+		if state == c.state {
+			c.writes.delete(op.Key)
+		}
+		return nil
+	case OpTypeMint:
+		// MINT operation: add amount to existing balance
+		return c.applyMintOperation(state, op)
+	case OpTypeTransfer:
+		// TRANSFER operation: deduct from sender and add to recipient
+		return c.applyTransferOperation(state, tx.From, op)
+	case OpTypeBurn:
+		// BURN operation: deduct from sender and reduce total supply
+		return c.applyBurnOperation(state, tx.From, op)
+	case OpTypeCAS:
+		// CAS operation: SET only if the key holds the expected value
+		return c.applyCASOperation(state, op)
+	case OpTypeIncrement:
+		// INCREMENT operation: add a signed delta to an integer counter
+		return c.applyIncrementOperation(state, op)
+	default:
+		return fmt.Errorf("unknown operation type: %s", op.Type)
+	}
+}
+
+// setState sets a key in state, queueing it for storage if this is the actual state
+func (c *Chain) setState(state *State, key string, value []byte) error {
+	state.Set(key, value)
+	if state == c.state {
+		c.writes.set(key, value)
+	}
+	return nil
+}
+
+// getBalance reads a balance key from state, treating missing or invalid data as zero.
+// A key that neither state nor the live state holds, and that isn't pending deletion,
+// is read through to storage, except while rebuildState replays blocks. This is the
+// one balance read path, for queries and block application alike (caller must hold c.mu).
+func (c *Chain) getBalance(state *State, balanceKey string) *Balance {
+	data, exists := state.Get(balanceKey)
+	if !exists && state != c.state {
+		_, exists = c.state.Get(balanceKey)
+	}
+	if !exists && !c.rebuilding && !c.writes.isDeleted(balanceKey) {
+		data, _ = c.storage.GetState(balanceKey)
+	}
+
+	balance, err := BalanceFromBytes(data)
 	if err != nil {
-		currentBalance = NewBalance(big.NewInt(0))
+		return NewBalance(big.NewInt(0))
+	}
+	return balance
+}
+
+// creditBalance adds amount to the balance stored under balanceKey, failing with
+// ErrBalanceOverflow if the result exceeds MaxBalance
+func (c *Chain) creditBalance(state *State, balanceKey string, amount *big.Int) error {
+	balance := c.getBalance(state, balanceKey)
+	balance.Add(amount)
+	if balance.Amount.Cmp(MaxBalance) > 0 {
+		return fmt.Errorf("%s: %w", balanceKey, ErrBalanceOverflow)
 	}
+	return c.setState(state, balanceKey, balance.ToBytes())
+}
 
-	// Add minted amount
-	mintAmount := new(big.Int).SetBytes(op.Value)
-	currentBalance.Add(mintAmount)
+// adjustTotalSupply adds delta (which may be negative) to the tracked total supply.
+// Chains without accounting don't track the supply.
+func (c *Chain) adjustTotalSupply(state *State, delta *big.Int) error {
+	if !c.tokenAccounting() {
+		return nil
+	}
 
-	// Save new balance
-	newData := currentBalance.ToBytes()
-	state.Set(op.Key, newData)
+	data, _ := state.Get(TotalSupplyKey)
+	supply, err := BalanceFromBytes(data)
+	if err != nil {
+		supply = NewBalance(big.NewInt(0))
+	}
 
-	// Persist to storage if this is the actual state
-	if state == c.state {
-		if err := c.storage.SaveState(op.Key, newData); err != nil {
-			return fmt.Errorf("failed to save minted balance: %w", err)
+	if delta.Sign() >= 0 {
+		supply.Add(delta)
+		if supply.Amount.Cmp(MaxBalance) > 0 {
+			return fmt.Errorf("total supply: %w", ErrBalanceOverflow)
 		}
+	} else if err := supply.Sub(new(big.Int).Neg(delta)); err != nil {
+		return fmt.Errorf("total supply underflow: %w", err)
 	}
 
-	return nil
+	return c.setState(state, TotalSupplyKey, supply.ToBytes())
+}
+
+// applyMintOperation applies a MINT operation to state
+func (c *Chain) applyMintOperation(state *State, op *KVOperation) error {
+	mintAmount := new(big.Int).SetBytes(op.Value)
+
+	if err := c.creditBalance(state, op.Key, mintAmount); err != nil {
+		return fmt.Errorf("failed to save minted balance: %w", err)
+	}
+
+	return c.adjustTotalSupply(state, mintAmount)
 }
 
 // applyTransferOperation applies a TRANSFER operation to state
@@ -397,111 +688,107 @@ func (c *Chain) applyTransferOperation(state *State, senderAddr string, op *KVOp
 
 	// Deduct from sender
 	senderKey := BalanceKey(senderAddr)
-	senderData, _ := state.Get(senderKey)
-	senderBalance, err := BalanceFromBytes(senderData)
-	if err != nil {
-		senderBalance = NewBalance(big.NewInt(0))
-	}
-
+	senderBalance := c.getBalance(state, senderKey)
 	if err := senderBalance.Sub(amount); err != nil {
 		return fmt.Errorf("insufficient balance for transfer: %w", err)
 	}
 
-	state.Set(senderKey, senderBalance.ToBytes())
-	if state == c.state {
-		if err := c.storage.SaveState(senderKey, senderBalance.ToBytes()); err != nil {
-			return fmt.Errorf("failed to save sender balance: %w", err)
-		}
+	if err := c.setState(state, senderKey, senderBalance.ToBytes()); err != nil {
+		return fmt.Errorf("failed to save sender balance: %w", err)
 	}
 
 	// Add to recipient (op.Key is the recipient's balance key)
-	recipientData, _ := state.Get(op.Key)
-	recipientBalance, err := BalanceFromBytes(recipientData)
-	if err != nil {
-		recipientBalance = NewBalance(big.NewInt(0))
+	if err := c.creditBalance(state, op.Key, amount); err != nil {
+		return fmt.Errorf("failed to save recipient balance: %w", err)
 	}
 
-	recipientBalance.Add(amount)
+	return nil
+}
+
+// applyBurnOperation applies a BURN operation to state
+// It deducts from the sender without crediting anyone
+func (c *Chain) applyBurnOperation(state *State, senderAddr string, op *KVOperation) error {
+	amount := new(big.Int).SetBytes(op.Value)
 
-	state.Set(op.Key, recipientBalance.ToBytes())
-	if state == c.state {
-		if err := c.storage.SaveState(op.Key, recipientBalance.ToBytes()); err != nil {
-			return fmt.Errorf("failed to save recipient balance: %w", err)
-		}
+	senderKey := BalanceKey(senderAddr)
+	senderBalance := c.getBalance(state, senderKey)
+	if err := senderBalance.Sub(amount); err != nil {
+		return fmt.Errorf("insufficient balance for burn: %w", err)
 	}
 
-	return nil
+	if err := c.setState(state, senderKey, senderBalance.ToBytes()); err != nil {
+		return fmt.Errorf("failed to save sender balance: %w", err)
+	}
+
+	return c.adjustTotalSupply(state, new(big.Int).Neg(amount))
 }
 
-// ApplyTransactionsWithFees applies transactions with gas fee deduction and collection
+// ApplyTransactionsWithFees applies transactions with gas fee deduction and collection,
+// then credits the collected fees and the block reward to the block producer.
+// Fees, rewards and supply tracking only apply when the token config enables
+// accounting; otherwise only the operations are applied, as on older chains.
 // Returns total fees collected and any error
 func (c *Chain) ApplyTransactionsWithFees(state *State, transactions []*Transaction, blockProducer string) (*big.Int, error) {
+	totalFees, _, err := c.applyTransactionsWithFees(state, transactions, blockProducer)
+	return totalFees, err
+}
+
+// applyTransactionsWithFees implements ApplyTransactionsWithFees, also returning the
+// outcome of each transaction for its receipt
+func (c *Chain) applyTransactionsWithFees(state *State, transactions []*Transaction, blockProducer string) (*big.Int, []txOutcome, error) {
 	totalFees := big.NewInt(0)
+	deleted := make(map[string]bool) // Balance keys deleted so far in this block
+	gasConfig := c.feeConfig()
+	outcomes := make([]txOutcome, 0, len(transactions))
 
 	for _, tx := range transactions {
+		// Each transaction runs against a copy of the keys it can touch and is committed
+		// only if it succeeds, so a failing operation never leaves state half-applied
+		keys := tx.touchedKeys()
+		txState := newTxState(state, keys)
+
+		var outcome txOutcome
+
 		// Skip fee deduction for genesis transactions
-		if !tx.IsGenesisTransaction() && c.gasConfig != nil {
-			txSize := tx.Size()
-			gasFee := c.gasConfig.CalculateGasFee(txSize)
+		var gasFee *big.Int
+		if !tx.IsGenesisTransaction() && gasConfig != nil {
+			gasFee = gasConfig.CalculateGasFee(tx.Size())
 
 			// Deduct fee from sender
 			senderKey := BalanceKey(tx.From)
-			senderData, _ := state.Get(senderKey)
-			senderBalance, err := BalanceFromBytes(senderData)
-			if err != nil {
-				senderBalance = NewBalance(big.NewInt(0))
-			}
-
+			senderBalance := c.getBalance(txState, senderKey)
 			if err := senderBalance.Sub(gasFee); err != nil {
-				return nil, fmt.Errorf("tx %s: insufficient balance for gas: %w", tx.HashString(), err)
+				return nil, nil, fmt.Errorf("tx %s: insufficient balance for gas: %w", tx.HashString(), err)
 			}
 
-			state.Set(senderKey, senderBalance.ToBytes())
-			if state == c.state {
-				if err := c.storage.SaveState(senderKey, senderBalance.ToBytes()); err != nil {
-					return nil, fmt.Errorf("failed to save sender balance: %w", err)
-				}
+			if err := c.setState(txState, senderKey, senderBalance.ToBytes()); err != nil {
+				return nil, nil, fmt.Errorf("failed to save sender balance: %w", err)
 			}
+		}
 
-			totalFees.Add(totalFees, gasFee)
+		// A transaction whose CAS expectation or INCREMENT fails is still included and
+		// pays its fee, but none of its operations are applied
+		var feeOnly *State
+		if tx.hasConditionalOperations() {
+			feeOnly = txState.Clone()
 		}
 
-		// Apply operations
-		for _, op := range tx.Data.Operations {
-			// Check authority for MINT operations
-			if op.Type == OpTypeMint && !tx.IsGenesisTransaction() {
-				if !c.IsAuthority(tx.From) {
-					return nil, fmt.Errorf("tx %s: only authorities can mint tokens", tx.HashString())
-				}
+		txDeleted := maps.Clone(deleted)
+		if err := c.applyTransactionOperations(txState, tx, txDeleted); err != nil {
+			if feeOnly == nil || !isConditionFailure(err) {
+				return nil, nil, err
 			}
+			txState, txDeleted = feeOnly, deleted
+			outcome.err = err
+		}
 
-			switch op.Type {
-			case OpTypeSet:
-				state.Set(op.Key, op.Value)
-				if state == c.state {
-					if err := c.storage.SaveState(op.Key, op.Value); err != nil {
-						return nil, fmt.Errorf("failed to save state: %w", err)
-					}
-				}
-			case OpTypeDelete:
-				state.Delete(op.Key)
-				if state == c.state {
-					if err := c.storage.DeleteState(op.Key); err != nil {
-						return nil, fmt.Errorf("failed to delete state: %w", err)
-					}
-				}
-			case OpTypeMint:
-				if err := c.applyMintOperation(state, op); err != nil {
-					return nil, err
-				}
-			case OpTypeTransfer:
-				if err := c.applyTransferOperation(state, tx.From, op); err != nil {
-					return nil, err
-				}
-			default:
-				return nil, fmt.Errorf("unknown operation type: %s", op.Type)
-			}
+		c.commitTxState(state, txState, keys)
+		deleted = txDeleted
+		if gasFee != nil {
+			totalFees.Add(totalFees, gasFee)
 		}
+		outcome.fee = gasFee
+		outcomes = append(outcomes, outcome)
 
 		// Update nonce
 		if state == c.state && !tx.IsGenesisTransaction() {
@@ -509,29 +796,136 @@ func (c *Chain) ApplyTransactionsWithFees(state *State, transactions []*Transact
 		}
 	}
 
+	// Burn collected fees if configured
+	if totalFees.Sign() > 0 && gasConfig.BurnFees {
+		if err := c.adjustTotalSupply(state, new(big.Int).Neg(totalFees)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Genesis (and unsigned) blocks earn neither fees nor rewards, and nothing is
+	// earned without accounting
+	if blockProducer == "" || blockProducer == GenesisAddress || !c.tokenAccounting() {
+		return totalFees, outcomes, nil
+	}
+
 	// Credit fees to block producer
-	if blockProducer != "" && blockProducer != GenesisAddress && totalFees.Sign() > 0 {
-		producerKey := BalanceKey(blockProducer)
-		producerData, _ := state.Get(producerKey)
-		producerBalance, err := BalanceFromBytes(producerData)
-		if err != nil {
-			producerBalance = NewBalance(big.NewInt(0))
+	earnedFees := big.NewInt(0)
+	if totalFees.Sign() > 0 && !gasConfig.BurnFees {
+		if err := c.creditBalance(state, BalanceKey(blockProducer), totalFees); err != nil {
+			return nil, nil, fmt.Errorf("failed to save producer balance: %w", err)
 		}
-		producerBalance.Add(totalFees)
+		earnedFees = totalFees
+	}
 
-		state.Set(producerKey, producerBalance.ToBytes())
-		if state == c.state {
-			if err := c.storage.SaveState(producerKey, producerBalance.ToBytes()); err != nil {
-				return nil, fmt.Errorf("failed to save producer balance: %w", err)
+	// Mint the block reward to the block producer
+	reward := c.blockReward()
+	if reward.Sign() > 0 {
+		if err := c.creditBalance(state, BalanceKey(blockProducer), reward); err != nil {
+			return nil, nil, fmt.Errorf("failed to save producer reward: %w", err)
+		}
+		if err := c.adjustTotalSupply(state, reward); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := c.creditProducerEarnings(state, blockProducer, earnedFees, reward); err != nil {
+		return nil, nil, fmt.Errorf("failed to save producer earnings: %w", err)
+	}
+
+	return totalFees, outcomes, nil
+}
+
+// applyTransactionOperations applies a transaction's operations in order, tracking
+// the balance keys it deletes in deleted. It stops at the first failing operation.
+func (c *Chain) applyTransactionOperations(state *State, tx *Transaction, deleted map[string]bool) error {
+	for _, op := range tx.Data.Operations {
+		// Check authority for MINT operations (chains without accounting leave this
+		// to mempool admission, as they always have)
+		if op.Type == OpTypeMint && !tx.IsGenesisTransaction() && c.tokenAccounting() {
+			if !c.isAuthority(tx.From) {
+				return fmt.Errorf("tx %s: only authorities can mint tokens", tx.HashString())
+			}
+		}
+
+		if err := c.checkKeyPermission(tx, op); err != nil {
+			return fmt.Errorf("tx %s: %w", tx.HashString(), err)
+		}
+
+		// Genesis may seed balance keys through its initial state
+		if c.locksBalanceKeys() && !tx.IsGenesisTransaction() && IsBalanceKey(op.Key) &&
+			(op.Type == OpTypeSet || op.Type == OpTypeCAS || op.Type == OpTypeDelete) {
+			return fmt.Errorf("tx %s: %s %s: %w", tx.HashString(), op.Type, op.Key, ErrBalanceKeyWrite)
+		}
+
+		if c.rejectsDeletedBalances() {
+			if key := deletedBalanceReference(tx, op, deleted); key != "" {
+				return fmt.Errorf("tx %s: %s: %w", tx.HashString(), key, ErrDeletedBalanceKey)
+			}
+		}
+
+		if err := c.applyOperation(state, tx, op); err != nil {
+			return fmt.Errorf("tx %s: %w", tx.HashString(), err)
+		}
+
+		// A SET recreates the key, so only a DELETE leaves it marked
+		if IsBalanceKey(op.Key) {
+			switch op.Type {
+			case OpTypeDelete:
+				deleted[op.Key] = true
+			case OpTypeSet, OpTypeCAS, OpTypeIncrement:
+				delete(deleted, op.Key)
 			}
 		}
 	}
 
-	return totalFees, nil
+	return nil
+}
+
+// rejectsDeletedBalances reports whether the chain uses DeletedBalanceReject
+func (c *Chain) rejectsDeletedBalances() bool {
+	return c.tokenConfig != nil && c.tokenConfig.DeletedBalancePolicy == DeletedBalanceReject
+}
+
+// locksBalanceKeys reports whether the chain uses LockBalanceKeys
+func (c *Chain) locksBalanceKeys() bool {
+	return c.tokenConfig != nil && c.tokenConfig.LockBalanceKeys
+}
+
+// deletedBalanceReference returns the balance key op debits or credits that was
+// deleted earlier in the block, or "" if there is none
+func deletedBalanceReference(tx *Transaction, op *KVOperation, deleted map[string]bool) string {
+	var keys []string
+	switch op.Type {
+	case OpTypeTransfer:
+		keys = []string{BalanceKey(tx.From), op.Key}
+	case OpTypeBurn:
+		keys = []string{BalanceKey(tx.From)}
+	case OpTypeMint:
+		keys = []string{op.Key}
+	}
+
+	for _, key := range keys {
+		if deleted[key] {
+			return key
+		}
+	}
+	return ""
+}
+
+// blockReward returns the configured per-block reward (zero if none)
+func (c *Chain) blockReward() *big.Int {
+	if c.tokenConfig == nil || c.tokenConfig.BlockReward == nil {
+		return big.NewInt(0)
+	}
+	return c.tokenConfig.BlockReward
 }
 
 // GetState retrieves a value from the current state
 func (c *Chain) GetState(key string) ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	value, exists := c.state.Get(key)
 	if !exists {
 		return nil, errors.New("key not found")
@@ -553,6 +947,35 @@ func (c *Chain) GetHeight() uint64 {
 	return c.height
 }
 
+// GetWeight returns the cumulative weight of the canonical chain
+func (c *Chain) GetWeight() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.weight
+}
+
+// GetBlockWeight returns the cumulative chain weight up to and including a block
+func (c *Chain) GetBlockWeight(hash []byte) (uint64, error) {
+	return c.storage.GetBlockWeight(hash)
+}
+
+// GetTipBranch returns the canonical chain tip for fork choice
+func (c *Chain) GetTipBranch() *Branch {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.tipBranch()
+}
+
+// tipBranch returns the canonical chain tip (caller must hold c.mu)
+func (c *Chain) tipBranch() *Branch {
+	return &Branch{
+		TipHash: c.currentBlock.Hash(),
+		Height:  c.height,
+		Weight:  c.weight,
+	}
+}
+
 // GetBlockByHeight retrieves a block by height
 func (c *Chain) GetBlockByHeight(height uint64) (*Block, error) {
 	return c.storage.GetBlockByHeight(height)
@@ -568,6 +991,21 @@ func (c *Chain) GetTransaction(hash []byte) (*Transaction, error) {
 	return c.storage.GetTransaction(hash)
 }
 
+// GetTransactionsByKey returns transactions that touched a state key, newest first
+func (c *Chain) GetTransactionsByKey(key string, limit int) ([]*Transaction, error) {
+	return c.storage.GetTransactionsByKey(key, limit)
+}
+
+// GetTransactionsByAddress returns transactions sent from an address, newest first
+func (c *Chain) GetTransactionsByAddress(address string, limit int) ([]*Transaction, error) {
+	return c.storage.GetTransactionsByAddress(address, limit)
+}
+
+// GetTransfersByAddress returns transactions that moved tokens to or from an address, newest first
+func (c *Chain) GetTransfersByAddress(address string, limit int) ([]*Transaction, error) {
+	return c.storage.GetTransfersByAddress(address, limit)
+}
+
 // GetNonce returns the next nonce for an address
 func (c *Chain) GetNonce(address string) uint64 {
 	c.mu.RLock()
@@ -582,12 +1020,25 @@ func (c *Chain) GetNonce(address string) uint64 {
 
 // GetStateRoot returns the current state root
 func (c *Chain) GetStateRoot() []byte {
-	return c.state.CalculateRoot()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.state.CalculateRoot(c.tipVersion())
+}
+
+// tipVersion returns the version of the tip block, whose tree the live state root
+// is calculated with (caller must hold c.mu)
+func (c *Chain) tipVersion() uint32 {
+	if c.currentBlock == nil {
+		return c.params.BlockVersion
+	}
+	return c.currentBlock.Header.Version
 }
 
-// CalculateStateRootWithTransactions calculates what the state root will be
-// after applying the given transactions, without modifying the actual state
-func (c *Chain) CalculateStateRootWithTransactions(transactions []*Transaction) ([]byte, error) {
+// CalculateStateRootWithTransactions calculates what the state root of the next block
+// will be after the given producer applies the given transactions, without modifying
+// the actual state. The root uses the tree of the chain's block_version.
+func (c *Chain) CalculateStateRootWithTransactions(transactions []*Transaction, producer string) ([]byte, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -595,12 +1046,12 @@ func (c *Chain) CalculateStateRootWithTransactions(transactions []*Transaction)
 	tempState := c.state.Clone()
 
 	// Apply transactions to temporary state
-	if err := c.applyTransactionsToState(tempState, transactions); err != nil {
+	if _, err := c.ApplyTransactionsWithFees(tempState, transactions, producer); err != nil {
 		return nil, err
 	}
 
 	// Calculate and return the root
-	return tempState.CalculateRoot(), nil
+	return tempState.CalculateRoot(c.params.BlockVersion), nil
 }
 
 // QueryStateByPrefix queries all state keys with a given prefix
@@ -617,7 +1068,21 @@ func (c *Chain) GetAllStateKeys(limit int) ([]string, error) {
 func (c *Chain) GetAuthorities() []string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return append([]string{}, c.authorities...)
+	return c.authorities.Addresses()
+}
+
+// SetAuthorities replaces the authorities new blocks are validated against
+func (c *Chain) SetAuthorities(authorities []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.authorities = NewAuthoritySet(authorities)
+}
+
+// GetAuthoritySet returns the normalized authority set used for validation
+func (c *Chain) GetAuthoritySet() *AuthoritySet {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.authorities
 }
 
 // IsAuthority checks if an address is an authority
@@ -625,32 +1090,21 @@ func (c *Chain) IsAuthority(address string) bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	normalizedAddr := strings.ToLower(address)
-	for _, auth := range c.authorities {
-		if strings.ToLower(auth) == normalizedAddr {
-			return true
-		}
-	}
-	return false
+	return c.isAuthority(address)
+}
+
+// isAuthority checks if an address is an authority (caller must hold c.mu)
+func (c *Chain) isAuthority(address string) bool {
+	return c.authorities.Contains(address)
 }
 
-// GetBalance returns the balance for an address
+// GetBalance returns the balance for an address, reading through to storage for a key
+// missing from the in-memory state, as block application does
 func (c *Chain) GetBalance(address string) (*big.Int, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	balanceKey := BalanceKey(address)
-	data, exists := c.state.Get(balanceKey)
-	if !exists {
-		return big.NewInt(0), nil
-	}
-
-	balance, err := BalanceFromBytes(data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse balance: %w", err)
-	}
-
-	return balance.Amount, nil
+	return c.getBalance(c.state, BalanceKey(address)).Amount, nil
 }
 
 // GetBalanceFromStorage returns the balance for an address from storage
@@ -669,12 +1123,30 @@ func (c *Chain) GetBalanceFromStorage(address string) (*big.Int, error) {
 	return balance.Amount, nil
 }
 
+// GetTotalSupply returns the tracked total token supply
+func (c *Chain) GetTotalSupply() *big.Int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	data, exists := c.state.Get(TotalSupplyKey)
+	if !exists {
+		return big.NewInt(0)
+	}
+
+	supply, err := BalanceFromBytes(data)
+	if err != nil {
+		return big.NewInt(0)
+	}
+	return supply.Amount
+}
+
 // EstimateGasFee estimates the gas fee for a transaction of given size
 func (c *Chain) EstimateGasFee(txSize int) *GasEstimate {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	if c.gasConfig == nil {
+	gasConfig := c.feeConfig()
+	if gasConfig == nil {
 		return &GasEstimate{
 			TransactionSize: txSize,
 			BaseFee:         big.NewInt(0),
@@ -683,16 +1155,17 @@ func (c *Chain) EstimateGasFee(txSize int) *GasEstimate {
 		}
 	}
 
-	return c.gasConfig.EstimateGas(txSize)
+	return gasConfig.EstimateGas(txSize)
 }
 
 // ChainInfo contains information about the chain
 type ChainInfo struct {
-	Height       uint64 `json:"height"`
-	CurrentHash  string `json:"current_hash"`
-	GenesisHash  string `json:"genesis_hash"`
-	Authorities  []string `json:"authorities"`
-	StateRoot    string `json:"state_root"`
+	Height      uint64   `json:"height"`
+	CurrentHash string   `json:"current_hash"`
+	GenesisHash string   `json:"genesis_hash"`
+	Authorities []string `json:"authorities"`
+	StateRoot   string   `json:"state_root"`
+	Weight      uint64   `json:"weight"`
 }
 
 // GetChainInfo returns information about the chain
@@ -713,7 +1186,8 @@ func (c *Chain) GetChainInfo() (*ChainInfo, error) {
 		Height:      c.height,
 		CurrentHash: fmt.Sprintf("0x%x", c.currentBlock.Hash()),
 		GenesisHash: fmt.Sprintf("0x%x", genesisBlock.Hash()),
-		Authorities: c.authorities,
-		StateRoot:   fmt.Sprintf("0x%x", c.GetStateRoot()),
+		Authorities: c.authorities.Addresses(),
+		StateRoot:   fmt.Sprintf("0x%x", c.state.CalculateRoot(c.tipVersion())),
+		Weight:      c.weight,
 	}, nil
 }
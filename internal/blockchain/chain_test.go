@@ -0,0 +1,99 @@
+package blockchain_test
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/consensus"
+)
+
+// TestProducedBlockReAdds produces a block the way a producing node does, from a
+// shuffled pool of transactions, and checks that the producer and a peer both accept
+// it: the state root preview must use the same order as the block body.
+func TestProducedBlockReAdds(t *testing.T) {
+	authority := newTestKey(t)
+	users := []*testKey{newTestKey(t), newTestKey(t), newTestKey(t), newTestKey(t)}
+
+	config := testGenesis(authority)
+	config.TokenConfig = &blockchain.TokenConfig{Name: "Podoru", Symbol: "PDR", Decimals: 18, Accounting: true}
+	config.GasConfig = &blockchain.GasConfigJSON{BaseFee: "1000", PerByteFee: "10"}
+	config.InitialBalances = make(map[string]string)
+	for _, user := range users {
+		config.InitialBalances[user.address] = amount(100).String()
+	}
+	producer := newTestChain(t, config)
+	peer := newTestChain(t, config)
+
+	// Transfers between the users make the result depend on the order they apply in
+	var pool []*blockchain.Transaction
+	for i, user := range users {
+		to := users[(i+1)%len(users)]
+		for nonce := range uint64(50) {
+			op := setOp(fmt.Sprintf("%s/%d", user.address, nonce), "v")
+			if nonce%2 == 1 {
+				op = blockchain.NewTransferOperation(to.address, amount(1).Bytes())
+			}
+			pool = append(pool, newTestTx(t, user, "", nonce, op))
+		}
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
+
+	blockchain.SortTransactions(pool)
+	transactions, rejected := producer.FilterApplicableTransactions(pool)
+	if len(rejected) > 0 {
+		t.Fatalf("%d transactions rejected, first: %v", len(rejected), rejected[0].Err)
+	}
+	block := buildBlock(t, producer, producer.GetCurrentBlock(), authority, transactions)
+
+	if err := producer.AddBlock(block); err != nil {
+		t.Fatalf("producer rejected its own block: %v", err)
+	}
+	if err := peer.AddBlock(block); err != nil {
+		if strings.Contains(err.Error(), "invalid state root") {
+			t.Fatalf("peer recomputed a different state root: %v", err)
+		}
+		t.Fatalf("peer rejected the block: %v", err)
+	}
+	if len(block.Transactions) != len(pool) {
+		t.Errorf("block has %d transactions, want %d", len(block.Transactions), len(pool))
+	}
+	if !bytes.Equal(producer.GetStateRoot(), peer.GetStateRoot()) {
+		t.Error("producer and peer state roots differ")
+	}
+}
+
+func TestChainEnforcesProducerRotation(t *testing.T) {
+	first, second := newTestKey(t), newTestKey(t)
+	config := testGenesis(first)
+	config.Authorities = []string{first.address, second.address}
+	chain := newTestChain(t, config)
+
+	poa, err := consensus.NewPoAEngine(config.Authorities, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.SetProducerValidator(poa.ValidateBlockProducer)
+
+	// Blocks one second apart leave no slot missed, so each height has one producer
+	for height := uint64(1); height <= 4; height++ {
+		scheduled, other := first, second
+		if !poa.CanProduceBlock(height, first.address) {
+			scheduled, other = second, first
+		}
+
+		wrong := buildBlock(t, chain, chain.GetCurrentBlock(), other, nil)
+		if err := chain.AddBlock(wrong); err == nil {
+			t.Fatalf("height %d: block from %s accepted out of turn", height, other.address)
+		}
+
+		addBlock(t, chain, scheduled)
+		if got := chain.GetCurrentBlock().Header.ProducerAddr; got != scheduled.address {
+			t.Fatalf("height %d: tip produced by %s, want %s", height, got, scheduled.address)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrWrongChainID is returned for a block or transaction signed for another chain
+var ErrWrongChainID = errors.New("wrong chain ID")
+
+// SetChainID sets the chain ID that blocks and transactions must carry. The ID is
+// part of their hashes, so a signature made for one chain is invalid on any other.
+func (c *Chain) SetChainID(chainID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.chainID = chainID
+}
+
+// GetChainID returns the chain ID that blocks and transactions must carry
+func (c *Chain) GetChainID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.chainID
+}
+
+// CheckTransactionChainID returns ErrWrongChainID if tx was signed for another chain.
+// Genesis transactions are built from the genesis config and carry no chain ID.
+func CheckTransactionChainID(tx *Transaction, chainID string) error {
+	if tx.IsGenesisTransaction() || tx.ChainID == chainID {
+		return nil
+	}
+	return fmt.Errorf("%w: transaction is for chain %q, expected %q", ErrWrongChainID, tx.ChainID, chainID)
+}
+
+// checkBlockChainID returns ErrWrongChainID if the block or any of its transactions
+// was signed for another chain. The genesis block carries no chain ID.
+func checkBlockChainID(block *Block, chainID string) error {
+	if IsGenesisBlock(block) {
+		return nil
+	}
+	if block.Header.ChainID != chainID {
+		return fmt.Errorf("%w: block is for chain %q, expected %q", ErrWrongChainID, block.Header.ChainID, chainID)
+	}
+	for i, tx := range block.Transactions {
+		if err := CheckTransactionChainID(tx, chainID); err != nil {
+			return fmt.Errorf("invalid transaction at index %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package blockchain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+func TestTransactionSignedForAnotherChain(t *testing.T) {
+	user := newTestKey(t)
+	tx := newTestTx(t, user, "chain-a", 0, setOp("k", "v"))
+
+	if err := tx.Verify(); err != nil {
+		t.Fatalf("Verify on its own chain: %v", err)
+	}
+	if err := blockchain.CheckTransactionChainID(tx, "chain-a"); err != nil {
+		t.Errorf("CheckTransactionChainID on its own chain: %v", err)
+	}
+	if err := blockchain.CheckTransactionChainID(tx, "chain-b"); !errors.Is(err, blockchain.ErrWrongChainID) {
+		t.Errorf("CheckTransactionChainID on another chain = %v, want ErrWrongChainID", err)
+	}
+
+	// Relabelling it for chain B changes its hash, so the signature no longer matches
+	replayed := *tx
+	replayed.ChainID = "chain-b"
+	if err := replayed.Verify(); err == nil {
+		t.Error("signature for chain A verifies on chain B")
+	}
+}
+
+func TestBlockSignedForAnotherChain(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	configA := testGenesis(authority)
+	configA.ChainID = "chain-a"
+	configB := *configA
+	configB.ChainID = "chain-b"
+	chainA, chainB := newTestChain(t, configA), newTestChain(t, &configB)
+
+	block := buildBlock(t, chainA, chainA.GetCurrentBlock(), authority, nil)
+	if err := chainB.AddBlock(block); !errors.Is(err, blockchain.ErrWrongChainID) {
+		t.Errorf("AddBlock of a chain A block on chain B = %v, want ErrWrongChainID", err)
+	}
+	if _, err := chainB.ProcessBlock(block); !errors.Is(err, blockchain.ErrWrongChainID) {
+		t.Errorf("ProcessBlock of a chain A block on chain B = %v, want ErrWrongChainID", err)
+	}
+
+	// A chain B block carrying a chain A transaction is rejected too
+	tx := newTestTx(t, user, "chain-a", 0, setOp("k", "v"))
+	block = signBlock(t, chainB, chainB.GetCurrentBlock(), authority, []*blockchain.Transaction{tx}, chainB.GetStateRoot())
+	if err := chainB.AddBlock(block); !errors.Is(err, blockchain.ErrWrongChainID) {
+		t.Errorf("AddBlock with a chain A transaction = %v, want ErrWrongChainID", err)
+	}
+
+	addBlock(t, chainB, authority, newTestTx(t, user, "chain-b", 0, setOp("k", "v")))
+}
@@ -0,0 +1,52 @@
+package blockchain_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+// TestStateReadsDuringAddBlock reads the state from several goroutines, as API
+// handlers do, while blocks are applied. Run with -race to catch unguarded reads.
+func TestStateReadsDuringAddBlock(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	chain := newTestChain(t, tokenGenesis(authority, user, true))
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for range 4 {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				chain.GetStateRoot()
+				chain.GetBalance(user.address)
+				chain.GetState("greeting")
+				chain.GetTotalSupply()
+				chain.GetChainInfo()
+			}
+		}()
+	}
+
+	for nonce := range uint64(20) {
+		addBlock(t, chain, authority,
+			newTestTx(t, user, "", nonce,
+				setOp(fmt.Sprintf("key/%d", nonce), "v"),
+				blockchain.NewTransferOperation(authority.address, amount(1).Bytes()),
+			),
+		)
+	}
+	close(stop)
+	readers.Wait()
+
+	if chain.GetHeight() != 20 {
+		t.Errorf("height = %d, want 20", chain.GetHeight())
+	}
+}
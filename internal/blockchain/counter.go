@@ -0,0 +1,57 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var (
+	// ErrCounterUnderflow is returned when an INCREMENT would take a counter below zero
+	ErrCounterUnderflow = errors.New("counter would become negative")
+
+	// ErrInvalidCounter is returned when an INCREMENT targets a key that doesn't hold an integer
+	ErrInvalidCounter = errors.New("value is not a decimal integer")
+)
+
+// NewIncrementOperation creates an INCREMENT operation adding delta (which may be
+// negative) to the counter stored under key
+func NewIncrementOperation(key string, delta *big.Int) *KVOperation {
+	return &KVOperation{
+		Type:  OpTypeIncrement,
+		Key:   key,
+		Value: []byte(delta.String()),
+	}
+}
+
+// ParseCounter parses a counter value or INCREMENT delta: a base-10 integer such as "42" or "-3"
+func ParseCounter(data []byte) (*big.Int, error) {
+	value, ok := new(big.Int).SetString(string(data), 10)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCounter, data)
+	}
+	return value, nil
+}
+
+// applyIncrementOperation adds the operation's delta to the counter, treating a
+// missing key as zero
+func (c *Chain) applyIncrementOperation(state *State, op *KVOperation) error {
+	delta, err := ParseCounter(op.Value)
+	if err != nil {
+		return fmt.Errorf("invalid delta for key %s: %w", op.Key, err)
+	}
+
+	counter := big.NewInt(0)
+	if data, exists := state.Get(op.Key); exists {
+		if counter, err = ParseCounter(data); err != nil {
+			return fmt.Errorf("key %s: %w", op.Key, err)
+		}
+	}
+
+	counter.Add(counter, delta)
+	if counter.Sign() < 0 {
+		return fmt.Errorf("%w: key %s", ErrCounterUnderflow, op.Key)
+	}
+
+	return c.setState(state, op.Key, []byte(counter.String()))
+}
@@ -0,0 +1,71 @@
+package blockchain
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+const (
+	// ProducerFeesKeyPrefix prefixes the state keys holding the gas fees each block
+	// producer has been credited
+	ProducerFeesKeyPrefix = "meta:fees:"
+
+	// ProducerRewardsKeyPrefix prefixes the state keys holding the block rewards each
+	// block producer has been minted
+	ProducerRewardsKeyPrefix = "meta:rewards:"
+)
+
+// ErrEarningsNotTracked is returned for producer earnings on a chain whose genesis
+// doesn't enable track_producer_earnings
+var ErrEarningsNotTracked = errors.New("producer earnings are not tracked on this chain")
+
+// ProducerFeesKey returns the state key for the cumulative fees of a block producer
+func ProducerFeesKey(address string) string {
+	return ProducerFeesKeyPrefix + strings.ToLower(address)
+}
+
+// ProducerRewardsKey returns the state key for the cumulative block rewards of a block producer
+func ProducerRewardsKey(address string) string {
+	return ProducerRewardsKeyPrefix + strings.ToLower(address)
+}
+
+// tracksProducerEarnings reports whether blocks record their producer's fees and
+// rewards in state. It changes the state roots, so it is a genesis option.
+func (c *Chain) tracksProducerEarnings() bool {
+	return c.tokenConfig != nil && c.tokenConfig.TrackProducerEarnings
+}
+
+// creditProducerEarnings adds a block's fees and reward to its producer's totals
+func (c *Chain) creditProducerEarnings(state *State, producer string, fees, reward *big.Int) error {
+	if !c.tracksProducerEarnings() {
+		return nil
+	}
+
+	if fees.Sign() > 0 {
+		if err := c.creditBalance(state, ProducerFeesKey(producer), fees); err != nil {
+			return err
+		}
+	}
+	if reward.Sign() > 0 {
+		if err := c.creditBalance(state, ProducerRewardsKey(producer), reward); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetProducerEarnings returns the gas fees and block rewards a producer has earned
+// to date, in wei
+func (c *Chain) GetProducerEarnings(address string) (fees, rewards *big.Int, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.tracksProducerEarnings() {
+		return nil, nil, ErrEarningsNotTracked
+	}
+
+	fees = c.getBalance(c.state, ProducerFeesKey(address)).Amount
+	rewards = c.getBalance(c.state, ProducerRewardsKey(address)).Amount
+	return fees, rewards, nil
+}
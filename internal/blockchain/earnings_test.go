@@ -0,0 +1,69 @@
+package blockchain_test
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+func TestProducerEarnings(t *testing.T) {
+	first, second, user := newTestKey(t), newTestKey(t), newTestKey(t)
+	config := tokenGenesis(first, user, true)
+	config.Authorities = append(config.Authorities, second.address)
+	config.TokenConfig.TrackProducerEarnings = true
+	config.TokenConfig.BlockReward = amount(2)
+	chain := newTestChain(t, config)
+
+	// The first authority produces two blocks and the second one
+	fees := map[string]*big.Int{first.address: new(big.Int), second.address: new(big.Int)}
+	blocks := map[string]int64{}
+	nonce := uint64(0)
+	for _, block := range []struct {
+		producer *testKey
+		txs      int
+	}{{first, 2}, {second, 1}, {first, 1}} {
+		var txs []*blockchain.Transaction
+		for range block.txs {
+			tx := newTestTx(t, user, "", nonce, setOp("k", "v"))
+			fees[block.producer.address].Add(fees[block.producer.address], chain.GetGasConfig().CalculateGasFee(tx.Size()))
+			txs = append(txs, tx)
+			nonce++
+		}
+		addBlock(t, chain, block.producer, txs...)
+		blocks[block.producer.address]++
+	}
+
+	for _, producer := range []*testKey{first, second} {
+		gotFees, gotRewards, err := chain.GetProducerEarnings(producer.address)
+		if err != nil {
+			t.Fatalf("GetProducerEarnings: %v", err)
+		}
+		if want := fees[producer.address]; gotFees.Cmp(want) != 0 {
+			t.Errorf("producer %s earned %s in fees, want %s", producer.address, gotFees, want)
+		}
+		if want := new(big.Int).Mul(amount(2), big.NewInt(blocks[producer.address])); gotRewards.Cmp(want) != 0 {
+			t.Errorf("producer %s earned %s in rewards, want %s", producer.address, gotRewards, want)
+		}
+	}
+
+	// Addresses that produced nothing have earned nothing
+	idleFees, idleRewards, err := chain.GetProducerEarnings(user.address)
+	if err != nil || idleFees.Sign() != 0 || idleRewards.Sign() != 0 {
+		t.Errorf("earnings of a non-producer = %s, %s, %v; want zero", idleFees, idleRewards, err)
+	}
+}
+
+func TestProducerEarningsNotTracked(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	chain := newTestChain(t, tokenGenesis(authority, user, true))
+	addBlock(t, chain, authority, newTestTx(t, user, "", 0, setOp("k", "v")))
+
+	if _, _, err := chain.GetProducerEarnings(authority.address); !errors.Is(err, blockchain.ErrEarningsNotTracked) {
+		t.Errorf("GetProducerEarnings without tracking: err = %v, want ErrEarningsNotTracked", err)
+	}
+	if _, err := chain.GetState(blockchain.ProducerFeesKey(authority.address)); err == nil {
+		t.Error("fees were recorded in state without tracking")
+	}
+}
@@ -0,0 +1,90 @@
+package blockchain_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/storage"
+)
+
+// emptyChain returns a chain configured from config on a fresh store, not yet initialized
+func emptyChain(config *blockchain.GenesisConfig) *blockchain.Chain {
+	chain := blockchain.NewChainWithConfig(storage.NewMemoryStore(), config.Authorities, config.GetGasConfig(), config.TokenConfig)
+	chain.SetConsensusParams(config.GetConsensusParams())
+	chain.SetKeyPolicy(config.KeyPolicy)
+	chain.SetChainID(config.ChainID)
+	return chain
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	config := testGenesis(authority)
+	chain := newTestChain(t, config)
+	for i := range 20 {
+		addBlock(t, chain, authority, newTestTx(t, user, "", uint64(i), setOp(fmt.Sprintf("k%d", i), "v")))
+	}
+
+	var export bytes.Buffer
+	if err := chain.Export(&export); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	imported := emptyChain(config)
+	if err := imported.Import(bytes.NewReader(export.Bytes())); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if imported.GetHeight() != 20 {
+		t.Errorf("imported height = %d, want 20", imported.GetHeight())
+	}
+	if !bytes.Equal(imported.GetStateRoot(), chain.GetStateRoot()) {
+		t.Error("imported state root differs from the exported chain")
+	}
+	if err := imported.VerifyGenesis(config); err != nil {
+		t.Errorf("VerifyGenesis after import: %v", err)
+	}
+
+	// Importing on top of existing data is refused
+	if err := imported.Import(bytes.NewReader(export.Bytes())); err == nil {
+		t.Error("import into a non-empty store accepted")
+	}
+}
+
+func TestImportRejectsTamperedExport(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	config := testGenesis(authority)
+	chain := newTestChain(t, config)
+	for i := range 3 {
+		addBlock(t, chain, authority, newTestTx(t, user, "", uint64(i), setOp(fmt.Sprintf("k%d", i), "original")))
+	}
+
+	var export bytes.Buffer
+	if err := chain.Export(&export); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	data := export.Bytes()
+
+	tests := map[string][]byte{
+		// Same length, so the record framing still holds
+		"changed transaction": bytes.Replace(data, []byte(`"b3JpZ2luYWw="`), []byte(`"Zm9yZ2VkISE="`), 1), // "original" -> "forged!!"
+		"changed genesis":     bytes.Replace(data, []byte(`"aGVsbG8="`), []byte(`"amVsbG8="`), 1),         // "hello" -> "jello"
+		"truncated":           data[:len(data)-10],
+		"no header":           data[8:],
+	}
+	for name, tampered := range tests {
+		t.Run(name, func(t *testing.T) {
+			if bytes.Equal(tampered, data) {
+				t.Fatal("tampering did not change the export")
+			}
+			if err := emptyChain(config).Import(bytes.NewReader(tampered)); err == nil {
+				t.Error("tampered export imported")
+			}
+		})
+	}
+
+	if err := emptyChain(config).Import(bytes.NewReader(data[:10])); !errors.Is(err, blockchain.ErrInvalidExport) {
+		t.Errorf("Import of a cut-off stream = %v, want ErrInvalidExport", err)
+	}
+}
@@ -0,0 +1,337 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// BaseBlockWeight is the weight every block contributes regardless of its contents
+const BaseBlockWeight = 1
+
+// BlockWeight returns the weight a single block adds to its chain:
+// the base weight plus one per included transaction
+func BlockWeight(block *Block) uint64 {
+	return BaseBlockWeight + uint64(len(block.Transactions))
+}
+
+// Branch describes the tip of a candidate chain for fork choice
+type Branch struct {
+	TipHash []byte `json:"tip_hash"`
+	Height  uint64 `json:"height"`
+	Weight  uint64 `json:"weight"` // Cumulative weight from genesis to tip
+}
+
+// CompareBranches implements the fork-choice rule. It returns a positive value if
+// a is preferred over b, negative if b is preferred, and 0 if they are the same tip.
+// The longer branch wins. Weight only breaks ties between equal heights, so packing
+// a block with transactions can't outweigh blocks honest authorities produced;
+// equal weights fall back to the lower tip hash so every node picks the same branch.
+func CompareBranches(a, b *Branch) int {
+	if a.Height != b.Height {
+		if a.Height > b.Height {
+			return 1
+		}
+		return -1
+	}
+
+	if a.Weight != b.Weight {
+		if a.Weight > b.Weight {
+			return 1
+		}
+		return -1
+	}
+
+	return bytes.Compare(b.TipHash, a.TipHash)
+}
+
+// MaxReorgDepth is how far below the tip a competing branch may fork off the canonical chain
+const MaxReorgDepth = 100
+
+var (
+	// ErrUnknownParent is returned for a block whose parent is neither canonical nor a known competing block
+	ErrUnknownParent = errors.New("parent block is unknown")
+
+	// ErrForkTooDeep is returned for a competing block more than MaxReorgDepth below the tip
+	ErrForkTooDeep = errors.New("block is too far below the tip to reorganize")
+)
+
+// sideBlock is a block on a competing branch and the cumulative weight of its branch
+type sideBlock struct {
+	block  *Block
+	weight uint64
+}
+
+// Reorg describes a switch of the canonical chain to a competing branch
+type Reorg struct {
+	ForkHeight uint64   // Height of the last block both branches share
+	Removed    []*Block // Formerly canonical blocks, lowest first
+	Added      []*Block // Newly canonical blocks, lowest first
+}
+
+// ProcessBlock adds a block received from the network. A block extending the tip is
+// added as with AddBlock. Any other block is kept as a competing block, and if the
+// fork-choice rule prefers its branch the chain reorganizes onto it. The returned
+// Reorg is nil unless that happened; blocks that are already known are ignored.
+func (c *Chain) ProcessBlock(block *Block) (*Reorg, error) {
+	if block == nil || block.Header == nil {
+		return nil, errors.New("block is nil")
+	}
+
+	c.mu.Lock()
+	if bytes.Equal(block.Header.PreviousHash, c.currentBlock.Hash()) {
+		err := c.appendBlock(block)
+		c.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		c.notifyCommit(block)
+		return nil, nil
+	}
+
+	reorg, err := c.addSideBlock(block)
+	onReorg := c.onReorg
+	c.mu.Unlock()
+	if err != nil || reorg == nil {
+		return nil, err
+	}
+
+	if onReorg != nil {
+		onReorg(reorg)
+	}
+	c.notifyCommit(reorg.Added...)
+	return reorg, nil
+}
+
+// addSideBlock validates and stores a block that doesn't extend the tip, then
+// reorganizes onto its branch if that branch is now preferred (caller must hold c.mu)
+func (c *Chain) addSideBlock(block *Block) (*Reorg, error) {
+	hash := block.Hash()
+	key := hex.EncodeToString(hash)
+	if _, known := c.sideBlocks[key]; known {
+		return nil, nil
+	}
+
+	height := block.Header.Height
+	if height == 0 {
+		return nil, errors.New("competing genesis block")
+	}
+	if height <= c.height {
+		canonical, err := c.storage.GetBlockByHeight(height)
+		if err == nil && bytes.Equal(canonical.Hash(), hash) {
+			return nil, nil
+		}
+	}
+	if height+MaxReorgDepth <= c.height {
+		return nil, fmt.Errorf("%w: height %d, tip %d", ErrForkTooDeep, height, c.height)
+	}
+
+	parent, parentWeight, err := c.findParent(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateBlock(block, parent, c.authorities, c.params); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidBlock, err)
+	}
+	if err := checkBlockChainID(block, c.chainID); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidBlock, err)
+	}
+	if c.validateProducer != nil {
+		if err := c.validateProducer(block, parent); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidBlock, err)
+		}
+	}
+
+	side := &sideBlock{block: block, weight: parentWeight + BlockWeight(block)}
+	c.sideBlocks[key] = side
+
+	candidate := &Branch{TipHash: hash, Height: height, Weight: side.weight}
+	if CompareBranches(candidate, c.tipBranch()) <= 0 {
+		return nil, nil
+	}
+
+	return c.reorganize(block)
+}
+
+// findParent returns a block's parent, canonical or competing, and the cumulative
+// weight of the chain up to it (caller must hold c.mu)
+func (c *Chain) findParent(block *Block) (*Block, uint64, error) {
+	if side, ok := c.sideBlocks[hex.EncodeToString(block.Header.PreviousHash)]; ok {
+		return side.block, side.weight, nil
+	}
+
+	parentHeight := block.Header.Height - 1
+	if parentHeight <= c.height {
+		parent, err := c.storage.GetBlockByHeight(parentHeight)
+		if err == nil && bytes.Equal(parent.Hash(), block.Header.PreviousHash) {
+			weight, err := c.storage.GetBlockWeight(parent.Hash())
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to load parent weight: %w", err)
+			}
+			return parent, weight, nil
+		}
+	}
+
+	return nil, 0, fmt.Errorf("%w: %x", ErrUnknownParent, block.Header.PreviousHash)
+}
+
+// reorganize makes the branch ending at tip canonical. The branch is first replayed on
+// top of the fork point to check its state roots; only then is the chain reverted to
+// the fork point and the branch committed on top. If a block then fails to commit,
+// the old branch is restored (caller must hold c.mu).
+func (c *Chain) reorganize(tip *Block) (*Reorg, error) {
+	// Collect the branch back to the fork point, lowest block first
+	branch := []*Block{tip}
+	for {
+		side, ok := c.sideBlocks[hex.EncodeToString(branch[0].Header.PreviousHash)]
+		if !ok {
+			break
+		}
+		branch = append([]*Block{side.block}, branch...)
+	}
+
+	forkHeight := branch[0].Header.Height - 1
+	ancestor, err := c.storage.GetBlockByHeight(forkHeight)
+	if err != nil || !bytes.Equal(ancestor.Hash(), branch[0].Header.PreviousHash) {
+		return nil, fmt.Errorf("%w: %x", ErrUnknownParent, branch[0].Header.PreviousHash)
+	}
+	if c.height-forkHeight > MaxReorgDepth {
+		return nil, fmt.Errorf("%w: fork at %d, tip %d", ErrForkTooDeep, forkHeight, c.height)
+	}
+
+	// Check the branch applies cleanly before changing anything
+	state, err := c.replayState(forkHeight)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, block := range branch {
+		if err := c.checkDuplicateTransactions(block, forkHeight, seen); err != nil {
+			c.dropSideBlock(block)
+			return nil, fmt.Errorf("%w: competing block %d: %w", ErrInvalidBlock, block.Header.Height, err)
+		}
+		if _, err := c.ApplyTransactionsWithFees(state, block.Transactions, block.Header.ProducerAddr); err != nil {
+			c.dropSideBlock(block)
+			return nil, fmt.Errorf("%w: competing block %d failed to apply: %w", ErrInvalidBlock, block.Header.Height, err)
+		}
+		if !bytes.Equal(state.CalculateRoot(block.Header.Version), block.Header.StateRoot) {
+			c.dropSideBlock(block)
+			return nil, fmt.Errorf("%w: competing block %d has an invalid state root", ErrInvalidBlock, block.Header.Height)
+		}
+	}
+
+	reorg := &Reorg{ForkHeight: forkHeight, Added: branch}
+
+	// Demote the old branch: its blocks become competing blocks
+	for h := forkHeight + 1; h <= c.height; h++ {
+		block, err := c.storage.GetBlockByHeight(h)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load block at height %d: %w", h, err)
+		}
+		weight, err := c.storage.GetBlockWeight(block.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("failed to load block weight at height %d: %w", h, err)
+		}
+
+		c.sideBlocks[hex.EncodeToString(block.Hash())] = &sideBlock{block: block, weight: weight}
+		reorg.Removed = append(reorg.Removed, block)
+	}
+
+	if err := c.revertToHeight(forkHeight); err != nil {
+		return nil, fmt.Errorf("failed to revert to the fork point: %w", err)
+	}
+
+	// Promote the new branch, putting the old one back if a block fails to commit
+	for _, block := range branch {
+		if err := c.commitBlock(block); err != nil {
+			err = fmt.Errorf("failed to commit block %d: %w", block.Header.Height, err)
+			if restoreErr := c.restoreBranch(forkHeight, block, reorg.Removed); restoreErr != nil {
+				return nil, fmt.Errorf("%w; restoring the previous branch also failed: %w", err, restoreErr)
+			}
+			return nil, err
+		}
+	}
+	for _, block := range branch {
+		delete(c.sideBlocks, hex.EncodeToString(block.Hash()))
+	}
+
+	c.pruneSideBlocks()
+	return reorg, nil
+}
+
+// restoreBranch undoes a reorganization whose block failed to commit: the
+// chain is reset to the fork point, anything the failed block left in storage is
+// dropped, and the blocks the reorganization removed are committed again. The new
+// branch stays as competing blocks (caller must hold c.mu).
+func (c *Chain) restoreBranch(forkHeight uint64, failed *Block, removed []*Block) error {
+	if err := c.resetToHeight(forkHeight); err != nil {
+		return fmt.Errorf("failed to revert to the fork point: %w", err)
+	}
+
+	height := failed.Header.Height
+	for _, tx := range failed.Transactions {
+		if err := c.storage.UnindexTransaction(tx, height); err != nil {
+			return fmt.Errorf("failed to unindex transaction: %w", err)
+		}
+		if err := c.storage.DeleteReceipt(tx.ID); err != nil {
+			return fmt.Errorf("failed to delete receipt: %w", err)
+		}
+	}
+	if err := c.storage.DeleteBlockHeight(height); err != nil {
+		return fmt.Errorf("failed to delete height index %d: %w", height, err)
+	}
+
+	for _, block := range removed {
+		if err := c.commitBlock(block); err != nil {
+			return fmt.Errorf("failed to recommit block %d: %w", block.Header.Height, err)
+		}
+		delete(c.sideBlocks, hex.EncodeToString(block.Hash()))
+	}
+	return nil
+}
+
+// dropSideBlock forgets an invalid competing block and every block built on it (caller must hold c.mu)
+func (c *Chain) dropSideBlock(block *Block) {
+	dropped := map[string]bool{hex.EncodeToString(block.Hash()): true}
+	delete(c.sideBlocks, hex.EncodeToString(block.Hash()))
+
+	for found := true; found; {
+		found = false
+		for key, side := range c.sideBlocks {
+			if dropped[hex.EncodeToString(side.block.Header.PreviousHash)] {
+				dropped[key] = true
+				delete(c.sideBlocks, key)
+				found = true
+			}
+		}
+	}
+}
+
+// pruneSideBlocks forgets competing blocks too far below the tip to be reorganized onto (caller must hold c.mu)
+func (c *Chain) pruneSideBlocks() {
+	for key, side := range c.sideBlocks {
+		if side.block.Header.Height+MaxReorgDepth <= c.height {
+			delete(c.sideBlocks, key)
+		}
+	}
+}
+
+// GetCompetingBlocks returns the known blocks at height that are not on the canonical chain
+func (c *Chain) GetCompetingBlocks(height uint64) []*Block {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var blocks []*Block
+	for _, side := range c.sideBlocks {
+		if side.block.Header.Height == height {
+			blocks = append(blocks, side.block)
+		}
+	}
+
+	sort.Slice(blocks, func(i, j int) bool { return bytes.Compare(blocks[i].Hash(), blocks[j].Hash()) < 0 })
+	return blocks
+}
@@ -0,0 +1,187 @@
+package blockchain_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/storage"
+)
+
+func TestCompareBranches(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b *blockchain.Branch
+		want int
+	}{
+		{
+			name: "longer branch beats heavier branch",
+			a:    &blockchain.Branch{TipHash: []byte{1}, Height: 11, Weight: 12},
+			b:    &blockchain.Branch{TipHash: []byte{2}, Height: 10, Weight: 500},
+			want: 1,
+		},
+		{
+			name: "heavier branch wins at equal height",
+			a:    &blockchain.Branch{TipHash: []byte{1}, Height: 10, Weight: 20},
+			b:    &blockchain.Branch{TipHash: []byte{2}, Height: 10, Weight: 30},
+			want: -1,
+		},
+		{
+			name: "lower tip hash breaks a full tie",
+			a:    &blockchain.Branch{TipHash: []byte{1}, Height: 10, Weight: 20},
+			b:    &blockchain.Branch{TipHash: []byte{2}, Height: 10, Weight: 20},
+			want: 1,
+		},
+		{
+			name: "same tip",
+			a:    &blockchain.Branch{TipHash: []byte{1}, Height: 10, Weight: 20},
+			b:    &blockchain.Branch{TipHash: []byte{1}, Height: 10, Weight: 20},
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := blockchain.CompareBranches(tt.a, tt.b); sign(got) != tt.want {
+				t.Errorf("CompareBranches = %d, want sign %d", got, tt.want)
+			}
+			if got := blockchain.CompareBranches(tt.b, tt.a); sign(got) != -tt.want {
+				t.Errorf("CompareBranches reversed = %d, want sign %d", got, -tt.want)
+			}
+		})
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
+
+func TestStuffedBlockDoesNotReplaceLongerBranch(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	chain := newTestChain(t, testGenesis(authority))
+	genesis := chain.GetCurrentBlock()
+
+	// A competing block at height 1 packed with transactions
+	var stuffed []*blockchain.Transaction
+	for i := range 50 {
+		stuffed = append(stuffed, newTestTx(t, user, "", uint64(i), setOp(fmt.Sprintf("k%d", i), "v")))
+	}
+	side := buildBlock(t, chain, genesis, authority, stuffed)
+
+	// The honest branch is three empty blocks long
+	for range 3 {
+		addBlock(t, chain, authority)
+	}
+	tip := chain.GetCurrentBlock()
+
+	reorg, err := chain.ProcessBlock(side)
+	if err != nil {
+		t.Fatalf("ProcessBlock: %v", err)
+	}
+	if reorg != nil {
+		t.Fatalf("chain reorganized onto a shorter branch at height %d", side.Header.Height)
+	}
+	if chain.GetHeight() != 3 || chain.GetCurrentBlock().HashString() != tip.HashString() {
+		t.Errorf("tip moved to height %d", chain.GetHeight())
+	}
+}
+
+func TestHeavierBranchWinsAtEqualHeight(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	chain := newTestChain(t, testGenesis(authority))
+	genesis := chain.GetCurrentBlock()
+
+	side := buildBlock(t, chain, genesis, authority, []*blockchain.Transaction{newTestTx(t, user, "", 0, setOp("k", "v"))})
+	addBlock(t, chain, authority)
+
+	reorg, err := chain.ProcessBlock(side)
+	if err != nil {
+		t.Fatalf("ProcessBlock: %v", err)
+	}
+	if reorg == nil || len(reorg.Added) != 1 || len(reorg.Removed) != 1 {
+		t.Fatalf("reorg = %+v, want one block replaced", reorg)
+	}
+	if got := chain.GetCurrentBlock().HashString(); got != side.HashString() {
+		t.Errorf("tip = %s, want the heavier block %s", got, side.HashString())
+	}
+}
+
+// failingStore is a memory store whose CommitBlock fails for one block
+type failingStore struct {
+	*storage.MemoryStore
+	failHash string
+}
+
+func (s *failingStore) CommitBlock(commit *blockchain.BlockCommit) error {
+	if commit.Block.HashString() == s.failHash {
+		return errors.New("disk full")
+	}
+	return s.MemoryStore.CommitBlock(commit)
+}
+
+func TestFailedReorgRestoresPreviousBranch(t *testing.T) {
+	authority, user, other := newTestKey(t), newTestKey(t), newTestKey(t)
+	config := testGenesis(authority)
+	store := &failingStore{MemoryStore: storage.NewMemoryStore()}
+	chain := newTestChainOn(t, store, config)
+
+	// A competing node builds a longer branch from the same genesis
+	competitor := newTestChain(t, config)
+	side1 := addBlock(t, competitor, authority)
+	side2 := addBlock(t, competitor, authority, newTestTx(t, other, "", 0, setOp("theirs", "v")))
+
+	tx := newTestTx(t, user, "", 0, setOp("ours", "v"))
+	ours := addBlock(t, chain, authority, tx)
+	stateRoot := chain.GetStateRoot()
+
+	// The branch replays cleanly, but its second block can't be written
+	store.failHash = side2.HashString()
+	if _, err := chain.ProcessBlock(side1); err != nil {
+		t.Fatalf("ProcessBlock(side1): %v", err)
+	}
+	reorg, err := chain.ProcessBlock(side2)
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("ProcessBlock(side2) = %v, %v; want the commit error", reorg, err)
+	}
+
+	if chain.GetHeight() != 1 || chain.GetCurrentBlock().HashString() != ours.HashString() {
+		t.Fatalf("tip = %d %s, want the original block %s", chain.GetHeight(), chain.GetCurrentBlock().HashString(), ours.HashString())
+	}
+	if !bytes.Equal(chain.GetStateRoot(), stateRoot) {
+		t.Error("state root differs from before the reorganization")
+	}
+	if _, err := chain.GetState("theirs"); err == nil {
+		t.Error("state still holds a key from the failed branch")
+	}
+	if _, err := chain.GetState("ours"); err != nil {
+		t.Errorf("state lost a key from the restored branch: %v", err)
+	}
+	if stored, err := chain.GetBlockByHeight(1); err != nil || stored.HashString() != ours.HashString() {
+		t.Errorf("height 1 in storage = %v, %v; want the original block", stored, err)
+	}
+	if _, err := chain.GetReceipt(tx.ID); err != nil {
+		t.Errorf("receipt of the restored block: %v", err)
+	}
+	if chain.GetNonce(user.address) != 1 {
+		t.Errorf("nonce = %d, want 1", chain.GetNonce(user.address))
+	}
+
+	// The chain keeps working, and the stored state matches a rebuild from the blocks
+	addBlock(t, chain, authority)
+	reloaded := blockchain.NewChain(store, config.Authorities)
+	if err := reloaded.LoadFromStorage(); err != nil {
+		t.Fatalf("LoadFromStorage: %v", err)
+	}
+	if !bytes.Equal(reloaded.GetStateRoot(), chain.GetStateRoot()) {
+		t.Error("reloaded state root differs from the live one")
+	}
+}
@@ -17,12 +17,14 @@ const (
 type GasConfig struct {
 	BaseFee    *big.Int // Minimum fee per transaction
 	PerByteFee *big.Int // Fee per byte of transaction data
+	BurnFees   bool     // Burn collected fees instead of crediting the producer
 }
 
 // GasConfigJSON is the JSON representation of GasConfig
 type GasConfigJSON struct {
 	BaseFee    string `json:"base_fee"`
 	PerByteFee string `json:"per_byte_fee"`
+	BurnFees   bool   `json:"burn_fees,omitempty"`
 }
 
 // DefaultGasConfig returns the default gas configuration
@@ -74,6 +76,7 @@ func GasConfigFromJSON(json *GasConfigJSON) (*GasConfig, error) {
 	return &GasConfig{
 		BaseFee:    baseFee,
 		PerByteFee: perByteFee,
+		BurnFees:   json.BurnFees,
 	}, nil
 }
 
@@ -82,6 +85,7 @@ func (gc *GasConfig) ToJSON() *GasConfigJSON {
 	return &GasConfigJSON{
 		BaseFee:    gc.BaseFee.String(),
 		PerByteFee: gc.PerByteFee.String(),
+		BurnFees:   gc.BurnFees,
 	}
 }
 
@@ -120,6 +124,7 @@ func (gc *GasConfig) Clone() *GasConfig {
 	return &GasConfig{
 		BaseFee:    new(big.Int).Set(gc.BaseFee),
 		PerByteFee: new(big.Int).Set(gc.PerByteFee),
+		BurnFees:   gc.BurnFees,
 	}
 }
 
@@ -1,21 +1,26 @@
 package blockchain
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
 	"os"
 	"sort"
 )
 
 // GenesisConfig defines the genesis block configuration
 type GenesisConfig struct {
+	ChainID         string            `json:"chain_id,omitempty"` // Network identifier checked in the peer handshake
 	Timestamp       int64             `json:"timestamp"`
 	Authorities     []string          `json:"authorities"`
 	InitialState    map[string]string `json:"initial_state"`
 	TokenConfig     *TokenConfig      `json:"token_config,omitempty"`
 	GasConfig       *GasConfigJSON    `json:"gas_config,omitempty"`
 	InitialBalances map[string]string `json:"initial_balances,omitempty"` // address -> amount in wei
+	ConsensusParams *ConsensusParams  `json:"consensus_params,omitempty"` // Block and mempool limits (defaults when unset)
+	KeyPolicy       *KeyPolicy        `json:"key_policy,omitempty"`       // Key ownership rules (any sender writes any key when unset)
 }
 
 // LoadGenesisConfig loads genesis configuration from a file
@@ -43,13 +48,16 @@ func (gc *GenesisConfig) Validate() error {
 		return errors.New("no authorities specified")
 	}
 
-	// Check for duplicate authorities
-	seen := make(map[string]bool)
-	for _, addr := range gc.Authorities {
-		if seen[addr] {
-			return fmt.Errorf("duplicate authority: %s", addr)
+	// Check for duplicate authorities, ignoring address casing
+	if dup := FindDuplicateAuthority(gc.Authorities); dup != "" {
+		return fmt.Errorf("duplicate authority: %s", dup)
+	}
+
+	// Reserved keys are written by the chain itself
+	for key := range gc.InitialState {
+		if IsReservedKey(key) {
+			return fmt.Errorf("initial state key %s is reserved (%s prefix)", key, ReservedKeyPrefix)
 		}
-		seen[addr] = true
 	}
 
 	// Validate token config if present
@@ -70,12 +78,39 @@ func (gc *GenesisConfig) Validate() error {
 		}
 	}
 
-	// Validate initial balances if present
+	// Validate consensus params if present
+	if gc.ConsensusParams != nil {
+		if err := gc.ConsensusParams.Validate(); err != nil {
+			return fmt.Errorf("invalid consensus params: %w", err)
+		}
+	}
+
+	// Validate key policy if present
+	if gc.KeyPolicy != nil {
+		if err := gc.KeyPolicy.Validate(); err != nil {
+			return fmt.Errorf("invalid key policy: %w", err)
+		}
+	}
+
+	// Validate initial balances if present; together they can't exceed the token's initial supply
 	if gc.InitialBalances != nil {
+		total := big.NewInt(0)
 		for addr, amountStr := range gc.InitialBalances {
-			if _, err := NewBalanceFromString(amountStr); err != nil {
+			balance, err := NewBalanceFromString(amountStr)
+			if err != nil {
 				return fmt.Errorf("invalid balance for %s: %w", addr, err)
 			}
+			if balance.Amount.Sign() < 0 {
+				return fmt.Errorf("invalid balance for %s: cannot be negative", addr)
+			}
+			total.Add(total, balance.Amount)
+		}
+
+		if gc.TokenConfig != nil {
+			if supply := gc.TokenConfig.GetInitialSupply(); total.Cmp(supply) > 0 {
+				return fmt.Errorf("initial balances total %s wei, more than the initial supply of %s wei",
+					total, supply)
+			}
 		}
 	}
 
@@ -99,8 +134,20 @@ func (gc *GenesisConfig) GetGasConfig() *GasConfig {
 	return config
 }
 
+// GetConsensusParams returns the consensus params with defaults applied
+func (gc *GenesisConfig) GetConsensusParams() *ConsensusParams {
+	return gc.ConsensusParams.WithDefaults()
+}
+
 // CreateGenesisBlock creates the genesis block from configuration
 func CreateGenesisBlock(config *GenesisConfig) *Block {
+	return createGenesisBlock(config, true)
+}
+
+// createGenesisBlock builds the genesis block. Chains created before the authority
+// set was committed to state have no authority transaction, so VerifyGenesis also
+// checks the block built without it.
+func createGenesisBlock(config *GenesisConfig, commitAuthorities bool) *Block {
 	// Create initial state transactions
 	// Sort keys to ensure deterministic order (maps have random iteration order)
 	keys := make([]string, 0, len(config.InitialState))
@@ -135,6 +182,28 @@ func CreateGenesisBlock(config *GenesisConfig) *Block {
 		nonce++
 	}
 
+	// Commit the authority set to state
+	if commitAuthorities {
+		tx := &Transaction{
+			From:      GenesisAddress,
+			Timestamp: config.Timestamp,
+			Data: &TransactionData{
+				Operations: []*KVOperation{
+					{
+						Type:  OpTypeSet,
+						Key:   AuthoritySetKey,
+						Value: EncodeAuthorities(config.Authorities),
+					},
+				},
+			},
+			Nonce:     nonce,
+			Signature: []byte{},
+		}
+		tx.ID = tx.Hash()
+		transactions = append(transactions, tx)
+		nonce++
+	}
+
 	// Create MINT transactions for initial balances
 	if config.InitialBalances != nil {
 		// Sort addresses for deterministic order
@@ -172,14 +241,18 @@ func CreateGenesisBlock(config *GenesisConfig) *Block {
 		}
 	}
 
-	// Calculate merkle root
-	merkleRoot := CalculateMerkleRoot(transactions)
-
-	// Determine block version based on token config
+	// Determine block version based on token config; chains on the domain-separated
+	// merkle tree use it from genesis on
 	version := uint32(1)
 	if config.HasTokenConfig() {
 		version = 2 // Version 2 indicates gas fees enabled
 	}
+	if blockVersion := config.GetConsensusParams().BlockVersion; !legacyMerkle(blockVersion) {
+		version = blockVersion
+	}
+
+	// Calculate merkle root
+	merkleRoot := CalculateMerkleRoot(transactions, version)
 
 	// Create genesis header
 	header := &BlockHeader{
@@ -207,3 +280,32 @@ func CreateGenesisBlock(config *GenesisConfig) *Block {
 func IsGenesisBlock(block *Block) bool {
 	return block != nil && block.Header.Height == 0
 }
+
+// ErrGenesisMismatch is returned when the stored genesis block doesn't match the genesis config
+var ErrGenesisMismatch = errors.New("genesis mismatch")
+
+// VerifyGenesis checks that the stored genesis block matches the one the given config produces.
+// The state root is derived from the genesis transactions, so it is taken from the stored block.
+func (c *Chain) VerifyGenesis(config *GenesisConfig) error {
+	stored, err := c.storage.GetBlockByHeight(0)
+	if err != nil {
+		return fmt.Errorf("failed to load genesis block: %w", err)
+	}
+
+	expected := CreateGenesisBlock(config)
+	expected.Header.StateRoot = stored.Header.StateRoot
+
+	if !bytes.Equal(stored.Hash(), expected.Hash()) {
+		// Chains created before the authority set was committed to state
+		legacy := createGenesisBlock(config, false)
+		legacy.Header.StateRoot = stored.Header.StateRoot
+		if bytes.Equal(stored.Hash(), legacy.Hash()) {
+			return nil
+		}
+
+		return fmt.Errorf("%w: stored genesis hash 0x%x, genesis config produces 0x%x",
+			ErrGenesisMismatch, stored.Hash(), expected.Hash())
+	}
+
+	return nil
+}
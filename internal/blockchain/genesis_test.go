@@ -0,0 +1,121 @@
+package blockchain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/storage"
+)
+
+func TestVerifyGenesis(t *testing.T) {
+	authority := newTestKey(t)
+	config := testGenesis(authority)
+	store := storage.NewMemoryStore()
+	chain := newTestChainOn(t, store, config)
+	addBlock(t, chain, authority)
+
+	// Restart on the same data with the same genesis file
+	restarted := blockchain.NewChain(store, config.Authorities)
+	if err := restarted.LoadFromStorage(); err != nil {
+		t.Fatalf("LoadFromStorage: %v", err)
+	}
+	if err := restarted.VerifyGenesis(config); err != nil {
+		t.Errorf("VerifyGenesis with the original config: %v", err)
+	}
+
+	changed := map[string]func(*blockchain.GenesisConfig){
+		"initial state": func(c *blockchain.GenesisConfig) { c.InitialState = map[string]string{"greeting": "bye"} },
+		"timestamp":     func(c *blockchain.GenesisConfig) { c.Timestamp++ },
+		"authorities":   func(c *blockchain.GenesisConfig) { c.Authorities = []string{newTestKey(t).address} },
+		"balances":      func(c *blockchain.GenesisConfig) { c.InitialBalances = map[string]string{authority.address: "1"} },
+	}
+	for name, change := range changed {
+		t.Run(name, func(t *testing.T) {
+			other := *config
+			change(&other)
+			err := restarted.VerifyGenesis(&other)
+			if !errors.Is(err, blockchain.ErrGenesisMismatch) {
+				t.Errorf("VerifyGenesis = %v, want ErrGenesisMismatch", err)
+			}
+		})
+	}
+}
+
+func TestGenesisBalancesWithinSupply(t *testing.T) {
+	authority, a, b := newTestKey(t), newTestKey(t), newTestKey(t)
+
+	tests := []struct {
+		name     string
+		supply   string // Token initial supply; empty for a genesis without a token
+		balances []string
+		valid    bool
+	}{
+		{name: "exactly at supply", supply: "1000", balances: []string{"600", "400"}, valid: true},
+		{name: "under supply", supply: "1000", balances: []string{"1", "2"}, valid: true},
+		{name: "over supply", supply: "1000", balances: []string{"600", "401"}},
+		{name: "one balance over supply", supply: "1000", balances: []string{"1001", "0"}},
+		{name: "negative balance", supply: "1000", balances: []string{"1500", "-600"}},
+		{name: "no token", balances: []string{"600", "401"}, valid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testGenesis(authority)
+			if tt.supply != "" {
+				config.TokenConfig = &blockchain.TokenConfig{Name: "Podoru", Symbol: "PDR", Decimals: 18, InitialSupply: tt.supply}
+			}
+			config.InitialBalances = map[string]string{a.address: tt.balances[0], b.address: tt.balances[1]}
+
+			err := config.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Validate accepted the genesis")
+			}
+		})
+	}
+}
+
+func TestGenesisBlockProducerAndTransactions(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	config := testGenesis(authority)
+
+	tests := []struct {
+		name   string
+		change func(*blockchain.Block)
+		valid  bool
+	}{
+		{name: "as created", change: func(*blockchain.Block) {}, valid: true},
+		{name: "other producer", change: func(b *blockchain.Block) { b.Header.ProducerAddr = authority.address }},
+		{name: "signed block", change: func(b *blockchain.Block) {
+			if err := b.Sign(authority.signer); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{name: "transaction from a user", change: func(b *blockchain.Block) {
+			b.Transactions = append(b.Transactions, newTestTx(t, user, "", 0, setOp("extra", "v")))
+		}},
+		{name: "signed genesis transaction", change: func(b *blockchain.Block) {
+			b.Transactions[0].Signature = []byte{1}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			genesis := blockchain.CreateGenesisBlock(config)
+			tt.change(genesis)
+			genesis.Header.MerkleRoot = blockchain.CalculateMerkleRoot(genesis.Transactions, genesis.Header.Version)
+
+			chain := blockchain.NewChain(storage.NewMemoryStore(), config.Authorities)
+			err := chain.Initialize(genesis)
+			if tt.valid && err != nil {
+				t.Errorf("Initialize: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Initialize accepted the genesis block")
+			}
+		})
+	}
+}
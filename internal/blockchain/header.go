@@ -0,0 +1,153 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrHeaderNotFound is returned for a height the header chain doesn't have
+var ErrHeaderNotFound = errors.New("header not found")
+
+// ErrInvalidStateProof is returned when a state proof doesn't match the state root
+// of the header it claims
+var ErrInvalidStateProof = errors.New("state proof does not match the header's state root")
+
+// SignedHeader is a block header with the producer's signature: everything a light
+// node needs to follow the chain without its transactions
+type SignedHeader struct {
+	Header    *BlockHeader `json:"header"`
+	Signature []byte       `json:"signature"`
+}
+
+// SignedHeader returns the block's header and signature
+func (b *Block) SignedHeader() *SignedHeader {
+	return &SignedHeader{Header: b.Header, Signature: b.Signature}
+}
+
+// Hash returns the hash of the block the header belongs to
+func (h *SignedHeader) Hash() []byte {
+	return h.Header.Hash()
+}
+
+// Verify verifies the producer's signature over the header
+func (h *SignedHeader) Verify() error {
+	return (&Block{Header: h.Header, Signature: h.Signature}).Verify()
+}
+
+// ValidateHeader checks a signed header against its parent, like ValidateBlock
+// without the transaction checks: height, previous hash, timestamp, producer
+// authority, signature and chain ID
+func ValidateHeader(header *SignedHeader, previous *BlockHeader, authorities *AuthoritySet, chainID string) error {
+	if header == nil || header.Header == nil {
+		return errors.New("block header is nil")
+	}
+
+	if err := validateHeader(header, previous, authorities); err != nil {
+		return err
+	}
+
+	if header.Header.ChainID != chainID {
+		return fmt.Errorf("%w: block is for chain %q, expected %q", ErrWrongChainID, header.Header.ChainID, chainID)
+	}
+	return nil
+}
+
+// HeaderChain is the header-only view of the chain kept by light nodes. Headers are
+// validated against their parent before they are appended, so the state roots they
+// carry can be trusted to verify state proofs from full nodes.
+type HeaderChain struct {
+	mu          sync.RWMutex
+	headers     []*SignedHeader // By height, starting at genesis
+	authorities *AuthoritySet
+	chainID     string
+
+	// validateProducer checks a header's producer against the consensus schedule
+	validateProducer func(block *Block, previous *Block) error
+}
+
+// NewHeaderChain creates a header chain starting at the given genesis block
+func NewHeaderChain(genesis *Block, authorities []string, chainID string) *HeaderChain {
+	return &HeaderChain{
+		headers:     []*SignedHeader{genesis.SignedHeader()},
+		authorities: NewAuthoritySet(authorities),
+		chainID:     chainID,
+	}
+}
+
+// SetProducerValidator sets a check of each header's producer against the consensus
+// schedule. The block passed to it has no transactions.
+func (hc *HeaderChain) SetProducerValidator(validator func(block *Block, previous *Block) error) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.validateProducer = validator
+}
+
+// SetAuthorities replaces the authorities new headers are validated against
+func (hc *HeaderChain) SetAuthorities(authorities []string) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.authorities = NewAuthoritySet(authorities)
+}
+
+// Height returns the height of the latest header
+func (hc *HeaderChain) Height() uint64 {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	return uint64(len(hc.headers) - 1)
+}
+
+// Tip returns the latest header
+func (hc *HeaderChain) Tip() *SignedHeader {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	return hc.headers[len(hc.headers)-1]
+}
+
+// GetHeader returns the header at height
+func (hc *HeaderChain) GetHeader(height uint64) (*SignedHeader, error) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	if height >= uint64(len(hc.headers)) {
+		return nil, fmt.Errorf("%w: height %d", ErrHeaderNotFound, height)
+	}
+	return hc.headers[height], nil
+}
+
+// AddHeaders validates headers in order on top of the tip and appends them. It stops
+// at the first invalid header, keeping the ones before it, and returns its error.
+func (hc *HeaderChain) AddHeaders(headers []*SignedHeader) error {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	for _, header := range headers {
+		previous := hc.headers[len(hc.headers)-1]
+		if err := ValidateHeader(header, previous.Header, hc.authorities, hc.chainID); err != nil {
+			return fmt.Errorf("invalid header at height %d: %w", len(hc.headers), err)
+		}
+
+		if hc.validateProducer != nil {
+			if err := hc.validateProducer(&Block{Header: header.Header}, &Block{Header: previous.Header}); err != nil {
+				return fmt.Errorf("invalid header at height %d: %w", len(hc.headers), err)
+			}
+		}
+
+		hc.headers = append(hc.headers, header)
+	}
+
+	return nil
+}
+
+// VerifyStateProof checks a state proof against the state root of the header at height
+func (hc *HeaderChain) VerifyStateProof(height uint64, proof *StateProof) error {
+	header, err := hc.GetHeader(height)
+	if err != nil {
+		return err
+	}
+
+	if !VerifyStateProof(header.Header, proof) {
+		return fmt.Errorf("%w at height %d", ErrInvalidStateProof, height)
+	}
+	return nil
+}
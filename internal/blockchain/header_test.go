@@ -0,0 +1,90 @@
+package blockchain_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+func TestHeaderChain(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	config := testGenesis(authority)
+	config.ChainID = "podoru-test"
+	chain := newTestChain(t, config)
+	genesis := chain.GetCurrentBlock()
+
+	var headers []*blockchain.SignedHeader
+	for i := range 3 {
+		block := addBlock(t, chain, authority, newTestTx(t, user, config.ChainID, uint64(i), setOp(fmt.Sprintf("k%d", i), "v")))
+		headers = append(headers, block.SignedHeader())
+	}
+
+	light := blockchain.NewHeaderChain(genesis, config.Authorities, config.ChainID)
+	if err := light.AddHeaders(headers); err != nil {
+		t.Fatalf("AddHeaders: %v", err)
+	}
+	if light.Height() != 3 {
+		t.Fatalf("height = %d, want 3", light.Height())
+	}
+
+	// State read through a proof from a full node
+	proof, tip, err := chain.ProveState("k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := light.VerifyStateProof(tip.Header.Height, proof); err != nil {
+		t.Errorf("VerifyStateProof: %v", err)
+	}
+	proof.Value = []byte("forged")
+	if err := light.VerifyStateProof(tip.Header.Height, proof); !errors.Is(err, blockchain.ErrInvalidStateProof) {
+		t.Errorf("VerifyStateProof of a forged value = %v, want ErrInvalidStateProof", err)
+	}
+	if err := light.VerifyStateProof(10, proof); !errors.Is(err, blockchain.ErrHeaderNotFound) {
+		t.Errorf("VerifyStateProof past the tip = %v, want ErrHeaderNotFound", err)
+	}
+}
+
+func TestHeaderChainRejectsInvalidHeaders(t *testing.T) {
+	authority, outsider := newTestKey(t), newTestKey(t)
+	config := testGenesis(authority)
+	chain := newTestChain(t, config)
+	genesis := chain.GetCurrentBlock()
+	first := addBlock(t, chain, authority)
+	second := buildBlock(t, chain, first, authority, nil)
+
+	// resign returns header changed by change and signed by key
+	resign := func(header *blockchain.BlockHeader, key *testKey, change func(*blockchain.BlockHeader)) *blockchain.SignedHeader {
+		changed := *header
+		change(&changed)
+		block := blockchain.NewBlock(&changed, nil)
+		if err := block.Sign(key.signer); err != nil {
+			t.Fatal(err)
+		}
+		return block.SignedHeader()
+	}
+
+	tests := map[string]*blockchain.SignedHeader{
+		"bad previous hash": resign(second.Header, authority, func(h *blockchain.BlockHeader) { h.PreviousHash = genesis.Hash() }),
+		"wrong height":      resign(second.Header, authority, func(h *blockchain.BlockHeader) { h.Height = 3 }),
+		"not an authority": resign(second.Header, outsider, func(h *blockchain.BlockHeader) {
+			h.ProducerAddr = outsider.address
+		}),
+		"bad signature":  {Header: second.Header, Signature: first.Signature},
+		"wrong chain ID": resign(second.Header, authority, func(h *blockchain.BlockHeader) { h.ChainID = "other" }),
+	}
+	for name, bad := range tests {
+		t.Run(name, func(t *testing.T) {
+			light := blockchain.NewHeaderChain(genesis, config.Authorities, config.ChainID)
+			err := light.AddHeaders([]*blockchain.SignedHeader{first.SignedHeader(), bad})
+			if err == nil {
+				t.Fatal("invalid header accepted")
+			}
+			// The valid header before it is kept
+			if light.Height() != 1 {
+				t.Errorf("height = %d, want 1", light.Height())
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package blockchain_test
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/crypto"
+	"github.com/podoru/podoru-chain/internal/storage"
+)
+
+// testKey is a key pair used to sign test transactions and blocks
+type testKey struct {
+	private *ecdsa.PrivateKey
+	signer  *crypto.KeySigner
+	address string
+}
+
+func newTestKey(t *testing.T) *testKey {
+	t.Helper()
+
+	private, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair: %v", err)
+	}
+	signer, err := crypto.NewKeySigner(private)
+	if err != nil {
+		t.Fatalf("NewKeySigner: %v", err)
+	}
+	return &testKey{private: private, signer: signer, address: signer.Address()}
+}
+
+// testGenesis returns a genesis config with a single authority, dated far enough
+// in the past that every test block can be one second after its parent
+func testGenesis(authority *testKey) *blockchain.GenesisConfig {
+	return &blockchain.GenesisConfig{
+		Timestamp:    time.Now().Add(-time.Hour).Unix(),
+		Authorities:  []string{authority.address},
+		InitialState: map[string]string{"greeting": "hello"},
+	}
+}
+
+// newTestChain creates a chain on a memory store and initializes it from config,
+// as the node does on first start
+func newTestChain(t *testing.T, config *blockchain.GenesisConfig) *blockchain.Chain {
+	t.Helper()
+
+	return newTestChainOn(t, storage.NewMemoryStore(), config)
+}
+
+// newTestChainOn is newTestChain on a given store
+func newTestChainOn(t *testing.T, store blockchain.Storage, config *blockchain.GenesisConfig) *blockchain.Chain {
+	t.Helper()
+
+	chain := blockchain.NewChainWithConfig(store, config.Authorities, config.GetGasConfig(), config.TokenConfig)
+	chain.SetConsensusParams(config.GetConsensusParams())
+	chain.SetKeyPolicy(config.KeyPolicy)
+	chain.SetChainID(config.ChainID)
+	if err := chain.Initialize(blockchain.CreateGenesisBlock(config)); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return chain
+}
+
+// newTestTx returns a transaction from key with the given operations, signed for chainID
+func newTestTx(t *testing.T, key *testKey, chainID string, nonce uint64, ops ...*blockchain.KVOperation) *blockchain.Transaction {
+	t.Helper()
+
+	tx := blockchain.NewTransaction(key.address, time.Now().Unix(), &blockchain.TransactionData{Operations: ops}, nonce)
+	tx.ChainID = chainID
+	if err := tx.Sign(key.private); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return tx
+}
+
+// setOp returns a SET operation
+func setOp(key, value string) *blockchain.KVOperation {
+	return &blockchain.KVOperation{Type: blockchain.OpTypeSet, Key: key, Value: []byte(value)}
+}
+
+// amount returns n whole tokens in wei
+func amount(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e18))
+}
+
+// buildBlock builds and signs the block producer would produce on top of parent,
+// taking the state root from the chain as the node does
+func buildBlock(t *testing.T, chain *blockchain.Chain, parent *blockchain.Block, producer *testKey, transactions []*blockchain.Transaction) *blockchain.Block {
+	t.Helper()
+
+	blockchain.SortTransactions(transactions)
+	stateRoot, err := chain.CalculateStateRootWithTransactions(transactions, producer.address)
+	if err != nil {
+		t.Fatalf("CalculateStateRootWithTransactions: %v", err)
+	}
+	return signBlock(t, chain, parent, producer, transactions, stateRoot)
+}
+
+// signBlock builds and signs a block for chain on top of parent with the given state root
+func signBlock(t *testing.T, chain *blockchain.Chain, parent *blockchain.Block, producer *testKey, transactions []*blockchain.Transaction, stateRoot []byte) *blockchain.Block {
+	t.Helper()
+
+	version := chain.GetConsensusParams().BlockVersion
+	header := &blockchain.BlockHeader{
+		Version:      version,
+		Height:       parent.Header.Height + 1,
+		PreviousHash: parent.Hash(),
+		Timestamp:    parent.Header.Timestamp + 1,
+		MerkleRoot:   blockchain.CalculateMerkleRoot(transactions, version),
+		StateRoot:    stateRoot,
+		ProducerAddr: producer.address,
+		ChainID:      chain.GetChainID(),
+	}
+	block := blockchain.NewBlock(header, transactions)
+	if err := block.Sign(producer.signer); err != nil {
+		t.Fatalf("Sign block: %v", err)
+	}
+	return block
+}
+
+// addBlock builds a block with transactions on top of the chain's tip and adds it
+func addBlock(t *testing.T, chain *blockchain.Chain, producer *testKey, transactions ...*blockchain.Transaction) *blockchain.Block {
+	t.Helper()
+
+	block := buildBlock(t, chain, chain.GetCurrentBlock(), producer, transactions)
+	if err := chain.AddBlock(block); err != nil {
+		t.Fatalf("AddBlock at height %d: %v", block.Header.Height, err)
+	}
+	return block
+}
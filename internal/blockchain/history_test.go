@@ -0,0 +1,75 @@
+package blockchain_test
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+func TestBalancesAtHeight(t *testing.T) {
+	authority, user, recipient := newTestKey(t), newTestKey(t), newTestKey(t)
+	chain := newTestChain(t, tokenGenesis(authority, user, true))
+
+	// Balances as of each height, recorded while the chain grows
+	accounts := []string{authority.address, user.address, recipient.address}
+	record := func() map[string]*big.Int {
+		balances := map[string]*big.Int{}
+		for _, address := range accounts {
+			balances[strings.ToLower(address)] = balanceOf(t, chain, address)
+		}
+		return balances
+	}
+	history := []map[string]*big.Int{record()}
+	for nonce := range uint64(3) {
+		addBlock(t, chain, authority, newTestTx(t, user, "", nonce,
+			blockchain.NewTransferOperation(recipient.address, amount(10).Bytes())))
+		history = append(history, record())
+	}
+
+	for height, want := range history {
+		balances, err := chain.BalancesAtHeight(uint64(height))
+		if err != nil {
+			t.Fatalf("BalancesAtHeight(%d): %v", height, err)
+		}
+		if !sort.SliceIsSorted(balances, func(i, j int) bool { return balances[i].Address < balances[j].Address }) {
+			t.Errorf("height %d: balances are not sorted by address", height)
+		}
+
+		got := map[string]*big.Int{}
+		for _, entry := range balances {
+			got[strings.ToLower(entry.Address)] = entry.Balance
+		}
+		for address, balance := range want {
+			if balance.Sign() == 0 {
+				if _, listed := got[address]; listed {
+					t.Errorf("height %d: zero balance of %s is listed", height, address)
+				}
+				continue
+			}
+			if got[address] == nil || got[address].Cmp(balance) != 0 {
+				t.Errorf("height %d: balance of %s = %v, want %s", height, address, got[address], balance)
+			}
+		}
+
+		historical, err := chain.GetBalanceAtHeight(recipient.address, uint64(height))
+		if err != nil {
+			t.Fatalf("GetBalanceAtHeight(%d): %v", height, err)
+		}
+		if want := want[strings.ToLower(recipient.address)]; historical.Cmp(want) != 0 {
+			t.Errorf("height %d: recipient balance = %s, want %s", height, historical, want)
+		}
+	}
+
+	// A past snapshot differs from the current balances
+	if history[1][strings.ToLower(recipient.address)].Cmp(balanceOf(t, chain, recipient.address)) == 0 {
+		t.Error("recipient balance did not change after height 1")
+	}
+
+	if _, err := chain.BalancesAtHeight(chain.GetHeight() + 1); !errors.Is(err, blockchain.ErrHeightAboveTip) {
+		t.Errorf("BalancesAtHeight above the tip: err = %v, want ErrHeightAboveTip", err)
+	}
+}
@@ -0,0 +1,48 @@
+package blockchain
+
+import "errors"
+
+// ErrIndexingDisabled is returned by index queries when the index is turned off
+var ErrIndexingDisabled = errors.New("indexing disabled")
+
+// IndexConfig controls which secondary transaction indexes are written
+type IndexConfig struct {
+	Sender   bool // Transactions by sender address
+	Key      bool // Transactions by touched state key
+	Transfer bool // Transfer history by sender and recipient
+}
+
+// DefaultIndexConfig returns an index configuration with all indexes enabled
+func DefaultIndexConfig() *IndexConfig {
+	return &IndexConfig{
+		Sender:   true,
+		Key:      true,
+		Transfer: true,
+	}
+}
+
+// TransferParties returns the lowercased addresses whose balances are moved by
+// the transaction's TRANSFER and BURN operations (sender and recipients)
+func (tx *Transaction) TransferParties() []string {
+	if !tx.HasTransferOperations() && !tx.HasBurnOperations() {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	parties := make([]string, 0)
+	add := func(addr string) {
+		if !seen[addr] {
+			seen[addr] = true
+			parties = append(parties, addr)
+		}
+	}
+
+	add(AddressFromBalanceKey(BalanceKey(tx.From)))
+	for _, op := range tx.Data.Operations {
+		if op.Type == OpTypeTransfer {
+			add(AddressFromBalanceKey(op.Key))
+		}
+	}
+
+	return parties
+}
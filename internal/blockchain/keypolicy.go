@@ -0,0 +1,110 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/podoru/podoru-chain/internal/crypto"
+)
+
+// UserKeyPrefix prefixes the keys owned by an address under a key policy:
+// u:<address>:<key>
+const UserKeyPrefix = "u:"
+
+// UnprefixedWrites controls who may write keys outside the user namespaces
+type UnprefixedWrites string
+
+const (
+	// UnprefixedWritesOpen lets any sender write unprefixed keys (default)
+	UnprefixedWritesOpen UnprefixedWrites = "open"
+
+	// UnprefixedWritesAuthority lets only authorities write unprefixed keys
+	UnprefixedWritesAuthority UnprefixedWrites = "authority"
+)
+
+// ErrKeyNotOwned is returned when a transaction writes a key its sender may not
+// write under the key policy
+var ErrKeyNotOwned = errors.New("sender may not write this key")
+
+// KeyPolicy restricts which senders may write which keys. Keys in a user namespace
+// (u:<address>:) may only be written by that address; unprefixed keys are open or
+// authority-only. It changes which blocks are valid, so it is a genesis option.
+type KeyPolicy struct {
+	UnprefixedWrites UnprefixedWrites `json:"unprefixed_writes,omitempty"` // Empty means UnprefixedWritesOpen
+}
+
+// Validate validates the key policy
+func (kp *KeyPolicy) Validate() error {
+	switch kp.UnprefixedWrites {
+	case "", UnprefixedWritesOpen, UnprefixedWritesAuthority:
+		return nil
+	default:
+		return fmt.Errorf("invalid unprefixed_writes %q (must be %q or %q)",
+			kp.UnprefixedWrites, UnprefixedWritesOpen, UnprefixedWritesAuthority)
+	}
+}
+
+// UserKey returns the key in the namespace of address
+func UserKey(address, key string) string {
+	return UserKeyPrefix + strings.ToLower(address) + ":" + key
+}
+
+// keyOwner returns the address owning a user namespace key, or false if the key is
+// outside the user namespaces. A malformed user key has an empty owner.
+func keyOwner(key string) (string, bool) {
+	rest, found := strings.CutPrefix(key, UserKeyPrefix)
+	if !found {
+		return "", false
+	}
+	owner, _, found := strings.Cut(rest, ":")
+	if !found {
+		return "", true
+	}
+	return owner, true
+}
+
+// isKeyWrite reports whether an operation type writes its key directly. Token
+// operations on balance keys follow their own rules.
+func isKeyWrite(opType OperationType) bool {
+	switch opType {
+	case OpTypeSet, OpTypeDelete, OpTypeCAS, OpTypeIncrement:
+		return true
+	}
+	return false
+}
+
+// SetKeyPolicy sets the key policy enforced when applying transactions (nil allows
+// any sender to write any key)
+func (c *Chain) SetKeyPolicy(policy *KeyPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keyPolicy = policy
+}
+
+// GetKeyPolicy returns the key policy, or nil if there is none
+func (c *Chain) GetKeyPolicy() *KeyPolicy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.keyPolicy
+}
+
+// checkKeyPermission checks that the key policy lets tx write op's key (caller must hold c.mu)
+func (c *Chain) checkKeyPermission(tx *Transaction, op *KVOperation) error {
+	if c.keyPolicy == nil || tx.IsGenesisTransaction() || !isKeyWrite(op.Type) {
+		return nil
+	}
+
+	if owner, namespaced := keyOwner(op.Key); namespaced {
+		if owner == "" || crypto.NormalizeAddress(owner) != crypto.NormalizeAddress(tx.From) {
+			return fmt.Errorf("%s %s: %w", op.Type, op.Key, ErrKeyNotOwned)
+		}
+		return nil
+	}
+
+	if c.keyPolicy.UnprefixedWrites == UnprefixedWritesAuthority && !c.isAuthority(tx.From) {
+		return fmt.Errorf("%s %s: only authorities may write keys outside %s<address>: namespaces: %w",
+			op.Type, op.Key, UserKeyPrefix, ErrKeyNotOwned)
+	}
+	return nil
+}
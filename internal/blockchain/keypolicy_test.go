@@ -0,0 +1,70 @@
+package blockchain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+func TestKeyPolicy(t *testing.T) {
+	authority, owner, other := newTestKey(t), newTestKey(t), newTestKey(t)
+	ownedKey := blockchain.UserKey(owner.address, "profile")
+
+	tests := []struct {
+		name    string
+		policy  *blockchain.KeyPolicy
+		sender  *testKey
+		op      *blockchain.KVOperation
+		allowed bool
+	}{
+		{name: "owner writes own namespace", policy: &blockchain.KeyPolicy{}, sender: owner, op: setOp(ownedKey, "v"), allowed: true},
+		{name: "owner deletes own key", policy: &blockchain.KeyPolicy{}, sender: owner, op: &blockchain.KVOperation{Type: blockchain.OpTypeDelete, Key: ownedKey}, allowed: true},
+		{name: "non-owner writes namespace", policy: &blockchain.KeyPolicy{}, sender: other, op: setOp(ownedKey, "v")},
+		{name: "non-owner deletes key", policy: &blockchain.KeyPolicy{}, sender: other, op: &blockchain.KVOperation{Type: blockchain.OpTypeDelete, Key: ownedKey}},
+		{name: "authority writes a user namespace", policy: &blockchain.KeyPolicy{}, sender: authority, op: setOp(ownedKey, "v")},
+		{name: "key without an owner", policy: &blockchain.KeyPolicy{}, sender: owner, op: setOp(blockchain.UserKeyPrefix+"profile", "v")},
+		{name: "open unprefixed key", policy: &blockchain.KeyPolicy{}, sender: other, op: setOp("shared", "v"), allowed: true},
+		{name: "authority-only unprefixed key", policy: &blockchain.KeyPolicy{UnprefixedWrites: blockchain.UnprefixedWritesAuthority}, sender: other, op: setOp("shared", "v")},
+		{name: "authority writes unprefixed key", policy: &blockchain.KeyPolicy{UnprefixedWrites: blockchain.UnprefixedWritesAuthority}, sender: authority, op: setOp("shared", "v"), allowed: true},
+		{name: "owner under authority-only policy", policy: &blockchain.KeyPolicy{UnprefixedWrites: blockchain.UnprefixedWritesAuthority}, sender: owner, op: setOp(ownedKey, "v"), allowed: true},
+		{name: "no policy", sender: other, op: setOp(ownedKey, "v"), allowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testGenesis(authority)
+			config.KeyPolicy = tt.policy
+			chain := newTestChain(t, config)
+			tx := newTestTx(t, tt.sender, "", 0, tt.op)
+
+			applicable, rejected := chain.FilterApplicableTransactions([]*blockchain.Transaction{tx})
+			if !tt.allowed {
+				if len(rejected) != 1 || !errors.Is(rejected[0].Err, blockchain.ErrKeyNotOwned) {
+					t.Fatalf("rejected %v, want ErrKeyNotOwned", rejected)
+				}
+				block := signBlock(t, chain, chain.GetCurrentBlock(), authority, []*blockchain.Transaction{tx}, chain.GetStateRoot())
+				if err := chain.AddBlock(block); !errors.Is(err, blockchain.ErrKeyNotOwned) {
+					t.Errorf("AddBlock = %v, want ErrKeyNotOwned", err)
+				}
+				return
+			}
+
+			if len(applicable) != 1 {
+				t.Fatalf("rejected %v, want the write allowed", rejected)
+			}
+			addBlock(t, chain, authority, tx)
+		})
+	}
+}
+
+func TestKeyPolicyValidate(t *testing.T) {
+	for _, writes := range []blockchain.UnprefixedWrites{"", blockchain.UnprefixedWritesOpen, blockchain.UnprefixedWritesAuthority} {
+		if err := (&blockchain.KeyPolicy{UnprefixedWrites: writes}).Validate(); err != nil {
+			t.Errorf("unprefixed_writes %q: %v", writes, err)
+		}
+	}
+	if err := (&blockchain.KeyPolicy{UnprefixedWrites: "owner"}).Validate(); err == nil {
+		t.Error("unknown unprefixed_writes accepted")
+	}
+}
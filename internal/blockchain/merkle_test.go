@@ -0,0 +1,191 @@
+package blockchain_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+// legacyRoot is the merkle tree chains were created with, written out independently:
+// unprefixed leaves and nodes, with an odd node hashed with itself
+func legacyRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		return make([]byte, 32)
+	}
+	for len(leaves) > 1 {
+		var next [][]byte
+		for i := 0; i < len(leaves); i += 2 {
+			right := leaves[i]
+			if i+1 < len(leaves) {
+				right = leaves[i+1]
+			}
+			sum := sha256.Sum256(append(append([]byte{}, leaves[i]...), right...))
+			next = append(next, sum[:])
+		}
+		leaves = next
+	}
+	return leaves[0]
+}
+
+// legacyStateRoot is the legacy state root of entries
+func legacyStateRoot(entries map[string][]byte) []byte {
+	var leaves [][]byte
+	for _, key := range slices.Sorted(maps.Keys(entries)) {
+		sum := sha256.Sum256(append([]byte(key), entries[key]...))
+		leaves = append(leaves, sum[:])
+	}
+	return legacyRoot(leaves)
+}
+
+func stateOf(entries map[string]string) *blockchain.State {
+	state := blockchain.NewState()
+	for key, value := range entries {
+		state.Set(key, []byte(value))
+	}
+	return state
+}
+
+func TestStateLeafSeparatesKeyFromValue(t *testing.T) {
+	a := stateOf(map[string]string{"ab": "c"})
+	b := stateOf(map[string]string{"a": "bc"})
+
+	if bytes.Equal(a.CalculateRoot(blockchain.MerkleTreeVersion), b.CalculateRoot(blockchain.MerkleTreeVersion)) {
+		t.Error(`("ab", "c") and ("a", "bc") have the same state root`)
+	}
+}
+
+func TestLegacyTreeUnchanged(t *testing.T) {
+	user := newTestKey(t)
+	var txs []*blockchain.Transaction
+	for i := range 3 {
+		txs = append(txs, newTestTx(t, user, "", uint64(i), setOp(fmt.Sprintf("k%d", i), "v")))
+	}
+
+	var leaves [][]byte
+	for _, tx := range txs {
+		leaves = append(leaves, tx.Hash())
+	}
+	for _, version := range []uint32{1, 2} {
+		if got := blockchain.CalculateMerkleRoot(txs, version); !bytes.Equal(got, legacyRoot(leaves)) {
+			t.Errorf("version %d merkle root changed", version)
+		}
+	}
+
+	entries := map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": []byte("3")}
+	state := blockchain.NewState()
+	for key, value := range entries {
+		state.Set(key, value)
+	}
+	if got := state.CalculateRoot(1); !bytes.Equal(got, legacyStateRoot(entries)) {
+		t.Error("version 1 state root changed")
+	}
+}
+
+func TestLegacyBlockStillValidates(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	chain := newTestChain(t, testGenesis(authority))
+
+	// Build the block as a node from before the tree changed would have
+	entries := make(map[string][]byte)
+	keys, err := chain.GetAllStateKeys(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		value, err := chain.GetState(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[key] = value
+	}
+	if !bytes.Equal(chain.GetStateRoot(), legacyStateRoot(entries)) {
+		t.Fatal("genesis state root is not the legacy root")
+	}
+
+	var txs []*blockchain.Transaction
+	var leaves [][]byte
+	for i := range 3 {
+		tx := newTestTx(t, user, "", uint64(i), setOp(fmt.Sprintf("k%d", i), "v"))
+		txs = append(txs, tx)
+		leaves = append(leaves, tx.Hash())
+		entries[fmt.Sprintf("k%d", i)] = []byte("v")
+	}
+
+	block := signBlock(t, chain, chain.GetCurrentBlock(), authority, txs, legacyStateRoot(entries))
+	block.Header.MerkleRoot = legacyRoot(leaves)
+	if err := block.Sign(authority.signer); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.AddBlock(block); err != nil {
+		t.Fatalf("AddBlock of a legacy-tree block: %v", err)
+	}
+}
+
+func TestMerkleTreeVersionChain(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	config := testGenesis(authority)
+	config.ConsensusParams = &blockchain.ConsensusParams{BlockVersion: blockchain.MerkleTreeVersion}
+	chain := newTestChain(t, config)
+
+	if got := chain.GetCurrentBlock().Header.Version; got != blockchain.MerkleTreeVersion {
+		t.Fatalf("genesis version = %d, want %d", got, blockchain.MerkleTreeVersion)
+	}
+
+	var txs []*blockchain.Transaction
+	for i := range 3 {
+		txs = append(txs, newTestTx(t, user, "", uint64(i), setOp(fmt.Sprintf("k%d", i), "v")))
+	}
+	block := addBlock(t, chain, authority, txs...)
+
+	for _, key := range []string{"greeting", "k0", "k1", "k2", blockchain.AuthoritySetKey} {
+		proof, tip, err := chain.ProveState(key)
+		if err != nil {
+			t.Fatalf("ProveState(%s): %v", key, err)
+		}
+		if !blockchain.VerifyStateProof(tip.Header, proof) {
+			t.Errorf("proof of %s does not verify", key)
+		}
+		legacy := *tip.Header
+		legacy.Version = 1
+		if blockchain.VerifyStateProof(&legacy, proof) {
+			t.Errorf("proof of %s verifies with the legacy tree", key)
+		}
+	}
+
+	// A block on the legacy tree is invalid on this chain
+	legacy := newTestTx(t, user, "", 3, setOp("k3", "v"))
+	old := signBlock(t, chain, block, authority, []*blockchain.Transaction{legacy}, chain.GetStateRoot())
+	old.Header.Version = 1
+	old.Header.MerkleRoot = blockchain.CalculateMerkleRoot(old.Transactions, 1)
+	if err := old.Sign(authority.signer); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.AddBlock(old); err == nil {
+		t.Error("version 1 block accepted on a version 3 chain")
+	}
+}
+
+func TestLegacyStateProofs(t *testing.T) {
+	// An odd number of entries exercises the node paired with itself
+	state := stateOf(map[string]string{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5"})
+	header := &blockchain.BlockHeader{Version: 1, StateRoot: state.CalculateRoot(1)}
+
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		proof, err := state.Prove(key, 1)
+		if err != nil {
+			t.Fatalf("Prove(%s): %v", key, err)
+		}
+		if !blockchain.VerifyStateProof(header, proof) {
+			t.Errorf("legacy proof of %s does not verify", key)
+		}
+		proof.Value = []byte("forged")
+		if blockchain.VerifyStateProof(header, proof) {
+			t.Errorf("forged legacy proof of %s verifies", key)
+		}
+	}
+}
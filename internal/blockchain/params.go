@@ -0,0 +1,137 @@
+package blockchain
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// MinBlockSize is the smallest max_block_size a genesis file may set (16 KB)
+	MinBlockSize = 16 * 1024
+
+	// MaxBlockSizeLimit is the largest max_block_size a genesis file may set (8 MB), so
+	// every valid block fits in a single P2P message
+	MaxBlockSizeLimit = 8 * 1024 * 1024
+
+	// DefaultMaxMempoolSize is the default maximum number of transactions in the mempool
+	DefaultMaxMempoolSize = 10000
+
+	// DefaultMaxMempoolTxSize is the default maximum size of a pending transaction (1 MB)
+	DefaultMaxMempoolTxSize = 1024 * 1024
+
+	// DefaultSlotTimeoutBlocks is how many block times pass before a missed slot goes
+	// to the next authority when the genesis file sets no slot_timeout
+	DefaultSlotTimeoutBlocks = 2
+
+	// DefaultBlockVersion is the block version of chains whose genesis file sets none
+	DefaultBlockVersion uint32 = 1
+)
+
+// ConsensusParams are the block and mempool limits of a network, set in the genesis
+// file. Zero fields take the defaults, so genesis files without them keep the
+// previous hard-coded limits.
+type ConsensusParams struct {
+	MaxBlockSize            int `json:"max_block_size,omitempty"` // Bytes
+	MaxTransactionsPerBlock int `json:"max_transactions_per_block,omitempty"`
+	MaxMempoolSize          int `json:"max_mempool_size,omitempty"`    // Pending transactions
+	MaxMempoolTxSize        int `json:"max_mempool_tx_size,omitempty"` // Bytes; defaults to the smaller of 1 MB and max_block_size
+
+	// MaxOperationsPerTransaction limits the operations of a transaction in a valid
+	// block. Zero means no limit: chains created before the field existed never had
+	// one, so it has no default.
+	MaxOperationsPerTransaction int `json:"max_operations_per_transaction,omitempty"`
+
+	// SlotTimeout is how many seconds after the previous block the authority whose
+	// turn it is may be skipped for the next one in the rotation. Zero means twice
+	// the node's block_time, as before the field existed.
+	SlotTimeout int `json:"slot_timeout,omitempty"`
+
+	// BlockVersion is the version of every block after genesis, and of the genesis
+	// block itself from MerkleTreeVersion on. It selects the merkle tree of the
+	// transaction and state roots, so it can't change on an existing chain. Defaults
+	// to 1, the legacy tree chains were created with before the field existed.
+	BlockVersion uint32 `json:"block_version,omitempty"`
+}
+
+// DefaultConsensusParams returns the limits used when the genesis file sets none
+func DefaultConsensusParams() *ConsensusParams {
+	return (*ConsensusParams)(nil).WithDefaults()
+}
+
+// WithDefaults returns a copy of the params with unset fields filled in. It may be
+// called on a nil *ConsensusParams.
+func (p *ConsensusParams) WithDefaults() *ConsensusParams {
+	params := &ConsensusParams{}
+	if p != nil {
+		*params = *p
+	}
+
+	if params.MaxBlockSize == 0 {
+		params.MaxBlockSize = DefaultMaxBlockSize
+	}
+	if params.MaxTransactionsPerBlock == 0 {
+		params.MaxTransactionsPerBlock = DefaultMaxTransactionsPerBlock
+	}
+	if params.MaxMempoolSize == 0 {
+		params.MaxMempoolSize = DefaultMaxMempoolSize
+	}
+	if params.MaxMempoolTxSize == 0 {
+		params.MaxMempoolTxSize = min(DefaultMaxMempoolTxSize, params.MaxBlockSize)
+	}
+	if params.BlockVersion == 0 {
+		params.BlockVersion = DefaultBlockVersion
+	}
+	return params
+}
+
+// Validate checks the params once defaults are applied
+func (p *ConsensusParams) Validate() error {
+	params := p.WithDefaults()
+
+	if params.MaxBlockSize < MinBlockSize || params.MaxBlockSize > MaxBlockSizeLimit {
+		return fmt.Errorf("max_block_size must be between %d and %d bytes", MinBlockSize, MaxBlockSizeLimit)
+	}
+	if params.MaxTransactionsPerBlock < 1 {
+		return fmt.Errorf("max_transactions_per_block must be at least 1")
+	}
+	if params.MaxMempoolSize < 1 {
+		return fmt.Errorf("max_mempool_size must be at least 1")
+	}
+	if params.MaxMempoolTxSize < 1 || params.MaxMempoolTxSize > params.MaxBlockSize {
+		return fmt.Errorf("max_mempool_tx_size must be between 1 and max_block_size (%d)", params.MaxBlockSize)
+	}
+	if params.MaxOperationsPerTransaction < 0 {
+		return fmt.Errorf("max_operations_per_transaction cannot be negative")
+	}
+	if params.SlotTimeout < 0 {
+		return fmt.Errorf("slot_timeout cannot be negative")
+	}
+	if params.BlockVersion != DefaultBlockVersion && params.BlockVersion != MerkleTreeVersion {
+		return fmt.Errorf("block_version must be %d or %d", DefaultBlockVersion, MerkleTreeVersion)
+	}
+	return nil
+}
+
+// SlotTimeoutFor returns the slot timeout for a network producing a block every
+// blockTime: the genesis slot_timeout if set, otherwise DefaultSlotTimeoutBlocks block times
+func (p *ConsensusParams) SlotTimeoutFor(blockTime time.Duration) time.Duration {
+	if p != nil && p.SlotTimeout > 0 {
+		return time.Duration(p.SlotTimeout) * time.Second
+	}
+	return DefaultSlotTimeoutBlocks * blockTime
+}
+
+// SetConsensusParams sets the block limits enforced when validating blocks (nil uses the defaults)
+func (c *Chain) SetConsensusParams(params *ConsensusParams) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.params = params.WithDefaults()
+}
+
+// GetConsensusParams returns the block and mempool limits of the chain
+func (c *Chain) GetConsensusParams() *ConsensusParams {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	params := *c.params
+	return &params
+}
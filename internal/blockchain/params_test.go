@@ -0,0 +1,56 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlotTimeoutFor(t *testing.T) {
+	// Without a genesis slot_timeout it follows the block time, so long block times still work
+	if got := DefaultConsensusParams().SlotTimeoutFor(time.Minute); got != 2*time.Minute {
+		t.Fatalf("default slot timeout for a 1m block time = %v, want 2m", got)
+	}
+	if got := (*ConsensusParams)(nil).SlotTimeoutFor(5 * time.Second); got != 10*time.Second {
+		t.Fatalf("default slot timeout for a 5s block time = %v, want 10s", got)
+	}
+
+	params := &ConsensusParams{SlotTimeout: 30}
+	if got := params.SlotTimeoutFor(time.Minute); got != 30*time.Second {
+		t.Fatalf("genesis slot timeout = %v, want 30s", got)
+	}
+	if err := (&ConsensusParams{SlotTimeout: -1}).Validate(); err == nil {
+		t.Fatal("negative slot_timeout accepted")
+	}
+}
+
+func TestConsensusParamsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  *ConsensusParams
+		wantErr bool
+	}{
+		{name: "defaults", params: nil},
+		{name: "smallest block size", params: &ConsensusParams{MaxBlockSize: MinBlockSize}},
+		{name: "block size below the minimum", params: &ConsensusParams{MaxBlockSize: MinBlockSize - 1}, wantErr: true},
+		{name: "block size above the limit", params: &ConsensusParams{MaxBlockSize: MaxBlockSizeLimit + 1}, wantErr: true},
+		{name: "negative transactions per block", params: &ConsensusParams{MaxTransactionsPerBlock: -1}, wantErr: true},
+		{name: "mempool tx size above the block size", params: &ConsensusParams{MaxBlockSize: MinBlockSize, MaxMempoolTxSize: MinBlockSize + 1}, wantErr: true},
+		{name: "unknown block version", params: &ConsensusParams{BlockVersion: 99}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.params.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Unset limits take the defaults; the mempool tx size follows a small block size
+	params := (&ConsensusParams{MaxBlockSize: MinBlockSize}).WithDefaults()
+	if params.MaxTransactionsPerBlock != DefaultConsensusParams().MaxTransactionsPerBlock {
+		t.Errorf("max_transactions_per_block = %d, want the default", params.MaxTransactionsPerBlock)
+	}
+	if params.MaxMempoolTxSize != MinBlockSize {
+		t.Errorf("max_mempool_tx_size = %d, want max_block_size %d", params.MaxMempoolTxSize, MinBlockSize)
+	}
+}
@@ -0,0 +1,134 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrKeyNotFound is returned when proving a key that is not in state
+var ErrKeyNotFound = errors.New("key not found")
+
+// MerkleProofStep is one sibling hash on the path from a leaf to the root
+type MerkleProofStep struct {
+	Hash []byte // Sibling hash
+	Left bool   // Whether the sibling is the left input of the pair
+}
+
+// StateProof proves that a key holds a value under a state root
+type StateProof struct {
+	Key   string
+	Value []byte
+	Steps []MerkleProofStep
+}
+
+// merkleProof returns the sibling path for the leaf at index, following the
+// pairing used by buildMerkleTree for blocks of version
+func merkleProof(hashes [][]byte, index int, version uint32) []MerkleProofStep {
+	var steps []MerkleProofStep
+
+	for len(hashes) > 1 {
+		switch {
+		case index%2 == 1:
+			steps = append(steps, MerkleProofStep{Hash: hashes[index-1], Left: true})
+		case index+1 < len(hashes):
+			steps = append(steps, MerkleProofStep{Hash: hashes[index+1], Left: false})
+		case legacyMerkle(version):
+			// The legacy tree pairs an odd node with itself
+			steps = append(steps, MerkleProofStep{Hash: hashes[index], Left: false})
+		}
+
+		hashes = nextMerkleLevel(hashes, version)
+		index /= 2
+	}
+
+	return steps
+}
+
+// VerifyMerkleProof reports whether leaf hashes up to root along steps in the tree of
+// blocks of version
+func VerifyMerkleProof(leaf []byte, steps []MerkleProofStep, root []byte, version uint32) bool {
+	hash := leaf
+	for _, step := range steps {
+		if step.Left {
+			hash = merkleNode(step.Hash, hash, version)
+		} else {
+			hash = merkleNode(hash, step.Hash, version)
+		}
+	}
+	return bytes.Equal(hash, root)
+}
+
+// stateLeaf returns the Merkle leaf of a state entry in the tree of blocks of version.
+// The domain-separated tree prefixes the key with its length, so ("ab", "c") and
+// ("a", "bc") get different leaves; the legacy leaf is just sha256(key || value).
+func stateLeaf(key string, value []byte, version uint32) []byte {
+	if legacyMerkle(version) {
+		hash := sha256.Sum256(append([]byte(key), value...))
+		return hash[:]
+	}
+
+	data := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(key)+len(value)), uint64(len(key)))
+	data = append(data, key...)
+	data = append(data, value...)
+	return merkleLeaf(data)
+}
+
+// Prove returns a Merkle proof of key's current value against CalculateRoot for
+// blocks of version
+func (s *State) Prove(key string, version uint32) (*StateProof, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, exists := s.data[key]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+
+	keys := make([]string, 0, len(s.data))
+	for k := range s.data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	index := 0
+	hashes := make([][]byte, len(keys))
+	for i, k := range keys {
+		hashes[i] = stateLeaf(k, s.data[k], version)
+		if k == key {
+			index = i
+		}
+	}
+
+	return &StateProof{
+		Key:   key,
+		Value: append([]byte{}, value...),
+		Steps: merkleProof(hashes, index, version),
+	}, nil
+}
+
+// VerifyStateProof reports whether proof shows its key holding its value under the
+// state root of header
+func VerifyStateProof(header *BlockHeader, proof *StateProof) bool {
+	if header == nil || proof == nil {
+		return false
+	}
+	leaf := stateLeaf(proof.Key, proof.Value, header.Version)
+	return VerifyMerkleProof(leaf, proof.Steps, header.StateRoot, header.Version)
+}
+
+// ProveState returns a Merkle proof of key against the current state, together with
+// the tip block whose header commits to that state root
+func (c *Chain) ProveState(key string) (*StateProof, *Block, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	proof, err := c.state.Prove(key, c.tipVersion())
+	if err != nil {
+		return nil, nil, err
+	}
+	return proof, c.currentBlock, nil
+}
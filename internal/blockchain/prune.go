@@ -0,0 +1,143 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// MinBlockRetention is the fewest recent blocks a pruning node keeps, so that any
+// reorganization up to MaxReorgDepth can still be replayed
+const MinBlockRetention = MaxReorgDepth
+
+// ErrBlockPruned is returned for blocks and state that pruning has removed
+var ErrBlockPruned = errors.New("block has been pruned")
+
+// storedSnapshot is the persisted form of a state snapshot
+type storedSnapshot struct {
+	Height uint64            `json:"height"`
+	State  map[string][]byte `json:"state"`
+	Nonces map[string]uint64 `json:"nonces"`
+	Weight uint64            `json:"weight"`
+
+	// Counters behind ChainStats (absent from snapshots saved before they existed)
+	Transactions   uint64 `json:"transactions,omitempty"`
+	Fees           string `json:"fees,omitempty"`
+	FirstBlockTime int64  `json:"first_block_time,omitempty"`
+}
+
+// encodeSnapshot serializes a snapshot for storage
+func encodeSnapshot(snapshot *stateSnapshot) ([]byte, error) {
+	snapshot.state.mu.RLock()
+	defer snapshot.state.mu.RUnlock()
+
+	return json.Marshal(&storedSnapshot{
+		Height: snapshot.height,
+		State:  snapshot.state.data,
+		Nonces: snapshot.nonces,
+		Weight: snapshot.weight,
+
+		Transactions:   snapshot.counters.transactions,
+		Fees:           snapshot.counters.fees.String(),
+		FirstBlockTime: snapshot.counters.firstBlockTime,
+	})
+}
+
+// decodeSnapshot parses a snapshot saved by encodeSnapshot
+func decodeSnapshot(data []byte) (*stateSnapshot, error) {
+	var stored storedSnapshot
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode state snapshot: %w", err)
+	}
+
+	state := NewState()
+	for key, value := range stored.State {
+		state.data[key] = value
+	}
+	nonces := stored.Nonces
+	if nonces == nil {
+		nonces = make(map[string]uint64)
+	}
+
+	counters := newChainCounters()
+	counters.transactions = stored.Transactions
+	counters.firstBlockTime = stored.FirstBlockTime
+	if stored.Fees != "" {
+		fees, ok := new(big.Int).SetString(stored.Fees, 10)
+		if !ok {
+			return nil, fmt.Errorf("failed to decode state snapshot: invalid fees %q", stored.Fees)
+		}
+		counters.fees = fees
+	}
+
+	return &stateSnapshot{height: stored.Height, state: state, nonces: nonces, weight: stored.Weight, counters: counters}, nil
+}
+
+// loadBaseSnapshot loads the snapshot that replaces the pruned blocks, if any (caller must hold c.mu)
+func (c *Chain) loadBaseSnapshot() error {
+	data, err := c.storage.GetStateSnapshot()
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		c.base = nil
+		return nil
+	}
+
+	base, err := decodeSnapshot(data)
+	if err != nil {
+		return err
+	}
+	c.base = base
+	return nil
+}
+
+// PruneBlocks deletes the stored blocks more than retention below the tip, keeping
+// genesis. The state as of the oldest kept block is saved first, so the chain can
+// still be loaded and reverted without the pruned blocks.
+func (c *Chain) PruneBlocks(retention uint64) error {
+	if retention < MinBlockRetention {
+		return fmt.Errorf("block retention %d is below the minimum of %d", retention, MinBlockRetention)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.height <= retention {
+		return nil
+	}
+	height := c.height - retention
+	if c.base != nil && height <= c.base.height {
+		return nil
+	}
+
+	snapshot, err := c.replayToHeight(height)
+	if err != nil {
+		return err
+	}
+	data, err := encodeSnapshot(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode state snapshot: %w", err)
+	}
+	if err := c.storage.SaveStateSnapshot(data); err != nil {
+		return fmt.Errorf("failed to save state snapshot: %w", err)
+	}
+	c.base = snapshot
+
+	if err := c.storage.PruneBelow(height); err != nil {
+		return fmt.Errorf("failed to prune blocks below %d: %w", height, err)
+	}
+	return nil
+}
+
+// PrunedBelow returns the height below which blocks other than genesis have been pruned, or 0
+func (c *Chain) PrunedBelow() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.base == nil {
+		return 0
+	}
+	return c.base.height
+}
@@ -0,0 +1,61 @@
+package blockchain_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/storage"
+)
+
+func TestPruneBlocks(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	config := testGenesis(authority)
+	store := storage.NewMemoryStore()
+	chain := newTestChainOn(t, store, config)
+
+	const tip = blockchain.MinBlockRetention + 10
+	for h := uint64(1); h <= tip; h++ {
+		addBlock(t, chain, authority, newTestTx(t, user, "", h-1, setOp("counter", fmt.Sprint(h))))
+	}
+
+	if err := chain.PruneBlocks(blockchain.MinBlockRetention - 1); err == nil {
+		t.Error("pruned with a retention below the minimum")
+	}
+	if err := chain.PruneBlocks(blockchain.MinBlockRetention); err != nil {
+		t.Fatalf("PruneBlocks: %v", err)
+	}
+	const keptFrom = tip - blockchain.MinBlockRetention
+	if got := chain.PrunedBelow(); got != keptFrom {
+		t.Errorf("PrunedBelow = %d, want %d", got, keptFrom)
+	}
+
+	if _, err := chain.GetBlockByHeight(keptFrom - 1); !errors.Is(err, blockchain.ErrBlockPruned) {
+		t.Errorf("GetBlockByHeight(%d) = %v, want ErrBlockPruned", keptFrom-1, err)
+	}
+	for _, height := range []uint64{0, keptFrom, tip} {
+		if _, err := chain.GetBlockByHeight(height); err != nil {
+			t.Errorf("GetBlockByHeight(%d): %v", height, err)
+		}
+	}
+
+	// Reverting into the pruned range is refused
+	if err := chain.RevertToHeight(keptFrom - 1); !errors.Is(err, blockchain.ErrBlockPruned) {
+		t.Errorf("RevertToHeight(%d) = %v, want ErrBlockPruned", keptFrom-1, err)
+	}
+
+	// The chain reloads from the saved snapshot and keeps growing
+	reloaded := blockchain.NewChain(store, config.Authorities)
+	if err := reloaded.LoadFromStorage(); err != nil {
+		t.Fatalf("LoadFromStorage: %v", err)
+	}
+	if !bytes.Equal(reloaded.GetStateRoot(), chain.GetStateRoot()) {
+		t.Error("reloaded state root differs from the live one")
+	}
+	addBlock(t, reloaded, authority, newTestTx(t, user, "", tip, setOp("counter", "next")))
+	if err := reloaded.RevertToHeight(keptFrom); err != nil {
+		t.Errorf("RevertToHeight(%d) after pruning: %v", keptFrom, err)
+	}
+}
@@ -0,0 +1,112 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrReceiptNotFound is returned for a transaction that has no receipt, because it
+// is not in a canonical block or its block was pruned
+var ErrReceiptNotFound = errors.New("receipt not found")
+
+// ErrDuplicateTransaction is returned for a transaction that is already included in
+// the canonical chain
+var ErrDuplicateTransaction = errors.New("transaction already included in the chain")
+
+// TransactionReceipt records the outcome of a transaction included in a block
+type TransactionReceipt struct {
+	TransactionHash []byte   `json:"transaction_hash"`
+	BlockHeight     uint64   `json:"block_height"`
+	BlockHash       []byte   `json:"block_hash"`
+	Index           int      `json:"index"`           // Position in the block
+	GasFee          *big.Int `json:"gas_fee"`         // Fee paid in wei (0 on chains without gas fees)
+	Success         bool     `json:"success"`         // Whether the operations were applied
+	Error           string   `json:"error,omitempty"` // Why the operations were not applied
+}
+
+// txOutcome is what applying one transaction of a block did
+type txOutcome struct {
+	fee *big.Int // Nil if no fee was charged
+	err error    // Condition failure that kept the operations from being applied
+}
+
+// newReceipts builds the receipts of a block's transactions from their outcomes
+func newReceipts(block *Block, outcomes []txOutcome) []*TransactionReceipt {
+	blockHash := block.Hash()
+	receipts := make([]*TransactionReceipt, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		receipt := &TransactionReceipt{
+			TransactionHash: tx.ID,
+			BlockHeight:     block.Header.Height,
+			BlockHash:       blockHash,
+			Index:           i,
+			GasFee:          big.NewInt(0),
+			Success:         true,
+		}
+		if i < len(outcomes) {
+			if outcomes[i].fee != nil {
+				receipt.GasFee = new(big.Int).Set(outcomes[i].fee)
+			}
+			if outcomes[i].err != nil {
+				receipt.Success = false
+				receipt.Error = outcomes[i].err.Error()
+			}
+		}
+		receipts[i] = receipt
+	}
+	return receipts
+}
+
+// GetReceipt returns the receipt of a transaction in the canonical chain
+func (c *Chain) GetReceipt(hash []byte) (*TransactionReceipt, error) {
+	return c.storage.GetReceipt(hash)
+}
+
+// GetTransactionLocation returns the height of the canonical block that included a
+// transaction and its position in the block. Receipts record both and are dropped
+// when their block is reverted, so the location comes from the receipt.
+func (c *Chain) GetTransactionLocation(hash []byte) (height uint64, index int, err error) {
+	receipt, err := c.storage.GetReceipt(hash)
+	if err != nil {
+		return 0, 0, err
+	}
+	return receipt.BlockHeight, receipt.Index, nil
+}
+
+// IsTransactionIncluded reports whether a transaction is included in the canonical
+// chain. Transactions of reverted blocks stay stored but lose their receipts, so this
+// goes by the receipt.
+func (c *Chain) IsTransactionIncluded(hash []byte) (bool, error) {
+	if _, err := c.storage.GetReceipt(hash); err != nil {
+		if errors.Is(err, ErrReceiptNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// checkDuplicateTransactions rejects a block that repeats a transaction included in
+// the canonical chain at or below height, or one in seen. The block's transactions
+// are added to seen (caller must hold c.mu).
+func (c *Chain) checkDuplicateTransactions(block *Block, height uint64, seen map[string]bool) error {
+	for _, tx := range block.Transactions {
+		if seen[string(tx.ID)] {
+			return fmt.Errorf("tx %s: %w", tx.HashString(), ErrDuplicateTransaction)
+		}
+		seen[string(tx.ID)] = true
+
+		receipt, err := c.storage.GetReceipt(tx.ID)
+		if errors.Is(err, ErrReceiptNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load receipt: %w", err)
+		}
+		if receipt.BlockHeight <= height {
+			return fmt.Errorf("tx %s: %w (block %d)", tx.HashString(), ErrDuplicateTransaction, receipt.BlockHeight)
+		}
+	}
+	return nil
+}
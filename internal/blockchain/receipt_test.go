@@ -0,0 +1,67 @@
+package blockchain_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+func TestTransferReceipt(t *testing.T) {
+	authority, user, recipient := newTestKey(t), newTestKey(t), newTestKey(t)
+	chain := newTestChain(t, tokenGenesis(authority, user, true))
+
+	first := newTestTx(t, user, "", 0, setOp("k", "v"))
+	transfer := newTestTx(t, user, "", 1, blockchain.NewTransferOperation(recipient.address, amount(10).Bytes()))
+	block := addBlock(t, chain, authority, first, transfer)
+
+	index := -1
+	for i, tx := range block.Transactions {
+		if bytes.Equal(tx.ID, transfer.ID) {
+			index = i
+		}
+	}
+	receipt, err := chain.GetReceipt(transfer.ID)
+	if err != nil {
+		t.Fatalf("GetReceipt: %v", err)
+	}
+	if !receipt.Success || receipt.Error != "" {
+		t.Errorf("success = %v, error = %q; want a successful receipt", receipt.Success, receipt.Error)
+	}
+	if !bytes.Equal(receipt.TransactionHash, transfer.ID) {
+		t.Errorf("receipt is for transaction %x, want %x", receipt.TransactionHash, transfer.ID)
+	}
+	if receipt.BlockHeight != 1 || !bytes.Equal(receipt.BlockHash, block.Hash()) || receipt.Index != index {
+		t.Errorf("receipt at block %d (%x) index %d, want block 1 (%x) index %d",
+			receipt.BlockHeight, receipt.BlockHash, receipt.Index, block.Hash(), index)
+	}
+	if want := chain.GetGasConfig().CalculateGasFee(transfer.Size()); receipt.GasFee.Cmp(want) != 0 {
+		t.Errorf("gas fee = %s, want %s", receipt.GasFee, want)
+	}
+	if got := balanceOf(t, chain, recipient.address); got.Cmp(amount(10)) != 0 {
+		t.Errorf("recipient balance = %s, want %s", got, amount(10))
+	}
+}
+
+// TestOverBalanceTransferHasNoReceipt checks that a transfer failing the balance check
+// is left out of blocks rather than included as failed, so it never gets a receipt
+func TestOverBalanceTransferHasNoReceipt(t *testing.T) {
+	authority, user, recipient := newTestKey(t), newTestKey(t), newTestKey(t)
+	chain := newTestChain(t, tokenGenesis(authority, user, true))
+
+	over := newTestTx(t, user, "", 0, blockchain.NewTransferOperation(recipient.address, amount(101).Bytes()))
+	applicable, rejected := chain.FilterApplicableTransactions([]*blockchain.Transaction{over})
+	if len(applicable) != 0 || len(rejected) != 1 || !strings.Contains(rejected[0].Err.Error(), "insufficient balance") {
+		t.Fatalf("transferring more than the balance: applicable %d, rejected %v", len(applicable), rejected)
+	}
+	block := signBlock(t, chain, chain.GetCurrentBlock(), authority, []*blockchain.Transaction{over}, chain.GetStateRoot())
+	if err := chain.AddBlock(block); err == nil {
+		t.Fatal("block transferring more than the balance accepted")
+	}
+
+	if _, err := chain.GetReceipt(over.ID); !errors.Is(err, blockchain.ErrReceiptNotFound) {
+		t.Errorf("GetReceipt of a rejected transfer: err = %v, want ErrReceiptNotFound", err)
+	}
+}
@@ -0,0 +1,209 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	// StateSnapshotInterval is how often, in blocks, the chain keeps a copy of its state
+	StateSnapshotInterval = 32
+
+	// MaxStateSnapshots is how many state snapshots are kept, enough to cover MaxReorgDepth
+	MaxStateSnapshots = MaxReorgDepth/StateSnapshotInterval + 2
+)
+
+// stateSnapshot is the chain state as of a canonical height
+type stateSnapshot struct {
+	height   uint64
+	state    *State
+	nonces   map[string]uint64
+	weight   uint64
+	counters chainCounters
+}
+
+// clone returns a copy of the snapshot that can be replayed onto
+func (s *stateSnapshot) clone() *stateSnapshot {
+	nonces := make(map[string]uint64, len(s.nonces))
+	for addr, nonce := range s.nonces {
+		nonces[addr] = nonce
+	}
+	return &stateSnapshot{height: s.height, state: s.state.Clone(), nonces: nonces, weight: s.weight, counters: s.counters}
+}
+
+// snapshotState records the live state if height is a snapshot height, dropping the
+// oldest snapshot once there are too many (caller must hold c.mu)
+func (c *Chain) snapshotState(height uint64) {
+	if height%StateSnapshotInterval != 0 {
+		return
+	}
+
+	live := &stateSnapshot{height: height, state: c.state, nonces: c.nonces, weight: c.weight, counters: c.counters}
+	c.snapshots = append(c.snapshots, live.clone())
+	if len(c.snapshots) > MaxStateSnapshots {
+		c.snapshots = c.snapshots[len(c.snapshots)-MaxStateSnapshots:]
+	}
+}
+
+// replayToHeight rebuilds the state, nonces, weight and counters as of a canonical height,
+// starting from the nearest snapshot at or below it, or from genesis if there is
+// none. The live state is not touched (caller must hold c.mu).
+func (c *Chain) replayToHeight(height uint64) (*stateSnapshot, error) {
+	// Blocks below the saved snapshot may be pruned, so it is the earliest starting point
+	from := c.base
+	if from != nil && height < from.height {
+		return nil, fmt.Errorf("%w: state before height %d is no longer available", ErrBlockPruned, from.height)
+	}
+	for i := len(c.snapshots) - 1; i >= 0; i-- {
+		if c.snapshots[i].height <= height {
+			if from == nil || c.snapshots[i].height > from.height {
+				from = c.snapshots[i]
+			}
+			break
+		}
+	}
+
+	replay := &stateSnapshot{state: NewState(), nonces: make(map[string]uint64), counters: newChainCounters()}
+	start := uint64(0)
+	if from != nil {
+		replay = from.clone()
+		start = replay.height + 1
+	}
+
+	for h := start; h <= height; h++ {
+		block, err := c.storage.GetBlockByHeight(h)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load block at height %d: %w", h, err)
+		}
+
+		fees, err := c.ApplyTransactionsWithFees(replay.state, block.Transactions, block.Header.ProducerAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply transactions at height %d: %w", h, err)
+		}
+
+		for _, tx := range block.Transactions {
+			if !tx.IsGenesisTransaction() {
+				replay.nonces[tx.From] = tx.Nonce + 1
+			}
+		}
+		replay.weight += BlockWeight(block)
+		replay.counters.add(block, fees)
+	}
+
+	replay.height = height
+	return replay, nil
+}
+
+// RevertToHeight rolls the chain back to a lower height: the state, nonces and tip
+// are restored as of that height, and the blocks above it are dropped from the height
+// and transaction indexes. Their blocks stay in storage by hash. Transactions in the
+// reverted blocks are not returned to the mempool.
+func (c *Chain) RevertToHeight(height uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.revertToHeight(height)
+}
+
+// revertToHeight implements RevertToHeight (caller must hold c.mu)
+func (c *Chain) revertToHeight(height uint64) error {
+	if height > c.height {
+		return fmt.Errorf("%w: %d > %d", ErrHeightAboveTip, height, c.height)
+	}
+	if height == c.height {
+		return nil
+	}
+
+	return c.resetToHeight(height)
+}
+
+// resetToHeight restores the state, nonces and tip as of a canonical height at or
+// below the tip, dropping the blocks above it from the indexes. At the tip itself it
+// discards live state changes left by a block that failed to commit (caller must hold c.mu).
+func (c *Chain) resetToHeight(height uint64) error {
+	target, err := c.storage.GetBlockByHeight(height)
+	if err != nil {
+		return fmt.Errorf("failed to load block at height %d: %w", height, err)
+	}
+
+	replay, err := c.replayToHeight(height)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(replay.state.CalculateRoot(target.Header.Version), target.Header.StateRoot) {
+		return fmt.Errorf("replayed state at height %d does not match the block's state root", height)
+	}
+
+	// Drop the reverted blocks from the indexes and receipts
+	for h := height + 1; h <= c.height; h++ {
+		block, err := c.storage.GetBlockByHeight(h)
+		if err != nil {
+			return fmt.Errorf("failed to load block at height %d: %w", h, err)
+		}
+		for _, tx := range block.Transactions {
+			if err := c.storage.UnindexTransaction(tx, h); err != nil {
+				return fmt.Errorf("failed to unindex transaction: %w", err)
+			}
+			if err := c.storage.DeleteReceipt(tx.ID); err != nil {
+				return fmt.Errorf("failed to delete receipt: %w", err)
+			}
+		}
+		if err := c.storage.DeleteBlockHeight(h); err != nil {
+			return fmt.Errorf("failed to delete height index %d: %w", h, err)
+		}
+	}
+
+	if err := c.persistState(replay.state); err != nil {
+		return err
+	}
+
+	c.state = replay.state
+	c.writes = newStateWrites()
+	c.nonces = replay.nonces
+	c.weight = replay.weight
+	c.counters = replay.counters
+	c.currentBlock = target
+	c.height = height
+
+	if err := c.storage.SaveBlockHeight(height); err != nil {
+		return fmt.Errorf("failed to save block height: %w", err)
+	}
+
+	// Snapshots above the new tip belong to the reverted blocks
+	for i, snapshot := range c.snapshots {
+		if snapshot.height > height {
+			c.snapshots = c.snapshots[:i]
+			break
+		}
+	}
+
+	return nil
+}
+
+// persistState writes the entries of state that differ from the live state to storage
+// and deletes the ones state doesn't have (caller must hold c.mu)
+func (c *Chain) persistState(state *State) error {
+	c.state.mu.RLock()
+	defer c.state.mu.RUnlock()
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	sets := make(map[string][]byte)
+	for key, value := range state.data {
+		if live, exists := c.state.data[key]; !exists || !bytes.Equal(live, value) {
+			sets[key] = value
+		}
+	}
+
+	var deletes []string
+	for key := range c.state.data {
+		if _, exists := state.data[key]; !exists {
+			deletes = append(deletes, key)
+		}
+	}
+
+	if err := c.storage.SaveStateBatch(sets, deletes); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return nil
+}
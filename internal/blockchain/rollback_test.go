@@ -0,0 +1,88 @@
+package blockchain_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+func TestRevertToHeight(t *testing.T) {
+	authority, user, recipient := newTestKey(t), newTestKey(t), newTestKey(t)
+	config := tokenGenesis(authority, user, true)
+	chain := newTestChain(t, config)
+
+	// Past the first state snapshot, so reverting above it replays from the snapshot
+	// and reverting below it replays from genesis
+	const tip = blockchain.StateSnapshotInterval + 8
+	var blocks []*blockchain.Block
+	balances := map[uint64]*big.Int{}
+	for h := uint64(1); h <= tip; h++ {
+		tx := newTestTx(t, user, "", h-1, setOp("counter", fmt.Sprint(h)),
+			blockchain.NewTransferOperation(recipient.address, amount(1).Bytes()))
+		blocks = append(blocks, addBlock(t, chain, authority, tx))
+		balances[h] = balanceOf(t, chain, user.address)
+	}
+
+	if err := chain.RevertToHeight(tip + 1); !errors.Is(err, blockchain.ErrHeightAboveTip) {
+		t.Errorf("RevertToHeight above the tip: err = %v, want ErrHeightAboveTip", err)
+	}
+
+	for _, height := range []uint64{blockchain.StateSnapshotInterval + 3, blockchain.StateSnapshotInterval - 5} {
+		if err := chain.RevertToHeight(height); err != nil {
+			t.Fatalf("RevertToHeight(%d): %v", height, err)
+		}
+
+		if chain.GetHeight() != height {
+			t.Errorf("height = %d, want %d", chain.GetHeight(), height)
+		}
+		target := blocks[height-1]
+		if !bytes.Equal(chain.GetCurrentBlock().Hash(), target.Hash()) {
+			t.Errorf("tip after reverting to %d is not the block at that height", height)
+		}
+		if !bytes.Equal(chain.GetStateRoot(), target.Header.StateRoot) {
+			t.Errorf("state root after reverting to %d does not match the block's", height)
+		}
+		if got := balanceOf(t, chain, user.address); got.Cmp(balances[height]) != 0 {
+			t.Errorf("balance after reverting to %d = %s, want %s", height, got, balances[height])
+		}
+		if got := chain.GetNonce(user.address); got != height {
+			t.Errorf("nonce after reverting to %d = %d, want %d", height, got, height)
+		}
+		if value, err := chain.GetState("counter"); err != nil || string(value) != fmt.Sprint(height) {
+			t.Errorf("counter after reverting to %d = %q, %v", height, value, err)
+		}
+
+		// The reverted blocks leave the height index and their receipts are dropped
+		if _, err := chain.GetBlockByHeight(height + 1); err == nil {
+			t.Errorf("block %d is still indexed after reverting to %d", height+1, height)
+		}
+		if _, err := chain.GetReceipt(blocks[height].Transactions[0].ID); err == nil {
+			t.Errorf("receipt of a reverted transaction is still stored")
+		}
+
+		// The state matches a fresh replay of the same blocks
+		replay := newTestChain(t, config)
+		for _, block := range blocks[:height] {
+			if err := replay.AddBlock(block); err != nil {
+				t.Fatalf("replaying block %d: %v", block.Header.Height, err)
+			}
+		}
+		if !bytes.Equal(chain.GetStateRoot(), replay.GetStateRoot()) {
+			t.Errorf("state root after reverting to %d differs from a fresh replay", height)
+		}
+		if got, want := chain.GetTotalSupply(), replay.GetTotalSupply(); got.Cmp(want) != 0 {
+			t.Errorf("total supply after reverting to %d = %s, want %s", height, got, want)
+		}
+	}
+
+	// The chain extends from the reverted tip
+	height := chain.GetHeight()
+	addBlock(t, chain, authority, newTestTx(t, user, "", height, setOp("counter", "new")))
+	if chain.GetHeight() != height+1 {
+		t.Errorf("height after extending the reverted tip = %d, want %d", chain.GetHeight(), height+1)
+	}
+}
@@ -0,0 +1,64 @@
+package blockchain
+
+import (
+	"math/big"
+)
+
+// chainCounters are the running totals behind ChainStats. They cover the blocks
+// above genesis and are kept with each state snapshot, so reverts and pruning
+// restore them along with the state.
+type chainCounters struct {
+	transactions   uint64   // Transactions included
+	fees           *big.Int // Gas fees paid, in wei
+	firstBlockTime int64    // Timestamp of block 1, 0 until it is added
+}
+
+// newChainCounters returns the counters of a chain holding only its genesis block
+func newChainCounters() chainCounters {
+	return chainCounters{fees: big.NewInt(0)}
+}
+
+// add counts a block and the gas fees its transactions paid
+func (cc *chainCounters) add(block *Block, fees *big.Int) {
+	if block.Header.Height == 0 {
+		return
+	}
+	if block.Header.Height == 1 {
+		cc.firstBlockTime = block.Header.Timestamp
+	}
+
+	cc.transactions += uint64(len(block.Transactions))
+	if fees != nil {
+		cc.fees = new(big.Int).Add(cc.fees, fees)
+	}
+}
+
+// ChainStats contains aggregate statistics of the canonical chain. Genesis is not
+// counted: the averages cover blocks 1 to the tip.
+type ChainStats struct {
+	Height                  uint64  `json:"height"`
+	TotalTransactions       uint64  `json:"total_transactions"`
+	AvgTransactionsPerBlock float64 `json:"avg_transactions_per_block"`
+	AvgBlockInterval        float64 `json:"avg_block_interval"` // Seconds between blocks
+	TotalFees               string  `json:"total_fees"`         // Gas fees paid, in wei
+}
+
+// GetStats returns aggregate statistics of the canonical chain from running counters
+func (c *Chain) GetStats() *ChainStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := &ChainStats{
+		Height:            c.height,
+		TotalTransactions: c.counters.transactions,
+		TotalFees:         c.counters.fees.String(),
+	}
+	if c.height > 0 {
+		stats.AvgTransactionsPerBlock = float64(c.counters.transactions) / float64(c.height)
+	}
+	if c.height > 1 && c.counters.firstBlockTime > 0 {
+		elapsed := c.currentBlock.Header.Timestamp - c.counters.firstBlockTime
+		stats.AvgBlockInterval = float64(elapsed) / float64(c.height-1)
+	}
+	return stats
+}
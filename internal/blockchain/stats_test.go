@@ -0,0 +1,71 @@
+package blockchain_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/storage"
+)
+
+func TestGetStats(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	config := tokenGenesis(authority, user, true)
+	store := storage.NewMemoryStore()
+	chain := newTestChainOn(t, store, config)
+
+	if stats := chain.GetStats(); stats.Height != 0 || stats.TotalTransactions != 0 || stats.TotalFees != "0" ||
+		stats.AvgTransactionsPerBlock != 0 || stats.AvgBlockInterval != 0 {
+		t.Errorf("stats of a genesis-only chain = %+v, want all zero", stats)
+	}
+
+	// Blocks of 3, 0 and 1 transactions, one second apart
+	nonce := uint64(0)
+	fees := new(big.Int)
+	var history []*blockchain.ChainStats
+	for _, count := range []int{3, 0, 1} {
+		var txs []*blockchain.Transaction
+		for range count {
+			tx := newTestTx(t, user, "", nonce, setOp("k", "v"))
+			fees.Add(fees, chain.GetGasConfig().CalculateGasFee(tx.Size()))
+			txs = append(txs, tx)
+			nonce++
+		}
+		addBlock(t, chain, authority, txs...)
+
+		stats := chain.GetStats()
+		height := chain.GetHeight()
+		if stats.Height != height || stats.TotalTransactions != nonce {
+			t.Errorf("height %d: stats at height %d with %d transactions, want %d", height, stats.Height, stats.TotalTransactions, nonce)
+		}
+		if want := float64(nonce) / float64(height); stats.AvgTransactionsPerBlock != want {
+			t.Errorf("height %d: average transactions per block = %v, want %v", height, stats.AvgTransactionsPerBlock, want)
+		}
+		wantInterval := 1.0
+		if height == 1 {
+			wantInterval = 0 // No interval yet
+		}
+		if stats.AvgBlockInterval != wantInterval {
+			t.Errorf("height %d: average block interval = %v, want %v", height, stats.AvgBlockInterval, wantInterval)
+		}
+		if stats.TotalFees != fees.String() {
+			t.Errorf("height %d: total fees = %s, want %s", height, stats.TotalFees, fees)
+		}
+		history = append(history, stats)
+	}
+
+	// The counters are restored with the state on reload and on revert
+	reloaded := blockchain.NewChainWithConfig(store, config.Authorities, config.GetGasConfig(), config.TokenConfig)
+	if err := reloaded.LoadFromStorage(); err != nil {
+		t.Fatalf("LoadFromStorage: %v", err)
+	}
+	if got, want := *reloaded.GetStats(), *history[2]; got != want {
+		t.Errorf("reloaded stats = %+v, want %+v", got, want)
+	}
+	if err := chain.RevertToHeight(1); err != nil {
+		t.Fatalf("RevertToHeight: %v", err)
+	}
+	if got, want := *chain.GetStats(), *history[0]; got != want {
+		t.Errorf("stats after reverting to height 1 = %+v, want %+v", got, want)
+	}
+}
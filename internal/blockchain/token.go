@@ -2,6 +2,7 @@ package blockchain
 
 import (
 	"errors"
+	"fmt"
 	"math/big"
 	"strings"
 )
@@ -21,6 +22,16 @@ const (
 
 	// InitialSupplyString is 100 million PDR in wei (100_000_000 * 10^18)
 	InitialSupplyString = "100000000000000000000000000"
+
+	// ReservedKeyPrefix marks state keys that only the chain itself writes; transactions
+	// and the genesis initial state can't set them
+	ReservedKeyPrefix = "meta:"
+
+	// TotalSupplyKey is the state key tracking cumulative issuance (mints and block rewards)
+	TotalSupplyKey = ReservedKeyPrefix + "total_supply"
+
+	// MaxBalanceBytes is the size limit of a serialized balance
+	MaxBalanceBytes = 32
 )
 
 var (
@@ -35,6 +46,33 @@ var (
 
 	// ZeroBalance represents zero balance
 	ZeroBalance = big.NewInt(0)
+
+	// MaxBalance is the largest representable balance or total supply (2^256 - 1 wei)
+	MaxBalance = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 8*MaxBalanceBytes), big.NewInt(1))
+
+	// ErrBalanceOverflow is returned when a credit would take a balance or the total
+	// supply above MaxBalance
+	ErrBalanceOverflow = errors.New("balance exceeds maximum representable amount")
+
+	// ErrDeletedBalanceKey is returned under DeletedBalanceReject when an operation
+	// references a balance key deleted earlier in the same block
+	ErrDeletedBalanceKey = errors.New("balance key was deleted earlier in the block")
+
+	// ErrBalanceKeyWrite is returned under LockBalanceKeys when a SET, CAS or DELETE
+	// targets a balance key
+	ErrBalanceKeyWrite = errors.New("balance keys can only be changed by MINT, TRANSFER and BURN")
+)
+
+// DeletedBalancePolicy controls operations that reference a balance key deleted
+// earlier in the same block
+type DeletedBalancePolicy string
+
+const (
+	// DeletedBalanceZero treats a deleted balance key as a zero balance (default)
+	DeletedBalanceZero DeletedBalancePolicy = "zero"
+
+	// DeletedBalanceReject makes the referencing transaction, and so its block, invalid
+	DeletedBalanceReject DeletedBalancePolicy = "reject"
 )
 
 // Balance represents an account balance using big.Int
@@ -64,14 +102,14 @@ func BalanceFromBytes(data []byte) (*Balance, error) {
 	if len(data) == 0 {
 		return NewBalance(big.NewInt(0)), nil
 	}
-	if len(data) > 32 {
+	if len(data) > MaxBalanceBytes {
 		return nil, errors.New("invalid balance data: too long")
 	}
 	amount := new(big.Int).SetBytes(data)
 	return NewBalance(amount), nil
 }
 
-// ToBytes serializes the balance to bytes (big-endian, up to 32 bytes)
+// ToBytes serializes the balance to bytes (big-endian, up to MaxBalanceBytes bytes)
 func (b *Balance) ToBytes() []byte {
 	if b.Amount == nil || b.Amount.Sign() == 0 {
 		return []byte{}
@@ -123,6 +161,11 @@ func (b *Balance) Clone() *Balance {
 	return NewBalance(b.Amount)
 }
 
+// IsReservedKey reports whether key is in the reserved state namespace
+func IsReservedKey(key string) bool {
+	return strings.HasPrefix(key, ReservedKeyPrefix)
+}
+
 // BalanceKey returns the state key for an address's balance
 func BalanceKey(address string) string {
 	return BalanceKeyPrefix + strings.ToLower(address)
@@ -143,10 +186,29 @@ func AddressFromBalanceKey(key string) string {
 
 // TokenConfig holds token configuration from genesis
 type TokenConfig struct {
-	Name          string `json:"name"`
-	Symbol        string `json:"symbol"`
-	Decimals      int    `json:"decimals"`
-	InitialSupply string `json:"initial_supply"`
+	Name          string   `json:"name"`
+	Symbol        string   `json:"symbol"`
+	Decimals      int      `json:"decimals"`
+	InitialSupply string   `json:"initial_supply"`
+	BlockReward   *big.Int `json:"block_reward,omitempty"` // Minted to the producer of each block (in wei); requires Accounting
+
+	// Accounting charges gas fees, restricts MINT to authorities, tracks the total
+	// supply and mints block rewards when blocks are applied. Chains created before
+	// it existed applied blocks without any of these, and it changes their state
+	// roots, so it is off unless the genesis file sets it.
+	Accounting bool `json:"accounting,omitempty"`
+
+	// DeletedBalancePolicy applies to TRANSFER, BURN and MINT operations on a balance
+	// key deleted earlier in the same block; empty means DeletedBalanceZero
+	DeletedBalancePolicy DeletedBalancePolicy `json:"deleted_balance_policy,omitempty"`
+
+	// TrackProducerEarnings records the fees and block rewards each producer earns
+	// under reserved state keys
+	TrackProducerEarnings bool `json:"track_producer_earnings,omitempty"`
+
+	// LockBalanceKeys makes transactions that SET, CAS or DELETE a balance key invalid,
+	// so balances and the total supply only change through token operations
+	LockBalanceKeys bool `json:"lock_balance_keys,omitempty"`
 }
 
 // DefaultTokenConfig returns the default token configuration
@@ -176,6 +238,18 @@ func (tc *TokenConfig) Validate() error {
 			return errors.New("invalid initial supply")
 		}
 	}
+	if tc.BlockReward != nil && tc.BlockReward.Sign() < 0 {
+		return errors.New("block reward cannot be negative")
+	}
+	if tc.BlockReward != nil && tc.BlockReward.Sign() > 0 && !tc.Accounting {
+		return errors.New("block reward requires accounting")
+	}
+	switch tc.DeletedBalancePolicy {
+	case "", DeletedBalanceZero, DeletedBalanceReject:
+	default:
+		return fmt.Errorf("invalid deleted balance policy %q (must be %q or %q)",
+			tc.DeletedBalancePolicy, DeletedBalanceZero, DeletedBalanceReject)
+	}
 	return nil
 }
 
@@ -191,7 +265,8 @@ func (tc *TokenConfig) GetInitialSupply() *big.Int {
 	return supply
 }
 
-// FormatBalance formats a balance in wei to a human-readable string
+// FormatBalance formats a balance in wei to a human-readable string, rounded to 6
+// decimal places. Use FormatBalanceFull where exact amounts matter.
 func FormatBalance(weiAmount *big.Int) string {
 	if weiAmount == nil || weiAmount.Sign() == 0 {
 		return "0 PDR"
@@ -205,19 +280,56 @@ func FormatBalance(weiAmount *big.Int) string {
 	return result.Text('f', 6) + " PDR"
 }
 
-// ParsePDR converts a PDR amount string to wei
-func ParsePDR(pdrAmount string) (*big.Int, error) {
-	// Parse the amount as a float
-	amount, _, err := big.ParseFloat(pdrAmount, 10, 256, big.ToNearestEven)
-	if err != nil {
-		return nil, err
+// FormatBalanceFull formats a balance in wei as an exact PDR amount, with all
+// TokenDecimals decimal places and trailing zeros trimmed ("1.5 PDR", "2 PDR")
+func FormatBalanceFull(weiAmount *big.Int) string {
+	if weiAmount == nil || weiAmount.Sign() == 0 {
+		return "0 PDR"
 	}
 
-	// Multiply by 10^18
-	oneToken := new(big.Float).SetInt(OnePDR)
-	weiFloat := new(big.Float).Mul(amount, oneToken)
+	sign := ""
+	if weiAmount.Sign() < 0 {
+		sign = "-"
+	}
+
+	whole, fraction := new(big.Int).QuoRem(new(big.Int).Abs(weiAmount), OnePDR, new(big.Int))
+	if fraction.Sign() == 0 {
+		return sign + whole.String() + " PDR"
+	}
+
+	decimals := fmt.Sprintf("%0*s", TokenDecimals, fraction.String())
+	return sign + whole.String() + "." + strings.TrimRight(decimals, "0") + " PDR"
+}
+
+// ParsePDR converts a decimal PDR amount string such as "1.5" to wei, exactly. The
+// amount may have at most TokenDecimals fractional digits.
+func ParsePDR(pdrAmount string) (*big.Int, error) {
+	whole, fraction, _ := strings.Cut(pdrAmount, ".")
+	if whole == "" && fraction == "" {
+		return nil, fmt.Errorf("invalid PDR amount %q", pdrAmount)
+	}
+	if len(fraction) > TokenDecimals {
+		return nil, fmt.Errorf("invalid PDR amount %q: more than %d decimal places", pdrAmount, TokenDecimals)
+	}
+	if !isDigits(whole) || !isDigits(fraction) {
+		return nil, fmt.Errorf("invalid PDR amount %q", pdrAmount)
+	}
 
-	// Convert to int
-	wei, _ := weiFloat.Int(nil)
+	// Pad the fraction to TokenDecimals digits, making the whole string a wei amount
+	digits := whole + fraction + strings.Repeat("0", TokenDecimals-len(fraction))
+	wei, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid PDR amount %q", pdrAmount)
+	}
 	return wei, nil
 }
+
+// isDigits reports whether s holds only the ASCII digits 0-9 (true for "")
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,542 @@
+package blockchain_test
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/storage"
+)
+
+// tokenGenesis returns a genesis config with a token, gas fees and a funded user
+func tokenGenesis(authority, user *testKey, accounting bool) *blockchain.GenesisConfig {
+	config := testGenesis(authority)
+	config.TokenConfig = &blockchain.TokenConfig{
+		Name:          "Podoru",
+		Symbol:        "PDR",
+		Decimals:      18,
+		InitialSupply: amount(1000).String(),
+		Accounting:    accounting,
+	}
+	config.GasConfig = &blockchain.GasConfigJSON{BaseFee: "1000", PerByteFee: "10"}
+	config.InitialBalances = map[string]string{user.address: amount(100).String()}
+	return config
+}
+
+func balanceOf(t *testing.T, chain *blockchain.Chain, address string) *big.Int {
+	t.Helper()
+
+	balance, err := chain.GetBalance(address)
+	if err != nil {
+		t.Fatalf("GetBalance(%s): %v", address, err)
+	}
+	return balance
+}
+
+func TestLegacyTokenChainSkipsAccounting(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	chain := newTestChain(t, tokenGenesis(authority, user, false))
+
+	if _, err := chain.GetState(blockchain.TotalSupplyKey); err == nil {
+		t.Error("genesis wrote the total supply without accounting")
+	}
+	if chain.HasGasFees() || chain.GetGasConfig() != nil {
+		t.Error("gas config is in effect without accounting")
+	}
+
+	// No fee is charged, and MINT is left to mempool admission as before
+	mint := newTestTx(t, user, "", 0, blockchain.NewMintOperation(user.address, amount(1).Bytes()))
+	addBlock(t, chain, authority, newTestTx(t, user, "", 1, setOp("k", "v")), mint)
+
+	if got, want := balanceOf(t, chain, user.address), amount(101); got.Cmp(want) != 0 {
+		t.Errorf("user balance = %s, want %s", got, want)
+	}
+	if got := balanceOf(t, chain, authority.address); got.Sign() != 0 {
+		t.Errorf("producer balance = %s, want 0", got)
+	}
+	if _, err := chain.GetState(blockchain.TotalSupplyKey); err == nil {
+		t.Error("MINT wrote the total supply without accounting")
+	}
+}
+
+func TestAccountingChargesFeesAndTracksSupply(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	chain := newTestChain(t, tokenGenesis(authority, user, true))
+
+	if got, want := chain.GetTotalSupply(), amount(100); got.Cmp(want) != 0 {
+		t.Fatalf("genesis total supply = %s, want %s", got, want)
+	}
+
+	tx := newTestTx(t, user, "", 0, setOp("k", "v"))
+	fee := chain.GetGasConfig().CalculateGasFee(tx.Size())
+	addBlock(t, chain, authority, tx)
+
+	if got, want := balanceOf(t, chain, user.address), new(big.Int).Sub(amount(100), fee); got.Cmp(want) != 0 {
+		t.Errorf("user balance = %s, want %s", got, want)
+	}
+	if got := balanceOf(t, chain, authority.address); got.Cmp(fee) != 0 {
+		t.Errorf("producer balance = %s, want %s", got, fee)
+	}
+
+	// Only authorities may mint once accounting is on
+	mint := newTestTx(t, user, "", 1, blockchain.NewMintOperation(user.address, amount(1).Bytes()))
+	block := signBlock(t, chain, chain.GetCurrentBlock(), authority, []*blockchain.Transaction{mint}, chain.GetStateRoot())
+	err := chain.AddBlock(block)
+	if err == nil || !strings.Contains(err.Error(), "only authorities can mint") {
+		t.Fatalf("AddBlock with a non-authority MINT = %v, want an authority error", err)
+	}
+}
+
+func TestAccountingChangesStateRoot(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	legacy := newTestChain(t, tokenGenesis(authority, user, false))
+	accounting := newTestChain(t, tokenGenesis(authority, user, true))
+
+	txs := []*blockchain.Transaction{newTestTx(t, user, "", 0, setOp("k", "v"))}
+	legacyRoot, err := legacy.CalculateStateRootWithTransactions(txs, authority.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountingRoot, err := accounting.CalculateStateRootWithTransactions(txs, authority.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(legacyRoot) == string(accountingRoot) {
+		t.Error("accounting left the state root unchanged; it must be opt-in per chain")
+	}
+}
+
+func TestReservedKeysRejected(t *testing.T) {
+	user := newTestKey(t)
+
+	tx := newTestTx(t, user, "", 0, setOp(blockchain.TotalSupplyKey, "1"))
+	if err := tx.Validate(); err == nil {
+		t.Errorf("transaction setting %s passed validation", blockchain.TotalSupplyKey)
+	}
+
+	config := testGenesis(user)
+	config.InitialState["meta:anything"] = "x"
+	if err := config.Validate(); err == nil {
+		t.Error("genesis initial state with a meta: key passed validation")
+	}
+}
+
+func TestBlockRewardRequiresAccounting(t *testing.T) {
+	config := &blockchain.TokenConfig{Name: "Podoru", Symbol: "PDR", Decimals: 18, BlockReward: amount(2)}
+	if err := config.Validate(); err == nil {
+		t.Error("block reward without accounting passed validation")
+	}
+
+	config.Accounting = true
+	if err := config.Validate(); err != nil {
+		t.Errorf("block reward with accounting: %v", err)
+	}
+}
+
+func TestGetBalanceReadsThroughToStorage(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	store := storage.NewMemoryStore()
+	chain := newTestChainOn(t, store, tokenGenesis(authority, user, true))
+
+	// A balance persisted but absent from the in-memory state, as a snapshot restore could leave it
+	stored := newTestKey(t).address
+	if err := store.SaveState(blockchain.BalanceKey(stored), blockchain.NewBalance(amount(7)).ToBytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := balanceOf(t, chain, stored); got.Cmp(amount(7)) != 0 {
+		t.Errorf("GetBalance = %s, want the stored %s", got, amount(7))
+	}
+	fromStorage, err := chain.GetBalanceFromStorage(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := balanceOf(t, chain, stored); got.Cmp(fromStorage) != 0 {
+		t.Errorf("GetBalance = %s, GetBalanceFromStorage = %s", got, fromStorage)
+	}
+
+	// The in-memory balance still wins when there is one, and an unknown address has none
+	if got := balanceOf(t, chain, user.address); got.Cmp(amount(100)) != 0 {
+		t.Errorf("in-memory balance = %s, want %s", got, amount(100))
+	}
+	if got := balanceOf(t, chain, newTestKey(t).address); got.Sign() != 0 {
+		t.Errorf("balance of an unknown address = %s, want 0", got)
+	}
+}
+
+func TestBlockBalancesUseTheQueryReadPath(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	store := storage.NewMemoryStore()
+	config := tokenGenesis(authority, user, true)
+	chain := newTestChainOn(t, store, config)
+
+	// A transfer credits a balance only storage holds, as GetBalance reports it
+	stored := newTestKey(t).address
+	if err := store.SaveState(blockchain.BalanceKey(stored), blockchain.NewBalance(amount(7)).ToBytes()); err != nil {
+		t.Fatal(err)
+	}
+	recipient := newTestKey(t).address
+	addBlock(t, chain, authority,
+		newTestTx(t, user, "", 0, blockchain.NewTransferOperation(stored, amount(1).Bytes())),
+		newTestTx(t, user, "", 1, blockchain.NewTransferOperation(recipient, amount(2).Bytes())))
+
+	if got := balanceOf(t, chain, stored); got.Cmp(amount(8)) != 0 {
+		t.Errorf("stored balance after a transfer = %s, want %s", got, amount(8))
+	}
+
+	// Replaying the blocks on restart doesn't read the later balances storage holds
+	reloaded := blockchain.NewChainWithConfig(store, config.Authorities, config.GetGasConfig(), config.TokenConfig)
+	if err := reloaded.LoadFromStorage(); err != nil {
+		t.Fatalf("LoadFromStorage: %v", err)
+	}
+	if got := balanceOf(t, reloaded, recipient); got.Cmp(amount(2)) != 0 {
+		t.Errorf("recipient balance after restart = %s, want %s", got, amount(2))
+	}
+	if got, want := balanceOf(t, reloaded, user.address), balanceOf(t, chain, user.address); got.Cmp(want) != 0 {
+		t.Errorf("sender balance after restart = %s, want %s", got, want)
+	}
+}
+
+func TestParsePDR(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string // wei; empty if the amount is rejected
+	}{
+		{in: "0", want: "0"},
+		{in: "1", want: "1000000000000000000"},
+		{in: "123456789", want: "123456789000000000000000000"},
+		{in: "1.5", want: "1500000000000000000"},
+		{in: ".5", want: "500000000000000000"},
+		{in: "2.", want: "2000000000000000000"},
+		{in: "1.000000000000000001", want: "1000000000000000001"},
+		{in: "0.000000000000000001", want: "1"},
+		{in: "99999999999999999999.999999999999999999", want: "99999999999999999999999999999999999999"},
+		{in: "1.0000000000000000001"},
+		{in: ""},
+		{in: "."},
+		{in: "-1"},
+		{in: "1e18"},
+		{in: "1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := blockchain.ParsePDR(tt.in)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("ParsePDR(%q) = %s, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePDR(%q): %v", tt.in, err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("ParsePDR(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatBalanceFull(t *testing.T) {
+	wei := func(s string) *big.Int {
+		n, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			t.Fatalf("bad amount %q", s)
+		}
+		return n
+	}
+
+	tests := []struct {
+		wei   *big.Int
+		full  string
+		short string
+	}{
+		{wei: nil, full: "0 PDR", short: "0 PDR"},
+		{wei: big.NewInt(0), full: "0 PDR", short: "0 PDR"},
+		{wei: big.NewInt(1), full: "0.000000000000000001 PDR", short: "0.000000 PDR"},
+		{wei: amount(2), full: "2 PDR", short: "2.000000 PDR"},
+		{wei: wei("1500000000000000000"), full: "1.5 PDR", short: "1.500000 PDR"},
+		{wei: wei("1000000000000000001"), full: "1.000000000000000001 PDR", short: "1.000000 PDR"},
+		{wei: wei("-2500000000000000000"), full: "-2.5 PDR", short: "-2.500000 PDR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.full, func(t *testing.T) {
+			if got := blockchain.FormatBalanceFull(tt.wei); got != tt.full {
+				t.Errorf("FormatBalanceFull(%s) = %q, want %q", tt.wei, got, tt.full)
+			}
+			if got := blockchain.FormatBalance(tt.wei); got != tt.short {
+				t.Errorf("FormatBalance(%s) = %q, want %q", tt.wei, got, tt.short)
+			}
+
+			// The full form parses back to the same amount
+			if tt.wei == nil || tt.wei.Sign() < 0 {
+				return
+			}
+			parsed, err := blockchain.ParsePDR(strings.TrimSuffix(tt.full, " PDR"))
+			if err != nil || parsed.Cmp(tt.wei) != 0 {
+				t.Errorf("ParsePDR(%q) = %s, %v; want %s", tt.full, parsed, err, tt.wei)
+			}
+		})
+	}
+}
+
+func TestBalanceRange(t *testing.T) {
+	stored := blockchain.NewBalance(blockchain.MaxBalance).ToBytes()
+	if len(stored) != blockchain.MaxBalanceBytes {
+		t.Errorf("MaxBalance serializes to %d bytes, want %d", len(stored), blockchain.MaxBalanceBytes)
+	}
+	if balance, err := blockchain.BalanceFromBytes(stored); err != nil || balance.Amount.Cmp(blockchain.MaxBalance) != 0 {
+		t.Errorf("BalanceFromBytes(MaxBalance) = %v, %v", balance, err)
+	}
+	if _, err := blockchain.BalanceFromBytes(make([]byte, blockchain.MaxBalanceBytes+1)); err == nil {
+		t.Error("BalanceFromBytes accepted more than MaxBalanceBytes")
+	}
+
+	user := newTestKey(t)
+	tooLarge := new(big.Int).Add(blockchain.MaxBalance, big.NewInt(1))
+	for _, op := range []*blockchain.KVOperation{
+		blockchain.NewMintOperation(user.address, tooLarge.Bytes()),
+		blockchain.NewTransferOperation(user.address, tooLarge.Bytes()),
+		blockchain.NewBurnOperation(user.address, tooLarge.Bytes()),
+	} {
+		if err := newTestTx(t, user, "", 0, op).Validate(); err == nil {
+			t.Errorf("%s of more than MaxBalance passed validation", op.Type)
+		}
+	}
+}
+
+// mintsUpTo adds a block minting the room left below MaxBalance, then checks that one
+// more wei can't be minted
+func mintsUpTo(t *testing.T, chain *blockchain.Chain, authority *testKey, room *big.Int) {
+	t.Helper()
+
+	recipient := newTestKey(t).address
+	addBlock(t, chain, authority, newTestTx(t, authority, "", 0, blockchain.NewMintOperation(recipient, room.Bytes())))
+
+	over := newTestTx(t, authority, "", 1, blockchain.NewMintOperation(recipient, big.NewInt(1).Bytes()))
+	applicable, rejected := chain.FilterApplicableTransactions([]*blockchain.Transaction{over})
+	if len(applicable) != 0 || len(rejected) != 1 || !errors.Is(rejected[0].Err, blockchain.ErrBalanceOverflow) {
+		t.Fatalf("minting past MaxBalance: applicable %d, rejected %v; want ErrBalanceOverflow", len(applicable), rejected)
+	}
+	block := signBlock(t, chain, chain.GetCurrentBlock(), authority, []*blockchain.Transaction{over}, chain.GetStateRoot())
+	if err := chain.AddBlock(block); err == nil {
+		t.Error("block minting past MaxBalance accepted")
+	}
+}
+
+func TestMintStopsAtMaxBalance(t *testing.T) {
+	t.Run("balance", func(t *testing.T) {
+		// Without accounting there is no total supply, so the balance is the limit
+		authority, user := newTestKey(t), newTestKey(t)
+		chain := newTestChain(t, tokenGenesis(authority, user, false))
+
+		mintsUpTo(t, chain, authority, blockchain.MaxBalance)
+	})
+
+	t.Run("total supply", func(t *testing.T) {
+		authority, user := newTestKey(t), newTestKey(t)
+		config := tokenGenesis(authority, user, true)
+		config.InitialBalances[authority.address] = amount(10).String() // Pays the MINT fees
+		chain := newTestChain(t, config)
+
+		room := new(big.Int).Sub(blockchain.MaxBalance, chain.GetTotalSupply())
+		mintsUpTo(t, chain, authority, room)
+		if got := chain.GetTotalSupply(); got.Cmp(blockchain.MaxBalance) != 0 {
+			t.Errorf("total supply = %s, want MaxBalance", got)
+		}
+	})
+}
+
+func TestLockBalanceKeys(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	balanceKey := blockchain.BalanceKey(user.address)
+	stored := blockchain.NewBalance(amount(100)).ToBytes()
+
+	tests := []struct {
+		name string
+		op   *blockchain.KVOperation
+	}{
+		{name: "set", op: setOp(balanceKey, "1")},
+		{name: "cas", op: blockchain.NewCASOperation(balanceKey, stored, []byte{1})},
+		{name: "delete", op: &blockchain.KVOperation{Type: blockchain.OpTypeDelete, Key: balanceKey}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tokenGenesis(authority, user, false)
+			config.TokenConfig.LockBalanceKeys = true
+			chain := newTestChain(t, config)
+
+			tx := newTestTx(t, user, "", 0, tt.op)
+			_, rejected := chain.FilterApplicableTransactions([]*blockchain.Transaction{tx})
+			if len(rejected) != 1 || !errors.Is(rejected[0].Err, blockchain.ErrBalanceKeyWrite) {
+				t.Errorf("%s on a balance key: rejected %v, want ErrBalanceKeyWrite", tt.op.Type, rejected)
+			}
+
+			// Other keys and token operations still work
+			addBlock(t, chain, authority,
+				newTestTx(t, user, "", 0, setOp("key", "v")),
+				newTestTx(t, user, "", 1, &blockchain.KVOperation{Type: blockchain.OpTypeDelete, Key: "key"}),
+				newTestTx(t, user, "", 2, blockchain.NewTransferOperation(authority.address, amount(1).Bytes())),
+			)
+			if got := balanceOf(t, chain, user.address); got.Cmp(amount(99)) != 0 {
+				t.Errorf("balance after transfer = %s, want %s", got, amount(99))
+			}
+
+			// Without the option the operation applies, as it always has
+			open := newTestChain(t, tokenGenesis(authority, user, false))
+			addBlock(t, open, authority, tx)
+		})
+	}
+}
+
+func TestBurnReducesBalanceAndSupply(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	chain := newTestChain(t, tokenGenesis(authority, user, true))
+
+	tx := newTestTx(t, user, "", 0, blockchain.NewBurnOperation(user.address, amount(30).Bytes()))
+	fee := chain.GetGasConfig().CalculateGasFee(tx.Size())
+	addBlock(t, chain, authority, tx)
+
+	if got, want := balanceOf(t, chain, user.address), new(big.Int).Sub(amount(70), fee); got.Cmp(want) != 0 {
+		t.Errorf("user balance = %s, want %s", got, want)
+	}
+	// The fee moves to the producer; only the burned amount leaves the supply
+	if got, want := chain.GetTotalSupply(), amount(70); got.Cmp(want) != 0 {
+		t.Errorf("total supply = %s, want %s", got, want)
+	}
+}
+
+func TestBurnOverBalanceRejected(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	chain := newTestChain(t, tokenGenesis(authority, user, true))
+
+	over := newTestTx(t, user, "", 0, blockchain.NewBurnOperation(user.address, amount(101).Bytes()))
+	applicable, rejected := chain.FilterApplicableTransactions([]*blockchain.Transaction{over})
+	if len(applicable) != 0 || len(rejected) != 1 || !strings.Contains(rejected[0].Err.Error(), "insufficient balance") {
+		t.Fatalf("burning more than the balance: applicable %d, rejected %v", len(applicable), rejected)
+	}
+	block := signBlock(t, chain, chain.GetCurrentBlock(), authority, []*blockchain.Transaction{over}, chain.GetStateRoot())
+	if err := chain.AddBlock(block); err == nil {
+		t.Error("block burning more than the balance accepted")
+	}
+
+	if got := balanceOf(t, chain, user.address); got.Cmp(amount(100)) != 0 {
+		t.Errorf("user balance = %s, want %s", got, amount(100))
+	}
+	if got := chain.GetTotalSupply(); got.Cmp(amount(100)) != 0 {
+		t.Errorf("total supply = %s, want %s", got, amount(100))
+	}
+}
+
+func TestBurnFees(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	config := tokenGenesis(authority, user, true)
+	config.GasConfig.BurnFees = true
+	chain := newTestChain(t, config)
+
+	tx := newTestTx(t, user, "", 0, setOp("k", "v"))
+	fee := chain.GetGasConfig().CalculateGasFee(tx.Size())
+	addBlock(t, chain, authority, tx)
+
+	if got, want := balanceOf(t, chain, user.address), new(big.Int).Sub(amount(100), fee); got.Cmp(want) != 0 {
+		t.Errorf("user balance = %s, want %s", got, want)
+	}
+	if got := balanceOf(t, chain, authority.address); got.Sign() != 0 {
+		t.Errorf("producer balance = %s, want 0 with fees burned", got)
+	}
+	if got, want := chain.GetTotalSupply(), new(big.Int).Sub(amount(100), fee); got.Cmp(want) != 0 {
+		t.Errorf("total supply = %s, want %s", got, want)
+	}
+}
+
+func TestDeletedBalanceKeyInBlock(t *testing.T) {
+	authority, user, recipient := newTestKey(t), newTestKey(t), newTestKey(t)
+	deleteBalance := func(key *testKey, nonce uint64, address string) *blockchain.Transaction {
+		return newTestTx(t, key, "", nonce, &blockchain.KVOperation{Type: blockchain.OpTypeDelete, Key: blockchain.BalanceKey(address)})
+	}
+	newChain := func(policy blockchain.DeletedBalancePolicy) *blockchain.Chain {
+		config := tokenGenesis(authority, user, false)
+		config.TokenConfig.DeletedBalancePolicy = policy
+		return newTestChain(t, config)
+	}
+
+	t.Run("zero policy credits a deleted key from zero", func(t *testing.T) {
+		chain := newChain(blockchain.DeletedBalanceZero)
+		addBlock(t, chain, authority,
+			deleteBalance(authority, 0, user.address),
+			newTestTx(t, authority, "", 1, blockchain.NewMintOperation(user.address, amount(5).Bytes())))
+
+		if got, want := balanceOf(t, chain, user.address), amount(5); got.Cmp(want) != 0 {
+			t.Errorf("balance after delete then mint = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("zero policy debits a deleted key as empty", func(t *testing.T) {
+		chain := newChain(blockchain.DeletedBalanceZero)
+		txs := []*blockchain.Transaction{
+			deleteBalance(user, 0, user.address),
+			newTestTx(t, user, "", 1, blockchain.NewTransferOperation(recipient.address, amount(1).Bytes())),
+		}
+		_, err := chain.CalculateStateRootWithTransactions(txs, authority.address)
+		if err == nil || !strings.Contains(err.Error(), "insufficient balance") {
+			t.Errorf("transfer from a deleted balance: err = %v, want insufficient balance", err)
+		}
+	})
+
+	t.Run("reject policy invalidates the block", func(t *testing.T) {
+		chain := newChain(blockchain.DeletedBalanceReject)
+		for name, txs := range map[string][]*blockchain.Transaction{
+			"mint": {
+				deleteBalance(authority, 0, user.address),
+				newTestTx(t, authority, "", 1, blockchain.NewMintOperation(user.address, amount(5).Bytes())),
+			},
+			"transfer": {
+				deleteBalance(user, 0, user.address),
+				newTestTx(t, user, "", 1, blockchain.NewTransferOperation(recipient.address, amount(1).Bytes())),
+			},
+		} {
+			if _, err := chain.CalculateStateRootWithTransactions(txs, authority.address); !errors.Is(err, blockchain.ErrDeletedBalanceKey) {
+				t.Errorf("%s after delete: err = %v, want ErrDeletedBalanceKey", name, err)
+			}
+		}
+
+		// A delete in an earlier block doesn't count
+		addBlock(t, chain, authority, deleteBalance(authority, 0, user.address))
+		addBlock(t, chain, authority, newTestTx(t, authority, "", 1, blockchain.NewMintOperation(user.address, amount(5).Bytes())))
+		if got, want := balanceOf(t, chain, user.address), amount(5); got.Cmp(want) != 0 {
+			t.Errorf("balance after mint in a later block = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("order within the block is deterministic", func(t *testing.T) {
+		chain := newChain(blockchain.DeletedBalanceZero)
+		txs := []*blockchain.Transaction{
+			deleteBalance(authority, 0, user.address),
+			newTestTx(t, authority, "", 1, blockchain.NewMintOperation(user.address, amount(5).Bytes())),
+			newTestTx(t, user, "", 0, setOp("k", "v")),
+			newTestTx(t, recipient, "", 0, setOp("k", "w")),
+		}
+		reversed := slices.Clone(txs)
+		slices.Reverse(reversed)
+
+		var roots [][]byte
+		for _, order := range [][]*blockchain.Transaction{txs, reversed} {
+			blockchain.SortTransactions(order)
+			root, err := chain.CalculateStateRootWithTransactions(order, authority.address)
+			if err != nil {
+				t.Fatalf("CalculateStateRootWithTransactions: %v", err)
+			}
+			roots = append(roots, root)
+		}
+		if !bytes.Equal(roots[0], roots[1]) {
+			t.Error("the same transactions in a different submission order give a different state root")
+		}
+	})
+}
@@ -1,11 +1,13 @@
 package blockchain
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
 
 	"github.com/podoru/podoru-chain/internal/crypto"
 )
@@ -14,17 +16,21 @@ import (
 type OperationType string
 
 const (
-	OpTypeSet      OperationType = "SET"
-	OpTypeDelete   OperationType = "DELETE"
-	OpTypeMint     OperationType = "MINT"     // Authority-only mint operation
-	OpTypeTransfer OperationType = "TRANSFER" // Token transfer operation
+	OpTypeSet       OperationType = "SET"
+	OpTypeDelete    OperationType = "DELETE"
+	OpTypeMint      OperationType = "MINT"      // Authority-only mint operation
+	OpTypeTransfer  OperationType = "TRANSFER"  // Token transfer operation
+	OpTypeBurn      OperationType = "BURN"      // Destroy tokens from the sender's balance
+	OpTypeCAS       OperationType = "CAS"       // SET only if the key holds the expected value
+	OpTypeIncrement OperationType = "INCREMENT" // Add a signed decimal delta to an integer counter
 )
 
 // KVOperation represents a single key-value operation
 type KVOperation struct {
-	Type  OperationType `json:"type"`
-	Key   string        `json:"key"`
-	Value []byte        `json:"value,omitempty"` // Empty for DELETE
+	Type     OperationType `json:"type"`
+	Key      string        `json:"key"`
+	Value    []byte        `json:"value,omitempty"`    // Empty for DELETE
+	Expected []byte        `json:"expected,omitempty"` // CAS only: current value required (empty: key must be absent)
 }
 
 // TransactionData contains the actual key-value pairs
@@ -34,12 +40,14 @@ type TransactionData struct {
 
 // Transaction represents a key-value operation on the blockchain
 type Transaction struct {
-	ID        []byte           `json:"id"`         // Transaction hash
-	From      string           `json:"from"`       // Sender address
-	Timestamp int64            `json:"timestamp"`  // Unix timestamp
-	Data      *TransactionData `json:"data"`       // Transaction data
-	Signature []byte           `json:"signature"`  // Signature
-	Nonce     uint64           `json:"nonce"`      // For ordering/replay protection
+	ID         []byte           `json:"id"`                    // Transaction hash
+	From       string           `json:"from"`                  // Sender address
+	Timestamp  int64            `json:"timestamp"`             // Unix timestamp
+	Data       *TransactionData `json:"data"`                  // Transaction data
+	Signature  []byte           `json:"signature"`             // Signature
+	Nonce      uint64           `json:"nonce"`                 // For ordering/replay protection
+	ValidUntil int64            `json:"valid_until,omitempty"` // Unix time after which the tx expires (0: never)
+	ChainID    string           `json:"chain_id,omitempty"`    // Chain the tx is signed for
 }
 
 // NewTransaction creates a new transaction
@@ -58,15 +66,19 @@ func NewTransaction(from string, timestamp int64, data *TransactionData, nonce u
 func (tx *Transaction) Hash() []byte {
 	// Create a copy without ID and Signature for hashing
 	hashTx := struct {
-		From      string           `json:"from"`
-		Timestamp int64            `json:"timestamp"`
-		Data      *TransactionData `json:"data"`
-		Nonce     uint64           `json:"nonce"`
+		From       string           `json:"from"`
+		Timestamp  int64            `json:"timestamp"`
+		Data       *TransactionData `json:"data"`
+		Nonce      uint64           `json:"nonce"`
+		ValidUntil int64            `json:"valid_until,omitempty"` // Omitted when zero so existing hashes are unchanged
+		ChainID    string           `json:"chain_id,omitempty"`    // Binds the signature to one chain
 	}{
-		From:      tx.From,
-		Timestamp: tx.Timestamp,
-		Data:      tx.Data,
-		Nonce:     tx.Nonce,
+		From:       tx.From,
+		Timestamp:  tx.Timestamp,
+		Data:       tx.Data,
+		Nonce:      tx.Nonce,
+		ValidUntil: tx.ValidUntil,
+		ChainID:    tx.ChainID,
 	}
 
 	txBytes, err := json.Marshal(hashTx)
@@ -79,6 +91,32 @@ func (tx *Transaction) Hash() []byte {
 	return hash[:]
 }
 
+// ErrTransactionIDMismatch is returned for a transaction whose ID is not its hash
+var ErrTransactionIDMismatch = errors.New("transaction ID does not match its hash")
+
+// SetID sets a missing transaction ID to the transaction's hash. A supplied ID
+// that differs from the hash is rejected rather than trusted.
+func (tx *Transaction) SetID() error {
+	hash := tx.Hash()
+	if len(tx.ID) == 0 {
+		tx.ID = hash
+		return nil
+	}
+	if !bytes.Equal(tx.ID, hash) {
+		return fmt.Errorf("%w: got 0x%x, expected 0x%x", ErrTransactionIDMismatch, tx.ID, hash)
+	}
+	return nil
+}
+
+// ErrTransactionExpired is returned for a transaction whose valid_until has passed
+var ErrTransactionExpired = errors.New("transaction expired")
+
+// IsExpiredAt reports whether the transaction's validity window has passed at
+// timestamp (unix seconds). A transaction with no ValidUntil never expires.
+func (tx *Transaction) IsExpiredAt(timestamp int64) bool {
+	return tx.ValidUntil != 0 && timestamp > tx.ValidUntil
+}
+
 // Sign signs the transaction with a private key
 func (tx *Transaction) Sign(privateKey *ecdsa.PrivateKey) error {
 	hash := tx.Hash()
@@ -104,6 +142,9 @@ func (tx *Transaction) Verify() error {
 	}
 
 	hash := tx.Hash()
+	if !bytes.Equal(tx.ID, hash) {
+		return ErrTransactionIDMismatch
+	}
 
 	// Recover address from signature
 	recoveredAddr, err := crypto.RecoverAddress(hash, tx.Signature)
@@ -134,6 +175,10 @@ func (tx *Transaction) Validate() error {
 		return fmt.Errorf("invalid sender address: %s", tx.From)
 	}
 
+	if tx.ValidUntil < 0 {
+		return errors.New("valid_until cannot be negative")
+	}
+
 	if tx.Data == nil {
 		return errors.New("transaction has no data")
 	}
@@ -148,7 +193,7 @@ func (tx *Transaction) Validate() error {
 			return fmt.Errorf("operation %d has empty key", i)
 		}
 
-		if op.Type != OpTypeSet && op.Type != OpTypeDelete && op.Type != OpTypeMint && op.Type != OpTypeTransfer {
+		if op.Type != OpTypeSet && op.Type != OpTypeDelete && op.Type != OpTypeMint && op.Type != OpTypeTransfer && op.Type != OpTypeBurn && op.Type != OpTypeCAS && op.Type != OpTypeIncrement {
 			return fmt.Errorf("operation %d has invalid type: %s", i, op.Type)
 		}
 
@@ -156,6 +201,30 @@ func (tx *Transaction) Validate() error {
 			return fmt.Errorf("operation %d is SET but has no value", i)
 		}
 
+		// CAS operations need a new value; only they carry an expected value
+		if op.Type == OpTypeCAS && len(op.Value) == 0 {
+			return fmt.Errorf("operation %d is CAS but has no value", i)
+		}
+		if op.Type != OpTypeCAS && len(op.Expected) > 0 {
+			return fmt.Errorf("operation %d: only CAS operations take an expected value", i)
+		}
+
+		// INCREMENT operations need a decimal delta and can't target balances,
+		// which are stored in binary
+		if op.Type == OpTypeIncrement {
+			if _, err := ParseCounter(op.Value); err != nil {
+				return fmt.Errorf("operation %d: INCREMENT delta: %w", i, err)
+			}
+			if IsBalanceKey(op.Key) {
+				return fmt.Errorf("operation %d: INCREMENT cannot target a balance key", i)
+			}
+		}
+
+		// Reserved keys are only written by the chain itself
+		if IsReservedKey(op.Key) {
+			return fmt.Errorf("operation %d: key %s is reserved", i, op.Key)
+		}
+
 		// MINT operations must target balance keys and have a value
 		if op.Type == OpTypeMint {
 			if !IsBalanceKey(op.Key) {
@@ -176,18 +245,36 @@ func (tx *Transaction) Validate() error {
 			}
 		}
 
-		// Check key and value sizes (prevent DOS)
-		const maxKeySize = 1024         // 1 KB
-		const maxValueSize = 1024 * 1024 // 1 MB
+		// BURN operations must target the sender's own balance key and have a value
+		if op.Type == OpTypeBurn {
+			if op.Key != BalanceKey(tx.From) {
+				return fmt.Errorf("operation %d: BURN key must be the sender's balance key (%s)", i, BalanceKey(tx.From))
+			}
+			if len(op.Value) == 0 {
+				return fmt.Errorf("operation %d: BURN must have a value (amount)", i)
+			}
+		}
 
-		if len(op.Key) > maxKeySize {
+		// Token amounts must fit in a balance
+		if (op.Type == OpTypeMint || op.Type == OpTypeTransfer || op.Type == OpTypeBurn) &&
+			new(big.Int).SetBytes(op.Value).Cmp(MaxBalance) > 0 {
+			return fmt.Errorf("operation %d: %s amount exceeds maximum balance", i, op.Type)
+		}
+
+		// Check key and value sizes (prevent DOS)
+		if len(op.Key) > MaxKeySize {
 			return fmt.Errorf("operation %d key too large: %d bytes (max %d)",
-				i, len(op.Key), maxKeySize)
+				i, len(op.Key), MaxKeySize)
 		}
 
-		if len(op.Value) > maxValueSize {
+		if len(op.Value) > MaxValueSize {
 			return fmt.Errorf("operation %d value too large: %d bytes (max %d)",
-				i, len(op.Value), maxValueSize)
+				i, len(op.Value), MaxValueSize)
+		}
+
+		if len(op.Expected) > MaxValueSize {
+			return fmt.Errorf("operation %d expected value too large: %d bytes (max %d)",
+				i, len(op.Expected), MaxValueSize)
 		}
 	}
 
@@ -264,3 +351,25 @@ func (tx *Transaction) HasTransferOperations() bool {
 	}
 	return false
 }
+
+// NewBurnOperation creates a new BURN operation destroying amount from the sender's balance
+func NewBurnOperation(fromAddress string, amount []byte) *KVOperation {
+	return &KVOperation{
+		Type:  OpTypeBurn,
+		Key:   BalanceKey(fromAddress),
+		Value: amount,
+	}
+}
+
+// HasBurnOperations returns true if the transaction contains any BURN operations
+func (tx *Transaction) HasBurnOperations() bool {
+	if tx.Data == nil {
+		return false
+	}
+	for _, op := range tx.Data.Operations {
+		if op.Type == OpTypeBurn {
+			return true
+		}
+	}
+	return false
+}
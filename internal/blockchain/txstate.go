@@ -0,0 +1,44 @@
+package blockchain
+
+import "bytes"
+
+// touchedKeys returns the state keys the transaction's fee and operations can read
+// or write: the sender's balance, the total supply and every operation key
+func (tx *Transaction) touchedKeys() []string {
+	keys := []string{BalanceKey(tx.From), TotalSupplyKey}
+	for _, op := range tx.Data.Operations {
+		keys = append(keys, op.Key)
+	}
+	return keys
+}
+
+// newTxState copies keys out of state, so a transaction can be applied to the copy
+// and committed only if all of its operations succeed
+func newTxState(state *State, keys []string) *State {
+	txState := NewState()
+	for _, key := range keys {
+		if value, exists := state.Get(key); exists {
+			txState.Set(key, value)
+		}
+	}
+	return txState
+}
+
+// commitTxState writes the changes a transaction made to keys in txState back to
+// state, queueing them for storage if state is the actual state
+func (c *Chain) commitTxState(state, txState *State, keys []string) {
+	for _, key := range keys {
+		value, exists := txState.Get(key)
+		current, existed := state.Get(key)
+
+		switch {
+		case exists && (!existed || !bytes.Equal(value, current)):
+			c.setState(state, key, value)
+		case !exists && existed:
+			state.Delete(key)
+			if state == c.state {
+				c.writes.delete(key)
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package blockchain_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/storage"
+)
+
+func TestFailingOperationLeavesNoPartialState(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	config := tokenGenesis(authority, user, false)
+	config.TokenConfig.LockBalanceKeys = true
+	store := storage.NewMemoryStore()
+	chain := newTestChainOn(t, store, config)
+	stateRoot := chain.GetStateRoot()
+
+	// The first operation applies, the second is refused
+	tx := newTestTx(t, user, "", 0, setOp("first", "v"), setOp(blockchain.BalanceKey(user.address), "1"))
+
+	applicable, rejected := chain.FilterApplicableTransactions([]*blockchain.Transaction{tx})
+	if len(applicable) != 0 || len(rejected) != 1 || !errors.Is(rejected[0].Err, blockchain.ErrBalanceKeyWrite) {
+		t.Fatalf("FilterApplicableTransactions = %d applicable, %v rejected; want the transaction rejected", len(applicable), rejected)
+	}
+
+	// A block carrying it anyway is invalid, and nothing of it is kept or persisted
+	block := signBlock(t, chain, chain.GetCurrentBlock(), authority, []*blockchain.Transaction{tx}, stateRoot)
+	if err := chain.AddBlock(block); err == nil {
+		t.Fatal("block with a failing operation accepted")
+	}
+	if _, err := chain.GetState("first"); err == nil {
+		t.Error("first operation of the failed transaction was applied")
+	}
+	if !bytes.Equal(chain.GetStateRoot(), stateRoot) {
+		t.Error("state root changed")
+	}
+
+	reloaded := blockchain.NewChain(store, config.Authorities)
+	if err := reloaded.LoadFromStorage(); err != nil {
+		t.Fatalf("LoadFromStorage: %v", err)
+	}
+	if _, err := reloaded.GetState("first"); err == nil {
+		t.Error("first operation of the failed transaction was persisted")
+	}
+
+	// The chain carries on with valid transactions
+	addBlock(t, chain, authority, newTestTx(t, user, "", 0, setOp("first", "v")))
+}
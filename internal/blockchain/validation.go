@@ -5,23 +5,67 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
-	"strings"
+	"sort"
 	"time"
+
+	"github.com/podoru/podoru-chain/internal/crypto"
 )
 
+// ErrInvalidBlock wraps the errors of a block that fails validation: a bad header,
+// signature or producer, transactions that don't apply, or a wrong state root
+var ErrInvalidBlock = errors.New("block validation failed")
+
 const (
-	// MaxBlockSize is the maximum size of a block in bytes (1 MB)
-	MaxBlockSize = 1024 * 1024
+	// DefaultMaxBlockSize is the default maximum size of a block in bytes (1 MB)
+	DefaultMaxBlockSize = 1024 * 1024
+
+	// DefaultBlockSizeSoftLimit is the size producers pack blocks up to (90% of DefaultMaxBlockSize),
+	// leaving headroom so produced blocks never sit at the validation limit
+	DefaultBlockSizeSoftLimit = DefaultMaxBlockSize * 9 / 10
+
+	// blockOverheadSize is a generous estimate of a serialized block without transactions
+	blockOverheadSize = 1024
 
-	// MaxTransactionsPerBlock is the maximum number of transactions per block
-	MaxTransactionsPerBlock = 1000
+	// DefaultMaxTransactionsPerBlock is the default maximum number of transactions per block
+	DefaultMaxTransactionsPerBlock = 1000
 
 	// MaxFutureBlockTime is the maximum time a block can be in the future
 	MaxFutureBlockTime = 30 // seconds
+
+	// MaxKeySize is the maximum size of a state key in bytes (1 KB)
+	MaxKeySize = 1024
+
+	// MaxValueSize is the maximum size of a state value in bytes (1 MB)
+	MaxValueSize = 1024 * 1024
 )
 
-// ValidateBlock performs comprehensive block validation
-func ValidateBlock(block *Block, previousBlock *Block, authorities []string) error {
+// ProtocolLimits describes the limits enforced during block and transaction validation
+type ProtocolLimits struct {
+	MaxBlockSize                int   `json:"max_block_size"`
+	MaxTransactionsPerBlock     int   `json:"max_transactions_per_block"`
+	MaxFutureBlockTime          int64 `json:"max_future_block_time"`
+	MaxKeySize                  int   `json:"max_key_size"`
+	MaxValueSize                int   `json:"max_value_size"`
+	MaxOperationsPerTransaction int   `json:"max_operations_per_transaction"` // 0 = no limit
+}
+
+// GetProtocolLimits returns the limits used by ValidateBlock and Transaction.Validate
+// under the given consensus params (nil uses the defaults)
+func GetProtocolLimits(params *ConsensusParams) *ProtocolLimits {
+	params = params.WithDefaults()
+	return &ProtocolLimits{
+		MaxBlockSize:                params.MaxBlockSize,
+		MaxTransactionsPerBlock:     params.MaxTransactionsPerBlock,
+		MaxFutureBlockTime:          MaxFutureBlockTime,
+		MaxKeySize:                  MaxKeySize,
+		MaxValueSize:                MaxValueSize,
+		MaxOperationsPerTransaction: params.MaxOperationsPerTransaction,
+	}
+}
+
+// ValidateBlock performs comprehensive block validation under the given consensus
+// params (nil uses the defaults)
+func ValidateBlock(block *Block, previousBlock *Block, authorities *AuthoritySet, params *ConsensusParams) error {
 	if block == nil {
 		return errors.New("block is nil")
 	}
@@ -35,71 +79,108 @@ func ValidateBlock(block *Block, previousBlock *Block, authorities []string) err
 		return validateGenesisBlock(block)
 	}
 
+	params = params.WithDefaults()
+
 	// Check block size
-	if block.Size() > MaxBlockSize {
-		return fmt.Errorf("block too large: %d bytes (max %d)", block.Size(), MaxBlockSize)
+	if block.Size() > params.MaxBlockSize {
+		return fmt.Errorf("block too large: %d bytes (max %d)", block.Size(), params.MaxBlockSize)
 	}
 
 	// Check transaction count
-	if len(block.Transactions) > MaxTransactionsPerBlock {
+	if len(block.Transactions) > params.MaxTransactionsPerBlock {
 		return fmt.Errorf("too many transactions: %d (max %d)",
-			len(block.Transactions), MaxTransactionsPerBlock)
+			len(block.Transactions), params.MaxTransactionsPerBlock)
 	}
 
-	// Validate block height
+	var previousHeader *BlockHeader
 	if previousBlock != nil {
-		if block.Header.Height != previousBlock.Header.Height+1 {
+		previousHeader = previousBlock.Header
+	}
+	if err := validateHeader(block.SignedHeader(), previousHeader, authorities); err != nil {
+		return err
+	}
+
+	// Validate all transactions
+	for i, tx := range block.Transactions {
+		if err := tx.Validate(); err != nil {
+			return fmt.Errorf("invalid transaction at index %d: %w", i, err)
+		}
+		if err := checkOperationCount(tx, params); err != nil {
+			return fmt.Errorf("invalid transaction at index %d: %w", i, err)
+		}
+		if tx.IsExpiredAt(block.Header.Timestamp) {
+			return fmt.Errorf("invalid transaction at index %d: %w", i, ErrTransactionExpired)
+		}
+	}
+
+	// Transactions must be in canonical order so the merkle root is unique
+	for i := 1; i < len(block.Transactions); i++ {
+		if transactionLess(block.Transactions[i], block.Transactions[i-1]) {
+			return fmt.Errorf("transaction at index %d is out of canonical order", i)
+		}
+	}
+
+	// Every block after genesis has the chain's version, which fixes its merkle tree
+	if block.Header.Version != params.BlockVersion {
+		return fmt.Errorf("block version %d, chain uses %d", block.Header.Version, params.BlockVersion)
+	}
+
+	// Verify merkle root
+	calculatedMerkle := CalculateMerkleRoot(block.Transactions, block.Header.Version)
+	if !bytes.Equal(calculatedMerkle, block.Header.MerkleRoot) {
+		return errors.New("invalid merkle root")
+	}
+
+	return nil
+}
+
+// checkOperationCount checks a transaction against the max_operations_per_transaction
+// consensus param (0 allows any number)
+func checkOperationCount(tx *Transaction, params *ConsensusParams) error {
+	if params.MaxOperationsPerTransaction > 0 && len(tx.Data.Operations) > params.MaxOperationsPerTransaction {
+		return fmt.Errorf("too many operations: %d (max %d)",
+			len(tx.Data.Operations), params.MaxOperationsPerTransaction)
+	}
+	return nil
+}
+
+// validateHeader checks a header against its parent (nil to skip the linkage checks):
+// height, previous hash, timestamp, producer authority and signature
+func validateHeader(header *SignedHeader, previous *BlockHeader, authorities *AuthoritySet) error {
+	// Validate block height
+	if previous != nil {
+		if header.Header.Height != previous.Height+1 {
 			return fmt.Errorf("invalid block height: expected %d, got %d",
-				previousBlock.Header.Height+1, block.Header.Height)
+				previous.Height+1, header.Header.Height)
 		}
 	}
 
 	// Validate previous hash
-	if previousBlock != nil {
-		if !bytes.Equal(block.Header.PreviousHash, previousBlock.Hash()) {
+	if previous != nil {
+		if !bytes.Equal(header.Header.PreviousHash, previous.Hash()) {
 			return errors.New("invalid previous hash")
 		}
 	}
 
 	// Validate timestamp
-	if block.Header.Timestamp > time.Now().Unix()+MaxFutureBlockTime {
+	if header.Header.Timestamp > time.Now().Unix()+MaxFutureBlockTime {
 		return errors.New("block timestamp too far in future")
 	}
 
-	if previousBlock != nil && block.Header.Timestamp <= previousBlock.Header.Timestamp {
+	if previous != nil && header.Header.Timestamp <= previous.Timestamp {
 		return errors.New("block timestamp must be greater than previous block")
 	}
 
 	// Validate block producer is an authority
-	isAuthority := false
-	for _, addr := range authorities {
-		if addr == block.Header.ProducerAddr {
-			isAuthority = true
-			break
-		}
-	}
-	if !isAuthority {
-		return fmt.Errorf("block producer %s is not an authority", block.Header.ProducerAddr)
+	if !authorities.Contains(header.Header.ProducerAddr) {
+		return fmt.Errorf("block producer %s is not an authority", header.Header.ProducerAddr)
 	}
 
 	// Verify block signature
-	if err := block.Verify(); err != nil {
+	if err := header.Verify(); err != nil {
 		return fmt.Errorf("block signature verification failed: %w", err)
 	}
 
-	// Validate all transactions
-	for i, tx := range block.Transactions {
-		if err := tx.Validate(); err != nil {
-			return fmt.Errorf("invalid transaction at index %d: %w", i, err)
-		}
-	}
-
-	// Verify merkle root
-	calculatedMerkle := CalculateMerkleRoot(block.Transactions)
-	if !bytes.Equal(calculatedMerkle, block.Header.MerkleRoot) {
-		return errors.New("invalid merkle root")
-	}
-
 	return nil
 }
 
@@ -115,9 +196,25 @@ func validateGenesisBlock(block *Block) error {
 		return errors.New("genesis block must have empty previous hash")
 	}
 
-	// Genesis block doesn't require signature
+	// Genesis block isn't signed, so nothing else vouches for its producer
+	if block.Header.ProducerAddr != GenesisAddress {
+		return fmt.Errorf("genesis block producer must be %s, got %s", GenesisAddress, block.Header.ProducerAddr)
+	}
+	if len(block.Signature) != 0 {
+		return errors.New("genesis block must not be signed")
+	}
+
+	for i, tx := range block.Transactions {
+		if !tx.IsGenesisTransaction() {
+			return fmt.Errorf("genesis transaction %d is from %s, not %s", i, tx.From, GenesisAddress)
+		}
+		if len(tx.Signature) != 0 {
+			return fmt.Errorf("genesis transaction %d must not be signed", i)
+		}
+	}
+
 	// Merkle root should still be valid
-	calculatedMerkle := CalculateMerkleRoot(block.Transactions)
+	calculatedMerkle := CalculateMerkleRoot(block.Transactions, block.Header.Version)
 	if !bytes.Equal(calculatedMerkle, block.Header.MerkleRoot) {
 		return errors.New("invalid merkle root in genesis block")
 	}
@@ -174,7 +271,7 @@ func ValidateTransactionBalance(tx *Transaction, senderBalance *big.Int, gasConf
 }
 
 // ValidateMintOperation validates a MINT operation
-func ValidateMintOperation(tx *Transaction, authorities []string) error {
+func ValidateMintOperation(tx *Transaction, authorities *AuthoritySet) error {
 	if tx == nil {
 		return errors.New("transaction is nil")
 	}
@@ -190,16 +287,7 @@ func ValidateMintOperation(tx *Transaction, authorities []string) error {
 	}
 
 	// Check if sender is an authority
-	normalizedFrom := strings.ToLower(tx.From)
-	isAuth := false
-	for _, auth := range authorities {
-		if strings.ToLower(auth) == normalizedFrom {
-			isAuth = true
-			break
-		}
-	}
-
-	if !isAuth {
+	if !authorities.Contains(tx.From) {
 		return fmt.Errorf("only authorities can mint tokens, %s is not an authority", tx.From)
 	}
 
@@ -207,7 +295,7 @@ func ValidateMintOperation(tx *Transaction, authorities []string) error {
 }
 
 // ValidateTransactionWithChain performs full transaction validation including balance check
-func ValidateTransactionWithChain(tx *Transaction, currentNonce uint64, senderBalance *big.Int, gasConfig *GasConfig, authorities []string) error {
+func ValidateTransactionWithChain(tx *Transaction, currentNonce uint64, senderBalance *big.Int, gasConfig *GasConfig, authorities *AuthoritySet) error {
 	// Basic validation
 	if err := ValidateTransaction(tx, currentNonce); err != nil {
 		return err
@@ -231,7 +319,7 @@ func ValidateTransactionWithChain(tx *Transaction, currentNonce uint64, senderBa
 	return nil
 }
 
-// ValidateTransferBalance validates that a sender has enough balance for transfers + gas
+// ValidateTransferBalance validates that a sender has enough balance for transfers and burns + gas
 func ValidateTransferBalance(tx *Transaction, senderBalance *big.Int, gasConfig *GasConfig) error {
 	if tx == nil || tx.Data == nil {
 		return nil
@@ -242,10 +330,10 @@ func ValidateTransferBalance(tx *Transaction, senderBalance *big.Int, gasConfig
 		return nil
 	}
 
-	// Calculate total transfer amount
+	// Calculate total amount leaving the sender's balance
 	totalTransfer := big.NewInt(0)
 	for _, op := range tx.Data.Operations {
-		if op.Type == OpTypeTransfer {
+		if op.Type == OpTypeTransfer || op.Type == OpTypeBurn {
 			amount := new(big.Int).SetBytes(op.Value)
 			totalTransfer.Add(totalTransfer, amount)
 		}
@@ -276,3 +364,114 @@ func ValidateTransferBalance(tx *Transaction, senderBalance *big.Int, gasConfig
 
 	return nil
 }
+
+// TransactionCost returns the total amount a transaction debits from its sender:
+// the gas fee plus all TRANSFER and BURN amounts
+func TransactionCost(tx *Transaction, gasConfig *GasConfig) *big.Int {
+	cost := big.NewInt(0)
+	if tx == nil || tx.IsGenesisTransaction() {
+		return cost
+	}
+
+	if gasConfig != nil && !gasConfig.IsZeroFee() {
+		cost.Add(cost, gasConfig.CalculateGasFee(tx.Size()))
+	}
+
+	if tx.Data != nil {
+		for _, op := range tx.Data.Operations {
+			if op.Type == OpTypeTransfer || op.Type == OpTypeBurn {
+				cost.Add(cost, new(big.Int).SetBytes(op.Value))
+			}
+		}
+	}
+
+	return cost
+}
+
+// RejectedTransaction is a candidate transaction that can't be included on top of the
+// current tip, and why
+type RejectedTransaction struct {
+	Tx  *Transaction
+	Err error
+}
+
+// FilterApplicableTransactions checks candidate transactions, in canonical order, for a
+// block on top of the current tip. Each is validated with ValidateTransactionWithChain
+// against the nonces and balances left by the ones before it, then applied to a copy of
+// the state. It returns the transactions that apply and those that never will (a
+// replayed nonce, an unaffordable fee or transfer, a failing operation). A transaction
+// with a nonce ahead of its sender's next one is neither, as it may apply once the
+// gap is filled.
+func (c *Chain) FilterApplicableTransactions(transactions []*Transaction) ([]*Transaction, []RejectedTransaction) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	tempState := c.state.Clone()
+	nonces := make(map[string]uint64)
+	applicable := make([]*Transaction, 0, len(transactions))
+	var rejected []RejectedTransaction
+
+	for _, tx := range transactions {
+		nonce, exists := nonces[tx.From]
+		if !exists {
+			nonce = c.nonces[tx.From]
+		}
+		if tx.Nonce > nonce {
+			continue // Waiting for the sender's earlier transactions
+		}
+
+		if _, err := c.storage.GetReceipt(tx.ID); err == nil {
+			rejected = append(rejected, RejectedTransaction{Tx: tx, Err: ErrDuplicateTransaction})
+			continue
+		}
+
+		balance := c.getBalance(tempState, BalanceKey(tx.From)).Amount
+		if err := ValidateTransactionWithChain(tx, nonce, balance, c.feeConfig(), c.authorities); err != nil {
+			rejected = append(rejected, RejectedTransaction{Tx: tx, Err: err})
+			continue
+		}
+
+		// A failing transaction leaves the state untouched
+		if _, _, err := c.applyTransactionsWithFees(tempState, []*Transaction{tx}, ""); err != nil {
+			rejected = append(rejected, RejectedTransaction{Tx: tx, Err: err})
+			continue
+		}
+
+		nonces[tx.From] = nonce + 1
+		applicable = append(applicable, tx)
+	}
+
+	return applicable, rejected
+}
+
+// SelectTransactionsBySize returns the leading transactions whose combined serialized
+// size keeps the block within sizeLimit bytes, stopping at the first one that doesn't fit
+func SelectTransactionsBySize(transactions []*Transaction, sizeLimit int) []*Transaction {
+	size := blockOverheadSize
+	for i, tx := range transactions {
+		size += tx.Size() + 1 // +1 for the separating comma
+		if size > sizeLimit {
+			return transactions[:i]
+		}
+	}
+	return transactions
+}
+
+// SortTransactions sorts transactions into the canonical block order: by sender,
+// then nonce, then transaction ID
+func SortTransactions(transactions []*Transaction) {
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactionLess(transactions[i], transactions[j])
+	})
+}
+
+// transactionLess reports whether a comes before b in the canonical block order
+func transactionLess(a, b *Transaction) bool {
+	if fromA, fromB := crypto.NormalizeAddress(a.From), crypto.NormalizeAddress(b.From); fromA != fromB {
+		return fromA < fromB
+	}
+	if a.Nonce != b.Nonce {
+		return a.Nonce < b.Nonce
+	}
+	return bytes.Compare(a.ID, b.ID) < 0
+}
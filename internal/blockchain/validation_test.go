@@ -0,0 +1,186 @@
+package blockchain_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+func TestTransactionCost(t *testing.T) {
+	user, other := newTestKey(t), newTestKey(t)
+	tx := newTestTx(t, user, "", 0,
+		blockchain.NewTransferOperation(other.address, big.NewInt(300).Bytes()),
+		blockchain.NewBurnOperation(user.address, big.NewInt(20).Bytes()),
+		setOp("k", "v"),
+	)
+	gas := &blockchain.GasConfig{BaseFee: big.NewInt(1000), PerByteFee: big.NewInt(2)}
+
+	if got := blockchain.TransactionCost(tx, nil); got.Cmp(big.NewInt(320)) != 0 {
+		t.Errorf("cost without gas = %s, want 320", got)
+	}
+	want := new(big.Int).Add(big.NewInt(320), gas.CalculateGasFee(tx.Size()))
+	if got := blockchain.TransactionCost(tx, gas); got.Cmp(want) != 0 {
+		t.Errorf("cost with gas = %s, want %s", got, want)
+	}
+}
+
+func TestCanonicalTransactionOrder(t *testing.T) {
+	authority := newTestKey(t)
+	chain := newTestChain(t, testGenesis(authority))
+	users := []*testKey{newTestKey(t), newTestKey(t), newTestKey(t)}
+
+	var txs []*blockchain.Transaction
+	for _, user := range users {
+		for nonce := range uint64(4) {
+			txs = append(txs, newTestTx(t, user, "", nonce, setOp(fmt.Sprintf("%s/%d", user.address, nonce), "v")))
+		}
+	}
+
+	// Any insertion order gives the same block contents and roots
+	var merkleRoot, stateRoot []byte
+	rng := rand.New(rand.NewSource(1))
+	for i := range 5 {
+		shuffled := slices.Clone(txs)
+		rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		blockchain.SortTransactions(shuffled)
+
+		version := chain.GetConsensusParams().BlockVersion
+		merkle := blockchain.CalculateMerkleRoot(shuffled, version)
+		state, err := chain.CalculateStateRootWithTransactions(shuffled, authority.address)
+		if err != nil {
+			t.Fatalf("CalculateStateRootWithTransactions: %v", err)
+		}
+		if i > 0 && (!bytes.Equal(merkle, merkleRoot) || !bytes.Equal(state, stateRoot)) {
+			t.Fatalf("order %d gives different roots", i)
+		}
+		merkleRoot, stateRoot = merkle, state
+	}
+
+	// A block listing them out of order is rejected even with matching roots
+	blockchain.SortTransactions(txs)
+	misordered := slices.Clone(txs)
+	misordered[0], misordered[1] = misordered[1], misordered[0]
+	block := signBlock(t, chain, chain.GetCurrentBlock(), authority, misordered, stateRoot)
+	err := chain.AddBlock(block)
+	if err == nil || !strings.Contains(err.Error(), "canonical order") {
+		t.Errorf("AddBlock of a misordered block = %v, want a canonical order error", err)
+	}
+
+	addBlock(t, chain, authority, txs...)
+}
+
+func TestBlockRejectsExpiredTransaction(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	chain := newTestChain(t, testGenesis(authority))
+	timestamp := chain.GetCurrentBlock().Header.Timestamp + 1
+
+	// valid_until is signed, so it can't be extended after the fact
+	tx := blockchain.NewTransaction(user.address, timestamp-10, &blockchain.TransactionData{
+		Operations: []*blockchain.KVOperation{setOp("k", "v")},
+	}, 0)
+	unbounded := tx.Hash()
+	tx.ValidUntil = timestamp - 1
+	if bytes.Equal(tx.Hash(), unbounded) {
+		t.Fatal("valid_until is not part of the transaction hash")
+	}
+	tx.ID = tx.Hash()
+	if err := tx.Sign(user.private); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	block := buildBlock(t, chain, chain.GetCurrentBlock(), authority, []*blockchain.Transaction{tx})
+	if err := chain.AddBlock(block); !errors.Is(err, blockchain.ErrTransactionExpired) {
+		t.Fatalf("block with an expired transaction: err = %v, want ErrTransactionExpired", err)
+	}
+
+	// Up to and including valid_until the transaction is still valid
+	tx.ValidUntil = timestamp
+	tx.ID = tx.Hash()
+	if err := tx.Sign(user.private); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	addBlock(t, chain, authority, tx)
+}
+
+func TestBlockLimitsFromGenesis(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	config := testGenesis(authority)
+	config.ConsensusParams = &blockchain.ConsensusParams{MaxBlockSize: blockchain.MinBlockSize, MaxTransactionsPerBlock: 3}
+	chain := newTestChain(t, config)
+
+	// Well under the default limits, over the configured ones
+	var small []*blockchain.Transaction
+	for nonce := range uint64(4) {
+		small = append(small, newTestTx(t, user, "", nonce, setOp(fmt.Sprint(nonce), "v")))
+	}
+	if err := chain.AddBlock(buildBlock(t, chain, chain.GetCurrentBlock(), authority, small)); err == nil || !strings.Contains(err.Error(), "too many transactions") {
+		t.Errorf("block over max_transactions_per_block: err = %v", err)
+	}
+
+	value := strings.Repeat("x", blockchain.MinBlockSize/3)
+	var large []*blockchain.Transaction
+	for nonce := range uint64(3) {
+		large = append(large, newTestTx(t, user, "", nonce, setOp(fmt.Sprint(nonce), value)))
+	}
+	if err := chain.AddBlock(buildBlock(t, chain, chain.GetCurrentBlock(), authority, large)); err == nil || !strings.Contains(err.Error(), "block too large") {
+		t.Errorf("block over max_block_size: err = %v", err)
+	}
+
+	addBlock(t, chain, authority, small[:3]...)
+}
+
+func TestSelectTransactionsBySize(t *testing.T) {
+	authority, user := newTestKey(t), newTestKey(t)
+	chain := newTestChain(t, testGenesis(authority))
+
+	value := strings.Repeat("x", 1500)
+	var txs []*blockchain.Transaction
+	for nonce := range uint64(12) {
+		txs = append(txs, newTestTx(t, user, "", nonce, setOp(fmt.Sprintf("key/%02d", nonce), value)))
+	}
+	blockchain.SortTransactions(txs)
+
+	// The selection stops exactly at the first transaction that doesn't fit
+	limit := 1024 // Allowance for the block without transactions
+	for _, tx := range txs[:4] {
+		limit += tx.Size() + 1
+	}
+	if got := blockchain.SelectTransactionsBySize(txs, limit); len(got) != 4 {
+		t.Errorf("selected %d transactions at the size of 4, want 4", len(got))
+	}
+	if got := blockchain.SelectTransactionsBySize(txs, limit-1); len(got) != 3 {
+		t.Errorf("selected %d transactions a byte short of 4, want 3", len(got))
+	}
+	if got := blockchain.SelectTransactionsBySize(txs, 0); len(got) != 0 {
+		t.Errorf("selected %d transactions with no room, want 0", len(got))
+	}
+
+	// Producers pack to the soft limit, while validation allows blocks up to the hard limit
+	full := buildBlock(t, chain, chain.GetCurrentBlock(), authority, txs)
+	hardLimit := full.Size()
+	softLimit := hardLimit * 9 / 10
+
+	packed := blockchain.SelectTransactionsBySize(txs, softLimit)
+	if len(packed) == 0 || len(packed) == len(txs) {
+		t.Fatalf("packed %d of %d transactions under the soft limit", len(packed), len(txs))
+	}
+	if size := buildBlock(t, chain, chain.GetCurrentBlock(), authority, packed).Size(); size > softLimit {
+		t.Errorf("packed block is %d bytes, over the soft limit %d", size, softLimit)
+	}
+
+	chain.SetConsensusParams(&blockchain.ConsensusParams{MaxBlockSize: hardLimit - 1})
+	if err := chain.AddBlock(full); err == nil {
+		t.Error("block a byte over the hard limit accepted")
+	}
+	chain.SetConsensusParams(&blockchain.ConsensusParams{MaxBlockSize: hardLimit})
+	if err := chain.AddBlock(full); err != nil {
+		t.Errorf("block at the hard limit rejected: %v", err)
+	}
+}
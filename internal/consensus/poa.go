@@ -9,12 +9,17 @@ import (
 	"github.com/podoru/podoru-chain/internal/blockchain"
 )
 
+// MaxClockDrift is how far ahead of the local clock a block may be dated. It is
+// well under the slot timeouts in use, so a block can't claim a slot by more than
+// clock skew between honest nodes.
+const MaxClockDrift = 2 * time.Second
+
 // PoAEngine implements Proof of Authority consensus
 type PoAEngine struct {
-	mu           sync.RWMutex
-	authorities  []string          // List of authority addresses
-	authorityMap map[string]bool   // Quick lookup for authorities
-	blockTime    time.Duration     // Target block time
+	mu          sync.RWMutex
+	authorities *blockchain.AuthoritySet // Authority addresses in rotation order
+	blockTime   time.Duration            // Target block time
+	slotTimeout time.Duration            // Time after which the next authority may take over a missed slot
 }
 
 // NewPoAEngine creates a new PoA consensus engine
@@ -27,27 +32,43 @@ func NewPoAEngine(authorities []string, blockTime time.Duration) (*PoAEngine, er
 		blockTime = 5 * time.Second // Default 5 seconds
 	}
 
-	authMap := make(map[string]bool)
-	for _, addr := range authorities {
-		if authMap[addr] {
-			return nil, fmt.Errorf("duplicate authority: %s", addr)
-		}
-		authMap[addr] = true
+	if dup := blockchain.FindDuplicateAuthority(authorities); dup != "" {
+		return nil, fmt.Errorf("duplicate authority: %s", dup)
 	}
 
 	return &PoAEngine{
-		authorities:  authorities,
-		authorityMap: authMap,
-		blockTime:    blockTime,
+		authorities: blockchain.NewAuthoritySet(authorities),
+		blockTime:   blockTime,
+		slotTimeout: 2 * blockTime,
 	}, nil
 }
 
+// SetSlotTimeout sets how long after the previous block a missed slot is handed
+// to the next authority in the rotation (defaults to 2x block time)
+func (poa *PoAEngine) SetSlotTimeout(timeout time.Duration) {
+	poa.mu.Lock()
+	defer poa.mu.Unlock()
+
+	if timeout <= 0 {
+		timeout = 2 * poa.blockTime
+	}
+	poa.slotTimeout = timeout
+}
+
+// GetSlotTimeout returns the slot timeout
+func (poa *PoAEngine) GetSlotTimeout() time.Duration {
+	poa.mu.RLock()
+	defer poa.mu.RUnlock()
+
+	return poa.slotTimeout
+}
+
 // IsAuthorized checks if an address is an authority
 func (poa *PoAEngine) IsAuthorized(address string) bool {
 	poa.mu.RLock()
 	defer poa.mu.RUnlock()
 
-	return poa.authorityMap[address]
+	return poa.authorities.Contains(address)
 }
 
 // GetBlockProducer determines which authority should produce the next block
@@ -56,40 +77,106 @@ func (poa *PoAEngine) GetBlockProducer(height uint64) string {
 	poa.mu.RLock()
 	defer poa.mu.RUnlock()
 
-	if len(poa.authorities) == 0 {
+	return poa.blockProducer(height)
+}
+
+// blockProducer returns the authority whose turn height is (caller must hold poa.mu)
+func (poa *PoAEngine) blockProducer(height uint64) string {
+	if poa.authorities.Len() == 0 {
 		return ""
 	}
 
-	index := height % uint64(len(poa.authorities))
-	return poa.authorities[index]
+	return poa.authorities.Get(int(height % uint64(poa.authorities.Len())))
+}
+
+// isProducer reports whether address is the authority whose turn height is,
+// ignoring address casing (caller must hold poa.mu)
+func (poa *PoAEngine) isProducer(height uint64, address string) bool {
+	index, exists := poa.authorities.Index(address)
+	return exists && uint64(index) == height%uint64(poa.authorities.Len())
 }
 
 // CanProduceBlock checks if a given address can produce a block at this height
 func (poa *PoAEngine) CanProduceBlock(height uint64, address string) bool {
-	expectedProducer := poa.GetBlockProducer(height)
-	return expectedProducer == address
+	poa.mu.RLock()
+	defer poa.mu.RUnlock()
+
+	return poa.isProducer(height, address)
+}
+
+// MissedSlots returns how many producers have been skipped for the next block,
+// based on the time elapsed since the previous block. Each full slot timeout
+// without a block hands the height to the next authority in the rotation.
+func (poa *PoAEngine) MissedSlots(lastBlockTime int64, now time.Time) uint64 {
+	poa.mu.RLock()
+	defer poa.mu.RUnlock()
+
+	if poa.slotTimeout <= 0 {
+		return 0
+	}
+
+	elapsed := now.Sub(time.Unix(lastBlockTime, 0))
+	if elapsed < poa.slotTimeout {
+		return 0
+	}
+
+	return uint64(elapsed / poa.slotTimeout)
+}
+
+// GetBlockProducerAt determines which authority should produce the block at height,
+// taking missed slots since the previous block into account
+func (poa *PoAEngine) GetBlockProducerAt(height uint64, lastBlockTime int64, now time.Time) string {
+	return poa.GetBlockProducer(height + poa.MissedSlots(lastBlockTime, now))
 }
 
-// ValidateBlockProducer validates that the correct authority produced the block
-func (poa *PoAEngine) ValidateBlockProducer(block *blockchain.Block) error {
+// CanProduceBlockAt checks if a given address can produce the block at height,
+// allowing backup authorities to take over slots missed by an offline producer
+func (poa *PoAEngine) CanProduceBlockAt(height uint64, address string, lastBlockTime int64, now time.Time) bool {
+	return poa.CanProduceBlock(height+poa.MissedSlots(lastBlockTime, now), address)
+}
+
+// ValidateBlockProducer validates that the correct authority produced the block,
+// bounding its timestamp by the local clock as in ValidateBlockProducerAt
+func (poa *PoAEngine) ValidateBlockProducer(block *blockchain.Block, previous *blockchain.Block) error {
+	return poa.ValidateBlockProducerAt(block, previous, time.Now())
+}
+
+// ValidateBlockProducerAt validates that the correct authority produced the block.
+// Slots missed between the previous block and this block's timestamp pass the turn
+// to the next authority, as in CanProduceBlockAt; a nil previous block allows none.
+// The missed slots depend only on the two timestamps, so every node agrees on them.
+// The local clock only bounds how far ahead a block may be dated (MaxClockDrift),
+// so an authority can't claim a later slot by dating its block well ahead of time.
+func (poa *PoAEngine) ValidateBlockProducerAt(block *blockchain.Block, previous *blockchain.Block, now time.Time) error {
 	// Skip validation for genesis block
 	if blockchain.IsGenesisBlock(block) {
 		return nil
 	}
 
+	blockTime := time.Unix(block.Header.Timestamp, 0)
+	if blockTime.After(now.Add(MaxClockDrift)) {
+		return fmt.Errorf("block %d is dated %v ahead of the local clock (max %v)",
+			block.Header.Height, blockTime.Sub(now), MaxClockDrift)
+	}
+
+	var missed uint64
+	if previous != nil {
+		missed = poa.MissedSlots(previous.Header.Timestamp, blockTime)
+	}
+
 	poa.mu.RLock()
 	defer poa.mu.RUnlock()
 
 	// Check if producer is an authority
-	if !poa.authorityMap[block.Header.ProducerAddr] {
+	if !poa.authorities.Contains(block.Header.ProducerAddr) {
 		return fmt.Errorf("producer %s is not an authority", block.Header.ProducerAddr)
 	}
 
 	// Check if it's the correct producer for this height
-	expectedProducer := poa.GetBlockProducer(block.Header.Height)
-	if block.Header.ProducerAddr != expectedProducer {
+	slot := block.Header.Height + missed
+	if !poa.isProducer(slot, block.Header.ProducerAddr) {
 		return fmt.Errorf("wrong producer for height %d: expected %s, got %s",
-			block.Header.Height, expectedProducer, block.Header.ProducerAddr)
+			block.Header.Height, poa.blockProducer(slot), block.Header.ProducerAddr)
 	}
 
 	return nil
@@ -103,15 +190,25 @@ func (poa *PoAEngine) GetBlockTime() time.Duration {
 	return poa.blockTime
 }
 
+// SetBlockTime sets the target block time. The slot timeout is left unchanged; call
+// SetSlotTimeout to recompute its default.
+func (poa *PoAEngine) SetBlockTime(blockTime time.Duration) {
+	poa.mu.Lock()
+	defer poa.mu.Unlock()
+
+	if blockTime <= 0 {
+		blockTime = 5 * time.Second // Default 5 seconds
+	}
+	poa.blockTime = blockTime
+}
+
 // GetAuthorities returns the list of authorities
 func (poa *PoAEngine) GetAuthorities() []string {
 	poa.mu.RLock()
 	defer poa.mu.RUnlock()
 
 	// Return a copy to prevent modification
-	authorities := make([]string, len(poa.authorities))
-	copy(authorities, poa.authorities)
-	return authorities
+	return poa.authorities.Addresses()
 }
 
 // GetAuthorityCount returns the number of authorities
@@ -119,7 +216,7 @@ func (poa *PoAEngine) GetAuthorityCount() int {
 	poa.mu.RLock()
 	defer poa.mu.RUnlock()
 
-	return len(poa.authorities)
+	return poa.authorities.Len()
 }
 
 // UpdateAuthorities updates the list of authorities
@@ -133,16 +230,11 @@ func (poa *PoAEngine) UpdateAuthorities(newAuthorities []string) error {
 	defer poa.mu.Unlock()
 
 	// Check for duplicates
-	authMap := make(map[string]bool)
-	for _, addr := range newAuthorities {
-		if authMap[addr] {
-			return fmt.Errorf("duplicate authority in new list: %s", addr)
-		}
-		authMap[addr] = true
+	if dup := blockchain.FindDuplicateAuthority(newAuthorities); dup != "" {
+		return fmt.Errorf("duplicate authority in new list: %s", dup)
 	}
 
-	poa.authorities = newAuthorities
-	poa.authorityMap = authMap
+	poa.authorities = blockchain.NewAuthoritySet(newAuthorities)
 
 	return nil
 }
@@ -156,6 +248,45 @@ func (poa *PoAEngine) CalculateNextBlockTime(lastBlockTime int64) time.Time {
 	return lastTime.Add(poa.blockTime)
 }
 
+// NextProductionTime returns the earliest time at which address may produce the block
+// at height, given the previous block's timestamp. It accounts for both the target
+// block time and the slot timeouts that hand missed slots to later authorities.
+// Non-authorities get now plus one block time.
+func (poa *PoAEngine) NextProductionTime(height uint64, address string, lastBlockTime int64, now time.Time) time.Time {
+	poa.mu.RLock()
+	defer poa.mu.RUnlock()
+
+	lastTime := time.Unix(lastBlockTime, 0)
+	earliest := lastTime.Add(poa.blockTime)
+
+	// Find how many slots must be missed before it is this address's turn
+	index, exists := poa.authorities.Index(address)
+	if !exists {
+		return now.Add(poa.blockTime)
+	}
+	count := uint64(poa.authorities.Len())
+	offset := (uint64(index) + count - height%count) % count
+
+	// Skip ahead by whole rotations if the scheduled slot has already passed
+	if poa.slotTimeout > 0 {
+		elapsedSlots := uint64(0)
+		if now.After(lastTime) {
+			elapsedSlots = uint64(now.Sub(lastTime) / poa.slotTimeout)
+		}
+		for offset < elapsedSlots {
+			offset += count
+		}
+		if slotStart := lastTime.Add(time.Duration(offset) * poa.slotTimeout); slotStart.After(earliest) {
+			earliest = slotStart
+		}
+	}
+
+	if earliest.Before(now) {
+		return now
+	}
+	return earliest
+}
+
 // ShouldProduceBlock checks if it's time to produce a new block
 func (poa *PoAEngine) ShouldProduceBlock(lastBlockTime int64) bool {
 	nextBlockTime := poa.CalculateNextBlockTime(lastBlockTime)
@@ -0,0 +1,147 @@
+package consensus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+const (
+	authorityA = "0x1000000000000000000000000000000000000001"
+	authorityB = "0x2000000000000000000000000000000000000002"
+	authorityC = "0x3000000000000000000000000000000000000003"
+)
+
+// testBlock returns an unsigned block header at height, timestamp and producer
+func testBlock(height uint64, timestamp int64, producer string) *blockchain.Block {
+	return &blockchain.Block{Header: &blockchain.BlockHeader{Height: height, Timestamp: timestamp, ProducerAddr: producer}}
+}
+
+func TestValidateBlockProducerMissedSlot(t *testing.T) {
+	poa, err := NewPoAEngine([]string{authorityA, authorityB, authorityC}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poa.SetSlotTimeout(10 * time.Second)
+
+	previous := testBlock(0, 1000, blockchain.GenesisAddress)
+	now := time.Unix(1100, 0)
+
+	// Height 1 is B's turn; C may take it once one slot timeout has passed
+	if err := poa.ValidateBlockProducerAt(testBlock(1, 1005, authorityB), previous, now); err != nil {
+		t.Fatalf("scheduled producer rejected: %v", err)
+	}
+	if err := poa.ValidateBlockProducerAt(testBlock(1, 1005, authorityC), previous, now); err == nil {
+		t.Fatal("backup producer accepted before the slot timeout")
+	}
+	if err := poa.ValidateBlockProducerAt(testBlock(1, 1010, authorityC), previous, now); err != nil {
+		t.Fatalf("backup producer rejected after the slot timeout: %v", err)
+	}
+}
+
+func TestValidateBlockProducerFutureTimestamp(t *testing.T) {
+	poa, err := NewPoAEngine([]string{authorityA, authorityB, authorityC}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poa.SetSlotTimeout(10 * time.Second)
+
+	previous := testBlock(0, 1000, blockchain.GenesisAddress)
+	now := time.Unix(1002, 0)
+
+	// C dates its block past the slot timeout while B's slot is still open
+	if err := poa.ValidateBlockProducerAt(testBlock(1, 1015, authorityC), previous, now); err == nil {
+		t.Fatal("backup block dated ahead of the local clock accepted")
+	}
+	// Once the clock reaches the timeout the same block is valid
+	if err := poa.ValidateBlockProducerAt(testBlock(1, 1015, authorityC), previous, time.Unix(1015, 0)); err != nil {
+		t.Fatalf("backup block rejected once its slot was missed: %v", err)
+	}
+}
+
+func TestValidateBlockProducerIgnoresClockSkew(t *testing.T) {
+	poa, err := NewPoAEngine([]string{authorityA, authorityB, authorityC}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poa.SetSlotTimeout(10 * time.Second)
+
+	previous := testBlock(0, 1000, blockchain.GenesisAddress)
+	backup := testBlock(1, 1010, authorityC)
+	scheduled := testBlock(1, 1010, authorityB)
+
+	// Two nodes whose clocks differ by less than the allowed drift agree on both blocks
+	for _, now := range []time.Time{time.Unix(1009, 0), time.Unix(1011, 0), time.Unix(1100, 0)} {
+		if err := poa.ValidateBlockProducerAt(backup, previous, now); err != nil {
+			t.Fatalf("backup block rejected at clock %d: %v", now.Unix(), err)
+		}
+		if err := poa.ValidateBlockProducerAt(scheduled, previous, now); err == nil {
+			t.Fatalf("scheduled producer accepted for a missed slot at clock %d", now.Unix())
+		}
+	}
+}
+
+func TestNextProductionTime(t *testing.T) {
+	poa, err := NewPoAEngine([]string{authorityA, authorityB, authorityC}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poa.SetSlotTimeout(10 * time.Second)
+	const last = 1000
+
+	tests := []struct {
+		name    string
+		address string
+		now     int64
+		want    int64
+	}{
+		// Height 1 is B's turn: one block time after the previous block, however
+		// far into the wait the clock is
+		{name: "scheduled producer", address: authorityB, now: 1001, want: 1005},
+		{name: "scheduled producer later", address: authorityB, now: 1004, want: 1005},
+		{name: "scheduled producer overdue", address: authorityB, now: 1007, want: 1007},
+		// Backups wait for the slots before theirs to time out
+		{name: "first backup", address: authorityC, now: 1001, want: 1010},
+		{name: "second backup", address: authorityA, now: 1001, want: 1020},
+		// A slot already passed comes round again a rotation later
+		{name: "missed own slot", address: authorityC, now: 1025, want: 1040},
+		{name: "not an authority", address: "0x4000000000000000000000000000000000000004", now: 1001, want: 1006},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := poa.NextProductionTime(1, tt.address, last, time.Unix(tt.now, 0))
+			if got.Unix() != tt.want {
+				t.Errorf("NextProductionTime = %d, want %d", got.Unix(), tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorityLookupsIgnoreCasing(t *testing.T) {
+	mixed := "0xAbCdEf0000000000000000000000000000000001"
+	poa, err := NewPoAEngine([]string{mixed, authorityB}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, address := range []string{mixed, strings.ToLower(mixed), strings.ToUpper(mixed[2:])} {
+		if !poa.IsAuthorized(address) {
+			t.Errorf("IsAuthorized(%q) = false", address)
+		}
+		// Height 0 is the first authority's turn
+		if !poa.CanProduceBlock(0, address) {
+			t.Errorf("CanProduceBlock(0, %q) = false", address)
+		}
+	}
+
+	// A block whose producer is spelled differently from the configured address is accepted
+	previous := testBlock(1, 1000, authorityB)
+	if err := poa.ValidateBlockProducerAt(testBlock(2, 1005, strings.ToLower(mixed)), previous, time.Unix(1005, 0)); err != nil {
+		t.Errorf("producer in lowercase rejected: %v", err)
+	}
+	if err := poa.ValidateBlockProducerAt(testBlock(2, 1005, strings.ToUpper(authorityB)), previous, time.Unix(1005, 0)); err == nil {
+		t.Error("producer out of turn accepted")
+	}
+}
@@ -0,0 +1,109 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+const (
+	// MnemonicEntropyBits is the entropy size for generated mnemonics (24 words)
+	MnemonicEntropyBits = 256
+
+	// hardenedOffset marks a hardened BIP32 child index
+	hardenedOffset uint32 = 0x80000000
+)
+
+// DerivationPath returns the BIP44 path used for the key at index: m/44'/60'/0'/0/index
+func DerivationPath(index uint32) string {
+	return fmt.Sprintf("m/44'/60'/0'/0/%d", index)
+}
+
+// NewMnemonic generates a new 24-word BIP39 mnemonic
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(MnemonicEntropyBits)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate entropy: %w", err)
+	}
+
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return "", fmt.Errorf("failed to create mnemonic: %w", err)
+	}
+
+	return mnemonic, nil
+}
+
+// DeriveKey derives the private key at BIP44 path m/44'/60'/0'/0/index from a BIP39 mnemonic
+func DeriveKey(mnemonic string, index uint32) (*ecdsa.PrivateKey, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid mnemonic: %w", err)
+	}
+
+	key, chainCode := masterKey(seed)
+
+	path := []uint32{
+		44 + hardenedOffset,
+		60 + hardenedOffset,
+		0 + hardenedOffset,
+		0,
+		index,
+	}
+	for _, child := range path {
+		key, chainCode, err = deriveChild(key, chainCode, child)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive %s: %w", DerivationPath(index), err)
+		}
+	}
+
+	return crypto.ToECDSA(key)
+}
+
+// masterKey computes the BIP32 master key and chain code from a seed
+func masterKey(seed []byte) ([]byte, []byte) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	return sum[:32], sum[32:]
+}
+
+// deriveChild computes a BIP32 child private key and chain code
+func deriveChild(key, chainCode []byte, index uint32) ([]byte, []byte, error) {
+	var data []byte
+	if index >= hardenedOffset {
+		data = append([]byte{0x00}, key...)
+	} else {
+		parent, err := crypto.ToECDSA(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = crypto.CompressPubkey(&parent.PublicKey)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	mac := hmac.New(sha512.New, chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := crypto.S256().Params().N
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(n) >= 0 {
+		return nil, nil, errors.New("derived key out of range")
+	}
+
+	child := il.Add(il, new(big.Int).SetBytes(key))
+	child.Mod(child, n)
+	if child.Sign() == 0 {
+		return nil, nil, errors.New("derived key is zero")
+	}
+
+	return child.FillBytes(make([]byte, 32)), sum[32:], nil
+}
@@ -0,0 +1,108 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestMasterKeyAndChildVectors(t *testing.T) {
+	// BIP32 test vector 1
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	key, chainCode := masterKey(seed)
+	if got := hex.EncodeToString(key); got != "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35" {
+		t.Errorf("master key = %s", got)
+	}
+	if got := hex.EncodeToString(chainCode); got != "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508" {
+		t.Errorf("master chain code = %s", got)
+	}
+
+	steps := []struct {
+		path      string
+		index     uint32
+		key       string
+		chainCode string
+	}{
+		{path: "m/0'", index: 0 + hardenedOffset,
+			key:       "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea",
+			chainCode: "47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141"},
+		{path: "m/0'/1", index: 1,
+			key:       "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368",
+			chainCode: "2a7857631386ba23dacac34180dd1983734e444fdbf774041578e9b6adb37c19"},
+	}
+	for _, step := range steps {
+		var err error
+		key, chainCode, err = deriveChild(key, chainCode, step.index)
+		if err != nil {
+			t.Fatalf("%s: %v", step.path, err)
+		}
+		if got := hex.EncodeToString(key); got != step.key {
+			t.Errorf("%s key = %s, want %s", step.path, got, step.key)
+		}
+		if got := hex.EncodeToString(chainCode); got != step.chainCode {
+			t.Errorf("%s chain code = %s, want %s", step.path, got, step.chainCode)
+		}
+	}
+}
+
+func TestDeriveKeyVectors(t *testing.T) {
+	const abandon = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	const junk = "test test test test test test test test test test test junk"
+
+	tests := []struct {
+		mnemonic string
+		index    uint32
+		key      string
+		address  string
+	}{
+		{mnemonic: abandon, index: 0,
+			key:     "1ab42cc412b618bdea3a599e3c9bae199ebf030895b039e9db1e30dafb12b727",
+			address: "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"},
+		{mnemonic: junk, index: 0,
+			key:     "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80",
+			address: "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"},
+		{mnemonic: junk, index: 1,
+			key:     "59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d",
+			address: "0x70997970C51812dc3A010C7d01b50e0d17dc79C8"},
+	}
+
+	for _, tt := range tests {
+		key, err := DeriveKey(tt.mnemonic, tt.index)
+		if err != nil {
+			t.Fatalf("DeriveKey(%s): %v", DerivationPath(tt.index), err)
+		}
+		if got := hex.EncodeToString(PrivateKeyToBytes(key)); got != tt.key {
+			t.Errorf("%s key = %s, want %s", DerivationPath(tt.index), got, tt.key)
+		}
+		address, err := AddressFromPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.EqualFold(address, tt.address) {
+			t.Errorf("%s address = %s, want %s", DerivationPath(tt.index), address, tt.address)
+		}
+	}
+}
+
+func TestDeriveKeyRejectsInvalidMnemonic(t *testing.T) {
+	// Valid words with a bad checksum
+	if _, err := DeriveKey("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon", 0); err == nil {
+		t.Error("mnemonic with a bad checksum accepted")
+	}
+	if _, err := DeriveKey("not a mnemonic", 0); err == nil {
+		t.Error("invalid mnemonic accepted")
+	}
+}
+
+func TestNewMnemonicDerives(t *testing.T) {
+	mnemonic, err := NewMnemonic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if words := len(strings.Fields(mnemonic)); words != 24 {
+		t.Errorf("mnemonic has %d words, want 24", words)
+	}
+	if _, err := DeriveKey(mnemonic, 0); err != nil {
+		t.Errorf("DeriveKey of a new mnemonic: %v", err)
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
 )
 
 // GenerateKeyPair generates a new ECDSA key pair using secp256k1 curve
@@ -78,3 +81,69 @@ func ValidatePrivateKey(privateKey *ecdsa.PrivateKey) error {
 	}
 	return nil
 }
+
+// SaveEncryptedPrivateKey saves a private key as a passphrase-encrypted
+// Web3 (v3) keystore JSON file
+func SaveEncryptedPrivateKey(privateKey *ecdsa.PrivateKey, filePath string, passphrase string) error {
+	if err := ValidatePrivateKey(privateKey); err != nil {
+		return err
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return fmt.Errorf("failed to generate key id: %w", err)
+	}
+
+	key := &keystore.Key{
+		Id:         id,
+		Address:    crypto.PubkeyToAddress(privateKey.PublicKey),
+		PrivateKey: privateKey,
+	}
+
+	keyJSON, err := keystore.EncryptKey(key, passphrase, keystore.StandardScryptN, keystore.StandardScryptP)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt key: %w", err)
+	}
+
+	return os.WriteFile(filePath, keyJSON, 0600)
+}
+
+// LoadEncryptedPrivateKey loads a private key from a Web3 (v3) keystore JSON file
+func LoadEncryptedPrivateKey(filePath string, passphrase string) (*ecdsa.PrivateKey, error) {
+	keyJSON, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key: %w", err)
+	}
+
+	return key.PrivateKey, nil
+}
+
+// IsKeystoreFile reports whether a key file holds keystore JSON rather than raw hex
+func IsKeystoreFile(filePath string) (bool, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	return strings.HasPrefix(strings.TrimSpace(string(data)), "{"), nil
+}
+
+// LoadPrivateKey loads a private key from either a keystore JSON file
+// (decrypted with passphrase) or a plaintext hex key file
+func LoadPrivateKey(filePath string, passphrase string) (*ecdsa.PrivateKey, error) {
+	isKeystore, err := IsKeystoreFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if isKeystore {
+		return LoadEncryptedPrivateKey(filePath, passphrase)
+	}
+
+	return LoadPrivateKeyFromFile(filePath)
+}
@@ -0,0 +1,67 @@
+package crypto
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptedKeyRoundTrip(t *testing.T) {
+	key, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "producer.json")
+	if err := SaveEncryptedPrivateKey(key, path, "correct horse"); err != nil {
+		t.Fatalf("SaveEncryptedPrivateKey: %v", err)
+	}
+
+	isKeystore, err := IsKeystoreFile(path)
+	if err != nil || !isKeystore {
+		t.Fatalf("IsKeystoreFile = %v, %v; want true", isKeystore, err)
+	}
+
+	loaded, err := LoadPrivateKey(path, "correct horse")
+	if err != nil {
+		t.Fatalf("LoadPrivateKey: %v", err)
+	}
+	if loaded.D.Cmp(key.D) != 0 {
+		t.Error("loaded key differs from the saved one")
+	}
+
+	if _, err := LoadPrivateKey(path, "wrong horse"); err == nil {
+		t.Error("key decrypted with a wrong passphrase")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupted := filepath.Join(t.TempDir(), "corrupted.json")
+	if err := os.WriteFile(corrupted, data[:len(data)/2], 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPrivateKey(corrupted, "correct horse"); err == nil {
+		t.Error("truncated keystore file loaded")
+	}
+}
+
+func TestLoadPrivateKeyPlainHex(t *testing.T) {
+	key, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "producer.key")
+	if err := SavePrivateKeyToFile(key, path); err != nil {
+		t.Fatal(err)
+	}
+
+	// The passphrase is ignored for a plaintext key file
+	loaded, err := LoadPrivateKey(path, "unused")
+	if err != nil {
+		t.Fatalf("LoadPrivateKey: %v", err)
+	}
+	if loaded.D.Cmp(key.D) != 0 {
+		t.Error("loaded key differs from the saved one")
+	}
+}
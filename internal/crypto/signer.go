@@ -0,0 +1,144 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Signer signs 32-byte hashes on behalf of an address
+type Signer interface {
+	// Sign returns a 65-byte recoverable secp256k1 signature of hash
+	Sign(hash []byte) ([]byte, error)
+
+	// Address returns the address whose key produces the signatures
+	Address() string
+}
+
+// KeySigner signs with a private key held in memory
+type KeySigner struct {
+	privateKey *ecdsa.PrivateKey
+	address    string
+}
+
+// NewKeySigner creates a signer from a local private key
+func NewKeySigner(privateKey *ecdsa.PrivateKey) (*KeySigner, error) {
+	address, err := AddressFromPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeySigner{
+		privateKey: privateKey,
+		address:    address,
+	}, nil
+}
+
+// Sign signs a hash with the private key
+func (s *KeySigner) Sign(hash []byte) ([]byte, error) {
+	return Sign(hash, s.privateKey)
+}
+
+// Address returns the signer's address
+func (s *KeySigner) Address() string {
+	return s.address
+}
+
+// DefaultRemoteSignerTimeout bounds each request to a remote signer
+const DefaultRemoteSignerTimeout = 5 * time.Second
+
+// RemoteSignRequest is the body POSTed to a remote signer
+type RemoteSignRequest struct {
+	Address string `json:"address"`
+	Hash    string `json:"hash"` // 0x-prefixed hex
+}
+
+// RemoteSignResponse is the body a remote signer replies with
+type RemoteSignResponse struct {
+	Signature string `json:"signature"` // 0x-prefixed hex, 65 bytes
+	Error     string `json:"error,omitempty"`
+}
+
+// RemoteSigner delegates signing to an HTTP service (e.g. an HSM gateway), so
+// the private key never reaches the node
+type RemoteSigner struct {
+	url     string
+	address string
+	client  *http.Client
+}
+
+// NewRemoteSigner creates a signer that POSTs hashes to url for the given address
+func NewRemoteSigner(url, address string, timeout time.Duration) (*RemoteSigner, error) {
+	if url == "" {
+		return nil, errors.New("remote signer url is required")
+	}
+	if !IsValidAddress(address) {
+		return nil, fmt.Errorf("invalid signer address: %s", address)
+	}
+	if timeout <= 0 {
+		timeout = DefaultRemoteSignerTimeout
+	}
+
+	return &RemoteSigner{
+		url:     url,
+		address: address,
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Sign requests a signature from the remote signer and checks it recovers to the signer's address
+func (s *RemoteSigner) Sign(hash []byte) ([]byte, error) {
+	if len(hash) != 32 {
+		return nil, errors.New("hash must be 32 bytes")
+	}
+
+	body, err := json.Marshal(&RemoteSignRequest{
+		Address: s.address,
+		Hash:    "0x" + hex.EncodeToString(hash),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal sign request: %w", err)
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("remote signer request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var signResp RemoteSignResponse
+	if err := json.NewDecoder(resp.Body).Decode(&signResp); err != nil {
+		return nil, fmt.Errorf("invalid remote signer response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote signer returned %d: %s", resp.StatusCode, signResp.Error)
+	}
+
+	signature, err := hex.DecodeString(strings.TrimPrefix(signResp.Signature, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote signature encoding: %w", err)
+	}
+
+	// Never trust the remote side to have used the right key
+	recovered, err := RecoverAddress(hash, signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote signature: %w", err)
+	}
+	if NormalizeAddress(recovered) != NormalizeAddress(s.address) {
+		return nil, fmt.Errorf("remote signature is from %s, expected %s", recovered, s.address)
+	}
+
+	return signature, nil
+}
+
+// Address returns the signer's address
+func (s *RemoteSigner) Address() string {
+	return s.address
+}
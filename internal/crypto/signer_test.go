@@ -0,0 +1,103 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// remoteSignerServer is a mock remote signer answering every request with a
+// signature by key
+func remoteSignerServer(t *testing.T, key *ecdsa.PrivateKey) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req RemoteSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&RemoteSignResponse{Error: err.Error()})
+			return
+		}
+		hash, err := hex.DecodeString(strings.TrimPrefix(req.Hash, "0x"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&RemoteSignResponse{Error: err.Error()})
+			return
+		}
+		signature, err := Sign(hash, key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&RemoteSignResponse{Error: err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(&RemoteSignResponse{Signature: "0x" + hex.EncodeToString(signature)})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRemoteSigner(t *testing.T) {
+	key, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := AddressFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256([]byte("block"))
+
+	signer, err := NewRemoteSigner(remoteSignerServer(t, key).URL, address, 0)
+	if err != nil {
+		t.Fatalf("NewRemoteSigner: %v", err)
+	}
+	signature, err := signer.Sign(hash[:])
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if recovered, err := RecoverAddress(hash[:], signature); err != nil || NormalizeAddress(recovered) != NormalizeAddress(address) {
+		t.Errorf("signature recovers to %s, %v; want %s", recovered, err, address)
+	}
+	if _, err := signer.Sign(hash[:16]); err == nil {
+		t.Error("signed a hash that isn't 32 bytes")
+	}
+
+	// A signer answering with another key's signatures is caught
+	other, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong, err := NewRemoteSigner(remoteSignerServer(t, other).URL, address, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wrong.Sign(hash[:]); err == nil {
+		t.Error("accepted a signature from the wrong key")
+	}
+
+	// Errors reported by the signer are passed on
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(&RemoteSignResponse{Error: "key locked"})
+	}))
+	defer failing.Close()
+	locked, err := NewRemoteSigner(failing.URL, address, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := locked.Sign(hash[:]); err == nil || !strings.Contains(err.Error(), "key locked") {
+		t.Errorf("Sign with a failing signer = %v, want its error", err)
+	}
+
+	if _, err := NewRemoteSigner("", address, 0); err == nil {
+		t.Error("remote signer without a URL accepted")
+	}
+	if _, err := NewRemoteSigner(failing.URL, "not-an-address", 0); err == nil {
+		t.Error("remote signer with an invalid address accepted")
+	}
+}
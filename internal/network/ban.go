@@ -0,0 +1,130 @@
+package network
+
+import (
+	"net"
+	"time"
+)
+
+// Peer banning defaults
+const (
+	// DefaultBanThreshold is how many faults a peer may commit before it is banned
+	DefaultBanThreshold = 3
+
+	// DefaultBanDuration is how long a banned peer is refused
+	DefaultBanDuration = time.Hour
+)
+
+// SetBanPolicy sets how many faults get a peer banned and for how long (threshold 0 disables banning)
+func (p2p *P2PServer) SetBanPolicy(threshold int, duration time.Duration) {
+	p2p.mu.Lock()
+	defer p2p.mu.Unlock()
+
+	p2p.banThreshold = threshold
+	p2p.banDuration = duration
+}
+
+// RecordFault counts a fault against a peer, such as serving an invalid block. Faults
+// are counted against both the node ID and the remote IP, since a node can generate a
+// new ID at will. A peer reaching the ban threshold on either is disconnected, and its
+// node ID and IP are refused until the ban expires. Returns true if the peer was banned.
+func (p2p *P2PServer) RecordFault(peer *Peer, reason error) bool {
+	p2p.mu.Lock()
+	defer p2p.mu.Unlock()
+
+	if p2p.banThreshold <= 0 {
+		return false
+	}
+
+	p2p.faults[peer.ID]++
+	faults := p2p.faults[peer.ID]
+	ip := bannableIP(peer.Address)
+	if ip != "" {
+		p2p.ipFaults[ip]++
+		faults = max(faults, p2p.ipFaults[ip])
+	}
+	p2p.logger.Warnf("Fault %d/%d for peer %s: %v", faults, p2p.banThreshold, peer.ID, reason)
+	if faults < p2p.banThreshold {
+		return false
+	}
+
+	until := time.Now().Add(p2p.banDuration)
+	delete(p2p.faults, peer.ID)
+	p2p.banned[peer.ID] = until
+	if ip != "" {
+		delete(p2p.ipFaults, ip)
+		p2p.bannedIPs[ip] = until
+	}
+	p2p.logger.Warnf("Banning peer %s (%s) for %v", peer.ID, peer.Address, p2p.banDuration)
+	peer.Conn.Close()
+	return true
+}
+
+// IsBanned reports whether the node with the given peer ID is currently banned
+func (p2p *P2PServer) IsBanned(peerID string) bool {
+	p2p.mu.Lock()
+	defer p2p.mu.Unlock()
+
+	return p2p.isBanned(peerID, time.Now())
+}
+
+// IsAddressBanned reports whether connections from the IP of address are currently refused
+func (p2p *P2PServer) IsAddressBanned(address string) bool {
+	p2p.mu.Lock()
+	defer p2p.mu.Unlock()
+
+	return p2p.isAddressBanned(address, time.Now())
+}
+
+// isBanned reports whether a peer is banned at now, forgetting an expired ban (caller must hold p2p.mu)
+func (p2p *P2PServer) isBanned(peerID string, now time.Time) bool {
+	return banActive(p2p.banned, peerID, now)
+}
+
+// isAddressBanned reports whether the IP of address is banned at now (caller must hold p2p.mu)
+func (p2p *P2PServer) isAddressBanned(address string, now time.Time) bool {
+	ip := bannableIP(address)
+	return ip != "" && banActive(p2p.bannedIPs, ip, now)
+}
+
+// banActive reports whether key is banned at now, forgetting an expired ban
+func banActive(bans map[string]time.Time, key string, now time.Time) bool {
+	until, exists := bans[key]
+	if !exists {
+		return false
+	}
+	if now.After(until) {
+		delete(bans, key)
+		return false
+	}
+	return true
+}
+
+// bannableIP returns the IP of a host:port address, or "" if it has none or it is a
+// loopback address: nodes sharing a host, as on a local test network, are told apart
+// by node ID only
+func bannableIP(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return ""
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() {
+		return ""
+	}
+	return ip.String()
+}
+
+// BannedPeers returns the IDs of banned peers and when their bans expire
+func (p2p *P2PServer) BannedPeers() map[string]time.Time {
+	p2p.mu.Lock()
+	defer p2p.mu.Unlock()
+
+	now := time.Now()
+	banned := make(map[string]time.Time, len(p2p.banned))
+	for id, until := range p2p.banned {
+		if p2p.isBanned(id, now) {
+			banned[id] = until
+		}
+	}
+	return banned
+}
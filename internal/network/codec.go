@@ -0,0 +1,174 @@
+package network
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Codec encodes P2P messages for the wire
+type Codec interface {
+	Name() string
+	Encode(msg *Message) ([]byte, error)
+	Decode(data []byte) (*Message, error)
+}
+
+// Codec names
+const (
+	CodecJSON = "json"
+	CodecGob  = "gob"
+)
+
+// JSONCodec encodes messages as JSON. It is the default and what every peer understands.
+type JSONCodec struct{}
+
+// Name returns the codec name
+func (JSONCodec) Name() string { return CodecJSON }
+
+// Encode marshals a message as JSON
+func (JSONCodec) Encode(msg *Message) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+// Decode unmarshals a JSON message
+func (JSONCodec) Decode(data []byte) (*Message, error) {
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// GobCodec encodes messages with encoding/gob, keeping []byte fields as raw bytes
+type GobCodec struct{}
+
+// gobMessage is the gob wire form of a Message; the payload is encoded separately
+// so it can be decoded into the concrete type for the message type
+type gobMessage struct {
+	Type    MessageType
+	From    string
+	Payload []byte
+}
+
+// Name returns the codec name
+func (GobCodec) Name() string { return CodecGob }
+
+// Encode gob-encodes a message
+func (GobCodec) Encode(msg *Message) ([]byte, error) {
+	wire := gobMessage{Type: msg.Type, From: msg.From}
+
+	// gob refuses structs without fields, so empty payloads are sent as no bytes
+	if hasPayloadFields(msg.Payload) {
+		var payload bytes.Buffer
+		if err := gob.NewEncoder(&payload).Encode(msg.Payload); err != nil {
+			return nil, fmt.Errorf("failed to encode payload: %w", err)
+		}
+		wire.Payload = payload.Bytes()
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&wire); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decodes a gob message, restoring the payload's concrete type
+func (GobCodec) Decode(data []byte) (*Message, error) {
+	var wire gobMessage
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&wire); err != nil {
+		return nil, err
+	}
+
+	payload := newPayload(wire.Type)
+	if payload == nil {
+		return nil, fmt.Errorf("unknown message type %d", wire.Type)
+	}
+
+	if len(wire.Payload) > 0 {
+		if err := gob.NewDecoder(bytes.NewReader(wire.Payload)).Decode(payload); err != nil {
+			return nil, fmt.Errorf("failed to decode payload: %w", err)
+		}
+	}
+
+	return &Message{Type: wire.Type, Payload: payload, From: wire.From}, nil
+}
+
+// hasPayloadFields reports whether a payload is a non-nil value with fields to encode
+func hasPayloadFields(payload interface{}) bool {
+	if payload == nil {
+		return false
+	}
+	v := reflect.ValueOf(payload)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	return v.Kind() != reflect.Struct || v.NumField() > 0
+}
+
+// newPayload returns an empty payload of the type carried by a message type
+func newPayload(msgType MessageType) interface{} {
+	switch msgType {
+	case MsgTypePing:
+		return &PingMessage{}
+	case MsgTypePong:
+		return &PongMessage{}
+	case MsgTypeGetPeers:
+		return &GetPeersMessage{}
+	case MsgTypePeers:
+		return &PeersMessage{}
+	case MsgTypeNewBlock:
+		return &NewBlockMessage{}
+	case MsgTypeGetBlocks:
+		return &GetBlocksMessage{}
+	case MsgTypeBlocks:
+		return &BlocksMessage{}
+	case MsgTypeNewTransaction:
+		return &NewTransactionMessage{}
+	case MsgTypeGetBlockByHeight:
+		return &GetBlockByHeightMessage{}
+	case MsgTypeGetBlockByHash:
+		return &GetBlockByHashMessage{}
+	case MsgTypeGetState:
+		return &GetStateMessage{}
+	case MsgTypeGetHeight:
+		return &GetHeightMessage{}
+	case MsgTypeHeight:
+		return &HeightMessage{}
+	case MsgTypeTipAnnounce:
+		return &TipAnnounceMessage{}
+	case MsgTypeCodecRequest, MsgTypeCodecAck:
+		return &CodecMessage{}
+	case MsgTypeHello:
+		return &HelloMessage{}
+	case MsgTypeHelloProof:
+		return &HelloProofMessage{}
+	case MsgTypeGetHeaders:
+		return &GetHeadersMessage{}
+	case MsgTypeHeaders:
+		return &HeadersMessage{}
+	case MsgTypeGetStateProof:
+		return &GetStateProofMessage{}
+	case MsgTypeStateProof:
+		return &StateProofMessage{}
+	default:
+		return nil
+	}
+}
+
+// CodecByName returns the codec with the given name, or nil if unknown
+func CodecByName(name string) Codec {
+	switch name {
+	case CodecJSON:
+		return JSONCodec{}
+	case CodecGob:
+		return GobCodec{}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,153 @@
+package network
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+// codecBlock returns a signed-looking block at height carrying txCount transactions
+func codecBlock(height uint64, txCount int) *blockchain.Block {
+	txs := make([]*blockchain.Transaction, txCount)
+	for i := range txs {
+		tx := blockchain.NewTransaction("0x0000000000000000000000000000000000000001", 1700000000+int64(i),
+			&blockchain.TransactionData{Operations: []*blockchain.KVOperation{
+				{Type: blockchain.OpTypeSet, Key: fmt.Sprintf("key:%d", i), Value: []byte("value")},
+				{Type: blockchain.OpTypeCAS, Key: "counter", Value: []byte("2"), Expected: []byte("1")},
+			}}, uint64(i))
+		tx.Signature = make([]byte, 65)
+		tx.ValidUntil = 1800000000
+		tx.ChainID = "test-chain"
+		txs[i] = tx
+	}
+
+	block := blockchain.NewBlock(&blockchain.BlockHeader{
+		Version:      2,
+		Height:       height,
+		PreviousHash: []byte{1, 2, 3},
+		Timestamp:    1700000000,
+		MerkleRoot:   []byte{4, 5, 6},
+		StateRoot:    []byte{7, 8, 9},
+		ProducerAddr: "0x0000000000000000000000000000000000000002",
+		Nonce:        1,
+		ChainID:      "test-chain",
+	}, txs)
+	block.Signature = make([]byte, 65)
+	return block
+}
+
+// codecPayloads returns a populated payload for every message type. Fields are
+// non-zero so a field the codec drops shows up as a mismatch.
+func codecPayloads() map[MessageType]interface{} {
+	block := codecBlock(7, 3)
+	return map[MessageType]interface{}{
+		MsgTypePing:     &PingMessage{Timestamp: 1},
+		MsgTypePong:     &PongMessage{Timestamp: 2},
+		MsgTypeGetPeers: &GetPeersMessage{},
+		MsgTypePeers: &PeersMessage{Peers: []PeerInfo{
+			{ID: "peer-1", Address: "10.0.0.1", Port: 9000},
+			{ID: "peer-2", Address: "10.0.0.2", Port: 9001},
+		}},
+		MsgTypeNewBlock:         &NewBlockMessage{Block: block},
+		MsgTypeGetBlocks:        &GetBlocksMessage{FromHeight: 1, ToHeight: 10},
+		MsgTypeBlocks:           &BlocksMessage{Blocks: []*blockchain.Block{codecBlock(1, 1), block}, More: true},
+		MsgTypeNewTransaction:   &NewTransactionMessage{Transaction: block.Transactions[0]},
+		MsgTypeGetBlockByHeight: &GetBlockByHeightMessage{Height: 5},
+		MsgTypeGetBlockByHash:   &GetBlockByHashMessage{Hash: []byte{0xde, 0xad}},
+		MsgTypeGetState:         &GetStateMessage{Key: "user:1"},
+		MsgTypeGetHeight:        &GetHeightMessage{},
+		MsgTypeHeight:           &HeightMessage{Height: 42},
+		MsgTypeTipAnnounce:      &TipAnnounceMessage{Height: 42, Hash: []byte{0xbe, 0xef}},
+		MsgTypeCodecRequest:     &CodecMessage{Codec: CodecGob},
+		MsgTypeCodecAck:         &CodecMessage{Codec: CodecGob},
+		MsgTypeHello: &HelloMessage{
+			ChainID:     "test-chain",
+			Version:     ProtocolVersion,
+			GenesisHash: []byte{1},
+			Height:      3,
+			NodeID:      "node",
+			ListenPort:  9000,
+			PublicKey:   []byte{2},
+			Nonce:       []byte{3},
+		},
+		MsgTypeHelloProof:    &HelloProofMessage{Signature: []byte{4}},
+		MsgTypeGetHeaders:    &GetHeadersMessage{FromHeight: 1, ToHeight: 2},
+		MsgTypeHeaders:       &HeadersMessage{Headers: []*blockchain.SignedHeader{block.SignedHeader()}},
+		MsgTypeGetStateProof: &GetStateProofMessage{Key: "user:1"},
+		MsgTypeStateProof: &StateProofMessage{
+			Key:    "user:1",
+			Height: 7,
+			Proof: &blockchain.StateProof{
+				Key:   "user:1",
+				Value: []byte("alice"),
+				Steps: []blockchain.MerkleProofStep{{Hash: []byte{5}, Left: true}, {Hash: []byte{6}}},
+			},
+			Error: "partial",
+		},
+	}
+}
+
+func TestGobCodecRoundTrip(t *testing.T) {
+	payloads := codecPayloads()
+	codec := GobCodec{}
+
+	for msgType := MsgTypePing; msgType <= MsgTypeStateProof; msgType++ {
+		payload, ok := payloads[msgType]
+		if !ok {
+			t.Errorf("no test payload for message type %d", msgType)
+			continue
+		}
+
+		msg := &Message{Type: msgType, Payload: payload, From: "peer-1"}
+		data, err := codec.Encode(msg)
+		if err != nil {
+			t.Errorf("type %d: Encode: %v", msgType, err)
+			continue
+		}
+		decoded, err := codec.Decode(data)
+		if err != nil {
+			t.Errorf("type %d: Decode: %v", msgType, err)
+			continue
+		}
+		if !reflect.DeepEqual(decoded, msg) {
+			t.Errorf("type %d: decoded %+v, want %+v", msgType, decoded.Payload, payload)
+		}
+	}
+}
+
+func TestGobCodecRejectsUnknownType(t *testing.T) {
+	codec := GobCodec{}
+	data, err := codec.Encode(&Message{Type: MsgTypeStateProof + 1, Payload: &PingMessage{Timestamp: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := codec.Decode(data); err == nil {
+		t.Error("decoded a message of unknown type")
+	}
+}
+
+// BenchmarkCodecEncodedSize reports the encoded size of a full BlocksMessage chunk
+// under each codec
+func BenchmarkCodecEncodedSize(b *testing.B) {
+	blocks := make([]*blockchain.Block, 16)
+	for i := range blocks {
+		blocks[i] = codecBlock(uint64(i+1), 50)
+	}
+	msg := &Message{Type: MsgTypeBlocks, Payload: &BlocksMessage{Blocks: blocks}, From: "peer-1"}
+
+	for _, codec := range []Codec{JSONCodec{}, GobCodec{}} {
+		b.Run(codec.Name(), func(b *testing.B) {
+			var size int
+			for b.Loop() {
+				data, err := codec.Encode(msg)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size), "bytes/msg")
+		})
+	}
+}
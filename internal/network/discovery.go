@@ -0,0 +1,175 @@
+package network
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"time"
+)
+
+// maxPeersPerMessage caps the addresses sent in one PeersMessage
+const maxPeersPerMessage = 100
+
+// redialInterval is how long a discovered address is left alone after dialing it,
+// so nodes that are full or unreachable aren't dialed on every discovery round
+const redialInterval = 5 * time.Minute
+
+// StartDiscovery periodically asks peers for the peers they know and dials new
+// ones until the peer limit is reached. It also answers peers' discovery requests.
+func (p2p *P2PServer) StartDiscovery(interval time.Duration) {
+	p2p.RegisterHandler(MsgTypeGetPeers, p2p.handleGetPeers)
+	p2p.RegisterHandler(MsgTypePeers, p2p.handlePeers)
+
+	if interval <= 0 {
+		return
+	}
+
+	p2p.wg.Add(1)
+	go func() {
+		defer p2p.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p2p.stopChan:
+				return
+			case <-ticker.C:
+				if !p2p.atPeerLimit() {
+					p2p.BroadcastMessage(&Message{Type: MsgTypeGetPeers, Payload: &GetPeersMessage{}})
+				}
+			}
+		}
+	}()
+}
+
+// handleGetPeers responds with the listen addresses of our other peers
+func (p2p *P2PServer) handleGetPeers(peer *Peer, msg *Message) error {
+	return p2p.SendMessage(peer, &Message{
+		Type:    MsgTypePeers,
+		Payload: &PeersMessage{Peers: p2p.knownPeers(peer.ID)},
+	})
+}
+
+// handlePeers dials the advertised peers we aren't connected to, up to the peer limit
+func (p2p *P2PServer) handlePeers(peer *Peer, msg *Message) error {
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return err
+	}
+
+	var peersMsg PeersMessage
+	if err := json.Unmarshal(payloadBytes, &peersMsg); err != nil {
+		return err
+	}
+
+	for _, info := range peersMsg.Peers {
+		if info.Port <= 0 || info.Address == "" {
+			continue
+		}
+		address := net.JoinHostPort(info.Address, strconv.Itoa(info.Port))
+
+		if !p2p.reserveDial(address, info.ID) {
+			continue
+		}
+		p2p.trackPeer(address, false)
+
+		go func() {
+			defer p2p.releaseDial(address)
+
+			p2p.logger.Infof("Discovered peer %s via %s, connecting", address, peer.ID)
+			if err := p2p.ConnectToPeer(address); err != nil {
+				p2p.logger.Debugf("Failed to connect to discovered peer %s: %v", address, err)
+
+				p2p.mu.Lock()
+				p2p.trackedDisconnected(address)
+				p2p.mu.Unlock()
+			}
+		}()
+	}
+
+	return nil
+}
+
+// knownPeers lists the dialable peers other than exclude
+func (p2p *P2PServer) knownPeers(exclude string) []PeerInfo {
+	p2p.mu.RLock()
+	defer p2p.mu.RUnlock()
+
+	infos := make([]PeerInfo, 0, len(p2p.peers))
+	for id, peer := range p2p.peers {
+		if id == exclude || peer.ListenAddr == "" {
+			continue
+		}
+
+		host, portStr, err := net.SplitHostPort(peer.ListenAddr)
+		if err != nil {
+			continue
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			continue
+		}
+
+		infos = append(infos, PeerInfo{ID: peer.NodeID, Address: host, Port: port})
+		if len(infos) == maxPeersPerMessage {
+			break
+		}
+	}
+
+	return infos
+}
+
+// atPeerLimit reports whether no more peers should be dialed
+func (p2p *P2PServer) atPeerLimit() bool {
+	p2p.mu.RLock()
+	defer p2p.mu.RUnlock()
+
+	return p2p.maxPeers > 0 && len(p2p.peers)+len(p2p.dialing) >= p2p.maxPeers
+}
+
+// reserveDial marks address as being dialed if it is new, not banned and there is
+// room for another peer. Returns false if it should not be dialed.
+func (p2p *P2PServer) reserveDial(address, nodeID string) bool {
+	p2p.mu.Lock()
+	defer p2p.mu.Unlock()
+
+	if nodeID != "" && nodeID == p2p.nodeID {
+		return false
+	}
+
+	now := time.Now()
+	if (nodeID != "" && p2p.isBanned(nodeID, now)) || p2p.isAddressBanned(address, now) {
+		return false
+	}
+	for addr, dialed := range p2p.lastDial {
+		if now.Sub(dialed) >= redialInterval {
+			delete(p2p.lastDial, addr)
+		}
+	}
+	if _, recent := p2p.lastDial[address]; recent {
+		return false
+	}
+
+	if p2p.dialing[address] || (p2p.maxPeers > 0 && len(p2p.peers)+len(p2p.dialing) >= p2p.maxPeers) {
+		return false
+	}
+	for _, peer := range p2p.peers {
+		if peer.ListenAddr == address || (nodeID != "" && peer.NodeID == nodeID) {
+			return false
+		}
+	}
+
+	p2p.dialing[address] = true
+	p2p.lastDial[address] = now
+	return true
+}
+
+// releaseDial clears a dial reservation
+func (p2p *P2PServer) releaseDial(address string) {
+	p2p.mu.Lock()
+	defer p2p.mu.Unlock()
+
+	delete(p2p.dialing, address)
+}
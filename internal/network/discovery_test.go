@@ -0,0 +1,105 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/podoru/podoru-chain/internal/crypto"
+)
+
+// discoveryServer starts a listening server with its own node identity that answers
+// and acts on discovery messages, returning it and its listen address
+func discoveryServer(t *testing.T) (*P2PServer, string) {
+	t.Helper()
+
+	key, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := handshakeServer("podoru", []byte("genesis"))
+	server.SetNodeKey(key)
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	server.StartDiscovery(0)
+	t.Cleanup(server.Stop)
+	return server, server.listener.Addr().String()
+}
+
+// waitPeers waits until server has want peers
+func waitPeers(t *testing.T, server *P2PServer, want int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for server.PeerCount() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("peer count = %d, want %d", server.PeerCount(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// discoveryNetwork returns a hub connected to two other servers, and a newcomer
+// connected only to the hub
+func discoveryNetwork(t *testing.T, newcomerMaxPeers int) (hub, newcomer *P2PServer, hubAddr string) {
+	t.Helper()
+
+	hub, hubAddr = discoveryServer(t)
+	for range 2 {
+		_, addr := discoveryServer(t)
+		if err := hub.ConnectToPeer(addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitPeers(t, hub, 2)
+
+	newcomer, _ = discoveryServer(t)
+	newcomer.SetMaxPeers(newcomerMaxPeers)
+	if err := newcomer.ConnectToPeer(hubAddr); err != nil {
+		t.Fatal(err)
+	}
+	waitPeers(t, newcomer, 1)
+	waitPeers(t, hub, 3)
+	return hub, newcomer, hubAddr
+}
+
+func TestDiscoveryFindsPeersOfPeers(t *testing.T) {
+	hub, newcomer, hubAddr := discoveryNetwork(t, 0)
+
+	// The hub advertises the two servers it dialed, not the newcomer asking
+	for _, peer := range hub.GetPeers() {
+		if peer.ListenAddr != "" {
+			continue
+		}
+		if infos := hub.knownPeers(peer.ID); len(infos) != 2 {
+			t.Errorf("hub advertises %d peers to the newcomer, want 2", len(infos))
+		}
+	}
+
+	newcomer.BroadcastMessage(&Message{Type: MsgTypeGetPeers, Payload: &GetPeersMessage{}})
+	waitPeers(t, newcomer, 3)
+
+	// Connected and recently dialed addresses aren't dialed again
+	if newcomer.reserveDial(hubAddr, "") {
+		t.Error("reserved a dial to an already connected peer")
+	}
+	if newcomer.reserveDial("127.0.0.1:1", newcomer.NodeID()) {
+		t.Error("reserved a dial to the node itself")
+	}
+}
+
+func TestDiscoveryRespectsMaxPeers(t *testing.T) {
+	_, newcomer, _ := discoveryNetwork(t, 2)
+
+	newcomer.BroadcastMessage(&Message{Type: MsgTypeGetPeers, Payload: &GetPeersMessage{}})
+	waitPeers(t, newcomer, 2)
+
+	// The second advertised peer is never dialed
+	time.Sleep(200 * time.Millisecond)
+	if count := newcomer.PeerCount(); count != 2 {
+		t.Errorf("peer count = %d over the limit of 2", count)
+	}
+	if !newcomer.atPeerLimit() {
+		t.Error("not at the peer limit with 2 of 2 peers")
+	}
+}
@@ -0,0 +1,52 @@
+package network
+
+import "sync"
+
+// DefaultSeenCacheSize is how many transaction IDs a node remembers for gossip
+const DefaultSeenCacheSize = 20000
+
+// SeenCache remembers recently seen IDs so gossiped messages aren't relayed twice.
+// Once full, the oldest IDs are forgotten first.
+type SeenCache struct {
+	mu    sync.Mutex
+	ids   map[string]struct{}
+	order []string // Ring buffer of IDs in insertion order
+	next  int      // Position of the oldest ID once the ring is full
+}
+
+// NewSeenCache creates a cache remembering up to size IDs
+func NewSeenCache(size int) *SeenCache {
+	return &SeenCache{
+		ids:   make(map[string]struct{}, size),
+		order: make([]string, 0, size),
+	}
+}
+
+// Has reports whether id has been seen
+func (c *SeenCache) Has(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, seen := c.ids[id]
+	return seen
+}
+
+// MarkSeen records id and reports whether it was new
+func (c *SeenCache) MarkSeen(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, seen := c.ids[id]; seen {
+		return false
+	}
+
+	if len(c.order) < cap(c.order) {
+		c.order = append(c.order, id)
+	} else {
+		delete(c.ids, c.order[c.next])
+		c.order[c.next] = id
+		c.next = (c.next + 1) % len(c.order)
+	}
+	c.ids[id] = struct{}{}
+	return true
+}
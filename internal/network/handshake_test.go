@@ -0,0 +1,180 @@
+package network
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/crypto"
+)
+
+// handshakeServer returns a server whose hello names chainID and genesis
+func handshakeServer(chainID string, genesis []byte) *P2PServer {
+	server := NewP2PServer("127.0.0.1", 0, quietLogger())
+	server.SetHelloFunc(func() *HelloMessage {
+		return &HelloMessage{ChainID: chainID, Version: ProtocolVersion, GenesisHash: genesis}
+	})
+	return server
+}
+
+// startHandshake runs server's side of the handshake on conn, returning its result
+func startHandshake(t *testing.T, server *P2PServer, conn net.Conn) (<-chan error, *Peer) {
+	t.Helper()
+
+	peer := newPeer(addrConn{Conn: conn, remote: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 30303}}, "")
+	server.wg.Add(1)
+	go server.writeLoop(peer)
+	t.Cleanup(func() {
+		conn.Close()
+		close(peer.done)
+		server.wg.Wait()
+	})
+
+	result := make(chan error, 1)
+	go func() { result <- server.handshake(peer, bufio.NewReader(conn)) }()
+	return result, peer
+}
+
+// handshakePair runs the handshake between a and b, returning each side's result
+func handshakePair(t *testing.T, a, b *P2PServer) (errA, errB error, peerA, peerB *Peer) {
+	t.Helper()
+
+	connA, connB := net.Pipe()
+	resultA, peerA := startHandshake(t, a, connA)
+	resultB, peerB := startHandshake(t, b, connB)
+	errA, errB = <-resultA, <-resultB
+	return errA, errB, peerA, peerB
+}
+
+func TestHandshake(t *testing.T) {
+	genesis := []byte("genesis")
+	a, b := handshakeServer("podoru", genesis), handshakeServer("podoru", genesis)
+
+	errA, errB, peerA, peerB := handshakePair(t, a, b)
+	if errA != nil || errB != nil {
+		t.Fatalf("handshake failed: %v, %v", errA, errB)
+	}
+	if peerA.NodeID != b.NodeID() || peerB.NodeID != a.NodeID() {
+		t.Errorf("peer node IDs = %s, %s; want %s, %s", peerA.NodeID, peerB.NodeID, b.NodeID(), a.NodeID())
+	}
+}
+
+func TestHandshakeRejectsIncompatiblePeer(t *testing.T) {
+	genesis := []byte("genesis")
+	tests := []struct {
+		name    string
+		chainID string
+		genesis []byte
+	}{
+		{name: "chain ID", chainID: "other", genesis: genesis},
+		{name: "genesis hash", chainID: "podoru", genesis: []byte("other genesis")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := handshakeServer("podoru", genesis), handshakeServer(tt.chainID, tt.genesis)
+			errA, errB, _, _ := handshakePair(t, a, b)
+			if !errors.Is(errA, ErrIncompatiblePeer) || !errors.Is(errB, ErrIncompatiblePeer) {
+				t.Errorf("handshake errors = %v, %v; want ErrIncompatiblePeer on both sides", errA, errB)
+			}
+		})
+	}
+}
+
+// writeFrame writes msg to conn as a length-prefixed JSON message
+func writeFrame(t *testing.T, conn net.Conn, msg *Message) {
+	t.Helper()
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := binary.Write(conn, binary.BigEndian, uint32(len(data))); err != nil {
+		t.Errorf("writing frame: %v", err)
+		return
+	}
+	if _, err := conn.Write(data); err != nil {
+		t.Errorf("writing frame: %v", err)
+	}
+}
+
+// readHello reads the hello server sends first
+func readHello(t *testing.T, server *P2PServer, reader *bufio.Reader) *HelloMessage {
+	t.Helper()
+
+	var hello HelloMessage
+	peer := &Peer{recvCodec: JSONCodec{}}
+	if err := server.readHandshakeMessage(peer, reader, MsgTypeHello, &hello); err != nil {
+		t.Fatalf("reading hello: %v", err)
+	}
+	return &hello
+}
+
+// scriptedHello is a remote's hello for the chain of local, claiming nodeID for key
+func scriptedHello(local *HelloMessage, key *ecdsa.PrivateKey, nodeID string) *HelloMessage {
+	return &HelloMessage{
+		ChainID:     local.ChainID,
+		Version:     local.Version,
+		GenesisHash: local.GenesisHash,
+		NodeID:      nodeID,
+		PublicKey:   crypto.PublicKeyToBytes(&key.PublicKey),
+		Nonce:       newHelloNonce(),
+	}
+}
+
+func TestHandshakeRejectsForeignNodeID(t *testing.T) {
+	server := handshakeServer("podoru", []byte("genesis"))
+	local, remote := net.Pipe()
+	result, _ := startHandshake(t, server, local)
+
+	key, _ := crypto.GenerateKeyPair()
+	other, _ := crypto.GenerateKeyPair()
+	hello := readHello(t, server, bufio.NewReader(remote))
+	writeFrame(t, remote, &Message{Type: MsgTypeHello, Payload: scriptedHello(hello, key, NodeIDFromPublicKey(&other.PublicKey))})
+
+	err := <-result
+	if err == nil || !strings.Contains(err.Error(), "does not match node key") {
+		t.Errorf("handshake error = %v, want a node ID mismatch", err)
+	}
+}
+
+func TestHandshakeRejectsBadProof(t *testing.T) {
+	server := handshakeServer("podoru", []byte("genesis"))
+	local, remote := net.Pipe()
+	result, _ := startHandshake(t, server, local)
+
+	key, _ := crypto.GenerateKeyPair()
+	reader := bufio.NewReader(remote)
+	hello := readHello(t, server, reader)
+	sent := scriptedHello(hello, key, NodeIDFromPublicKey(&key.PublicKey))
+	writeFrame(t, remote, &Message{Type: MsgTypeHello, Payload: sent})
+
+	// The server proves its own identity, then gets a signature over the wrong nonce
+	var proof HelloProofMessage
+	if err := server.readHandshakeMessage(&Peer{recvCodec: JSONCodec{}}, reader, MsgTypeHelloProof, &proof); err != nil {
+		t.Fatalf("reading hello proof: %v", err)
+	}
+	serverKey, err := crypto.PublicKeyFromBytes(hello.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !crypto.Verify(helloProofHash(sent.Nonce), proof.Signature, serverKey) {
+		t.Error("server's hello proof does not verify")
+	}
+
+	signature, err := crypto.Sign(helloProofHash(newHelloNonce()), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFrame(t, remote, &Message{Type: MsgTypeHelloProof, Payload: &HelloProofMessage{Signature: signature}})
+
+	err = <-result
+	if err == nil || !strings.Contains(err.Error(), "failed to prove its identity") {
+		t.Errorf("handshake error = %v, want a failed identity proof", err)
+	}
+}
@@ -0,0 +1,76 @@
+package network
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/podoru/podoru-chain/internal/crypto"
+)
+
+// helloProofDomain prefixes the nonce signed in the handshake so the node key
+// never signs anything that could be mistaken for a block or transaction hash
+const helloProofDomain = "podoru-p2p-hello:"
+
+// NodeIDFromPublicKey derives a node ID from its public key: the first 16 bytes
+// of the SHA-256 of the uncompressed key, hex encoded
+func NodeIDFromPublicKey(publicKey *ecdsa.PublicKey) string {
+	hash := sha256.Sum256(crypto.PublicKeyToBytes(publicKey))
+	return hex.EncodeToString(hash[:16])
+}
+
+// LoadOrCreateNodeKey loads the node identity key from path, generating and
+// saving a new one if the file does not exist yet
+func LoadOrCreateNodeKey(path string) (*ecdsa.PrivateKey, error) {
+	key, err := crypto.LoadPrivateKeyFromFile(path)
+	if err == nil {
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load node key: %w", err)
+	}
+
+	key, err = crypto.GenerateKeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate node key: %w", err)
+	}
+	if err := crypto.SavePrivateKeyToFile(key, path); err != nil {
+		return nil, fmt.Errorf("failed to save node key: %w", err)
+	}
+
+	return key, nil
+}
+
+// SetNodeKey sets the key identifying this node to peers; the node ID is derived from it
+func (p2p *P2PServer) SetNodeKey(key *ecdsa.PrivateKey) {
+	p2p.mu.Lock()
+	defer p2p.mu.Unlock()
+
+	p2p.nodeKey = key
+	p2p.nodeID = NodeIDFromPublicKey(&key.PublicKey)
+}
+
+// NodeID returns the ID this node identifies itself with in the handshake
+func (p2p *P2PServer) NodeID() string {
+	p2p.mu.RLock()
+	defer p2p.mu.RUnlock()
+
+	return p2p.nodeID
+}
+
+// newHelloNonce returns a random nonce for the peer to sign in the handshake
+func newHelloNonce() []byte {
+	nonce := make([]byte, 32)
+	rand.Read(nonce)
+	return nonce
+}
+
+// helloProofHash is the hash a node signs to prove it holds its node key
+func helloProofHash(nonce []byte) []byte {
+	hash := sha256.Sum256(append([]byte(helloProofDomain), nonce...))
+	return hash[:]
+}
@@ -0,0 +1,222 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/sirupsen/logrus"
+)
+
+// LightSyncer keeps a light node's header chain in step with its peers and fetches
+// state proofs from them
+type LightSyncer struct {
+	headers    *blockchain.HeaderChain
+	p2pServer  *P2PServer
+	logger     *logrus.Logger
+	syncPeriod time.Duration
+
+	// Requests are made one at a time; a response is matched by peer and message type
+	mu sync.Mutex
+}
+
+// NewLightSyncer creates a light syncer for a header chain
+func NewLightSyncer(headers *blockchain.HeaderChain, p2pServer *P2PServer, logger *logrus.Logger) *LightSyncer {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	return &LightSyncer{
+		headers:    headers,
+		p2pServer:  p2pServer,
+		logger:     logger,
+		syncPeriod: 30 * time.Second,
+	}
+}
+
+// SyncHeaders fetches and validates the headers above the local tip from the peer
+// with the highest chain
+func (ls *LightSyncer) SyncHeaders() error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return ls.syncHeaders()
+}
+
+// syncHeaders syncs headers from the best peer (caller must hold ls.mu)
+func (ls *LightSyncer) syncHeaders() error {
+	peers := ls.p2pServer.GetPeers()
+	if len(peers) == 0 {
+		return errors.New("no peers to sync with")
+	}
+
+	var bestPeer *Peer
+	var maxHeight uint64
+	for _, peer := range peers {
+		height, err := ls.getPeerHeight(peer)
+		if err != nil {
+			ls.logger.Warnf("Failed to get height from peer %s: %v", peer.ID, err)
+			continue
+		}
+		if height > maxHeight {
+			maxHeight = height
+			bestPeer = peer
+		}
+	}
+
+	if bestPeer == nil || maxHeight <= ls.headers.Height() {
+		return nil
+	}
+
+	return ls.syncFromPeer(bestPeer, maxHeight)
+}
+
+// syncFromPeer fetches headers from peer in batches until the local tip reaches
+// targetHeight (caller must hold ls.mu)
+func (ls *LightSyncer) syncFromPeer(peer *Peer, targetHeight uint64) error {
+	for ls.headers.Height() < targetHeight {
+		from := ls.headers.Height() + 1
+		to := min(from+MaxHeadersPerRequest-1, targetHeight)
+
+		headers, err := ls.requestHeaders(peer, from, to)
+		if err != nil {
+			return err
+		}
+		if len(headers) == 0 {
+			return fmt.Errorf("peer %s returned no headers from height %d", peer.ID, from)
+		}
+
+		if err := ls.headers.AddHeaders(headers); err != nil {
+			return fmt.Errorf("failed to add headers from peer %s: %w", peer.ID, err)
+		}
+		ls.logger.Infof("Synced headers %d to %d from peer %s", from, ls.headers.Height(), peer.ID)
+	}
+
+	return nil
+}
+
+// ProveState requests a Merkle proof of key from a peer and verifies it against the
+// state root of the local header at the proof's height, syncing headers first if
+// the peer is ahead. It returns the proven value and that height.
+func (ls *LightSyncer) ProveState(key string) ([]byte, uint64, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	peers := ls.p2pServer.GetPeers()
+	if len(peers) == 0 {
+		return nil, 0, errors.New("no peers to request a state proof from")
+	}
+
+	var lastErr error
+	for _, peer := range peers {
+		resp, err := ls.requestStateProof(peer, key)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.Error != "" {
+			if resp.Error == blockchain.ErrKeyNotFound.Error() {
+				return nil, resp.Height, fmt.Errorf("%w: %s", blockchain.ErrKeyNotFound, key)
+			}
+			lastErr = fmt.Errorf("peer %s: %s", peer.ID, resp.Error)
+			continue
+		}
+		if resp.Proof == nil || resp.Proof.Key != key {
+			lastErr = fmt.Errorf("peer %s returned a proof for the wrong key", peer.ID)
+			continue
+		}
+
+		if resp.Height > ls.headers.Height() {
+			if err := ls.syncFromPeer(peer, resp.Height); err != nil {
+				lastErr = err
+				continue
+			}
+		}
+
+		if err := ls.headers.VerifyStateProof(resp.Height, resp.Proof); err != nil {
+			lastErr = fmt.Errorf("peer %s: %w", peer.ID, err)
+			continue
+		}
+		return resp.Proof.Value, resp.Height, nil
+	}
+
+	return nil, 0, lastErr
+}
+
+// StartAutoSync periodically syncs headers in the background
+func (ls *LightSyncer) StartAutoSync() {
+	go func() {
+		ticker := time.NewTicker(ls.syncPeriod)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := ls.SyncHeaders(); err != nil {
+				ls.logger.Debugf("Header sync failed: %v", err)
+			}
+		}
+	}()
+}
+
+// getPeerHeight requests the current height from a peer
+func (ls *LightSyncer) getPeerHeight(peer *Peer) (uint64, error) {
+	response, err := ls.p2pServer.SendAndWaitForResponse(peer, &Message{
+		Type:    MsgTypeGetHeight,
+		Payload: &GetHeightMessage{},
+	}, MsgTypeHeight, 10*time.Second)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get peer height: %w", err)
+	}
+
+	var heightMsg HeightMessage
+	if err := decodePayload(response, &heightMsg); err != nil {
+		return 0, err
+	}
+	return heightMsg.Height, nil
+}
+
+// requestHeaders requests the signed headers in a height range from a peer
+func (ls *LightSyncer) requestHeaders(peer *Peer, fromHeight, toHeight uint64) ([]*blockchain.SignedHeader, error) {
+	response, err := ls.p2pServer.SendAndWaitForResponse(peer, &Message{
+		Type:    MsgTypeGetHeaders,
+		Payload: &GetHeadersMessage{FromHeight: fromHeight, ToHeight: toHeight},
+	}, MsgTypeHeaders, 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request headers: %w", err)
+	}
+
+	var headersMsg HeadersMessage
+	if err := decodePayload(response, &headersMsg); err != nil {
+		return nil, err
+	}
+	return headersMsg.Headers, nil
+}
+
+// requestStateProof requests a state proof of key from a peer
+func (ls *LightSyncer) requestStateProof(peer *Peer, key string) (*StateProofMessage, error) {
+	response, err := ls.p2pServer.SendAndWaitForResponse(peer, &Message{
+		Type:    MsgTypeGetStateProof,
+		Payload: &GetStateProofMessage{Key: key},
+	}, MsgTypeStateProof, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request state proof: %w", err)
+	}
+
+	var proofMsg StateProofMessage
+	if err := decodePayload(response, &proofMsg); err != nil {
+		return nil, err
+	}
+	return &proofMsg, nil
+}
+
+// decodePayload converts a message payload into v. JSON-decoded payloads arrive as
+// generic maps, so the payload is re-encoded into the concrete type.
+func decodePayload(msg *Message, v interface{}) error {
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payloadBytes, v)
+}
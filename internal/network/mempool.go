@@ -2,24 +2,67 @@ package network
 
 import (
 	"errors"
+	"fmt"
+	"math/big"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/crypto"
 )
 
 const (
-	// MaxMempoolSize is the maximum number of transactions in the mempool
-	MaxMempoolSize = 10000
+	// DefaultDedupWindow is the number of recent blocks whose transactions are rejected as replays
+	DefaultDedupWindow = 100
 
-	// MaxMempoolTxSize is the maximum size of a single transaction
-	MaxMempoolTxSize = 1024 * 1024 // 1 MB
+	// DefaultRebroadcastInterval is how long a transaction stays pending before it is announced again
+	DefaultRebroadcastInterval = 30 * time.Second
+
+	// DefaultMaxRebroadcasts is how many times a pending transaction is announced again
+	DefaultMaxRebroadcasts = 5
+
+	// DefaultExpirySweepInterval is how often expired transactions are dropped from the mempool
+	DefaultExpirySweepInterval = 10 * time.Second
+
+	// MinFeeSamples is the number of pending transactions below which fee suggestions
+	// fall back to the base fee
+	MinFeeSamples = 5
 )
 
+// ErrAlreadyMined is returned when a transaction was included in a recent block
+var ErrAlreadyMined = errors.New("transaction already included in a recent block")
+
+// BalanceFunc returns the confirmed balance of an address
+type BalanceFunc func(address string) (*big.Int, error)
+
+// CostFunc returns the total amount a transaction debits from its sender
+type CostFunc func(tx *blockchain.Transaction) *big.Int
+
 // Mempool manages pending transactions
 type Mempool struct {
 	mu           sync.RWMutex
-	transactions map[string]*blockchain.Transaction // txID -> transaction
-	byNonce      map[string]map[uint64]*blockchain.Transaction // address -> nonce -> tx
+	transactions map[string]*blockchain.Transaction            // txID -> transaction
+	byNonce      map[string]map[uint64]*blockchain.Transaction // normalized address -> nonce -> tx
+	balanceFn    BalanceFunc                                   // Optional admission balance check
+	costFn       CostFunc
+	maxSize      int // Maximum number of pending transactions
+	maxTxSize    int // Maximum size of a pending transaction in bytes
+	maxOps       int // Maximum operations in a pending transaction (0 = no limit)
+
+	// Transactions mined in the last dedupWindow blocks, rejected on re-submission
+	dedupWindow int
+	minedBlocks [][]string // Transaction IDs per recent block, oldest first
+	mined       map[string]struct{}
+
+	// When each pending transaction was last announced, and how often it was rebroadcast
+	announced map[string]*announcement
+}
+
+// announcement tracks the broadcasts of a pending transaction
+type announcement struct {
+	at           time.Time
+	rebroadcasts int
 }
 
 // NewMempool creates a new mempool
@@ -27,7 +70,151 @@ func NewMempool() *Mempool {
 	return &Mempool{
 		transactions: make(map[string]*blockchain.Transaction),
 		byNonce:      make(map[string]map[uint64]*blockchain.Transaction),
+		maxSize:      blockchain.DefaultMaxMempoolSize,
+		maxTxSize:    blockchain.DefaultMaxMempoolTxSize,
+		dedupWindow:  DefaultDedupWindow,
+		mined:        make(map[string]struct{}),
+		announced:    make(map[string]*announcement),
+	}
+}
+
+// SetLimits sets the maximum number of pending transactions, the maximum size of a
+// pending transaction and its maximum number of operations, from the chain's
+// consensus params
+func (mp *Mempool) SetLimits(params *blockchain.ConsensusParams) {
+	params = params.WithDefaults()
+
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	mp.maxSize = params.MaxMempoolSize
+	mp.maxTxSize = params.MaxMempoolTxSize
+	mp.maxOps = params.MaxOperationsPerTransaction
+}
+
+// Limits returns the maximum number of pending transactions and the maximum size of
+// a pending transaction
+func (mp *Mempool) Limits() (maxSize, maxTxSize int) {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	return mp.maxSize, mp.maxTxSize
+}
+
+// SetDedupWindow sets how many recent blocks' transactions are remembered (0 disables)
+func (mp *Mempool) SetDedupWindow(blocks int) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.dedupWindow = blocks
+	mp.trimMined()
+}
+
+// RecordMinedBlock remembers the transactions of a newly added block so replays of
+// them are rejected, and removes them from the pending set
+func (mp *Mempool) RecordMinedBlock(block *blockchain.Block) {
+	mp.RemoveTransactions(block.Transactions)
+
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	if mp.dedupWindow <= 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		id := string(tx.ID)
+		ids = append(ids, id)
+		mp.mined[id] = struct{}{}
 	}
+	mp.minedBlocks = append(mp.minedBlocks, ids)
+	mp.trimMined()
+}
+
+// ForgetMinedBlock undoes RecordMinedBlock for a block that is no longer canonical,
+// so its transactions can be accepted into the mempool again
+func (mp *Mempool) ForgetMinedBlock(block *blockchain.Block) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	forgotten := make(map[string]bool, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		forgotten[string(tx.ID)] = true
+		delete(mp.mined, string(tx.ID))
+	}
+
+	// Drop them from the window too, so trimming an old block can't forget a later re-mining
+	for i, ids := range mp.minedBlocks {
+		kept := ids[:0]
+		for _, id := range ids {
+			if !forgotten[id] {
+				kept = append(kept, id)
+			}
+		}
+		mp.minedBlocks[i] = kept
+	}
+}
+
+// trimMined forgets the blocks that fell out of the dedup window (caller must hold mp.mu)
+func (mp *Mempool) trimMined() {
+	for len(mp.minedBlocks) > 0 && len(mp.minedBlocks) > mp.dedupWindow {
+		for _, id := range mp.minedBlocks[0] {
+			delete(mp.mined, id)
+		}
+		mp.minedBlocks = mp.minedBlocks[1:]
+	}
+}
+
+// IsRecentlyMined reports whether a transaction was included in one of the recent blocks
+func (mp *Mempool) IsRecentlyMined(txID []byte) bool {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	_, mined := mp.mined[string(txID)]
+	return mined
+}
+
+// SetBalanceCheck enables admission-time balance checks. A transaction is rejected
+// if the sender's confirmed balance cannot cover its cost together with the cost
+// of the sender's transactions already pending in the mempool.
+func (mp *Mempool) SetBalanceCheck(balanceFn BalanceFunc, costFn CostFunc) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.balanceFn = balanceFn
+	mp.costFn = costFn
+}
+
+// checkBalance verifies the sender can afford tx on top of its pending spend (caller must hold mp.mu)
+func (mp *Mempool) checkBalance(tx *blockchain.Transaction) error {
+	if mp.balanceFn == nil || mp.costFn == nil || tx.IsGenesisTransaction() {
+		return nil
+	}
+
+	cost := mp.costFn(tx)
+	if cost.Sign() == 0 {
+		return nil
+	}
+
+	balance, err := mp.balanceFn(tx.From)
+	if err != nil {
+		return fmt.Errorf("failed to get sender balance: %w", err)
+	}
+
+	pending := big.NewInt(0)
+	for nonce, pendingTx := range mp.byNonce[crypto.NormalizeAddress(tx.From)] {
+		if nonce == tx.Nonce {
+			continue // Replaced by tx
+		}
+		pending.Add(pending, mp.costFn(pendingTx))
+	}
+
+	required := new(big.Int).Add(pending, cost)
+	if balance.Cmp(required) < 0 {
+		return fmt.Errorf("insufficient balance for pending transactions: have %s, need %s (pending: %s, this tx: %s)",
+			balance.String(), required.String(), pending.String(), cost.String())
+	}
+
+	return nil
 }
 
 // AddTransaction adds a transaction to the mempool
@@ -40,14 +227,17 @@ func (mp *Mempool) AddTransaction(tx *blockchain.Transaction) error {
 	defer mp.mu.Unlock()
 
 	// Check mempool size
-	if len(mp.transactions) >= MaxMempoolSize {
+	if len(mp.transactions) >= mp.maxSize {
 		return errors.New("mempool is full")
 	}
 
 	// Check transaction size
-	if tx.Size() > MaxMempoolTxSize {
+	if tx.Size() > mp.maxTxSize {
 		return errors.New("transaction too large")
 	}
+	if mp.maxOps > 0 && tx.Data != nil && len(tx.Data.Operations) > mp.maxOps {
+		return fmt.Errorf("too many operations: %d (max %d)", len(tx.Data.Operations), mp.maxOps)
+	}
 
 	// Check if transaction already exists
 	txID := string(tx.ID)
@@ -55,15 +245,39 @@ func (mp *Mempool) AddTransaction(tx *blockchain.Transaction) error {
 		return errors.New("transaction already in mempool")
 	}
 
-	// Add transaction
+	// Reject replays of recently mined transactions
+	if _, mined := mp.mined[txID]; mined {
+		return ErrAlreadyMined
+	}
+
+	// Reject transactions that could no longer be included in a block
+	if tx.IsExpiredAt(time.Now().Unix()) {
+		return blockchain.ErrTransactionExpired
+	}
+
+	// Check the sender can afford this and its other pending transactions
+	if err := mp.checkBalance(tx); err != nil {
+		return err
+	}
+
+	// A transaction with the same sender and nonce replaces the pending one
+	sender := crypto.NormalizeAddress(tx.From)
+	if existing, exists := mp.byNonce[sender][tx.Nonce]; exists {
+		delete(mp.transactions, string(existing.ID))
+		delete(mp.announced, string(existing.ID))
+	}
+
+	// Add transaction; it is announced by whoever added it
 	mp.transactions[txID] = tx
+	mp.announced[txID] = &announcement{at: time.Now()}
 
 	// Index by nonce
-	if mp.byNonce[tx.From] == nil {
-		mp.byNonce[tx.From] = make(map[uint64]*blockchain.Transaction)
+	if mp.byNonce[sender] == nil {
+		mp.byNonce[sender] = make(map[uint64]*blockchain.Transaction)
 	}
-	mp.byNonce[tx.From][tx.Nonce] = tx
+	mp.byNonce[sender][tx.Nonce] = tx
 
+	mp.checkInvariants()
 	return nil
 }
 
@@ -72,20 +286,45 @@ func (mp *Mempool) RemoveTransaction(txID []byte) {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
-	txIDStr := string(txID)
+	mp.removeTransaction(string(txID))
+}
+
+// RemoveExpired drops the pending transactions whose valid_until has passed at
+// now (unix seconds) and returns them
+func (mp *Mempool) RemoveExpired(now int64) []*blockchain.Transaction {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	var expired []*blockchain.Transaction
+	for id, tx := range mp.transactions {
+		if tx.IsExpiredAt(now) {
+			mp.removeTransaction(id)
+			expired = append(expired, tx)
+		}
+	}
+	return expired
+}
+
+// removeTransaction removes a transaction and its index entries. The caller holds mp.mu.
+func (mp *Mempool) removeTransaction(txIDStr string) {
 	tx, exists := mp.transactions[txIDStr]
 	if !exists {
 		return
 	}
 
 	delete(mp.transactions, txIDStr)
-
-	if mp.byNonce[tx.From] != nil {
-		delete(mp.byNonce[tx.From], tx.Nonce)
-		if len(mp.byNonce[tx.From]) == 0 {
-			delete(mp.byNonce, tx.From)
+	delete(mp.announced, txIDStr)
+
+	// Only drop the nonce entry if it still points at this transaction
+	sender := crypto.NormalizeAddress(tx.From)
+	if indexed, exists := mp.byNonce[sender][tx.Nonce]; exists && string(indexed.ID) == txIDStr {
+		delete(mp.byNonce[sender], tx.Nonce)
+		if len(mp.byNonce[sender]) == 0 {
+			delete(mp.byNonce, sender)
 		}
 	}
+
+	mp.checkInvariants()
 }
 
 // RemoveTransactions removes multiple transactions
@@ -147,6 +386,59 @@ func (mp *Mempool) Count() int {
 	return len(mp.transactions)
 }
 
+// FeeSuggestion holds suggested transaction fees, in wei, taken from the fees the
+// pending transactions pay
+type FeeSuggestion struct {
+	Low     *big.Int // 25th percentile
+	Medium  *big.Int // 50th percentile
+	High    *big.Int // 90th percentile
+	Samples int      // Pending transactions the percentiles were taken over
+}
+
+// FeePercentiles returns the 25th, 50th and 90th percentiles of the gas fees paid by
+// the pending transactions. With fewer than MinFeeSamples pending, or no gas config,
+// every suggestion is the base fee.
+func (mp *Mempool) FeePercentiles(gasConfig *blockchain.GasConfig) *FeeSuggestion {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	if gasConfig == nil {
+		return &FeeSuggestion{Low: big.NewInt(0), Medium: big.NewInt(0), High: big.NewInt(0), Samples: len(mp.transactions)}
+	}
+	if len(mp.transactions) < MinFeeSamples {
+		return &FeeSuggestion{
+			Low:     new(big.Int).Set(gasConfig.BaseFee),
+			Medium:  new(big.Int).Set(gasConfig.BaseFee),
+			High:    new(big.Int).Set(gasConfig.BaseFee),
+			Samples: len(mp.transactions),
+		}
+	}
+
+	fees := make([]*big.Int, 0, len(mp.transactions))
+	for _, tx := range mp.transactions {
+		fees = append(fees, gasConfig.CalculateGasFee(tx.Size()))
+	}
+	sort.Slice(fees, func(i, j int) bool {
+		return fees[i].Cmp(fees[j]) < 0
+	})
+
+	return &FeeSuggestion{
+		Low:     feePercentile(fees, 25),
+		Medium:  feePercentile(fees, 50),
+		High:    feePercentile(fees, 90),
+		Samples: len(fees),
+	}
+}
+
+// feePercentile returns the nearest-rank percentile p of sorted, non-empty fees
+func feePercentile(fees []*big.Int, p int) *big.Int {
+	rank := (p*len(fees) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return new(big.Int).Set(fees[rank-1])
+}
+
 // Clear removes all transactions from the mempool
 func (mp *Mempool) Clear() {
 	mp.mu.Lock()
@@ -154,6 +446,35 @@ func (mp *Mempool) Clear() {
 
 	mp.transactions = make(map[string]*blockchain.Transaction)
 	mp.byNonce = make(map[string]map[uint64]*blockchain.Transaction)
+	mp.announced = make(map[string]*announcement)
+}
+
+// DueForRebroadcast returns the pending transactions last announced at least interval
+// ago that have been rebroadcast fewer than maxRebroadcasts times, ordered by sender and
+// nonce, and records them as announced now
+func (mp *Mempool) DueForRebroadcast(interval time.Duration, maxRebroadcasts int) []*blockchain.Transaction {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	now := time.Now()
+	var due []*blockchain.Transaction
+	for id, tx := range mp.transactions {
+		a := mp.announced[id]
+		if a.rebroadcasts >= maxRebroadcasts || now.Sub(a.at) < interval {
+			continue
+		}
+		a.at = now
+		a.rebroadcasts++
+		due = append(due, tx)
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].From != due[j].From {
+			return due[i].From < due[j].From
+		}
+		return due[i].Nonce < due[j].Nonce
+	})
+	return due
 }
 
 // HasTransaction checks if a transaction exists in the mempool
@@ -170,7 +491,7 @@ func (mp *Mempool) GetTransactionsByAddress(address string) []*blockchain.Transa
 	mp.mu.RLock()
 	defer mp.mu.RUnlock()
 
-	txMap, exists := mp.byNonce[address]
+	txMap, exists := mp.byNonce[crypto.NormalizeAddress(address)]
 	if !exists {
 		return []*blockchain.Transaction{}
 	}
@@ -182,3 +503,72 @@ func (mp *Mempool) GetTransactionsByAddress(address string) []*blockchain.Transa
 
 	return transactions
 }
+
+// GetPendingNonce returns the next nonce for an address after its pending transactions,
+// starting from the confirmed next nonce and skipping the contiguous run of pending nonces
+func (mp *Mempool) GetPendingNonce(address string, confirmedNonce uint64) uint64 {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	nonce := confirmedNonce
+	txMap := mp.byNonce[crypto.NormalizeAddress(address)]
+	for {
+		if _, exists := txMap[nonce]; !exists {
+			return nonce
+		}
+		nonce++
+	}
+}
+
+// verifyConsistency checks that the transactions map and the byNonce index hold
+// exactly the same transactions (caller must hold mp.mu)
+func (mp *Mempool) verifyConsistency() error {
+	indexed := 0
+	for address, txMap := range mp.byNonce {
+		if len(txMap) == 0 {
+			return fmt.Errorf("empty nonce index for %s", address)
+		}
+
+		for nonce, tx := range txMap {
+			if crypto.NormalizeAddress(tx.From) != address || tx.Nonce != nonce {
+				return fmt.Errorf("transaction %x indexed under %s/%d but is %s/%d",
+					tx.ID, address, nonce, tx.From, tx.Nonce)
+			}
+
+			primary, exists := mp.transactions[string(tx.ID)]
+			if !exists {
+				return fmt.Errorf("transaction %x in nonce index but not in mempool", tx.ID)
+			}
+			if primary != tx {
+				return fmt.Errorf("transaction %x differs between mempool and nonce index", tx.ID)
+			}
+			indexed++
+		}
+	}
+
+	if indexed != len(mp.transactions) {
+		return fmt.Errorf("mempool has %d transactions but nonce index has %d", len(mp.transactions), indexed)
+	}
+
+	for id := range mp.transactions {
+		if _, exists := mp.announced[id]; !exists {
+			return fmt.Errorf("transaction %x has no announcement record", id)
+		}
+	}
+	if len(mp.announced) != len(mp.transactions) {
+		return fmt.Errorf("mempool has %d transactions but %d announcement records", len(mp.transactions), len(mp.announced))
+	}
+
+	return nil
+}
+
+// checkInvariants panics on an inconsistent mempool in builds with the mempooldebug tag (caller must hold mp.mu)
+func (mp *Mempool) checkInvariants() {
+	if !mempoolDebug {
+		return
+	}
+
+	if err := mp.verifyConsistency(); err != nil {
+		panic(fmt.Sprintf("mempool inconsistency: %v", err))
+	}
+}
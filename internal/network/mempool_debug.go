@@ -0,0 +1,6 @@
+//go:build mempooldebug
+
+package network
+
+// mempoolDebug enables mempool consistency checks after every mutation
+const mempoolDebug = true
@@ -0,0 +1,120 @@
+//go:build mempooldebug
+
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+// senderTransaction returns an unsigned transaction from sender with one SET operation
+func senderTransaction(id byte, sender string, nonce uint64) *blockchain.Transaction {
+	tx := testTransaction(id, nonce, 1)
+	tx.From = sender
+	return tx
+}
+
+// mustBeConsistent fails the test if the mempool's indexes disagree
+func mustBeConsistent(t *testing.T, mp *Mempool, step string) {
+	t.Helper()
+
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	if err := mp.verifyConsistency(); err != nil {
+		t.Fatalf("after %s: %v", step, err)
+	}
+}
+
+func TestMempoolConsistency(t *testing.T) {
+	mp := NewMempool()
+	for i, tx := range []*blockchain.Transaction{
+		senderTransaction(1, "0xa", 0),
+		senderTransaction(2, "0xa", 1),
+		senderTransaction(3, "0xa", 2),
+		senderTransaction(4, "0xb", 0),
+		senderTransaction(5, "0xb", 1),
+	} {
+		if err := mp.AddTransaction(tx); err != nil {
+			t.Fatalf("AddTransaction %d: %v", i, err)
+		}
+	}
+	mustBeConsistent(t, mp, "add")
+
+	// Same sender and nonce replaces the pending transaction
+	if err := mp.AddTransaction(senderTransaction(6, "0xa", 1)); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	mustBeConsistent(t, mp, "replace")
+	if mp.HasTransaction([]byte{2}) || mp.Count() != 5 {
+		t.Fatalf("replaced transaction still pending (count %d)", mp.Count())
+	}
+
+	// Removing the replaced transaction must not drop its replacement from the index
+	mp.RemoveTransaction([]byte{2})
+	mustBeConsistent(t, mp, "remove of a replaced transaction")
+	mp.RemoveTransaction([]byte{4})
+	mustBeConsistent(t, mp, "remove")
+	if got := mp.GetTransactionsByAddress("0xb"); len(got) != 1 {
+		t.Fatalf("0xb has %d pending transactions, want 1", len(got))
+	}
+
+	expiring := senderTransaction(7, "0xc", 0)
+	expiring.ValidUntil = time.Now().Add(time.Minute).Unix()
+	if err := mp.AddTransaction(expiring); err != nil {
+		t.Fatalf("AddTransaction: %v", err)
+	}
+	if expired := mp.RemoveExpired(expiring.ValidUntil + 1); len(expired) != 1 {
+		t.Fatalf("RemoveExpired dropped %d transactions, want 1", len(expired))
+	}
+	mustBeConsistent(t, mp, "expiry")
+
+	// A reorg: the transactions of a block are mined, the block is reverted and they
+	// come back, then a competing block mines a replacement of one of them
+	mined := blockchain.NewBlock(&blockchain.BlockHeader{Height: 1}, []*blockchain.Transaction{
+		mp.byNonce["0xa"][0], mp.byNonce["0xa"][1],
+	})
+	mp.RecordMinedBlock(mined)
+	mustBeConsistent(t, mp, "mined block")
+
+	mp.ForgetMinedBlock(mined)
+	for _, tx := range mined.Transactions {
+		if err := mp.AddTransaction(tx); err != nil {
+			t.Fatalf("re-adding reverted transaction: %v", err)
+		}
+	}
+	mustBeConsistent(t, mp, "reverted block")
+
+	competing := blockchain.NewBlock(&blockchain.BlockHeader{Height: 1}, []*blockchain.Transaction{
+		senderTransaction(8, "0xa", 0),
+	})
+	if err := mp.AddTransaction(competing.Transactions[0]); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	mp.RecordMinedBlock(competing)
+	mustBeConsistent(t, mp, "competing block")
+	if _, exists := mp.byNonce["0xa"][0]; exists {
+		t.Error("nonce mined by the competing block is still pending")
+	}
+
+	mp.Clear()
+	mustBeConsistent(t, mp, "clear")
+}
+
+func TestMempoolInconsistencyPanics(t *testing.T) {
+	mp := NewMempool()
+	if err := mp.AddTransaction(senderTransaction(1, "0xa", 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop the transaction from the primary map only, as a buggy code path would
+	delete(mp.transactions, string([]byte{1}))
+
+	defer func() {
+		if recover() == nil {
+			t.Error("mutation of an inconsistent mempool did not panic")
+		}
+	}()
+	mp.AddTransaction(senderTransaction(2, "0xb", 0))
+}
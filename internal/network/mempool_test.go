@@ -0,0 +1,253 @@
+package network
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+// testTransaction returns an unsigned transaction from "0xa" with ops SET operations
+func testTransaction(id byte, nonce uint64, ops int) *blockchain.Transaction {
+	data := &blockchain.TransactionData{}
+	for i := 0; i < ops; i++ {
+		data.Operations = append(data.Operations, &blockchain.KVOperation{Type: blockchain.OpTypeSet, Key: "k", Value: []byte("v")})
+	}
+	return &blockchain.Transaction{ID: []byte{id}, From: "0xa", Nonce: nonce, Data: data}
+}
+
+func TestMempoolOperationLimit(t *testing.T) {
+	mp := NewMempool()
+	if err := mp.AddTransaction(testTransaction(1, 0, 1500)); err != nil {
+		t.Fatalf("no operation limit by default: %v", err)
+	}
+
+	mp.SetLimits(&blockchain.ConsensusParams{MaxOperationsPerTransaction: 2})
+	if err := mp.AddTransaction(testTransaction(2, 1, 2)); err != nil {
+		t.Fatalf("transaction at the limit rejected: %v", err)
+	}
+	if err := mp.AddTransaction(testTransaction(3, 2, 3)); err == nil {
+		t.Fatal("transaction over the limit accepted")
+	}
+}
+
+func TestMempoolLimitsFromParams(t *testing.T) {
+	mp := NewMempool()
+	mp.SetLimits(&blockchain.ConsensusParams{MaxMempoolSize: 2, MaxMempoolTxSize: 1000})
+	if size, txSize := mp.Limits(); size != 2 || txSize != 1000 {
+		t.Fatalf("Limits() = %d, %d; want 2, 1000", size, txSize)
+	}
+
+	large := testTransaction(1, 0, 100)
+	if large.Size() <= 1000 {
+		t.Fatalf("test transaction is only %d bytes", large.Size())
+	}
+	if err := mp.AddTransaction(large); err == nil {
+		t.Error("transaction over max_mempool_tx_size accepted")
+	}
+
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		if err := mp.AddTransaction(testTransaction(byte(nonce+2), nonce, 1)); err != nil {
+			t.Fatalf("nonce %d rejected: %v", nonce, err)
+		}
+	}
+	if err := mp.AddTransaction(testTransaction(4, 2, 1)); err == nil {
+		t.Error("transaction beyond max_mempool_size accepted")
+	}
+
+	// Unset params fall back to the defaults
+	mp.SetLimits(nil)
+	if size, txSize := mp.Limits(); size != blockchain.DefaultMaxMempoolSize || txSize != blockchain.DefaultMaxMempoolTxSize {
+		t.Errorf("default Limits() = %d, %d", size, txSize)
+	}
+}
+
+func TestMempoolBalanceCheck(t *testing.T) {
+	mp := NewMempool()
+	mp.SetBalanceCheck(
+		func(address string) (*big.Int, error) { return big.NewInt(10), nil },
+		func(tx *blockchain.Transaction) *big.Int { return big.NewInt(4) },
+	)
+
+	// Each transaction is affordable alone; the third overdraws the pending total
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		if err := mp.AddTransaction(testTransaction(byte(nonce+1), nonce, 1)); err != nil {
+			t.Fatalf("nonce %d rejected: %v", nonce, err)
+		}
+	}
+	if err := mp.AddTransaction(testTransaction(3, 2, 1)); err == nil {
+		t.Fatal("transaction overdrawing the pending spend accepted")
+	}
+
+	// A replacement is counted instead of the transaction it replaces
+	if err := mp.AddTransaction(testTransaction(4, 1, 1)); err != nil {
+		t.Fatalf("replacement rejected: %v", err)
+	}
+	if mp.Count() != 2 {
+		t.Errorf("count = %d, want 2", mp.Count())
+	}
+
+	// Other senders have their own balance
+	other := testTransaction(5, 0, 1)
+	other.From = "0xb"
+	if err := mp.AddTransaction(other); err != nil {
+		t.Errorf("other sender rejected: %v", err)
+	}
+}
+
+func TestMempoolSenderCase(t *testing.T) {
+	mp := NewMempool()
+	mp.SetBalanceCheck(
+		func(address string) (*big.Int, error) { return big.NewInt(10), nil },
+		func(tx *blockchain.Transaction) *big.Int { return big.NewInt(4) },
+	)
+
+	// The same sender spelled three ways shares one pending spend
+	for i, from := range []string{"0xabc", "0xABC", "ABC"} {
+		tx := testTransaction(byte(i+1), uint64(i), 1)
+		tx.From = from
+		err := mp.AddTransaction(tx)
+		if i < 2 && err != nil {
+			t.Fatalf("%s nonce %d rejected: %v", from, i, err)
+		}
+		if i == 2 && err == nil {
+			t.Fatalf("%s overdrawing the pending spend of 0xabc accepted", from)
+		}
+	}
+
+	// A transaction with the same nonce in another case replaces the pending one
+	replacement := testTransaction(4, 1, 1)
+	replacement.From = "0xAbC"
+	if err := mp.AddTransaction(replacement); err != nil {
+		t.Fatalf("replacement rejected: %v", err)
+	}
+	if mp.Count() != 2 {
+		t.Errorf("count = %d, want 2", mp.Count())
+	}
+	if got := len(mp.GetTransactionsByAddress("0xABC")); got != 2 {
+		t.Errorf("transactions of 0xABC = %d, want 2", got)
+	}
+	if err := mp.verifyConsistency(); err != nil {
+		t.Error(err)
+	}
+
+	mp.RemoveTransaction(replacement.ID)
+	if got := len(mp.GetTransactionsByAddress("0xabc")); got != 1 {
+		t.Errorf("transactions of 0xabc after removal = %d, want 1", got)
+	}
+	if err := mp.verifyConsistency(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMempoolPendingNonce(t *testing.T) {
+	mp := NewMempool()
+	if got := mp.GetPendingNonce("0xa", 3); got != 3 {
+		t.Errorf("pending nonce with nothing pending = %d, want the confirmed 3", got)
+	}
+
+	// Nonces 3, 4 and 6 pending: the next one to use fills the gap at 5
+	for i, nonce := range []uint64{3, 4, 6} {
+		if err := mp.AddTransaction(testTransaction(byte(i+1), nonce, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := mp.GetPendingNonce("0xa", 3); got != 5 {
+		t.Errorf("pending nonce = %d, want 5", got)
+	}
+	if got := mp.GetPendingNonce("0xA", 3); got != 5 {
+		t.Errorf("pending nonce of 0xA = %d, want 5 as for 0xa", got)
+	}
+	if got := mp.GetPendingNonce("0xb", 0); got != 0 {
+		t.Errorf("pending nonce of another sender = %d, want 0", got)
+	}
+
+	// Pending transactions below the confirmed nonce are already mined
+	if got := mp.GetPendingNonce("0xa", 5); got != 5 {
+		t.Errorf("pending nonce past confirmed 5 = %d, want 5", got)
+	}
+}
+
+func TestMempoolFeePercentiles(t *testing.T) {
+	gasConfig := &blockchain.GasConfig{BaseFee: big.NewInt(1000), PerByteFee: big.NewInt(10)}
+
+	mp := NewMempool()
+	for i := 0; i < MinFeeSamples-1; i++ {
+		if err := mp.AddTransaction(testTransaction(byte(i+1), uint64(i), 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Too few samples: every suggestion is the base fee
+	sparse := mp.FeePercentiles(gasConfig)
+	for name, fee := range map[string]*big.Int{"low": sparse.Low, "medium": sparse.Medium, "high": sparse.High} {
+		if fee.Cmp(gasConfig.BaseFee) != 0 {
+			t.Errorf("sparse %s = %s, want the base fee %s", name, fee, gasConfig.BaseFee)
+		}
+	}
+	if sparse.Samples != MinFeeSamples-1 {
+		t.Errorf("sparse samples = %d, want %d", sparse.Samples, MinFeeSamples-1)
+	}
+
+	// Larger transactions pay more, spreading the fees
+	for i := MinFeeSamples - 1; i < 20; i++ {
+		if err := mp.AddTransaction(testTransaction(byte(i+1), uint64(i), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fees := mp.FeePercentiles(gasConfig)
+	if fees.Samples != 20 {
+		t.Errorf("samples = %d, want 20", fees.Samples)
+	}
+	if fees.Low.Cmp(gasConfig.BaseFee) < 0 {
+		t.Errorf("low = %s, below the base fee", fees.Low)
+	}
+	if fees.Low.Cmp(fees.Medium) > 0 || fees.Medium.Cmp(fees.High) > 0 {
+		t.Errorf("fees not ordered: low %s, medium %s, high %s", fees.Low, fees.Medium, fees.High)
+	}
+	if fees.Low.Cmp(fees.High) == 0 {
+		t.Errorf("low and high are both %s for transactions of different sizes", fees.Low)
+	}
+
+	// Without a gas config there is nothing to pay
+	if none := mp.FeePercentiles(nil); none.Low.Sign() != 0 || none.Medium.Sign() != 0 || none.High.Sign() != 0 {
+		t.Errorf("fees without a gas config = %s, %s, %s; want zero", none.Low, none.Medium, none.High)
+	}
+}
+
+func TestMempoolExpiry(t *testing.T) {
+	mp := NewMempool()
+	now := time.Now().Unix()
+
+	expired := testTransaction(1, 0, 1)
+	expired.ValidUntil = now - 1
+	if err := mp.AddTransaction(expired); !errors.Is(err, blockchain.ErrTransactionExpired) {
+		t.Fatalf("expired transaction: err = %v, want ErrTransactionExpired", err)
+	}
+
+	// Zero means no expiry
+	forever := testTransaction(2, 0, 1)
+	expiring := testTransaction(3, 1, 1)
+	expiring.ValidUntil = now + 60
+	for _, tx := range []*blockchain.Transaction{forever, expiring} {
+		if err := mp.AddTransaction(tx); err != nil {
+			t.Fatalf("AddTransaction: %v", err)
+		}
+	}
+
+	if dropped := mp.RemoveExpired(now + 60); len(dropped) != 0 {
+		t.Errorf("dropped %d transactions at their valid_until, want none", len(dropped))
+	}
+	dropped := mp.RemoveExpired(now + 61)
+	if len(dropped) != 1 || dropped[0] != expiring {
+		t.Fatalf("dropped %v after valid_until, want the expiring transaction", dropped)
+	}
+	if mp.HasTransaction(expiring.ID) || !mp.HasTransaction(forever.ID) {
+		t.Error("the sweep removed the wrong transactions")
+	}
+	if got := mp.GetPendingNonce("0xa", 0); got != 1 {
+		t.Errorf("pending nonce after the sweep = %d, want 1", got)
+	}
+}
@@ -2,27 +2,69 @@ package network
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/ecdsa"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/podoru/podoru-chain/internal/crypto"
 	"github.com/sirupsen/logrus"
 )
 
 // Peer represents a connected peer
 type Peer struct {
-	ID      string
+	ID      string // Node ID once the handshake completes, the remote address before
 	Conn    net.Conn
 	Address string
 	writer  *bufio.Writer
-	mu      sync.Mutex
+
+	sendQueue chan *outboundMessage // Drained by the peer's write loop
+	done      chan struct{}         // Closed when the connection is finished
+
+	sendCodec Codec // Codec for messages sent to the peer (write loop only)
+	recvCodec Codec // Codec for messages read from the peer (read loop only)
+
+	// Set by the handshake
+	NodeID      string
+	ChainID     string
+	Version     uint32
+	GenesisHash []byte
+	Height      uint64 // Height at connect time
+
+	// ListenAddr is the address the peer accepts connections on (empty if unknown)
+	ListenAddr string
+	outbound   bool // We dialed the peer
+}
+
+// handshakeTimeout bounds how long a new peer has to send its hello
+const handshakeTimeout = 10 * time.Second
+
+// peerSendQueueSize is how many outbound messages can wait for a peer's write loop.
+// A peer whose queue fills up can't keep up and is disconnected.
+const peerSendQueueSize = 256
+
+// peerWriteTimeout bounds a single write to a peer
+const peerWriteTimeout = 30 * time.Second
+
+// ErrSendQueueFull is returned when a message is sent to a peer whose send queue is full
+var ErrSendQueueFull = errors.New("peer send queue full")
+
+// outboundMessage is a message waiting in a peer's send queue
+type outboundMessage struct {
+	msg       *Message
+	nextCodec Codec // Codec to switch to once msg is written (codec acks only)
 }
 
+// ErrIncompatiblePeer is returned when a peer's hello doesn't match the local chain
+var ErrIncompatiblePeer = errors.New("incompatible peer")
+
 // P2PServer manages peer-to-peer connections
 type P2PServer struct {
 	mu              sync.RWMutex
@@ -34,12 +76,46 @@ type P2PServer struct {
 	logger          *logrus.Logger
 	stopChan        chan struct{}
 	wg              sync.WaitGroup
+	onPeerChange    func(count int)      // Called with the new peer count after a peer is added or removed
+	codec           Codec                // Codec requested from peers for the messages they send us
+	hello           func() *HelloMessage // Builds the local hello; nil disables the handshake
+	nodeKey         *ecdsa.PrivateKey    // Identity proven to peers in the handshake
+	nodeID          string               // Derived from nodeKey; sent in the hello
+	dialing         map[string]bool      // Addresses with a discovery dial in progress
+	lastDial        map[string]time.Time // When each discovered address was last dialed
+	maxPeers        int                  // Connection limit (0 = unlimited)
+
+	// Reconnection of dropped peers
+	tracked       map[string]*trackedPeer // Dial address -> tracked peer
+	reconnectBase time.Duration
+	reconnectMax  time.Duration
+
+	// Peers that serve invalid data are banned after banThreshold faults
+	faults       map[string]int       // Peer ID -> faults since its last ban
+	ipFaults     map[string]int       // Remote IP -> faults since its last ban
+	banned       map[string]time.Time // Peer ID -> ban expiry
+	bannedIPs    map[string]time.Time // Remote IP -> ban expiry
+	banThreshold int                  // 0 disables banning
+	banDuration  time.Duration
 
 	// Response handling for synchronous request-response pattern
-	responseChans map[MessageType]chan *Message
+	responseChans map[responseKey]*responseWaiter
 	responseMu    sync.Mutex
 }
 
+// responseKey identifies an awaited response. Requests to different peers can be in
+// flight at once; requests to one peer for the same response type cannot.
+type responseKey struct {
+	peer    *Peer
+	msgType MessageType
+}
+
+// responseWaiter receives the responses to a request until done is closed
+type responseWaiter struct {
+	ch   chan *Message
+	done chan struct{}
+}
+
 // MessageHandler is a function that handles incoming messages
 type MessageHandler func(peer *Peer, msg *Message) error
 
@@ -49,6 +125,9 @@ func NewP2PServer(bindAddr string, port int, logger *logrus.Logger) *P2PServer {
 		logger = logrus.New()
 	}
 
+	// An ephemeral identity until SetNodeKey provides a stable one
+	nodeKey, _ := crypto.GenerateKeyPair()
+
 	return &P2PServer{
 		bindAddr:        bindAddr,
 		port:            port,
@@ -56,10 +135,52 @@ func NewP2PServer(bindAddr string, port int, logger *logrus.Logger) *P2PServer {
 		messageHandlers: make(map[MessageType]MessageHandler),
 		logger:          logger,
 		stopChan:        make(chan struct{}),
-		responseChans:   make(map[MessageType]chan *Message),
+		codec:           JSONCodec{},
+		nodeKey:         nodeKey,
+		nodeID:          NodeIDFromPublicKey(&nodeKey.PublicKey),
+		dialing:         make(map[string]bool),
+		lastDial:        make(map[string]time.Time),
+		tracked:         make(map[string]*trackedPeer),
+		reconnectBase:   DefaultReconnectBaseDelay,
+		reconnectMax:    DefaultReconnectMaxDelay,
+		faults:          make(map[string]int),
+		ipFaults:        make(map[string]int),
+		banned:          make(map[string]time.Time),
+		bannedIPs:       make(map[string]time.Time),
+		banThreshold:    DefaultBanThreshold,
+		banDuration:     DefaultBanDuration,
+		responseChans:   make(map[responseKey]*responseWaiter),
 	}
 }
 
+// SetHelloFunc sets the function building the hello sent to new peers.
+// Once set, every connection must complete the handshake before any other traffic.
+func (p2p *P2PServer) SetHelloFunc(hello func() *HelloMessage) {
+	p2p.mu.Lock()
+	defer p2p.mu.Unlock()
+
+	p2p.hello = hello
+}
+
+// SetMaxPeers limits the number of connected peers (0 = unlimited).
+// Connections beyond the limit are closed after the handshake.
+func (p2p *P2PServer) SetMaxPeers(maxPeers int) {
+	p2p.mu.Lock()
+	defer p2p.mu.Unlock()
+
+	p2p.maxPeers = maxPeers
+}
+
+// SetCodec sets the codec requested from new peers. Every connection starts on
+// JSON and switches once the peer acknowledges; peers that don't support codec
+// negotiation stay on JSON.
+func (p2p *P2PServer) SetCodec(codec Codec) {
+	p2p.mu.Lock()
+	defer p2p.mu.Unlock()
+
+	p2p.codec = codec
+}
+
 // RegisterHandler registers a message handler for a specific message type
 func (p2p *P2PServer) RegisterHandler(msgType MessageType, handler MessageHandler) {
 	p2p.mu.Lock()
@@ -83,6 +204,9 @@ func (p2p *P2PServer) Start() error {
 	p2p.wg.Add(1)
 	go p2p.acceptLoop()
 
+	p2p.wg.Add(1)
+	go p2p.reconnectLoop()
+
 	return nil
 }
 
@@ -107,31 +231,87 @@ func (p2p *P2PServer) acceptLoop() {
 			continue
 		}
 
+		if p2p.IsAddressBanned(conn.RemoteAddr().String()) {
+			p2p.logger.Debugf("Refusing connection from banned address %s", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+
 		p2p.wg.Add(1)
-		go p2p.handlePeer(conn)
+		go p2p.handlePeer(conn, "")
 	}
 }
 
-// handlePeer handles communication with a peer
-func (p2p *P2PServer) handlePeer(conn net.Conn) {
-	defer p2p.wg.Done()
-	defer conn.Close()
-
-	peer := &Peer{
+// newPeer creates the peer for a new connection, speaking JSON until a codec is
+// negotiated. dialAddr is the address we dialed, empty for inbound connections.
+func newPeer(conn net.Conn, dialAddr string) *Peer {
+	return &Peer{
 		ID:      conn.RemoteAddr().String(),
 		Conn:    conn,
 		Address: conn.RemoteAddr().String(),
 		writer:  bufio.NewWriter(conn),
+
+		sendQueue: make(chan *outboundMessage, peerSendQueueSize),
+		done:      make(chan struct{}),
+
+		sendCodec: JSONCodec{},
+		recvCodec: JSONCodec{},
+
+		ListenAddr: dialAddr,
+		outbound:   dialAddr != "",
+	}
+}
+
+// handlePeer handles communication with a peer. dialAddr is the address we dialed,
+// empty for inbound connections.
+func (p2p *P2PServer) handlePeer(conn net.Conn, dialAddr string) {
+	defer p2p.wg.Done()
+	defer conn.Close()
+
+	peer := newPeer(conn, dialAddr)
+	reader := bufio.NewReader(conn)
+
+	defer close(peer.done)
+	p2p.wg.Add(1)
+	go p2p.writeLoop(peer)
+
+	// Let the reconnection manager know when a connection we dialed ends
+	if peer.outbound {
+		defer func() {
+			p2p.mu.Lock()
+			p2p.trackedDisconnected(dialAddr)
+			p2p.mu.Unlock()
+		}()
+	}
+
+	if err := p2p.handshake(peer, reader); err != nil {
+		p2p.logger.Warnf("Dropping peer %s: handshake failed: %v", peer.ID, err)
+		return
 	}
 
 	// Add peer
-	p2p.addPeer(peer)
-	defer p2p.removePeer(peer.ID)
+	if err := p2p.addPeer(peer); err != nil {
+		p2p.logger.Debugf("Dropping peer %s: %v", peer.ID, err)
+		return
+	}
+	defer p2p.removePeer(peer)
 
 	p2p.logger.Infof("New peer connected: %s", peer.ID)
 
+	// Ask the peer to switch to our preferred codec
+	p2p.mu.RLock()
+	codec := p2p.codec
+	p2p.mu.RUnlock()
+	if codec.Name() != CodecJSON {
+		if err := p2p.SendMessage(peer, &Message{
+			Type:    MsgTypeCodecRequest,
+			Payload: &CodecMessage{Codec: codec.Name()},
+		}); err != nil {
+			p2p.logger.Warnf("Failed to request %s codec from %s: %v", codec.Name(), peer.ID, err)
+		}
+	}
+
 	// Read messages
-	reader := bufio.NewReader(conn)
 	for {
 		select {
 		case <-p2p.stopChan:
@@ -139,7 +319,7 @@ func (p2p *P2PServer) handlePeer(conn net.Conn) {
 		default:
 		}
 
-		msg, err := p2p.readMessage(reader)
+		msg, err := p2p.readMessage(reader, peer.recvCodec)
 		if err != nil {
 			if err != io.EOF {
 				p2p.logger.Errorf("Error reading message from %s: %v", peer.ID, err)
@@ -147,6 +327,13 @@ func (p2p *P2PServer) handlePeer(conn net.Conn) {
 			return
 		}
 
+		if msg.Type == MsgTypeCodecRequest || msg.Type == MsgTypeCodecAck {
+			if err := p2p.handleCodecMessage(peer, msg); err != nil {
+				p2p.logger.Errorf("Error negotiating codec with %s: %v", peer.ID, err)
+			}
+			continue
+		}
+
 		// Handle message
 		if err := p2p.handleMessage(peer, msg); err != nil {
 			p2p.logger.Errorf("Error handling message from %s: %v", peer.ID, err)
@@ -154,8 +341,112 @@ func (p2p *P2PServer) handlePeer(conn net.Conn) {
 	}
 }
 
-// readMessage reads a message from a reader (length-prefixed JSON)
-func (p2p *P2PServer) readMessage(reader *bufio.Reader) (*Message, error) {
+// handshake exchanges hellos with a new peer, checks it is on the same chain and
+// has it prove it holds the key its node ID is derived from
+func (p2p *P2PServer) handshake(peer *Peer, reader *bufio.Reader) error {
+	p2p.mu.RLock()
+	helloFunc := p2p.hello
+	nodeKey := p2p.nodeKey
+	nodeID := p2p.nodeID
+	p2p.mu.RUnlock()
+
+	if helloFunc == nil {
+		return nil
+	}
+	local := helloFunc()
+	local.NodeID = nodeID
+	local.ListenPort = p2p.port
+	local.PublicKey = crypto.PublicKeyToBytes(&nodeKey.PublicKey)
+	local.Nonce = newHelloNonce()
+
+	peer.Conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	defer peer.Conn.SetReadDeadline(time.Time{})
+
+	if err := p2p.SendMessage(peer, &Message{Type: MsgTypeHello, Payload: local}); err != nil {
+		return fmt.Errorf("failed to send hello: %w", err)
+	}
+
+	var remote HelloMessage
+	if err := p2p.readHandshakeMessage(peer, reader, MsgTypeHello, &remote); err != nil {
+		return fmt.Errorf("invalid hello: %w", err)
+	}
+
+	if remote.ChainID != local.ChainID {
+		return fmt.Errorf("%w: chain ID %q, expected %q", ErrIncompatiblePeer, remote.ChainID, local.ChainID)
+	}
+	if !bytes.Equal(remote.GenesisHash, local.GenesisHash) {
+		return fmt.Errorf("%w: genesis hash %x, expected %x", ErrIncompatiblePeer, remote.GenesisHash, local.GenesisHash)
+	}
+	if remote.Version != local.Version {
+		return fmt.Errorf("%w: protocol version %d, expected %d", ErrIncompatiblePeer, remote.Version, local.Version)
+	}
+
+	remoteKey, err := crypto.PublicKeyFromBytes(remote.PublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid node key: %w", err)
+	}
+	if remote.NodeID != NodeIDFromPublicKey(remoteKey) {
+		return fmt.Errorf("node ID %s does not match node key", remote.NodeID)
+	}
+	if remote.NodeID == local.NodeID {
+		return errors.New("connected to self")
+	}
+
+	// Prove our identity by signing the peer's nonce, and check the peer signed ours
+	signature, err := crypto.Sign(helloProofHash(remote.Nonce), nodeKey)
+	if err != nil {
+		return fmt.Errorf("failed to sign hello proof: %w", err)
+	}
+	if err := p2p.SendMessage(peer, &Message{
+		Type:    MsgTypeHelloProof,
+		Payload: &HelloProofMessage{Signature: signature},
+	}); err != nil {
+		return fmt.Errorf("failed to send hello proof: %w", err)
+	}
+
+	var proof HelloProofMessage
+	if err := p2p.readHandshakeMessage(peer, reader, MsgTypeHelloProof, &proof); err != nil {
+		return fmt.Errorf("invalid hello proof: %w", err)
+	}
+	if !crypto.Verify(helloProofHash(local.Nonce), proof.Signature, remoteKey) {
+		return fmt.Errorf("node %s failed to prove its identity", remote.NodeID)
+	}
+
+	if peer.ListenAddr == "" && remote.ListenPort > 0 {
+		if host, _, err := net.SplitHostPort(peer.Address); err == nil {
+			peer.ListenAddr = net.JoinHostPort(host, strconv.Itoa(remote.ListenPort))
+		}
+	}
+
+	peer.ID = remote.NodeID
+	peer.NodeID = remote.NodeID
+	peer.ChainID = remote.ChainID
+	peer.Version = remote.Version
+	peer.GenesisHash = remote.GenesisHash
+	peer.Height = remote.Height
+
+	return nil
+}
+
+// readHandshakeMessage reads the next message, which must be of type msgType, into payload
+func (p2p *P2PServer) readHandshakeMessage(peer *Peer, reader *bufio.Reader, msgType MessageType, payload interface{}) error {
+	msg, err := p2p.readMessage(reader, peer.recvCodec)
+	if err != nil {
+		return err
+	}
+	if msg.Type != msgType {
+		return fmt.Errorf("expected message type %d, got %d", msgType, msg.Type)
+	}
+
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payloadBytes, payload)
+}
+
+// readMessage reads a length-prefixed message from a reader and decodes it with codec
+func (p2p *P2PServer) readMessage(reader *bufio.Reader, codec Codec) (*Message, error) {
 	// Read message length (4 bytes)
 	var length uint32
 	if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
@@ -163,7 +454,7 @@ func (p2p *P2PServer) readMessage(reader *bufio.Reader) (*Message, error) {
 	}
 
 	// Prevent DOS attacks
-	if length > 10*1024*1024 { // 10 MB max
+	if length > MaxMessageSize {
 		return nil, errors.New("message too large")
 	}
 
@@ -174,25 +465,70 @@ func (p2p *P2PServer) readMessage(reader *bufio.Reader) (*Message, error) {
 	}
 
 	// Unmarshal message
-	var msg Message
-	if err := json.Unmarshal(msgBytes, &msg); err != nil {
+	msg, err := codec.Decode(msgBytes)
+	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal message: %w", err)
 	}
 
-	return &msg, nil
+	return msg, nil
 }
 
-// SendMessage sends a message to a peer
+// SendMessage queues a message for a peer without waiting for it to be written.
+// If the peer's send queue is full the peer is disconnected and ErrSendQueueFull returned.
 func (p2p *P2PServer) SendMessage(peer *Peer, msg *Message) error {
-	peer.mu.Lock()
-	defer peer.mu.Unlock()
+	return p2p.enqueue(peer, &outboundMessage{msg: msg})
+}
+
+// enqueue adds a message to a peer's send queue, disconnecting the peer if it is full
+func (p2p *P2PServer) enqueue(peer *Peer, out *outboundMessage) error {
+	select {
+	case <-peer.done:
+		return errors.New("peer disconnected")
+	default:
+	}
 
+	select {
+	case peer.sendQueue <- out:
+		return nil
+	default:
+		p2p.logger.Warnf("Send queue to %s is full, disconnecting", peer.Address)
+		peer.Conn.Close()
+		return ErrSendQueueFull
+	}
+}
+
+// writeLoop writes a peer's queued messages until the connection is finished.
+// A failed write closes the connection.
+func (p2p *P2PServer) writeLoop(peer *Peer) {
+	defer p2p.wg.Done()
+
+	for {
+		select {
+		case <-peer.done:
+			return
+		case out := <-peer.sendQueue:
+			if err := p2p.writeMessage(peer, out.msg); err != nil {
+				p2p.logger.Warnf("Failed to send message to %s: %v", peer.Address, err)
+				peer.Conn.Close()
+				return
+			}
+			if out.nextCodec != nil {
+				peer.sendCodec = out.nextCodec
+			}
+		}
+	}
+}
+
+// writeMessage encodes and writes a message to a peer (write loop only)
+func (p2p *P2PServer) writeMessage(peer *Peer, msg *Message) error {
 	// Marshal message
-	msgBytes, err := json.Marshal(msg)
+	msgBytes, err := peer.sendCodec.Encode(msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
+	peer.Conn.SetWriteDeadline(time.Now().Add(peerWriteTimeout))
+
 	// Write length prefix
 	length := uint32(len(msgBytes))
 	if err := binary.Write(peer.writer, binary.BigEndian, length); err != nil {
@@ -209,10 +545,18 @@ func (p2p *P2PServer) SendMessage(peer *Peer, msg *Message) error {
 
 // BroadcastMessage broadcasts a message to all peers
 func (p2p *P2PServer) BroadcastMessage(msg *Message) {
+	p2p.BroadcastMessageExcept(msg, nil)
+}
+
+// BroadcastMessageExcept broadcasts a message to all peers other than exclude,
+// typically the peer the message was received from
+func (p2p *P2PServer) BroadcastMessageExcept(msg *Message, exclude *Peer) {
 	p2p.mu.RLock()
 	peers := make([]*Peer, 0, len(p2p.peers))
 	for _, peer := range p2p.peers {
-		peers = append(peers, peer)
+		if peer != exclude {
+			peers = append(peers, peer)
+		}
 	}
 	p2p.mu.RUnlock()
 
@@ -225,32 +569,63 @@ func (p2p *P2PServer) BroadcastMessage(msg *Message) {
 
 // SendAndWaitForResponse sends a message and waits for a response of the specified type
 func (p2p *P2PServer) SendAndWaitForResponse(peer *Peer, msg *Message, responseType MessageType, timeout time.Duration) (*Message, error) {
-	// Create response channel
-	responseChan := make(chan *Message, 1)
+	var response *Message
+	err := p2p.SendAndStreamResponses(peer, msg, responseType, timeout, func(m *Message) (bool, error) {
+		response = m
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// SendAndStreamResponses sends a message and passes each response of the specified type
+// to handle, in order, until handle reports it is done or returns an error. timeout
+// bounds the wait for each response, and the request fails as soon as the peer
+// disconnects. The peer's read loop waits while handle runs, so a response stream is
+// never dropped.
+func (p2p *P2PServer) SendAndStreamResponses(peer *Peer, msg *Message, responseType MessageType, timeout time.Duration, handle func(*Message) (bool, error)) error {
+	waiter := &responseWaiter{
+		ch:   make(chan *Message, 1),
+		done: make(chan struct{}),
+	}
 
-	// Register channel for response type
+	// Register the waiter for the peer's responses
+	key := responseKey{peer: peer, msgType: responseType}
 	p2p.responseMu.Lock()
-	p2p.responseChans[responseType] = responseChan
+	p2p.responseChans[key] = waiter
 	p2p.responseMu.Unlock()
 
-	// Ensure cleanup
+	// Ensure cleanup; closing done releases a read loop blocked on delivery
 	defer func() {
 		p2p.responseMu.Lock()
-		delete(p2p.responseChans, responseType)
+		delete(p2p.responseChans, key)
 		p2p.responseMu.Unlock()
+		close(waiter.done)
 	}()
 
 	// Send the request
 	if err := p2p.SendMessage(peer, msg); err != nil {
-		return nil, err
+		return err
 	}
 
-	// Wait for response with timeout
-	select {
-	case response := <-responseChan:
-		return response, nil
-	case <-time.After(timeout):
-		return nil, errors.New("request timeout")
+	// Wait for responses with timeout
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case response := <-waiter.ch:
+			done, err := handle(response)
+			if err != nil || done {
+				return err
+			}
+			timer.Reset(timeout)
+		case <-peer.done:
+			return errors.New("peer disconnected")
+		case <-timer.C:
+			return errors.New("request timeout")
+		}
 	}
 }
 
@@ -258,16 +633,16 @@ func (p2p *P2PServer) SendAndWaitForResponse(peer *Peer, msg *Message, responseT
 func (p2p *P2PServer) handleMessage(peer *Peer, msg *Message) error {
 	// Check if this is a response we're waiting for
 	p2p.responseMu.Lock()
-	if ch, ok := p2p.responseChans[msg.Type]; ok {
+	waiter, ok := p2p.responseChans[responseKey{peer: peer, msgType: msg.Type}]
+	p2p.responseMu.Unlock()
+	if ok {
 		select {
-		case ch <- msg:
-		default:
-			// Channel full, skip
+		case waiter.ch <- msg:
+		case <-waiter.done:
+			// The request finished, skip
 		}
-		p2p.responseMu.Unlock()
 		return nil
 	}
-	p2p.responseMu.Unlock()
 
 	// Otherwise dispatch to handler
 	p2p.mu.RLock()
@@ -281,6 +656,46 @@ func (p2p *P2PServer) handleMessage(peer *Peer, msg *Message) error {
 	return handler(peer, msg)
 }
 
+// handleCodecMessage applies a codec request or acknowledgement from a peer
+func (p2p *P2PServer) handleCodecMessage(peer *Peer, msg *Message) error {
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return err
+	}
+
+	var codecMsg CodecMessage
+	if err := json.Unmarshal(payloadBytes, &codecMsg); err != nil {
+		return err
+	}
+
+	switch msg.Type {
+	case MsgTypeCodecRequest:
+		codec := CodecByName(codecMsg.Codec)
+		if codec == nil {
+			p2p.logger.Warnf("Peer %s requested unknown codec %q, staying on %s", peer.ID, codecMsg.Codec, CodecJSON)
+			codec = JSONCodec{}
+		}
+
+		// The ack goes out on the old codec; everything queued after it uses the new one
+		if err := p2p.enqueue(peer, &outboundMessage{
+			msg:       &Message{Type: MsgTypeCodecAck, Payload: &CodecMessage{Codec: codec.Name()}},
+			nextCodec: codec,
+		}); err != nil {
+			return fmt.Errorf("failed to acknowledge codec: %w", err)
+		}
+
+	case MsgTypeCodecAck:
+		codec := CodecByName(codecMsg.Codec)
+		if codec == nil {
+			return fmt.Errorf("peer acknowledged unknown codec %q", codecMsg.Codec)
+		}
+		peer.recvCodec = codec
+		p2p.logger.Debugf("Peer %s now sends %s", peer.ID, codec.Name())
+	}
+
+	return nil
+}
+
 // ConnectToPeer connects to a remote peer
 func (p2p *P2PServer) ConnectToPeer(address string) error {
 	conn, err := net.DialTimeout("tcp", address, 10*time.Second)
@@ -289,26 +704,74 @@ func (p2p *P2PServer) ConnectToPeer(address string) error {
 	}
 
 	p2p.wg.Add(1)
-	go p2p.handlePeer(conn)
+	go p2p.handlePeer(conn, address)
 
 	return nil
 }
 
-// addPeer adds a peer to the peer list
-func (p2p *P2PServer) addPeer(peer *Peer) {
+// SetPeerChangeHandler sets a callback invoked with the peer count whenever a peer is added or removed
+func (p2p *P2PServer) SetPeerChangeHandler(handler func(count int)) {
+	p2p.mu.Lock()
+	defer p2p.mu.Unlock()
+
+	p2p.onPeerChange = handler
+}
+
+// addPeer adds a peer to the peer list, refusing banned nodes and addresses and a
+// second connection to the same node
+func (p2p *P2PServer) addPeer(peer *Peer) error {
 	p2p.mu.Lock()
 	defer p2p.mu.Unlock()
 
+	now := time.Now()
+	if p2p.isBanned(peer.ID, now) {
+		return fmt.Errorf("node %s is banned", peer.ID)
+	}
+	if p2p.isAddressBanned(peer.Address, now) {
+		return fmt.Errorf("address %s is banned", peer.Address)
+	}
+
+	existing, exists := p2p.peers[peer.ID]
+	if exists {
+		// Both nodes dialed each other: both sides keep the connection dialed by
+		// the node with the lower ID
+		if peer.outbound != (p2p.nodeID < peer.NodeID) || existing.outbound == peer.outbound {
+			if peer.outbound {
+				p2p.trackedConnected(peer.ListenAddr, peer.NodeID)
+			}
+			return fmt.Errorf("already connected to node %s", peer.ID)
+		}
+		existing.Conn.Close()
+	} else if p2p.maxPeers > 0 && len(p2p.peers) >= p2p.maxPeers {
+		return fmt.Errorf("peer limit of %d reached", p2p.maxPeers)
+	}
+
 	p2p.peers[peer.ID] = peer
+	if peer.outbound {
+		p2p.trackedConnected(peer.ListenAddr, peer.NodeID)
+	}
+	if p2p.onPeerChange != nil {
+		p2p.onPeerChange(len(p2p.peers))
+	}
+	return nil
 }
 
-// removePeer removes a peer from the peer list
-func (p2p *P2PServer) removePeer(peerID string) {
+// removePeer removes a peer from the peer list, unless it was already replaced
+// by a newer connection to the same node
+func (p2p *P2PServer) removePeer(peer *Peer) {
 	p2p.mu.Lock()
 	defer p2p.mu.Unlock()
 
-	delete(p2p.peers, peerID)
-	p2p.logger.Infof("Peer disconnected: %s", peerID)
+	if p2p.peers[peer.ID] != peer {
+		return
+	}
+
+	delete(p2p.peers, peer.ID)
+	p2p.trackedNodeRemoved(peer.ID)
+	p2p.logger.Infof("Peer disconnected: %s", peer.ID)
+	if p2p.onPeerChange != nil {
+		p2p.onPeerChange(len(p2p.peers))
+	}
 }
 
 // GetPeers returns a list of connected peers
@@ -0,0 +1,115 @@
+package network
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStalledPeerDoesNotBlockBroadcast(t *testing.T) {
+	local := NewP2PServer("127.0.0.1", 0, quietLogger())
+
+	// A responsive peer counting the pings it receives
+	var received atomic.Int32
+	responsive := NewP2PServer("127.0.0.1", 0, quietLogger())
+	responsive.RegisterHandler(MsgTypePing, func(peer *Peer, msg *Message) error {
+		received.Add(1)
+		return nil
+	})
+	connect(local, responsive, "192.0.2.2:30303")
+
+	// A stalled peer that never reads what it is sent
+	stalled, conn := net.Pipe()
+	local.wg.Add(1)
+	go local.handlePeer(addrConn{Conn: stalled, remote: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 3), Port: 30303}}, "")
+	t.Cleanup(func() {
+		conn.Close()
+		local.Stop()
+		responsive.Stop()
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for local.PeerCount() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("peers did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Fill the stalled peer's queue, so the broadcasts overflow it
+	var stalledPeer *Peer
+	for _, peer := range local.GetPeers() {
+		if peer.Address == "192.0.2.3:30303" {
+			stalledPeer = peer
+		}
+	}
+	for range peerSendQueueSize {
+		if err := local.SendMessage(stalledPeer, &Message{Type: MsgTypePing, Payload: &PingMessage{}}); err != nil {
+			t.Fatalf("filling the stalled peer's queue: %v", err)
+		}
+	}
+
+	const broadcasts = 50
+	start := time.Now()
+	for range broadcasts {
+		local.BroadcastMessage(&Message{Type: MsgTypePing, Payload: &PingMessage{Timestamp: time.Now().Unix()}})
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("broadcasting took %v", elapsed)
+	}
+
+	deadline = time.Now().Add(5 * time.Second)
+	for received.Load() < broadcasts || local.PeerCount() > 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("responsive peer got %d of %d broadcasts, %d peers connected", received.Load(), broadcasts, local.PeerCount())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDuplicateConnectionsCollapseToOnePeer(t *testing.T) {
+	a, addrA := discoveryServer(t)
+	b, addrB := discoveryServer(t)
+
+	// A and B dial each other at once: both keep the connection dialed by the lower ID
+	if err := a.ConnectToPeer(addrB); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ConnectToPeer(addrA); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	waitPeers(t, a, 1)
+	waitPeers(t, b, 1)
+
+	// A dials B again while connected: the new connection is refused on both sides
+	if err := a.ConnectToPeer(addrB); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	waitPeers(t, a, 1)
+	waitPeers(t, b, 1)
+
+	peerA, peerB := b.GetPeers()[0], a.GetPeers()[0]
+	if peerA.ID != a.NodeID() || peerB.ID != b.NodeID() {
+		t.Errorf("peers keyed by %s and %s, want the node IDs %s and %s", peerA.ID, peerB.ID, a.NodeID(), b.NodeID())
+	}
+
+	// Both sides keep the same connection, so it stays usable
+	var pinged atomic.Int32
+	b.RegisterHandler(MsgTypePing, func(peer *Peer, msg *Message) error {
+		pinged.Add(1)
+		return nil
+	})
+	if err := a.SendMessage(peerB, &Message{Type: MsgTypePing, Payload: &PingMessage{}}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for pinged.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("ping over the kept connection never arrived")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -21,8 +21,35 @@ const (
 	MsgTypeGetState
 	MsgTypeGetHeight
 	MsgTypeHeight
+	MsgTypeTipAnnounce
+	MsgTypeCodecRequest
+	MsgTypeCodecAck
+	MsgTypeHello
+	MsgTypeHelloProof
+	MsgTypeGetHeaders
+	MsgTypeHeaders
+	MsgTypeGetStateProof
+	MsgTypeStateProof
 )
 
+// MaxHeadersPerRequest is the most headers a node returns for one GetHeaders request
+const MaxHeadersPerRequest = 1000
+
+// MaxBlocksPerRequest is the most blocks a node returns for one GetBlocks request;
+// requesters page through longer ranges
+const MaxBlocksPerRequest = 256
+
+// MaxMessageSize is the largest encoded message a peer accepts (10 MB)
+const MaxMessageSize = 10 * 1024 * 1024
+
+// MaxBlocksChunkSize bounds the blocks sent in one BlocksMessage, leaving room under
+// MaxMessageSize for the envelope. A larger block is sent on its own.
+const MaxBlocksChunkSize = 4 * 1024 * 1024
+
+// ProtocolVersion is the P2P protocol version exchanged in the handshake.
+// Peers with a different version are disconnected.
+const ProtocolVersion uint32 = 3
+
 // Message is the envelope for all P2P messages
 type Message struct {
 	Type    MessageType `json:"type"`
@@ -66,9 +93,11 @@ type GetBlocksMessage struct {
 	ToHeight   uint64 `json:"to_height"`
 }
 
-// BlocksMessage responds with blocks
+// BlocksMessage responds with blocks. A response is streamed as a series of messages
+// of at most MaxBlocksChunkSize of blocks each; all but the last set More.
 type BlocksMessage struct {
 	Blocks []*blockchain.Block `json:"blocks"`
+	More   bool                `json:"more,omitempty"` // More chunks of the response follow
 }
 
 // NewTransactionMessage broadcasts a new transaction
@@ -97,6 +126,31 @@ type StateMessage struct {
 	Value []byte `json:"value"`
 }
 
+// GetHeadersMessage requests the signed headers in a height range, for light nodes
+type GetHeadersMessage struct {
+	FromHeight uint64 `json:"from_height"`
+	ToHeight   uint64 `json:"to_height"`
+}
+
+// HeadersMessage responds with signed headers in height order
+type HeadersMessage struct {
+	Headers []*blockchain.SignedHeader `json:"headers"`
+}
+
+// GetStateProofMessage requests a Merkle proof of a state key against the peer's tip
+type GetStateProofMessage struct {
+	Key string `json:"key"`
+}
+
+// StateProofMessage responds with a state proof and the height of the header whose
+// state root it is proven against. Error is set instead if the key can't be proven.
+type StateProofMessage struct {
+	Key    string                 `json:"key"`
+	Height uint64                 `json:"height"`
+	Proof  *blockchain.StateProof `json:"proof,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
 // GetHeightMessage requests the current chain height
 type GetHeightMessage struct{}
 
@@ -104,3 +158,35 @@ type GetHeightMessage struct{}
 type HeightMessage struct {
 	Height uint64 `json:"height"`
 }
+
+// TipAnnounceMessage advertises the sender's current chain tip
+type TipAnnounceMessage struct {
+	Height uint64 `json:"height"`
+	Hash   []byte `json:"hash"`
+}
+
+// CodecMessage names a wire codec. A CodecRequest asks the peer to encode the
+// messages it sends with that codec; the CodecAck names the codec the peer
+// switched to, and is the last message it sends with the previous one.
+type CodecMessage struct {
+	Codec string `json:"codec"`
+}
+
+// HelloMessage is the first message each side sends on a new connection.
+// Peers on a different chain or protocol version are disconnected.
+type HelloMessage struct {
+	ChainID     string `json:"chain_id"`
+	Version     uint32 `json:"version"`
+	GenesisHash []byte `json:"genesis_hash"`
+	Height      uint64 `json:"height"`
+	NodeID      string `json:"node_id"`     // Derived from PublicKey; detects self and duplicate connections
+	ListenPort  int    `json:"listen_port"` // P2P port the sender accepts connections on
+	PublicKey   []byte `json:"public_key"`  // Sender's node key
+	Nonce       []byte `json:"nonce"`       // Random value the receiver signs in its HelloProof
+}
+
+// HelloProofMessage follows the hello and proves the sender holds the key its
+// node ID is derived from, by signing the nonce from the receiver's hello
+type HelloProofMessage struct {
+	Signature []byte `json:"signature"`
+}
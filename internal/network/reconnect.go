@@ -0,0 +1,174 @@
+package network
+
+import (
+	"time"
+)
+
+// Reconnection backoff defaults
+const (
+	DefaultReconnectBaseDelay = time.Second
+	DefaultReconnectMaxDelay  = 2 * time.Minute
+
+	// reconnectCheckInterval is how often tracked peers are checked for a due reconnect
+	reconnectCheckInterval = 500 * time.Millisecond
+)
+
+// trackedPeer is a dialable peer address the reconnection manager knows about
+type trackedPeer struct {
+	address     string
+	nodeID      string // Node last reached at the address
+	persistent  bool   // Re-dialed after a disconnect; non-persistent peers are forgotten instead
+	connected   bool
+	dialing     bool
+	attempts    int // Failed dials since the last successful connection
+	nextAttempt time.Time
+}
+
+// AddPersistentPeer tracks an address that should always be connected. It is dialed
+// right away and re-dialed with exponential backoff whenever the connection drops.
+func (p2p *P2PServer) AddPersistentPeer(address string) {
+	p2p.trackPeer(address, true)
+	p2p.dialDuePeers(time.Now())
+}
+
+// SetReconnectBackoff sets the first retry delay and the cap it doubles up to
+func (p2p *P2PServer) SetReconnectBackoff(base, max time.Duration) {
+	p2p.mu.Lock()
+	defer p2p.mu.Unlock()
+
+	p2p.reconnectBase = base
+	p2p.reconnectMax = max
+}
+
+// trackPeer starts tracking an address; persistent wins if it is tracked twice
+func (p2p *P2PServer) trackPeer(address string, persistent bool) {
+	p2p.mu.Lock()
+	defer p2p.mu.Unlock()
+
+	if tracked, exists := p2p.tracked[address]; exists {
+		tracked.persistent = tracked.persistent || persistent
+		return
+	}
+	p2p.tracked[address] = &trackedPeer{address: address, persistent: persistent}
+}
+
+// reconnectBackoff returns the delay before retry number attempts (caller must hold p2p.mu)
+func (p2p *P2PServer) reconnectBackoff(attempts int) time.Duration {
+	delay := p2p.reconnectBase
+	for i := 1; i < attempts && delay < p2p.reconnectMax; i++ {
+		delay *= 2
+	}
+	if delay > p2p.reconnectMax {
+		delay = p2p.reconnectMax
+	}
+	return delay
+}
+
+// reconnectLoop periodically dials persistent peers that are due for a reconnect
+func (p2p *P2PServer) reconnectLoop() {
+	defer p2p.wg.Done()
+
+	ticker := time.NewTicker(reconnectCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		p2p.dialDuePeers(time.Now())
+
+		select {
+		case <-p2p.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dialDuePeers starts a dial to every disconnected persistent peer whose backoff has elapsed
+func (p2p *P2PServer) dialDuePeers(now time.Time) {
+	p2p.mu.Lock()
+	var due []*trackedPeer
+	for _, tracked := range p2p.tracked {
+		if !tracked.persistent || tracked.connected || tracked.dialing || now.Before(tracked.nextAttempt) {
+			continue
+		}
+		tracked.dialing = true
+		due = append(due, tracked)
+	}
+	p2p.mu.Unlock()
+
+	for _, tracked := range due {
+		go func(tracked *trackedPeer) {
+			if err := p2p.ConnectToPeer(tracked.address); err != nil {
+				p2p.mu.Lock()
+				delay := p2p.dialFailed(tracked)
+				attempts := tracked.attempts
+				p2p.mu.Unlock()
+
+				p2p.logger.Warnf("Failed to connect to peer %s (attempt %d, retrying in %s): %v",
+					tracked.address, attempts, delay, err)
+			}
+		}(tracked)
+	}
+}
+
+// dialFailed records a failed dial and schedules the next one (caller must hold p2p.mu)
+func (p2p *P2PServer) dialFailed(tracked *trackedPeer) time.Duration {
+	tracked.dialing = false
+	tracked.attempts++
+	delay := p2p.reconnectBackoff(tracked.attempts)
+	tracked.nextAttempt = time.Now().Add(delay)
+	return delay
+}
+
+// trackedConnected records that the node at a tracked address is connected
+// (caller must hold p2p.mu)
+func (p2p *P2PServer) trackedConnected(address, nodeID string) {
+	tracked, exists := p2p.tracked[address]
+	if !exists {
+		return
+	}
+
+	tracked.nodeID = nodeID
+	tracked.connected = true
+	tracked.dialing = false
+	tracked.attempts = 0
+}
+
+// trackedNodeRemoved schedules a reconnect to the tracked address of a node whose
+// last connection was removed, or forgets it if it isn't persistent (caller must hold p2p.mu)
+func (p2p *P2PServer) trackedNodeRemoved(nodeID string) {
+	for address, tracked := range p2p.tracked {
+		if tracked.nodeID != nodeID || !tracked.connected {
+			continue
+		}
+
+		if !tracked.persistent {
+			delete(p2p.tracked, address)
+			continue
+		}
+
+		// Dropped after a successful connection: retry after the base delay
+		tracked.connected = false
+		tracked.attempts = 0
+		tracked.nextAttempt = time.Now().Add(p2p.reconnectBase)
+	}
+}
+
+// trackedDisconnected is called when a connection we dialed ends. A dial that
+// never connected counts as a failed attempt; a non-persistent address is forgotten
+// unless its node is still connected (caller must hold p2p.mu).
+func (p2p *P2PServer) trackedDisconnected(address string) {
+	tracked, exists := p2p.tracked[address]
+	if !exists || tracked.connected {
+		return
+	}
+
+	if !tracked.persistent {
+		delete(p2p.tracked, address)
+		return
+	}
+
+	if tracked.dialing {
+		// Connected but the handshake failed
+		p2p.dialFailed(tracked)
+	}
+}
@@ -0,0 +1,73 @@
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPersistentPeerReconnects(t *testing.T) {
+	local, _ := discoveryServer(t)
+	remote, remoteAddr := discoveryServer(t)
+	const base = 300 * time.Millisecond
+	local.SetReconnectBackoff(base, time.Second)
+
+	local.AddPersistentPeer(remoteAddr)
+	waitPeers(t, local, 1)
+	waitPeers(t, remote, 1)
+
+	// Kill the connection from the remote side
+	remote.GetPeers()[0].Conn.Close()
+	dropped := time.Now()
+	waitPeers(t, local, 0)
+
+	waitPeers(t, local, 1)
+	if elapsed := time.Since(dropped); elapsed < base {
+		t.Errorf("reconnected after %s, before the %s backoff", elapsed, base)
+	}
+
+	local.mu.RLock()
+	tracked := local.tracked[remoteAddr]
+	local.mu.RUnlock()
+	if tracked == nil || !tracked.persistent {
+		t.Fatal("persistent peer is no longer tracked")
+	}
+}
+
+func TestDroppedDiscoveredPeerIsForgotten(t *testing.T) {
+	local, _ := discoveryServer(t)
+	remote, remoteAddr := discoveryServer(t)
+
+	local.trackPeer(remoteAddr, false)
+	if err := local.ConnectToPeer(remoteAddr); err != nil {
+		t.Fatal(err)
+	}
+	waitPeers(t, local, 1)
+	waitPeers(t, remote, 1)
+
+	remote.GetPeers()[0].Conn.Close()
+	waitPeers(t, local, 0)
+
+	local.mu.RLock()
+	_, tracked := local.tracked[remoteAddr]
+	local.mu.RUnlock()
+	if tracked {
+		t.Error("non-persistent peer is still tracked after disconnecting")
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	p2p := NewP2PServer("127.0.0.1", 0, quietLogger())
+	p2p.SetReconnectBackoff(time.Second, 5*time.Second)
+
+	for attempts, want := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 5 * time.Second,
+		9: 5 * time.Second,
+	} {
+		if got := p2p.reconnectBackoff(attempts); got != want {
+			t.Errorf("backoff after %d attempts = %s, want %s", attempts, got, want)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/podoru/podoru-chain/internal/blockchain"
@@ -16,8 +18,12 @@ type Syncer struct {
 	p2pServer  *P2PServer
 	mempool    *Mempool
 	logger     *logrus.Logger
-	isSyncing  bool
 	syncPeriod time.Duration
+
+	// Sync coalescing: one sync runs at a time and at most one more waits behind it
+	mu          sync.Mutex
+	isSyncing   bool
+	pendingSync func()
 }
 
 // NewSyncer creates a new syncer
@@ -37,13 +43,51 @@ func NewSyncer(chain *blockchain.Chain, p2pServer *P2PServer, mempool *Mempool,
 
 // SyncWithPeers synchronizes the blockchain with peers
 func (s *Syncer) SyncWithPeers() error {
-	if s.isSyncing {
+	if !s.beginSync() {
 		return errors.New("sync already in progress")
 	}
+	defer s.endSync()
 
-	s.isSyncing = true
-	defer func() { s.isSyncing = false }()
+	return s.syncWithPeers()
+}
+
+// syncPeer is a peer the syncer downloads from and the height it reported
+type syncPeer struct {
+	peer   *Peer
+	height uint64
+}
+
+// blockBatch is a height range downloaded from a single peer
+type blockBatch struct {
+	from, to uint64
+	blocks   []*blockchain.Block
+	source   *Peer          // Peer that delivered blocks
+	failed   map[*Peer]bool // Peers that failed to deliver this batch
+
+	downloaded bool // Set once blocks is in; blocks is released after it is applied
+}
 
+var (
+	// errMalformedBatch is returned for a block batch that doesn't hold the requested heights
+	errMalformedBatch = errors.New("malformed block batch")
+
+	// errSyncRewind and errSyncRetry stop a sync round so it restarts at an earlier height
+	errSyncRewind = errors.New("peers are on a competing branch")
+	errSyncRetry  = errors.New("peer served an invalid block")
+)
+
+const (
+	// syncBatchSize is the number of blocks requested at a time (at most MaxBlocksPerRequest)
+	syncBatchSize = uint64(100)
+
+	// maxSyncRoundBatches bounds the batches downloaded before they are applied, and
+	// so the blocks held in memory during a sync
+	maxSyncRoundBatches = 16
+)
+
+// syncWithPeers downloads the blocks up to the highest peer height from all peers
+// that have them (caller must hold the sync slot)
+func (s *Syncer) syncWithPeers() error {
 	peers := s.p2pServer.GetPeers()
 	if len(peers) == 0 {
 		return errors.New("no peers to sync with")
@@ -54,68 +98,228 @@ func (s *Syncer) SyncWithPeers() error {
 	// Get current height
 	currentHeight := s.chain.GetHeight()
 
-	// Query all peers for their heights
-	peerHeights := make(map[string]uint64)
+	// Query all peers for their heights; the ones ahead of us are download sources
+	var sources []syncPeer
+	var maxHeight uint64
+	responded := 0
 	for _, peer := range peers {
 		height, err := s.getPeerHeight(peer)
 		if err != nil {
 			s.logger.Warnf("Failed to get height from peer %s: %v", peer.ID, err)
 			continue
 		}
-		peerHeights[peer.ID] = height
-	}
-
-	// Find the best peer (highest height)
-	var bestPeer *Peer
-	var maxHeight uint64
-	for _, peer := range peers {
-		if height, ok := peerHeights[peer.ID]; ok && height > maxHeight {
-			maxHeight = height
-			bestPeer = peer
+		responded++
+		if height > currentHeight {
+			sources = append(sources, syncPeer{peer: peer, height: height})
+			maxHeight = max(maxHeight, height)
 		}
 	}
 
-	if bestPeer == nil {
+	if responded == 0 {
 		return errors.New("no valid peers found")
 	}
 
-	if maxHeight <= currentHeight {
+	if len(sources) == 0 {
 		s.logger.Info("Already in sync")
 		return nil
 	}
 
-	s.logger.Infof("Syncing from peer %s (height %d -> %d)", bestPeer.ID, currentHeight, maxHeight)
+	return s.syncBlocks(sources, currentHeight, maxHeight)
+}
 
-	// Request blocks in batches
-	batchSize := uint64(100)
-	for height := currentHeight + 1; height <= maxHeight; height += batchSize {
-		toHeight := height + batchSize - 1
-		if toHeight > maxHeight {
-			toHeight = maxHeight
-		}
+// SyncFromPeer synchronizes the blockchain up to a height a specific peer is known to have
+func (s *Syncer) SyncFromPeer(peer *Peer, targetHeight uint64) error {
+	if !s.beginSync() {
+		return errors.New("sync already in progress")
+	}
+	defer s.endSync()
 
-		blocks, err := s.requestBlocks(bestPeer, height, toHeight)
-		if err != nil {
-			return fmt.Errorf("failed to request blocks: %w", err)
+	return s.syncToHeight(peer, targetHeight)
+}
+
+// syncToHeight syncs from a peer up to targetHeight (caller must hold the sync slot)
+func (s *Syncer) syncToHeight(peer *Peer, targetHeight uint64) error {
+	currentHeight := s.chain.GetHeight()
+	if targetHeight <= currentHeight {
+		return nil
+	}
+
+	return s.syncBlocks([]syncPeer{{peer: peer, height: targetHeight}}, currentHeight, targetHeight)
+}
+
+// syncBlocks downloads and applies blocks (currentHeight, maxHeight]. The range is
+// split into batches that are downloaded concurrently, each from a peer whose chain
+// reaches it. Each batch is applied as soon as the batches below it are, and then
+// released, so only batches downloaded ahead of the next one to apply are held.
+func (s *Syncer) syncBlocks(peers []syncPeer, currentHeight, maxHeight uint64) error {
+	s.logger.Infof("Syncing from %d peers (height %d -> %d)", len(peers), currentHeight, maxHeight)
+
+	rewound := false
+	for height := currentHeight + 1; height <= maxHeight; {
+		// Split the next round into batches
+		roundBatches := 2 * len(peers)
+		if roundBatches > maxSyncRoundBatches {
+			roundBatches = maxSyncRoundBatches
+		}
+		var batches []*blockBatch
+		for from := height; from <= maxHeight && len(batches) < roundBatches; from += syncBatchSize {
+			to := from + syncBatchSize - 1
+			if to > maxHeight {
+				to = maxHeight
+			}
+			batches = append(batches, &blockBatch{from: from, to: to, failed: make(map[*Peer]bool)})
 		}
 
-		// Validate and add blocks
-		for _, block := range blocks {
-			if err := s.chain.AddBlock(block); err != nil {
-				return fmt.Errorf("failed to add block at height %d: %w", block.Header.Height, err)
+		// Validate and add blocks in order; a block on a preferred competing branch
+		// reorganizes the chain
+		var faulty *Peer
+		var retryHeight uint64
+		apply := func(batch *blockBatch) error {
+			for _, block := range batch.blocks {
+				if _, err := s.chain.ProcessBlock(block); err != nil {
+					if errors.Is(err, blockchain.ErrUnknownParent) && !rewound {
+						return errSyncRewind
+					}
+					if errors.Is(err, blockchain.ErrInvalidBlock) {
+						// The peer served an invalid block: count it against the peer
+						// and fetch the rest from the others
+						s.logger.Warnf("Peer %s served invalid block %d: %v", batch.source.ID, block.Header.Height, err)
+						s.p2pServer.RecordFault(batch.source, err)
+						faulty, retryHeight = batch.source, block.Header.Height
+						return errSyncRetry
+					}
+					return fmt.Errorf("failed to add block at height %d: %w", block.Header.Height, err)
+				}
+
+				// Remove synced transactions from mempool
+				s.mempool.RemoveTransactions(block.Transactions)
 			}
+			s.logger.Infof("Synced blocks %d to %d", batch.from, batch.to)
+			return nil
+		}
 
-			// Remove synced transactions from mempool
-			s.mempool.RemoveTransactions(block.Transactions)
+		var err error
+		peers, err = s.downloadBatches(peers, batches, apply)
+		switch {
+		case errors.Is(err, errSyncRetry):
+			peers = slices.DeleteFunc(peers, func(p syncPeer) bool { return p.peer == faulty })
+			height = retryHeight
+			continue
+		case errors.Is(err, errSyncRewind):
+			// The peers are on a different branch: fetch it again from as far back as
+			// a reorg can reach, so the fork point and the branch are both known
+			rewound = true
+			height = 1
+			if currentHeight >= blockchain.MaxReorgDepth {
+				height = currentHeight - blockchain.MaxReorgDepth + 1
+			}
+			s.logger.Infof("Peers are on a competing branch, re-syncing from height %d", height)
+			continue
+		case err != nil:
+			return err
 		}
 
-		s.logger.Infof("Synced blocks %d to %d", height, toHeight)
+		height = batches[len(batches)-1].to + 1
 	}
 
 	s.logger.Info("Blockchain sync completed")
 	return nil
 }
 
+// downloadBatches fetches every batch, at most one request per peer at a time, and
+// passes the batches to apply in order as they become complete. A batch a peer fails
+// to deliver is reassigned to another peer that reaches it, and the failed peer is not
+// used again during this sync; a malformed batch also counts as a fault against the
+// peer. If apply fails, no more batches are requested and its error is returned once
+// the requests in flight finish. It returns the peers still in use.
+func (s *Syncer) downloadBatches(peers []syncPeer, batches []*blockBatch, apply func(*blockBatch) error) ([]syncPeer, error) {
+	type result struct {
+		batch  *blockBatch
+		source syncPeer
+		blocks []*blockchain.Block
+		err    error
+	}
+
+	results := make(chan result, len(peers))
+	idle := append([]syncPeer(nil), peers...)
+	pending := append([]*blockBatch(nil), batches...)
+	var dropped []*Peer
+	var applyErr error
+	inFlight, next := 0, 0
+
+	for (len(pending) > 0 && applyErr == nil) || inFlight > 0 {
+		// Hand pending batches to idle peers that can serve them
+		if applyErr == nil {
+			remaining := pending[:0]
+			for _, batch := range pending {
+				i := slices.IndexFunc(idle, func(p syncPeer) bool {
+					return p.height >= batch.to && !batch.failed[p.peer]
+				})
+				if i < 0 {
+					remaining = append(remaining, batch)
+					continue
+				}
+
+				source := idle[i]
+				idle = slices.Delete(idle, i, i+1)
+				inFlight++
+				go func(batch *blockBatch, source syncPeer) {
+					blocks, err := s.requestBatch(source.peer, batch.from, batch.to)
+					results <- result{batch: batch, source: source, blocks: blocks, err: err}
+				}(batch, source)
+			}
+			pending = remaining
+
+			if inFlight == 0 {
+				applyErr = fmt.Errorf("no peer left to download blocks %d to %d", pending[0].from, pending[0].to)
+				break
+			}
+		}
+
+		r := <-results
+		inFlight--
+		if r.err != nil {
+			s.logger.Warnf("Failed to download blocks %d to %d from peer %s: %v", r.batch.from, r.batch.to, r.source.peer.ID, r.err)
+			if errors.Is(r.err, errMalformedBatch) {
+				s.p2pServer.RecordFault(r.source.peer, r.err)
+			}
+			r.batch.failed[r.source.peer] = true
+			pending = append(pending, r.batch)
+			dropped = append(dropped, r.source.peer)
+			continue
+		}
+
+		r.batch.blocks = r.blocks
+		r.batch.source = r.source.peer
+		r.batch.downloaded = true
+		idle = append(idle, r.source)
+
+		// Apply the batches that are now complete in order, releasing their blocks
+		for applyErr == nil && next < len(batches) && batches[next].downloaded {
+			applyErr = apply(batches[next])
+			batches[next].blocks = nil
+			next++
+		}
+	}
+
+	return slices.DeleteFunc(peers, func(p syncPeer) bool {
+		return slices.Contains(dropped, p.peer)
+	}), applyErr
+}
+
+// requestBatch requests blocks from a peer and checks it returned the whole range in order
+func (s *Syncer) requestBatch(peer *Peer, fromHeight, toHeight uint64) ([]*blockchain.Block, error) {
+	blocks, err := s.requestBlocks(peer, fromHeight, toHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	if uint64(len(blocks)) != toHeight-fromHeight+1 {
+		return nil, fmt.Errorf("%w: %d blocks, expected %d", errMalformedBatch, len(blocks), toHeight-fromHeight+1)
+	}
+	return blocks, nil
+}
+
 // getPeerHeight requests the current height from a peer
 func (s *Syncer) getPeerHeight(peer *Peer) (uint64, error) {
 	msg := &Message{
@@ -142,7 +346,8 @@ func (s *Syncer) getPeerHeight(peer *Peer) (uint64, error) {
 	return heightMsg.Height, nil
 }
 
-// requestBlocks requests blocks from a peer
+// requestBlocks requests blocks from a peer, collecting the chunks of the response.
+// The blocks must arrive in height order from fromHeight; the peer may stop early.
 func (s *Syncer) requestBlocks(peer *Peer, fromHeight, toHeight uint64) ([]*blockchain.Block, error) {
 	msg := &Message{
 		Type: MsgTypeGetBlocks,
@@ -152,23 +357,33 @@ func (s *Syncer) requestBlocks(peer *Peer, fromHeight, toHeight uint64) ([]*bloc
 		},
 	}
 
-	response, err := s.p2pServer.SendAndWaitForResponse(peer, msg, MsgTypeBlocks, 30*time.Second)
-	if err != nil {
-		return nil, fmt.Errorf("failed to request blocks: %w", err)
-	}
+	var blocks []*blockchain.Block
+	err := s.p2pServer.SendAndStreamResponses(peer, msg, MsgTypeBlocks, 30*time.Second, func(response *Message) (bool, error) {
+		// Parse response
+		payloadBytes, err := json.Marshal(response.Payload)
+		if err != nil {
+			return false, err
+		}
 
-	// Parse response
-	payloadBytes, err := json.Marshal(response.Payload)
-	if err != nil {
-		return nil, err
-	}
+		var blocksMsg BlocksMessage
+		if err := json.Unmarshal(payloadBytes, &blocksMsg); err != nil {
+			return false, err
+		}
 
-	var blocksMsg BlocksMessage
-	if err := json.Unmarshal(payloadBytes, &blocksMsg); err != nil {
-		return nil, err
+		for _, block := range blocksMsg.Blocks {
+			expected := fromHeight + uint64(len(blocks))
+			if block == nil || block.Header == nil || block.Header.Height != expected || expected > toHeight {
+				return false, fmt.Errorf("%w: unexpected block at height %d", errMalformedBatch, expected)
+			}
+			blocks = append(blocks, block)
+		}
+		return !blocksMsg.More, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request blocks: %w", err)
 	}
 
-	return blocksMsg.Blocks, nil
+	return blocks, nil
 }
 
 // StartAutoSync starts automatic synchronization in the background
@@ -185,16 +400,148 @@ func (s *Syncer) StartAutoSync() {
 	}()
 }
 
-// TriggerSync triggers a sync if not already in progress
-func (s *Syncer) TriggerSync() {
-	if s.isSyncing {
-		s.logger.Debug("Sync already in progress, skipping trigger")
+// TriggerSyncFromPeer triggers a sync from a peer that announced a higher tip. If a
+// sync is running it is queued behind it, or dropped if another sync is already queued.
+func (s *Syncer) TriggerSyncFromPeer(peer *Peer, targetHeight uint64) {
+	queued := s.queueSync(func() {
+		if err := s.syncToHeight(peer, targetHeight); err != nil {
+			s.logger.Warnf("Tip-triggered sync from peer %s failed: %v", peer.ID, err)
+			return
+		}
+		s.AnnounceTip()
+	})
+	if !queued {
+		s.logger.Debug("Sync already queued, skipping tip-triggered sync")
+	}
+}
+
+// AnnounceTip sends the local chain tip to all peers
+func (s *Syncer) AnnounceTip() {
+	block := s.chain.GetCurrentBlock()
+	if block == nil {
+		return
+	}
+
+	s.p2pServer.BroadcastMessage(&Message{
+		Type: MsgTypeTipAnnounce,
+		Payload: &TipAnnounceMessage{
+			Height: block.Header.Height,
+			Hash:   block.Hash(),
+		},
+	})
+}
+
+// StartTipAnnouncements periodically announces the local chain tip to peers
+func (s *Syncer) StartTipAnnouncements(interval time.Duration) {
+	if interval <= 0 {
 		return
 	}
 
 	go func() {
-		if err := s.SyncWithPeers(); err != nil {
-			s.logger.Warnf("Triggered sync failed: %v", err)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.AnnounceTip()
+		}
+	}()
+}
+
+// StartRebroadcast periodically announces pending transactions again, in case their
+// first broadcast didn't reach any peer. A transaction is rebroadcast once it has been
+// pending for interval since its last announcement, at most maxRebroadcasts times.
+func (s *Syncer) StartRebroadcast(interval time.Duration, maxRebroadcasts int) {
+	if interval <= 0 || maxRebroadcasts <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.rebroadcastPending(interval, maxRebroadcasts)
 		}
 	}()
 }
+
+// rebroadcastPending announces the pending transactions that are due to peers
+func (s *Syncer) rebroadcastPending(interval time.Duration, maxRebroadcasts int) {
+	if s.p2pServer.PeerCount() == 0 {
+		return
+	}
+
+	txs := s.mempool.DueForRebroadcast(interval, maxRebroadcasts)
+	for _, tx := range txs {
+		s.p2pServer.BroadcastMessage(&Message{
+			Type:    MsgTypeNewTransaction,
+			Payload: &NewTransactionMessage{Transaction: tx},
+		})
+	}
+	if len(txs) > 0 {
+		s.logger.Debugf("Rebroadcast %d pending transactions", len(txs))
+	}
+}
+
+// TriggerSync triggers a sync with peers. If a sync is running it is queued behind it,
+// or dropped if another sync is already queued.
+func (s *Syncer) TriggerSync() {
+	queued := s.queueSync(func() {
+		if err := s.syncWithPeers(); err != nil {
+			s.logger.Warnf("Triggered sync failed: %v", err)
+		}
+	})
+	if !queued {
+		s.logger.Debug("Sync already queued, skipping trigger")
+	}
+}
+
+// beginSync takes the sync slot, returning false if a sync is already running
+func (s *Syncer) beginSync() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isSyncing {
+		return false
+	}
+	s.isSyncing = true
+	return true
+}
+
+// endSync hands the sync slot to the queued sync, or releases it if none is queued
+func (s *Syncer) endSync() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := s.pendingSync
+	s.pendingSync = nil
+	if next == nil {
+		s.isSyncing = false
+		return
+	}
+	go s.runSync(next)
+}
+
+// queueSync runs job in the background now if no sync is running, or queues it if
+// nothing else is queued. Returns false if the job was dropped.
+func (s *Syncer) queueSync(job func()) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isSyncing {
+		s.isSyncing = true
+		go s.runSync(job)
+		return true
+	}
+	if s.pendingSync != nil {
+		return false
+	}
+	s.pendingSync = job
+	return true
+}
+
+// runSync runs a job holding the sync slot, then passes the slot on
+func (s *Syncer) runSync(job func()) {
+	defer s.endSync()
+	job()
+}
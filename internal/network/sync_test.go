@@ -0,0 +1,519 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/crypto"
+	"github.com/podoru/podoru-chain/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// addrConn is one end of an in-memory connection reporting a remote address, so
+// peers connected over net.Pipe have distinct IDs and IPs
+type addrConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c addrConn) RemoteAddr() net.Addr { return c.remote }
+
+func quietLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// testChains returns a chain with height blocks and an empty chain on the same genesis
+func testChains(t *testing.T, height int) (full, empty *blockchain.Chain, producer *crypto.KeySigner) {
+	t.Helper()
+
+	key, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer, err = crypto.NewKeySigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &blockchain.GenesisConfig{
+		Timestamp:   time.Now().Add(-time.Hour).Unix(),
+		Authorities: []string{producer.Address()},
+	}
+	newChain := func() *blockchain.Chain {
+		chain := blockchain.NewChain(storage.NewMemoryStore(), config.Authorities)
+		chain.SetConsensusParams(config.GetConsensusParams())
+		if err := chain.Initialize(blockchain.CreateGenesisBlock(config)); err != nil {
+			t.Fatalf("Initialize: %v", err)
+		}
+		return chain
+	}
+
+	full, empty = newChain(), newChain()
+	for range height {
+		block := nextBlock(t, full, producer, nil)
+		if err := full.AddBlock(block); err != nil {
+			t.Fatalf("AddBlock: %v", err)
+		}
+	}
+	return full, empty, producer
+}
+
+// nextBlock builds an empty block on the chain's tip with the given state root, or the
+// correct one if stateRoot is nil
+func nextBlock(t *testing.T, chain *blockchain.Chain, producer *crypto.KeySigner, stateRoot []byte) *blockchain.Block {
+	t.Helper()
+
+	parent := chain.GetCurrentBlock()
+	if stateRoot == nil {
+		var err error
+		if stateRoot, err = chain.CalculateStateRootWithTransactions(nil, producer.Address()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	version := chain.GetConsensusParams().BlockVersion
+	block := blockchain.NewBlock(&blockchain.BlockHeader{
+		Version:      version,
+		Height:       parent.Header.Height + 1,
+		PreviousHash: parent.Hash(),
+		Timestamp:    parent.Header.Timestamp + 1,
+		MerkleRoot:   blockchain.CalculateMerkleRoot(nil, version),
+		StateRoot:    stateRoot,
+		ProducerAddr: producer.Address(),
+	}, nil)
+	if err := block.Sign(producer); err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+// servePeer starts a mock peer answering height and block requests from chain,
+// passing every served block through tamper if it is set
+func servePeer(chain *blockchain.Chain, tamper func(*blockchain.Block) *blockchain.Block) *P2PServer {
+	server := NewP2PServer("127.0.0.1", 0, quietLogger())
+	server.RegisterHandler(MsgTypeGetHeight, func(peer *Peer, msg *Message) error {
+		return server.SendMessage(peer, &Message{Type: MsgTypeHeight, Payload: &HeightMessage{Height: chain.GetHeight()}})
+	})
+	server.RegisterHandler(MsgTypeGetBlocks, func(peer *Peer, msg *Message) error {
+		payload, err := json.Marshal(msg.Payload)
+		if err != nil {
+			return err
+		}
+		var req GetBlocksMessage
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return err
+		}
+
+		var blocks []*blockchain.Block
+		for h := req.FromHeight; h <= req.ToHeight; h++ {
+			block, err := chain.GetBlockByHeight(h)
+			if err != nil {
+				break
+			}
+			if tamper != nil {
+				block = tamper(block)
+			}
+			blocks = append(blocks, block)
+		}
+		return server.SendMessage(peer, &Message{Type: MsgTypeBlocks, Payload: &BlocksMessage{Blocks: blocks}})
+	})
+	return server
+}
+
+// connect joins local and remote over an in-memory connection, as if remote
+// connected from remoteAddr
+func connect(local, remote *P2PServer, remoteAddr string) {
+	a, b := net.Pipe()
+	addr, _ := net.ResolveTCPAddr("tcp", remoteAddr)
+	local.wg.Add(1)
+	go local.handlePeer(addrConn{Conn: a, remote: addr}, "")
+	remote.wg.Add(1)
+	go remote.handlePeer(addrConn{Conn: b, remote: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 30303}}, "")
+}
+
+func TestSyncBansPeerServingInvalidBlocks(t *testing.T) {
+	// Enough blocks for several batches, so both peers are asked for some
+	const height = 2*int(syncBatchSize) + 1
+	source, chain, producer := testChains(t, height)
+
+	honest := servePeer(source, nil)
+	malicious := servePeer(source, func(block *blockchain.Block) *blockchain.Block {
+		// A correctly signed block with a state root the syncing node won't reach
+		forged := *block
+		header := *block.Header
+		header.StateRoot = make([]byte, 32)
+		forged.Header = &header
+		if err := forged.Sign(producer); err != nil {
+			t.Error(err)
+		}
+		return &forged
+	})
+
+	local := NewP2PServer("127.0.0.1", 0, quietLogger())
+	local.SetBanPolicy(1, time.Hour)
+	const honestAddr, maliciousAddr = "192.0.2.2:30303", "192.0.2.3:30303"
+	connect(local, honest, honestAddr)
+	connect(local, malicious, maliciousAddr)
+	t.Cleanup(func() {
+		local.Stop()
+		honest.Stop()
+		malicious.Stop()
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for local.PeerCount() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("peers did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	syncer := NewSyncer(chain, local, NewMempool(), quietLogger())
+	if err := syncer.SyncWithPeers(); err != nil {
+		t.Fatalf("SyncWithPeers: %v", err)
+	}
+
+	if chain.GetHeight() != uint64(height) {
+		t.Errorf("height = %d, want %d", chain.GetHeight(), height)
+	}
+	if chain.GetCurrentBlock().HashString() != source.GetCurrentBlock().HashString() {
+		t.Error("synced tip differs from the honest chain")
+	}
+	if !local.IsBanned(maliciousAddr) {
+		t.Error("malicious peer is not banned")
+	}
+	if !local.IsAddressBanned("192.0.2.3:40000") {
+		t.Error("malicious peer's IP is not banned")
+	}
+	if local.IsBanned(honestAddr) || local.IsAddressBanned(honestAddr) {
+		t.Error("honest peer is banned")
+	}
+}
+
+func TestBanCoversRemoteIP(t *testing.T) {
+	server := NewP2PServer("127.0.0.1", 0, quietLogger())
+	server.SetBanPolicy(2, time.Hour)
+
+	// The same host reconnecting under a fresh node ID keeps its fault count
+	for i, id := range []string{"node-a", "node-b"} {
+		a, b := net.Pipe()
+		defer b.Close()
+		peer := &Peer{ID: id, Conn: a, Address: "203.0.113.7:30303"}
+		if banned := server.RecordFault(peer, errMalformedBatch); banned != (i == 1) {
+			t.Fatalf("fault %d: banned = %v", i+1, banned)
+		}
+	}
+
+	if !server.IsBanned("node-b") {
+		t.Error("node ID is not banned")
+	}
+	if !server.IsAddressBanned("203.0.113.7:1234") {
+		t.Error("IP is not banned")
+	}
+	if err := server.addPeer(&Peer{ID: "node-c", Address: "203.0.113.7:30303"}); err == nil {
+		t.Error("banned IP added as a peer under a new node ID")
+	}
+
+	// Loopback addresses are shared by nodes on one host and are never banned
+	a, b := net.Pipe()
+	defer b.Close()
+	server.SetBanPolicy(1, time.Hour)
+	server.RecordFault(&Peer{ID: "local", Conn: a, Address: "127.0.0.1:30303"}, errMalformedBatch)
+	if server.IsAddressBanned("127.0.0.1:30304") {
+		t.Error("loopback address banned")
+	}
+}
+
+// waitIdle waits for the syncer to release its sync slot
+func waitIdle(t *testing.T, s *Syncer) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		idle := !s.isSyncing
+		s.mu.Unlock()
+		if idle {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("sync slot never released")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSyncTriggersCoalesce(t *testing.T) {
+	s := NewSyncer(nil, NewP2PServer("127.0.0.1", 0, quietLogger()), NewMempool(), quietLogger())
+
+	// Hold the sync slot while triggers pile up
+	release := make(chan struct{})
+	if !s.queueSync(func() { <-release }) {
+		t.Fatal("first sync not started")
+	}
+
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.TriggerSync()
+		}()
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	queued := s.pendingSync != nil
+	s.mu.Unlock()
+	if !queued {
+		t.Error("no sync queued behind the running one")
+	}
+
+	close(release)
+	waitIdle(t, s)
+}
+
+func TestSyncQueueDropsExcessJobs(t *testing.T) {
+	s := NewSyncer(nil, NewP2PServer("127.0.0.1", 0, quietLogger()), NewMempool(), quietLogger())
+
+	release := make(chan struct{})
+	var ran, accepted atomic.Int32
+	s.queueSync(func() { <-release; ran.Add(1) })
+
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.queueSync(func() { ran.Add(1) }) {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if accepted.Load() != 1 {
+		t.Errorf("%d jobs queued behind the running sync, want 1", accepted.Load())
+	}
+
+	close(release)
+	waitIdle(t, s)
+	if ran.Load() != 2 {
+		t.Errorf("%d syncs ran, want the running one and the queued one", ran.Load())
+	}
+
+	// With the slot free, a direct sync can start again
+	if !s.beginSync() {
+		t.Fatal("sync slot still taken")
+	}
+	s.endSync()
+}
+
+func TestSyncMergesBatchesFromSeveralPeers(t *testing.T) {
+	const height = 3*int(syncBatchSize) + 20
+	source, chain, _ := testChains(t, height)
+
+	// Count the batches each peer serves; the last peer never returns any blocks
+	served := make([]atomic.Int32, 3)
+	local := NewP2PServer("127.0.0.1", 0, quietLogger())
+	var remotes []*P2PServer
+	for i := range served {
+		remote := servePeer(source, nil)
+		get := remote.messageHandlers[MsgTypeGetBlocks]
+		remote.RegisterHandler(MsgTypeGetBlocks, func(peer *Peer, msg *Message) error {
+			served[i].Add(1)
+			if i == len(served)-1 {
+				return remote.SendMessage(peer, &Message{Type: MsgTypeBlocks, Payload: &BlocksMessage{}})
+			}
+			return get(peer, msg)
+		})
+		connect(local, remote, fmt.Sprintf("192.0.2.%d:30303", i+10))
+		remotes = append(remotes, remote)
+	}
+	t.Cleanup(func() {
+		local.Stop()
+		for _, remote := range remotes {
+			remote.Stop()
+		}
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for local.PeerCount() < len(remotes) {
+		if time.Now().After(deadline) {
+			t.Fatal("peers did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	syncer := NewSyncer(chain, local, NewMempool(), quietLogger())
+	if err := syncer.SyncWithPeers(); err != nil {
+		t.Fatalf("SyncWithPeers: %v", err)
+	}
+
+	if chain.GetHeight() != uint64(height) || chain.GetCurrentBlock().HashString() != source.GetCurrentBlock().HashString() {
+		t.Fatalf("synced to height %d, want the source tip at %d", chain.GetHeight(), height)
+	}
+	if served[0].Load() == 0 || served[1].Load() == 0 {
+		t.Errorf("batches served = %d, %d; want both working peers used", served[0].Load(), served[1].Load())
+	}
+	if served[2].Load() > 1 {
+		t.Errorf("failing peer asked %d times, want it dropped after its first batch", served[2].Load())
+	}
+}
+
+func TestTipAnnounceTriggersSync(t *testing.T) {
+	source, chain, _ := testChains(t, int(syncBatchSize)+5)
+
+	remote := servePeer(source, nil)
+	local := NewP2PServer("127.0.0.1", 0, quietLogger())
+	syncer := NewSyncer(chain, local, NewMempool(), quietLogger())
+
+	// Sync from a peer announcing a tip ahead of ours, as the node does
+	local.RegisterHandler(MsgTypeTipAnnounce, func(peer *Peer, msg *Message) error {
+		payload, err := json.Marshal(msg.Payload)
+		if err != nil {
+			return err
+		}
+		var tip TipAnnounceMessage
+		if err := json.Unmarshal(payload, &tip); err != nil {
+			return err
+		}
+		if tip.Height > chain.GetHeight() {
+			syncer.TriggerSyncFromPeer(peer, tip.Height)
+		}
+		return nil
+	})
+
+	// The synced node announces its new tip in turn
+	announced := make(chan uint64, 1)
+	remote.RegisterHandler(MsgTypeTipAnnounce, func(peer *Peer, msg *Message) error {
+		payload, err := json.Marshal(msg.Payload)
+		if err != nil {
+			return err
+		}
+		var tip TipAnnounceMessage
+		if err := json.Unmarshal(payload, &tip); err != nil {
+			return err
+		}
+		select {
+		case announced <- tip.Height:
+		default:
+		}
+		return nil
+	})
+
+	connect(local, remote, "192.0.2.2:30303")
+	t.Cleanup(func() {
+		local.Stop()
+		remote.Stop()
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for local.PeerCount() < 1 || remote.PeerCount() < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("peers did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	NewSyncer(source, remote, NewMempool(), quietLogger()).AnnounceTip()
+
+	select {
+	case height := <-announced:
+		if height != source.GetHeight() {
+			t.Errorf("announced height = %d, want %d", height, source.GetHeight())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no tip announced after the sync; height %d of %d", chain.GetHeight(), source.GetHeight())
+	}
+	waitIdle(t, syncer)
+
+	if chain.GetHeight() != source.GetHeight() {
+		t.Errorf("height = %d, want %d", chain.GetHeight(), source.GetHeight())
+	}
+	if chain.GetCurrentBlock().HashString() != source.GetCurrentBlock().HashString() {
+		t.Error("synced tip differs from the announced chain")
+	}
+}
+
+func TestRebroadcastPending(t *testing.T) {
+	chain, _, _ := testChains(t, 0)
+	mempool := NewMempool()
+	tx := testTransaction(1, 0, 1)
+	if err := mempool.AddTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	local := NewP2PServer("127.0.0.1", 0, quietLogger())
+	remote := NewP2PServer("127.0.0.1", 0, quietLogger())
+	received := make(chan struct{}, 10)
+	remote.RegisterHandler(MsgTypeNewTransaction, func(peer *Peer, msg *Message) error {
+		received <- struct{}{}
+		return nil
+	})
+	connect(local, remote, "192.0.2.2:30303")
+	t.Cleanup(func() {
+		local.Stop()
+		remote.Stop()
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for local.PeerCount() < 1 || remote.PeerCount() < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("peers did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	syncer := NewSyncer(chain, local, mempool, quietLogger())
+	const interval, maxRebroadcasts = time.Minute, 2
+
+	// age pretends the transaction was last announced an interval ago
+	age := func() {
+		mempool.mu.Lock()
+		mempool.announced[string(tx.ID)].at = time.Now().Add(-interval)
+		mempool.mu.Unlock()
+	}
+	expect := func(want int) {
+		t.Helper()
+		got := 0
+		timeout := time.After(200 * time.Millisecond)
+		for {
+			select {
+			case <-received:
+				got++
+			case <-timeout:
+				if got != want {
+					t.Fatalf("peer received %d announcements, want %d", got, want)
+				}
+				return
+			}
+		}
+	}
+
+	// Not before the interval has passed since it was added
+	syncer.rebroadcastPending(interval, maxRebroadcasts)
+	expect(0)
+
+	for range maxRebroadcasts {
+		age()
+		syncer.rebroadcastPending(interval, maxRebroadcasts)
+		expect(1)
+
+		// Announcing it restarts the interval
+		syncer.rebroadcastPending(interval, maxRebroadcasts)
+		expect(0)
+	}
+
+	// Once rebroadcast the maximum number of times it is left alone
+	age()
+	syncer.rebroadcastPending(interval, maxRebroadcasts)
+	expect(0)
+	if !mempool.HasTransaction(tx.ID) {
+		t.Error("rebroadcasting removed the transaction from the mempool")
+	}
+}
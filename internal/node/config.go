@@ -3,12 +3,22 @@ package node
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"reflect"
+	"strings"
 	"time"
 
+	"github.com/podoru/podoru-chain/internal/api/websocket"
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/network"
+	"github.com/podoru/podoru-chain/internal/storage"
 	"github.com/spf13/viper"
 )
 
+// EnvPrefix prefixes the environment variables that override config keys
+const EnvPrefix = "PODORU"
+
 // Config holds node configuration
 type Config struct {
 	// Node identity
@@ -16,42 +26,160 @@ type Config struct {
 	Address    string   `mapstructure:"address"`
 	PrivateKey string   `mapstructure:"private_key"`
 
+	// PrivateKeyPassphraseFile holds the passphrase for a keystore-encrypted private_key
+	PrivateKeyPassphraseFile string `mapstructure:"private_key_passphrase_file"`
+
+	// ProducerKeys are key files of further authorities this node produces blocks for,
+	// for running several validators in one process when testing
+	ProducerKeys []string `mapstructure:"producer_keys"`
+
+	// Block signing: "file" (private_key, default) or "remote" (HTTP signer, key stays off the node)
+	Signer              SignerType    `mapstructure:"signer"`
+	RemoteSignerURL     string        `mapstructure:"remote_signer_url"`
+	RemoteSignerTimeout time.Duration `mapstructure:"remote_signer_timeout"`
+
 	// Network
+	ChainID        string   `mapstructure:"chain_id"` // Overrides the genesis chain_id; must match it when both are set
 	P2PPort        int      `mapstructure:"p2p_port"`
 	P2PBindAddr    string   `mapstructure:"p2p_bind_addr"`
 	BootstrapPeers []string `mapstructure:"bootstrap_peers"`
 	MaxPeers       int      `mapstructure:"max_peers"`
+	P2PCodec       string   `mapstructure:"p2p_codec"` // Wire codec requested from peers: "json" (default) or "gob"
+
+	// TipAnnounceInterval is how often the chain tip is gossiped to peers (0 disables)
+	TipAnnounceInterval time.Duration `mapstructure:"tip_announce_interval"`
+
+	// PeerDiscoveryInterval is how often peers are asked for their peers (0 disables)
+	PeerDiscoveryInterval time.Duration `mapstructure:"peer_discovery_interval"`
+
+	// Peers serving invalid blocks during sync are banned for the duration after this many faults (0 disables)
+	PeerBanThreshold int           `mapstructure:"peer_ban_threshold"`
+	PeerBanDuration  time.Duration `mapstructure:"peer_ban_duration"`
 
 	// API
-	APIEnabled  bool   `mapstructure:"api_enabled"`
-	APIPort     int    `mapstructure:"api_port"`
-	APIBindAddr string `mapstructure:"api_bind_addr"`
+	APIEnabled          bool   `mapstructure:"api_enabled"`
+	APIPort             int    `mapstructure:"api_port"`
+	APIBindAddr         string `mapstructure:"api_bind_addr"`
+	APIRESTEnabled      bool   `mapstructure:"api_rest_enabled"`
+	APIWebSocketEnabled bool   `mapstructure:"api_websocket_enabled"`
+	APIAuthToken        string `mapstructure:"api_auth_token"`         // Bearer token required on write endpoints (empty = open)
+	APIMaxResponseBytes int    `mapstructure:"api_max_response_bytes"` // Cap on streamed list responses (0 = unlimited)
+
+	// SignResponses signs every API response body with the producer key (X-Podoru-Signature header)
+	SignResponses bool `mapstructure:"sign_responses"`
+
+	// Browser origins allowed to open a WebSocket connection (empty = any)
+	WSAllowedOrigins []string `mapstructure:"ws_allowed_origins"`
+
+	// WebSocket client limits: largest message from a client, and events queued per client
+	WSMaxMessageSize int `mapstructure:"ws_max_message_size"`
+	WSSendBuffer     int `mapstructure:"ws_send_buffer"`
+
+	// Browser origins allowed to make credentialed CORS requests (others get a wildcard without credentials)
+	CORSAllowedOrigins []string `mapstructure:"cors_allowed_origins"`
+
+	// Request body caps (0 = unlimited): transaction submission, and the other POST endpoints
+	APIMaxTxBodyBytes    int `mapstructure:"api_max_tx_body_bytes"`
+	APIMaxQueryBodyBytes int `mapstructure:"api_max_query_body_bytes"`
+
+	// TLS for the API server (HTTPS/wss://); both must be set to enable
+	TLSCertFile string `mapstructure:"tls_cert_file"`
+	TLSKeyFile  string `mapstructure:"tls_key_file"`
+
+	// API rate limiting per client IP (0 disables)
+	RateLimitPerSecond float64 `mapstructure:"rate_limit_per_second"`
+	RateLimitBurst     int     `mapstructure:"rate_limit_burst"`
+	TrustProxy         bool    `mapstructure:"trust_proxy"` // Use the last X-Forwarded-For entry as the client IP
+
+	// Metrics
+	MetricsEnabled bool `mapstructure:"metrics_enabled"` // Serve Prometheus metrics at /metrics on the API port
+
+	// Mempool
+	MempoolBalanceCheck bool `mapstructure:"mempool_balance_check"` // Reject txs the sender can't afford with its pending txs
+	MempoolDedupWindow  int  `mapstructure:"mempool_dedup_window"`  // Recent blocks whose txs are rejected as replays (0 disables)
+
+	// Pending transactions are announced again after this long, up to the max times (0 disables)
+	MempoolRebroadcastInterval time.Duration `mapstructure:"mempool_rebroadcast_interval"`
+	MempoolMaxRebroadcasts     int           `mapstructure:"mempool_max_rebroadcasts"`
+
+	// How often transactions past their valid_until are dropped from the mempool (0 disables)
+	MempoolExpirySweepInterval time.Duration `mapstructure:"mempool_expiry_sweep_interval"`
 
 	// Storage
-	DataDir string `mapstructure:"data_dir"`
+	StorageBackend string         `mapstructure:"storage_backend"` // badger or memory
+	DataDir        string         `mapstructure:"data_dir"`
+	Indexing       IndexingConfig `mapstructure:"indexing"`
+
+	// BlockRetention is how many recent blocks are kept; older ones are pruned (0 keeps all)
+	BlockRetention uint64 `mapstructure:"block_retention"`
+
+	// VerifyChainOnStartup re-validates every stored block before the node starts serving
+	VerifyChainOnStartup bool `mapstructure:"verify_chain_on_startup"`
 
 	// Consensus
 	Authorities []string      `mapstructure:"authorities"`
 	BlockTime   time.Duration `mapstructure:"block_time"`
 
+	// BlockSizeSoftLimit caps the size of produced blocks (validation still allows the genesis max_block_size)
+	BlockSizeSoftLimit int `mapstructure:"block_size_soft_limit"`
+
 	// Genesis
 	GenesisPath string `mapstructure:"genesis_path"`
 }
 
+// IndexingConfig toggles the secondary transaction indexes written per block
+type IndexingConfig struct {
+	Sender   bool `mapstructure:"sender"`
+	Key      bool `mapstructure:"key"`
+	Transfer bool `mapstructure:"transfer"`
+}
+
 // LoadConfig loads configuration from a file
 func LoadConfig(configPath string) (*Config, error) {
 	v := viper.New()
 
 	// Set default values
 	v.SetDefault("node_type", "full")
+	v.SetDefault("signer", "file")
 	v.SetDefault("p2p_port", 9000)
 	v.SetDefault("p2p_bind_addr", "0.0.0.0")
+	v.SetDefault("p2p_codec", network.CodecJSON)
 	v.SetDefault("max_peers", 50)
+	v.SetDefault("tip_announce_interval", "5s")
+	v.SetDefault("peer_discovery_interval", "30s")
+	v.SetDefault("peer_ban_threshold", network.DefaultBanThreshold)
+	v.SetDefault("peer_ban_duration", network.DefaultBanDuration.String())
 	v.SetDefault("api_enabled", true)
 	v.SetDefault("api_port", 8545)
 	v.SetDefault("api_bind_addr", "0.0.0.0")
+	v.SetDefault("api_rest_enabled", true)
+	v.SetDefault("api_websocket_enabled", true)
+	v.SetDefault("api_max_response_bytes", 32*1024*1024)
+	v.SetDefault("api_max_tx_body_bytes", 2*1024*1024)
+	v.SetDefault("api_max_query_body_bytes", 256*1024)
+	v.SetDefault("ws_max_message_size", websocket.DefaultMaxMessageSize)
+	v.SetDefault("ws_send_buffer", websocket.DefaultSendBuffer)
+	v.SetDefault("metrics_enabled", false)
+	v.SetDefault("mempool_balance_check", true)
+	v.SetDefault("mempool_dedup_window", network.DefaultDedupWindow)
+	v.SetDefault("mempool_rebroadcast_interval", network.DefaultRebroadcastInterval.String())
+	v.SetDefault("mempool_max_rebroadcasts", network.DefaultMaxRebroadcasts)
+	v.SetDefault("mempool_expiry_sweep_interval", network.DefaultExpirySweepInterval.String())
+	v.SetDefault("storage_backend", storage.BackendBadger)
 	v.SetDefault("data_dir", "./data")
+	v.SetDefault("indexing.sender", true)
+	v.SetDefault("indexing.key", true)
+	v.SetDefault("indexing.transfer", true)
+	v.SetDefault("block_retention", 0)
 	v.SetDefault("block_time", "5s")
+	v.SetDefault("block_size_soft_limit", blockchain.DefaultBlockSizeSoftLimit)
+
+	// Environment variables override the file, e.g. PODORU_P2P_PORT for p2p_port
+	// and PODORU_INDEXING_SENDER for indexing.sender
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	bindEnvKeys(v, reflect.TypeOf(Config{}), "")
 
 	// Read config file
 	v.SetConfigFile(configPath)
@@ -61,6 +189,11 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
 
+	// slot_timeout moved to the genesis consensus params so every node agrees on it
+	if v.IsSet("slot_timeout") {
+		return nil, errors.New("slot_timeout is no longer a node setting; set consensus_params.slot_timeout in the genesis file instead")
+	}
+
 	var config Config
 	if err := v.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
@@ -74,6 +207,25 @@ func LoadConfig(configPath string) (*Config, error) {
 	return &config, nil
 }
 
+// bindEnvKeys registers every config key with viper, so environment variables are
+// picked up for keys that have neither a default nor a value in the file
+func bindEnvKeys(v *viper.Viper, t reflect.Type, prefix string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("mapstructure")
+		if key == "" {
+			continue
+		}
+		key = prefix + key
+
+		if field.Type.Kind() == reflect.Struct {
+			bindEnvKeys(v, field.Type, key+".")
+			continue
+		}
+		_ = v.BindEnv(key)
+	}
+}
+
 // Validate validates the configuration
 func (c *Config) Validate() error {
 	// Validate node type
@@ -86,13 +238,41 @@ func (c *Config) Validate() error {
 		if c.Address == "" {
 			return errors.New("address is required for producer nodes")
 		}
-		if c.PrivateKey == "" {
-			return errors.New("private_key is required for producer nodes")
-		}
 
-		// Check if private key file exists
-		if _, err := os.Stat(c.PrivateKey); os.IsNotExist(err) {
-			return fmt.Errorf("private key file not found: %s", c.PrivateKey)
+		switch c.Signer {
+		case SignerTypeFile:
+			if c.PrivateKey == "" {
+				return errors.New("private_key is required for producer nodes")
+			}
+
+			// Check if private key file exists
+			if _, err := os.Stat(c.PrivateKey); os.IsNotExist(err) {
+				return fmt.Errorf("private key file not found: %s", c.PrivateKey)
+			}
+
+			for _, path := range c.ProducerKeys {
+				if _, err := os.Stat(path); os.IsNotExist(err) {
+					return fmt.Errorf("producer key file not found: %s", path)
+				}
+			}
+
+			if c.PrivateKeyPassphraseFile != "" {
+				if _, err := os.Stat(c.PrivateKeyPassphraseFile); os.IsNotExist(err) {
+					return fmt.Errorf("private key passphrase file not found: %s", c.PrivateKeyPassphraseFile)
+				}
+			}
+		case SignerTypeRemote:
+			if c.RemoteSignerURL == "" {
+				return errors.New("remote_signer_url is required when signer is remote")
+			}
+			if len(c.ProducerKeys) > 0 {
+				return errors.New("producer_keys requires the file signer")
+			}
+			if c.RemoteSignerTimeout < 0 {
+				return errors.New("remote_signer_timeout cannot be negative")
+			}
+		default:
+			return fmt.Errorf("invalid signer: %s", c.Signer)
 		}
 	}
 
@@ -101,10 +281,85 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid p2p_port: %d", c.P2PPort)
 	}
 
+	if c.P2PCodec != "" && network.CodecByName(c.P2PCodec) == nil {
+		return fmt.Errorf("invalid p2p_codec: %s", c.P2PCodec)
+	}
+
 	if c.APIEnabled {
 		if c.APIPort <= 0 || c.APIPort > 65535 {
 			return fmt.Errorf("invalid api_port: %d", c.APIPort)
 		}
+		if !c.APIRESTEnabled && !c.APIWebSocketEnabled {
+			return errors.New("api_enabled requires api_rest_enabled or api_websocket_enabled")
+		}
+	}
+
+	if c.SignResponses && c.NodeType != NodeTypeProducer {
+		return errors.New("sign_responses requires a producer node, whose key signs the responses")
+	}
+
+	// WebSocket client limits (0 means default)
+	if c.WSMaxMessageSize != 0 && c.WSMaxMessageSize < websocket.MinMaxMessageSize {
+		return fmt.Errorf("ws_max_message_size must be at least %d bytes", websocket.MinMaxMessageSize)
+	}
+	if c.WSSendBuffer != 0 && c.WSSendBuffer < websocket.MinSendBuffer {
+		return fmt.Errorf("ws_send_buffer must be at least %d", websocket.MinSendBuffer)
+	}
+
+	if err := validateOrigins("ws_allowed_origins", c.WSAllowedOrigins); err != nil {
+		return err
+	}
+	if err := validateOrigins("cors_allowed_origins", c.CORSAllowedOrigins); err != nil {
+		return err
+	}
+
+	// Validate TLS files
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return errors.New("tls_cert_file and tls_key_file must be set together")
+	}
+	if c.TLSEnabled() {
+		if _, err := os.Stat(c.TLSCertFile); os.IsNotExist(err) {
+			return fmt.Errorf("tls cert file not found: %s", c.TLSCertFile)
+		}
+		if _, err := os.Stat(c.TLSKeyFile); os.IsNotExist(err) {
+			return fmt.Errorf("tls key file not found: %s", c.TLSKeyFile)
+		}
+	}
+
+	if c.MempoolDedupWindow < 0 {
+		return errors.New("mempool_dedup_window cannot be negative")
+	}
+
+	if !storage.IsValidBackend(c.StorageBackend) {
+		return fmt.Errorf("invalid storage_backend: %s", c.StorageBackend)
+	}
+
+	// Validate block retention (0 keeps all blocks)
+	if c.BlockRetention > 0 && c.BlockRetention < blockchain.MinBlockRetention {
+		return fmt.Errorf("block_retention must be 0 or at least %d", blockchain.MinBlockRetention)
+	}
+
+	// Validate mempool rebroadcast (0 disables)
+	if c.MempoolRebroadcastInterval < 0 {
+		return errors.New("mempool_rebroadcast_interval cannot be negative")
+	}
+	if c.MempoolMaxRebroadcasts < 0 {
+		return errors.New("mempool_max_rebroadcasts cannot be negative")
+	}
+	if c.MempoolExpirySweepInterval < 0 {
+		return errors.New("mempool_expiry_sweep_interval cannot be negative")
+	}
+
+	if c.APIMaxResponseBytes < 0 {
+		return errors.New("api_max_response_bytes cannot be negative")
+	}
+
+	if c.APIMaxTxBodyBytes < 0 || c.APIMaxQueryBodyBytes < 0 {
+		return errors.New("api_max_tx_body_bytes and api_max_query_body_bytes cannot be negative")
+	}
+
+	if c.RateLimitPerSecond < 0 || c.RateLimitBurst < 0 {
+		return errors.New("rate_limit_per_second and rate_limit_burst cannot be negative")
 	}
 
 	// Validate authorities
@@ -127,10 +382,69 @@ func (c *Config) Validate() error {
 		return errors.New("block_time must be positive")
 	}
 
+	// Validate block size soft limit
+	if c.BlockSizeSoftLimit <= 0 {
+		return errors.New("block_size_soft_limit must be positive")
+	}
+
+	// Validate tip announce interval (0 disables)
+	if c.TipAnnounceInterval < 0 {
+		return errors.New("tip_announce_interval cannot be negative")
+	}
+
+	// Validate peer discovery interval (0 disables)
+	if c.PeerDiscoveryInterval < 0 {
+		return errors.New("peer_discovery_interval cannot be negative")
+	}
+
+	// Validate peer banning (threshold 0 disables)
+	if c.PeerBanThreshold < 0 {
+		return errors.New("peer_ban_threshold cannot be negative")
+	}
+	if c.PeerBanThreshold > 0 && c.PeerBanDuration <= 0 {
+		return errors.New("peer_ban_duration must be positive when peer_ban_threshold is set")
+	}
+
 	return nil
 }
 
+// validateOrigins checks that every origin has the form of a browser's Origin header,
+// scheme://host[:port]
+func validateOrigins(name string, origins []string) error {
+	for _, origin := range origins {
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" {
+			return fmt.Errorf("invalid %s entry %q: expected scheme://host[:port]", name, origin)
+		}
+	}
+	return nil
+}
+
+// LoadPrivateKeyPassphrase reads the private key passphrase file (empty if not configured)
+func (c *Config) LoadPrivateKeyPassphrase() (string, error) {
+	if c.PrivateKeyPassphraseFile == "" {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(c.PrivateKeyPassphraseFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase file: %w", err)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
 // IsProducer returns true if this is a producer node
 func (c *Config) IsProducer() bool {
 	return c.NodeType == NodeTypeProducer
 }
+
+// IsLight returns true if this is a light node
+func (c *Config) IsLight() bool {
+	return c.NodeType == NodeTypeLight
+}
+
+// TLSEnabled returns true if the API server should serve TLS
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
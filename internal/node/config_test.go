@@ -0,0 +1,119 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/podoru/podoru-chain/internal/api/websocket"
+)
+
+// writeConfig writes a full node config with the given extra YAML lines, and an
+// empty genesis file for it to point at, into a temporary directory
+func writeConfig(t *testing.T, extra string) string {
+	t.Helper()
+	dir := t.TempDir()
+	genesisPath := filepath.Join(dir, "genesis.json")
+	if err := os.WriteFile(genesisPath, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	content := "node_type: full\n" +
+		"authorities: [\"0x1000000000000000000000000000000000000001\"]\n" +
+		"genesis_path: " + genesisPath + "\n" + extra
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigRejectsSlotTimeout(t *testing.T) {
+	if _, err := LoadConfig(writeConfig(t, "block_time: 1m\n")); err != nil {
+		t.Fatalf("config with a long block_time rejected: %v", err)
+	}
+
+	// slot_timeout moved to genesis; a node config still setting it is not silently ignored
+	if _, err := LoadConfig(writeConfig(t, "slot_timeout: 30s\n")); err == nil {
+		t.Fatal("config with the removed slot_timeout key accepted")
+	}
+}
+
+func TestLoadConfigEnvOverrides(t *testing.T) {
+	path := writeConfig(t, "p2p_port: 9100\n"+
+		"data_dir: /var/lib/podoru\n"+
+		"bootstrap_peers: [\"10.0.0.1:9000\"]\n"+
+		"indexing:\n  sender: true\n")
+
+	t.Setenv(EnvPrefix+"_P2P_PORT", "9200")
+	t.Setenv(EnvPrefix+"_BOOTSTRAP_PEERS", "10.0.0.2:9000,10.0.0.3:9000")
+	t.Setenv(EnvPrefix+"_INDEXING_SENDER", "false")
+	t.Setenv(EnvPrefix+"_BLOCK_TIME", "2s")
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	// The environment beats the file, the file beats the defaults
+	if config.P2PPort != 9200 {
+		t.Errorf("p2p_port = %d, want 9200 from the environment", config.P2PPort)
+	}
+	if want := []string{"10.0.0.2:9000", "10.0.0.3:9000"}; !slices.Equal(config.BootstrapPeers, want) {
+		t.Errorf("bootstrap_peers = %q, want %q", config.BootstrapPeers, want)
+	}
+	if config.Indexing.Sender {
+		t.Error("indexing.sender = true, want false from the environment")
+	}
+	if config.BlockTime != 2*time.Second {
+		t.Errorf("block_time = %v, want 2s from the environment", config.BlockTime)
+	}
+	if config.DataDir != "/var/lib/podoru" {
+		t.Errorf("data_dir = %q, want the file's value", config.DataDir)
+	}
+	if config.MaxPeers != 50 {
+		t.Errorf("max_peers = %d, want the default 50", config.MaxPeers)
+	}
+}
+
+func TestLoadConfigRejectsInvalidEnv(t *testing.T) {
+	tests := []struct {
+		name, key, value string
+	}{
+		{name: "non-numeric port", key: "P2P_PORT", value: "ninety"},
+		{name: "invalid duration", key: "BLOCK_TIME", value: "soon"},
+		{name: "invalid bool", key: "INDEXING_SENDER", value: "maybe"},
+		{name: "out of range port", key: "P2P_PORT", value: "70000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvPrefix+"_"+tt.key, tt.value)
+			if _, err := LoadConfig(writeConfig(t, "")); err == nil {
+				t.Errorf("%s_%s=%q accepted", EnvPrefix, tt.key, tt.value)
+			}
+		})
+	}
+}
+
+func TestLoadConfigWebSocketLimits(t *testing.T) {
+	config, err := LoadConfig(writeConfig(t, "ws_max_message_size: 8192\nws_send_buffer: 1024\n"))
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if config.WSMaxMessageSize != 8192 || config.WSSendBuffer != 1024 {
+		t.Errorf("limits = %d bytes and %d events, want 8192 and 1024", config.WSMaxMessageSize, config.WSSendBuffer)
+	}
+
+	for _, extra := range []string{
+		fmt.Sprintf("ws_max_message_size: %d\n", websocket.MinMaxMessageSize-1),
+		fmt.Sprintf("ws_send_buffer: %d\n", websocket.MinSendBuffer-1),
+	} {
+		if _, err := LoadConfig(writeConfig(t, extra)); err == nil {
+			t.Errorf("config with %q accepted", strings.TrimSpace(extra))
+		}
+	}
+}
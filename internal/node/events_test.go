@@ -0,0 +1,135 @@
+package node
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorillaws "github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"github.com/podoru/podoru-chain/internal/api/websocket"
+)
+
+// subscribeEvents serves n's WebSocket hub and subscribes a client to events. The
+// subscription also replays the tip block, which acknowledges it.
+func subscribeEvents(t *testing.T, n *Node, events ...websocket.EventType) *gorillaws.Conn {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	ws := websocket.NewServer(logger)
+	ws.GetHub().SetBlockSource(n.chain)
+	n.SetWebSocketHub(ws.GetHub())
+	ws.Start()
+	t.Cleanup(ws.Stop)
+
+	server := httptest.NewServer(http.HandlerFunc(ws.HandleWebSocket))
+	t.Cleanup(server.Close)
+	conn, _, err := gorillaws.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	tip := n.chain.GetHeight()
+	if err := conn.WriteJSON(&websocket.SubscribeMessage{
+		Action:     "subscribe",
+		Events:     append(events, websocket.EventNewBlock),
+		FromHeight: &tip,
+	}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if readEvent(t, conn, websocket.EventNewBlock) == nil {
+		t.Fatal("replayed block never arrived")
+	}
+	return conn
+}
+
+func TestChainUpdateEvent(t *testing.T) {
+	producer := newTestProducer(t)
+	n := newTestNode(t, producerConfig(t, producer, testGenesis(producer), ""))
+	conn := subscribeEvents(t, n, websocket.EventChainUpdate)
+
+	if err := n.produceBlock(); err != nil {
+		t.Fatalf("produceBlock: %v", err)
+	}
+	if n.chain.GetHeight() != 1 {
+		t.Fatalf("height = %d, want a block produced", n.chain.GetHeight())
+	}
+
+	data := readEvent(t, conn, websocket.EventChainUpdate)
+	if data == nil {
+		t.Fatal("no chain_update event after producing a block")
+	}
+	var update websocket.ChainUpdateEvent
+	if err := json.Unmarshal(data, &update); err != nil {
+		t.Fatalf("decoding event: %v", err)
+	}
+	if update.Height != 1 {
+		t.Errorf("height = %d, want 1", update.Height)
+	}
+	if want := n.chain.GetCurrentBlock().HashString(); update.CurrentHash != want {
+		t.Errorf("current hash = %s, want %s", update.CurrentHash, want)
+	}
+	if len(update.Authorities) != 1 || !strings.EqualFold(update.Authorities[0], producer.address) {
+		t.Errorf("authorities = %v, want [%s]", update.Authorities, producer.address)
+	}
+}
+
+// readEvent returns the data of the next event of type want, skipping others, or
+// nil if none arrives in time
+func readEvent(t *testing.T, conn *gorillaws.Conn, want websocket.EventType) json.RawMessage {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var event struct {
+			Type websocket.EventType `json:"type"`
+			Data json.RawMessage     `json:"data"`
+		}
+		if err := conn.ReadJSON(&event); err != nil {
+			return nil
+		}
+		if event.Type == want {
+			return event.Data
+		}
+	}
+}
+
+func TestTransactionEvents(t *testing.T) {
+	producer := newTestProducer(t)
+	n := newTestNode(t, producerConfig(t, producer, testGenesis(producer), ""))
+	conn := subscribeEvents(t, n, websocket.EventNewTransaction)
+
+	tx := signedTx(t, producer.key, 0, setOp("key", "v"))
+	if err := n.SubmitTransaction(tx); err != nil {
+		t.Fatalf("SubmitTransaction: %v", err)
+	}
+	if err := n.produceBlock(); err != nil {
+		t.Fatalf("produceBlock: %v", err)
+	}
+	if len(n.chain.GetCurrentBlock().Transactions) != 1 {
+		t.Fatal("transaction was not included")
+	}
+
+	// The transaction is announced as pending on submission, then as confirmed
+	// once it is in a block
+	for _, status := range []string{"pending", "confirmed"} {
+		data := readEvent(t, conn, websocket.EventNewTransaction)
+		if data == nil {
+			t.Fatalf("no %s transaction event", status)
+		}
+		var event websocket.TransactionEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			t.Fatalf("decoding event: %v", err)
+		}
+		if event.Status != status || event.Hash != tx.HashString() {
+			t.Errorf("event for %s is %s, want %s for %s", event.Hash, event.Status, status, tx.HashString())
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/network"
+)
+
+// startHeaderChain sets up the header chain a light node follows instead of full blocks
+func (n *Node) startHeaderChain() error {
+	genesis, err := n.chain.GetBlockByHeight(0)
+	if err != nil {
+		return fmt.Errorf("failed to load genesis block: %w", err)
+	}
+
+	n.headers = blockchain.NewHeaderChain(genesis, n.GetConfig().Authorities, n.chainID)
+	n.headers.SetProducerValidator(n.consensus.ValidateBlockProducer)
+	return nil
+}
+
+// startLightSync starts following the peers' header chains
+func (n *Node) startLightSync() error {
+	n.logger.Info("Initializing light syncer...")
+	n.light = network.NewLightSyncer(n.headers, n.p2pServer, n.logger)
+
+	go func() {
+		if err := n.light.SyncHeaders(); err != nil {
+			n.logger.Warnf("Initial header sync failed: %v", err)
+		}
+	}()
+
+	n.light.StartAutoSync()
+	return nil
+}
+
+// registerLightP2PHandlers registers the message handlers of a light node. Light
+// nodes hold no blocks or mempool, so they only follow new tips.
+func (n *Node) registerLightP2PHandlers() {
+	// Report the genesis-only chain height so peers never sync blocks from us
+	n.p2pServer.RegisterHandler(network.MsgTypeGetHeight, n.handleGetHeight)
+	n.p2pServer.RegisterHandler(network.MsgTypeNewBlock, n.handleLightNewBlock)
+	n.p2pServer.RegisterHandler(network.MsgTypeTipAnnounce, n.handleLightTipAnnounce)
+	n.p2pServer.RegisterHandler(network.MsgTypePing, n.handlePing)
+}
+
+// handleLightNewBlock appends the header of a gossiped block, or syncs headers if
+// the block does not extend the local tip
+func (n *Node) handleLightNewBlock(peer *network.Peer, msg *network.Message) error {
+	var newBlockMsg network.NewBlockMessage
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	if err := json.Unmarshal(payloadBytes, &newBlockMsg); err != nil {
+		return fmt.Errorf("failed to unmarshal new block message: %w", err)
+	}
+
+	block := newBlockMsg.Block
+	if block == nil || block.Header == nil {
+		return fmt.Errorf("block is nil")
+	}
+
+	height := n.headers.Height()
+	if block.Header.Height <= height {
+		return nil
+	}
+	if block.Header.Height > height+1 {
+		n.triggerHeaderSync()
+		return nil
+	}
+
+	if err := n.headers.AddHeaders([]*blockchain.SignedHeader{block.SignedHeader()}); err != nil {
+		return fmt.Errorf("failed to add header %d: %w", block.Header.Height, err)
+	}
+	return nil
+}
+
+// handleLightTipAnnounce syncs headers when a peer announces a higher tip
+func (n *Node) handleLightTipAnnounce(peer *network.Peer, msg *network.Message) error {
+	var tipMsg network.TipAnnounceMessage
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	if err := json.Unmarshal(payloadBytes, &tipMsg); err != nil {
+		return fmt.Errorf("failed to unmarshal tip announce message: %w", err)
+	}
+
+	if tipMsg.Height > n.headers.Height() {
+		n.triggerHeaderSync()
+	}
+	return nil
+}
+
+// triggerHeaderSync syncs headers in the background. Handlers run on the peer's read
+// loop, which has to stay free to deliver the sync responses.
+func (n *Node) triggerHeaderSync() {
+	go func() {
+		if err := n.light.SyncHeaders(); err != nil {
+			n.logger.Debugf("Header sync failed: %v", err)
+		}
+	}()
+}
+
+// handleGetHeaders serves signed block headers to light nodes
+func (n *Node) handleGetHeaders(peer *network.Peer, msg *network.Message) error {
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return err
+	}
+
+	var req network.GetHeadersMessage
+	if err := json.Unmarshal(payloadBytes, &req); err != nil {
+		return err
+	}
+
+	toHeight := req.ToHeight
+	if req.FromHeight <= toHeight && toHeight-req.FromHeight >= network.MaxHeadersPerRequest {
+		toHeight = req.FromHeight + network.MaxHeadersPerRequest - 1
+	}
+
+	headers := make([]*blockchain.SignedHeader, 0)
+	for h := req.FromHeight; h <= toHeight; h++ {
+		block, err := n.chain.GetBlockByHeight(h)
+		if err != nil {
+			break // No more blocks
+		}
+		headers = append(headers, block.SignedHeader())
+	}
+
+	n.logger.Debugf("Sending %d headers (height %d to %d) to peer %s", len(headers), req.FromHeight, toHeight, peer.ID)
+
+	response := &network.Message{
+		Type:    network.MsgTypeHeaders,
+		Payload: &network.HeadersMessage{Headers: headers},
+	}
+	return n.p2pServer.SendMessage(peer, response)
+}
+
+// handleGetStateProof serves a Merkle proof of a state key against the current tip
+func (n *Node) handleGetStateProof(peer *network.Peer, msg *network.Message) error {
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return err
+	}
+
+	var req network.GetStateProofMessage
+	if err := json.Unmarshal(payloadBytes, &req); err != nil {
+		return err
+	}
+
+	resp := &network.StateProofMessage{Key: req.Key}
+	proof, block, err := n.chain.ProveState(req.Key)
+	if err != nil {
+		resp.Height = n.chain.GetHeight()
+		resp.Error = err.Error()
+		if errors.Is(err, blockchain.ErrKeyNotFound) {
+			resp.Error = blockchain.ErrKeyNotFound.Error()
+		}
+	} else {
+		resp.Height = block.Header.Height
+		resp.Proof = proof
+	}
+
+	response := &network.Message{
+		Type:    network.MsgTypeStateProof,
+		Payload: resp,
+	}
+	return n.p2pServer.SendMessage(peer, response)
+}
+
+// GetChainID returns the chain ID this node serves
+func (n *Node) GetChainID() string {
+	return n.chainID
+}
+
+// GetHeaderChain returns the header chain of a light node, or nil on other node types
+func (n *Node) GetHeaderChain() *blockchain.HeaderChain {
+	return n.headers
+}
+
+// ProveState reads a state key on a light node through a Merkle proof from a full
+// peer, verified against the local header chain. It returns the value and the
+// height of the header it was proven against.
+func (n *Node) ProveState(key string) ([]byte, uint64, error) {
+	if n.light == nil {
+		return nil, 0, errors.New("state proofs are only requested by light nodes")
+	}
+	return n.light.ProveState(key)
+}
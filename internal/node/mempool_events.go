@@ -0,0 +1,110 @@
+package node
+
+import (
+	"sync"
+	"time"
+
+	"github.com/podoru/podoru-chain/internal/api/websocket"
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+// maxRecentMempoolHashes is how many recently added transactions a mempool update lists
+const maxRecentMempoolHashes = 10
+
+// mempoolUpdates throttles mempool_update events to at most one per interval. The
+// first change after a quiet interval is sent at once; later changes inside the
+// interval are coalesced into one event sent when it ends.
+type mempoolUpdates struct {
+	mu       sync.Mutex
+	interval time.Duration
+	recent   []*blockchain.Transaction // Most recently added transactions, oldest first
+	lastSent time.Time
+	timer    *time.Timer // Pending coalesced event, if any
+	stopped  bool
+	emit     func(recent []*blockchain.Transaction)
+}
+
+// newMempoolUpdates creates a throttle that calls emit with the recently added transactions
+func newMempoolUpdates(interval time.Duration, emit func(recent []*blockchain.Transaction)) *mempoolUpdates {
+	return &mempoolUpdates{
+		interval: interval,
+		emit:     emit,
+	}
+}
+
+// added records a transaction added to the mempool and schedules an update
+func (mu *mempoolUpdates) added(tx *blockchain.Transaction) {
+	mu.mu.Lock()
+	mu.recent = append(mu.recent, tx)
+	if len(mu.recent) > maxRecentMempoolHashes {
+		mu.recent = mu.recent[len(mu.recent)-maxRecentMempoolHashes:]
+	}
+	mu.mu.Unlock()
+
+	mu.changed()
+}
+
+// changed schedules an update after the mempool changed
+func (mu *mempoolUpdates) changed() {
+	mu.mu.Lock()
+	if mu.stopped || mu.timer != nil {
+		mu.mu.Unlock()
+		return
+	}
+
+	if wait := mu.interval - time.Since(mu.lastSent); wait > 0 {
+		mu.timer = time.AfterFunc(wait, mu.flush)
+		mu.mu.Unlock()
+		return
+	}
+
+	mu.lastSent = time.Now()
+	recent := append([]*blockchain.Transaction{}, mu.recent...)
+	mu.mu.Unlock()
+
+	mu.emit(recent)
+}
+
+// flush sends the coalesced update when the interval ends
+func (mu *mempoolUpdates) flush() {
+	mu.mu.Lock()
+	mu.timer = nil
+	if mu.stopped {
+		mu.mu.Unlock()
+		return
+	}
+	mu.lastSent = time.Now()
+	recent := append([]*blockchain.Transaction{}, mu.recent...)
+	mu.mu.Unlock()
+
+	mu.emit(recent)
+}
+
+// stop cancels any pending update
+func (mu *mempoolUpdates) stop() {
+	mu.mu.Lock()
+	defer mu.mu.Unlock()
+
+	mu.stopped = true
+	if mu.timer != nil {
+		mu.timer.Stop()
+		mu.timer = nil
+	}
+}
+
+// broadcastMempoolEvent broadcasts the mempool size and the hashes of the recently
+// added transactions still pending, newest first, via WebSocket
+func (n *Node) broadcastMempoolEvent(recent []*blockchain.Transaction) {
+	if n.wsHub == nil || n.mempool == nil {
+		return
+	}
+
+	hashes := make([]string, 0, len(recent))
+	for i := len(recent) - 1; i >= 0; i-- {
+		if n.mempool.HasTransaction(recent[i].ID) {
+			hashes = append(hashes, recent[i].HashString())
+		}
+	}
+
+	n.wsHub.Broadcast(websocket.NewMempoolUpdateEvent(n.mempool.Count(), hashes))
+}
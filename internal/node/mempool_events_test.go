@@ -0,0 +1,60 @@
+package node
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+func TestMempoolUpdatesThrottle(t *testing.T) {
+	const interval = 200 * time.Millisecond
+	var mu sync.Mutex
+	var sent []time.Time
+	var lastRecent int
+	updates := newMempoolUpdates(interval, func(recent []*blockchain.Transaction) {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, time.Now())
+		lastRecent = len(recent)
+	})
+	emitted := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(sent)
+	}
+
+	// The first change is sent at once, the burst after it in one event at the end of the interval
+	start := time.Now()
+	for range 2 * maxRecentMempoolHashes {
+		updates.added(&blockchain.Transaction{})
+	}
+	if got := emitted(); got != 1 {
+		t.Fatalf("%d events sent right away, want 1", got)
+	}
+
+	time.Sleep(2 * interval)
+	mu.Lock()
+	if len(sent) != 2 {
+		t.Fatalf("%d events sent, want 2", len(sent))
+	}
+	if gap := sent[1].Sub(start); gap < interval {
+		t.Errorf("coalesced event sent %s after the first, before the %s interval", gap, interval)
+	}
+	if lastRecent != maxRecentMempoolHashes {
+		t.Errorf("coalesced event lists %d transactions, want the last %d", lastRecent, maxRecentMempoolHashes)
+	}
+	mu.Unlock()
+
+	// After a quiet interval the next change is sent at once again, and a pending
+	// coalesced event is dropped by stop
+	time.Sleep(interval)
+	updates.added(&blockchain.Transaction{})
+	updates.added(&blockchain.Transaction{})
+	updates.stop()
+	time.Sleep(2 * interval)
+	if got := emitted(); got != 3 {
+		t.Errorf("%d events sent, want 3", got)
+	}
+}
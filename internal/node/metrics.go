@@ -0,0 +1,86 @@
+package node
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the Prometheus metrics exported by a node
+type Metrics struct {
+	registry *prometheus.Registry
+
+	ChainHeight            prometheus.Gauge
+	MempoolSize            prometheus.Gauge
+	PeerCount              prometheus.Gauge
+	BlocksProduced         prometheus.Counter
+	BlocksReceived         prometheus.Counter
+	TransactionsApplied    prometheus.Counter
+	TransactionsSubmitted  prometheus.Counter
+	ChainReorgs            prometheus.Counter
+	BlockProductionSeconds prometheus.Histogram
+}
+
+// NewMetrics creates node metrics registered on their own registry
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		ChainHeight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "podoru_chain_height",
+			Help: "Height of the latest block in the local chain.",
+		}),
+		MempoolSize: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "podoru_mempool_size",
+			Help: "Number of pending transactions in the mempool.",
+		}),
+		PeerCount: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "podoru_peers",
+			Help: "Number of connected P2P peers.",
+		}),
+		BlocksProduced: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "podoru_blocks_produced_total",
+			Help: "Blocks produced by this node.",
+		}),
+		BlocksReceived: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "podoru_blocks_received_total",
+			Help: "Blocks received from peers and added to the chain.",
+		}),
+		TransactionsApplied: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "podoru_transactions_applied_total",
+			Help: "Transactions applied in blocks added to the chain.",
+		}),
+		TransactionsSubmitted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "podoru_transactions_submitted_total",
+			Help: "Transactions accepted into the mempool through the API.",
+		}),
+		ChainReorgs: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "podoru_chain_reorgs_total",
+			Help: "Times the chain switched to a competing branch.",
+		}),
+		BlockProductionSeconds: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "podoru_block_production_seconds",
+			Help:    "Time taken to build, sign and apply a produced block.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.ChainHeight,
+		m.MempoolSize,
+		m.PeerCount,
+		m.BlocksProduced,
+		m.BlocksReceived,
+		m.TransactionsApplied,
+		m.TransactionsSubmitted,
+		m.ChainReorgs,
+		m.BlockProductionSeconds,
+	)
+
+	return m
+}
+
+// Handler returns an HTTP handler serving the metrics in Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
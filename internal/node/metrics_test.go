@@ -0,0 +1,43 @@
+package node
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	producer := newTestProducer(t)
+	n := newTestNode(t, producerConfig(t, producer, testGenesis(producer), "metrics_enabled: true\n"))
+
+	if err := n.SubmitTransaction(signedTx(t, producer.key, 0, setOp("key", "v"))); err != nil {
+		t.Fatalf("SubmitTransaction: %v", err)
+	}
+	if err := n.produceBlock(); err != nil {
+		t.Fatalf("produceBlock: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	n.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	body := rec.Body.String()
+	for _, sample := range []string{
+		"podoru_chain_height 1",
+		"podoru_mempool_size 0",
+		"podoru_peers 0",
+		"podoru_blocks_produced_total 1",
+		"podoru_blocks_received_total 0",
+		"podoru_transactions_applied_total 1",
+		"podoru_transactions_submitted_total 1",
+		"podoru_chain_reorgs_total 0",
+		"podoru_block_production_seconds_count 1",
+	} {
+		if !strings.Contains(body, sample+"\n") {
+			t.Errorf("metrics missing %q", sample)
+		}
+	}
+}
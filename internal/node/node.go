@@ -1,9 +1,16 @@
 package node
 
 import (
-	"crypto/ecdsa"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/podoru/podoru-chain/internal/api/websocket"
@@ -15,19 +22,39 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// Version is the node software version
+const Version = "1.0.0"
+
+// nodeKeyFile is the file in the data directory holding the P2P identity key
+const nodeKeyFile = "node.key"
+
 // Node represents a blockchain node
 type Node struct {
-	config     *Config
-	logger     *logrus.Logger
-	storage    *storage.BadgerStore
-	chain      *blockchain.Chain
-	consensus  *consensus.PoAEngine
-	p2pServer  *network.P2PServer
-	mempool    *network.Mempool
-	syncer     *network.Syncer
-	privateKey *ecdsa.PrivateKey
-	wsHub      *websocket.Hub
-	stopChan   chan struct{}
+	config    atomic.Pointer[Config] // Replaced, never modified, by Reload
+	logger    *logrus.Logger
+	storage   blockchain.Storage
+	chain     *blockchain.Chain
+	consensus *consensus.PoAEngine
+	p2pServer *network.P2PServer
+	mempool   *network.Mempool
+	syncer    *network.Syncer
+	headers   *blockchain.HeaderChain // Light nodes only
+	light     *network.LightSyncer    // Light nodes only
+	signers   []crypto.Signer         // Producer keys, the configured address's first
+	wsHub     *websocket.Hub
+	metrics   *Metrics
+	startup   *startupTracker
+	receipts  *receiptWaiters
+	mpUpdates *mempoolUpdates    // Throttled mempool_update WebSocket events
+	seenTxs   *network.SeenCache // Transactions already gossiped to peers
+	pruning   atomic.Bool        // Set while old blocks are being pruned
+	producing atomic.Bool        // Set while the block production loop runs
+	stopChan  chan struct{}
+	loops     sync.WaitGroup // Background loops that must finish before storage is closed
+	reloadMu  sync.Mutex     // Serializes Reload
+
+	chainID     string // Chain ID from config or genesis, sent in the peer handshake
+	genesisHash []byte
 }
 
 // NewNode creates a new blockchain node
@@ -36,83 +63,342 @@ func NewNode(config *Config) (*Node, error) {
 	logger.SetLevel(logrus.InfoLevel)
 
 	node := &Node{
-		config:   config,
 		logger:   logger,
+		metrics:  NewMetrics(),
+		startup:  newStartupTracker(),
+		receipts: newReceiptWaiters(),
+		seenTxs:  network.NewSeenCache(network.DefaultSeenCacheSize),
 		stopChan: make(chan struct{}),
 	}
+	node.config.Store(config)
+	node.mpUpdates = newMempoolUpdates(config.BlockTime, node.broadcastMempoolEvent)
 
-	// Load private key if this is a producer node
+	// Set up the block signers if this is a producer node
 	if config.IsProducer() {
-		privateKey, err := crypto.LoadPrivateKeyFromFile(config.PrivateKey)
+		signer, err := newSigner(config)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load private key: %w", err)
+			return nil, err
 		}
-		node.privateKey = privateKey
 
 		// Verify address matches
-		derivedAddr, err := crypto.AddressFromPrivateKey(privateKey)
+		if crypto.NormalizeAddress(signer.Address()) != crypto.NormalizeAddress(config.Address) {
+			return nil, fmt.Errorf("address mismatch: config=%s, signer=%s", config.Address, signer.Address())
+		}
+
+		extra, err := newProducerKeySigners(config)
 		if err != nil {
-			return nil, fmt.Errorf("failed to derive address: %w", err)
+			return nil, err
 		}
-		if crypto.NormalizeAddress(derivedAddr) != crypto.NormalizeAddress(config.Address) {
-			return nil, fmt.Errorf("address mismatch: config=%s, derived=%s", config.Address, derivedAddr)
+		node.signers = append([]crypto.Signer{signer}, extra...)
+		if dup := blockchain.FindDuplicateAuthority(node.producerAddresses()); dup != "" {
+			return nil, fmt.Errorf("duplicate producer key for %s", dup)
 		}
 	}
 
 	return node, nil
 }
 
-// Start starts the node
+// newSigner creates the block signer selected by the config
+func newSigner(config *Config) (crypto.Signer, error) {
+	if config.Signer == SignerTypeRemote {
+		signer, err := crypto.NewRemoteSigner(config.RemoteSignerURL, config.Address, config.RemoteSignerTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create remote signer: %w", err)
+		}
+		return signer, nil
+	}
+
+	passphrase, err := config.LoadPrivateKeyPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	return newKeySigner(config.PrivateKey, passphrase)
+}
+
+// newProducerKeySigners creates a signer for each of the config's producer_keys
+func newProducerKeySigners(config *Config) ([]crypto.Signer, error) {
+	if len(config.ProducerKeys) == 0 {
+		return nil, nil
+	}
+
+	passphrase, err := config.LoadPrivateKeyPassphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	signers := make([]crypto.Signer, 0, len(config.ProducerKeys))
+	for _, path := range config.ProducerKeys {
+		signer, err := newKeySigner(path, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("producer key %s: %w", path, err)
+		}
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+// newKeySigner loads a private key file into a signer
+func newKeySigner(path, passphrase string) (crypto.Signer, error) {
+	// Accepts both keystore JSON and plaintext hex key files
+	privateKey, err := crypto.LoadPrivateKey(path, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load private key: %w", err)
+	}
+
+	signer, err := crypto.NewKeySigner(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive address: %w", err)
+	}
+	return signer, nil
+}
+
+// producerAddresses returns the addresses this node produces blocks for
+func (n *Node) producerAddresses() []string {
+	addresses := make([]string, len(n.signers))
+	for i, signer := range n.signers {
+		addresses[i] = signer.Address()
+	}
+	return addresses
+}
+
+// Start starts the node, running each startup stage in order and recording its outcome
 func (n *Node) Start() error {
-	n.logger.Infof("Starting Podoru Chain node (type: %s)...", n.config.NodeType)
+	n.logger.Infof("Starting Podoru Chain node (type: %s)...", n.GetConfig().NodeType)
+
+	stages := []struct {
+		name string
+		run  func() error
+	}{
+		{StageStorage, n.startStorage},
+		{StageConsensus, n.startConsensus},
+		{StageChain, n.startChain},
+		{StageVerify, n.verifyChain},
+		{StageMempool, n.startMempool},
+		{StageP2P, n.startP2P},
+		{StageSync, n.startSync},
+		{StageProduction, n.startProduction},
+	}
 
-	// Initialize storage
+	for _, stage := range stages {
+		if (stage.name == StageProduction && !n.GetConfig().IsProducer()) ||
+			(stage.name == StageVerify && (!n.GetConfig().VerifyChainOnStartup || n.GetConfig().IsLight())) ||
+			(stage.name == StageMempool && n.GetConfig().IsLight()) {
+			n.startup.skip(stage.name)
+			continue
+		}
+
+		if err := n.startup.run(stage.name, stage.run); err != nil {
+			n.logger.Errorf("Node startup failed: %v", err)
+			return err
+		}
+	}
+
+	n.startup.markReady()
+	n.logger.Info("Node started successfully")
+	return nil
+}
+
+// startStorage opens the database
+func (n *Node) startStorage() error {
 	n.logger.Info("Initializing storage...")
-	store, err := storage.NewBadgerStore(n.config.DataDir)
+	backend := n.GetConfig().StorageBackend
+	if n.GetConfig().IsLight() {
+		// Light nodes keep only the genesis block, to derive the handshake's genesis hash
+		backend = storage.BackendMemory
+	}
+	store, err := storage.Open(backend, n.GetConfig().DataDir)
 	if err != nil {
 		return fmt.Errorf("failed to initialize storage: %w", err)
 	}
 	n.storage = store
+	if indexed, ok := store.(storage.IndexConfigurable); ok {
+		indexed.SetIndexConfig(&blockchain.IndexConfig{
+			Sender:   n.GetConfig().Indexing.Sender,
+			Key:      n.GetConfig().Indexing.Key,
+			Transfer: n.GetConfig().Indexing.Transfer,
+		})
+	}
+	if backend == storage.BackendMemory {
+		if !n.GetConfig().IsLight() {
+			n.logger.Warn("Using in-memory storage; chain data will be lost on shutdown")
+		}
 
-	// Initialize consensus
+		// The data directory still holds the node key
+		if err := os.MkdirAll(n.GetConfig().DataDir, 0700); err != nil {
+			return fmt.Errorf("failed to create data directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// startConsensus creates the consensus engine
+func (n *Node) startConsensus() error {
 	n.logger.Info("Initializing consensus engine...")
-	consensusEngine, err := consensus.NewPoAEngine(n.config.Authorities, n.config.BlockTime)
+	consensusEngine, err := consensus.NewPoAEngine(n.GetConfig().Authorities, n.GetConfig().BlockTime)
 	if err != nil {
 		return fmt.Errorf("failed to initialize consensus: %w", err)
 	}
 	n.consensus = consensusEngine
+	return nil
+}
 
-	// Initialize blockchain
+// startChain loads the blockchain or creates it from genesis
+func (n *Node) startChain() error {
 	n.logger.Info("Initializing blockchain...")
-	n.chain = blockchain.NewChain(n.storage, n.config.Authorities)
+	n.chain = blockchain.NewChain(n.storage, n.GetConfig().Authorities)
+	n.chain.SetBlockCommitHandler(func(block *blockchain.Block) {
+		if n.mempool != nil {
+			n.mempool.RecordMinedBlock(block)
+		}
+		n.receipts.notify(block, n.chain.GetGasConfig())
+		n.maybePrune(block)
+	})
+	n.chain.SetProducerValidator(n.consensus.ValidateBlockProducer)
+	n.chain.SetReorgHandler(n.handleReorg)
 
 	// Try to load existing chain or create genesis
 	if err := n.initializeChain(); err != nil {
 		return fmt.Errorf("failed to initialize chain: %w", err)
 	}
 
-	// Initialize mempool
+	if n.GetConfig().IsLight() {
+		return n.startHeaderChain()
+	}
+
+	n.metrics.ChainHeight.Set(float64(n.chain.GetHeight()))
+	return nil
+}
+
+// maybePrune prunes old blocks in the background every StateSnapshotInterval blocks
+// when block_retention is set
+func (n *Node) maybePrune(block *blockchain.Block) {
+	if n.GetConfig().BlockRetention == 0 || block.Header.Height%blockchain.StateSnapshotInterval != 0 {
+		return
+	}
+	if !n.pruning.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer n.pruning.Store(false)
+		if err := n.chain.PruneBlocks(n.GetConfig().BlockRetention); err != nil {
+			n.logger.Warnf("Failed to prune old blocks: %v", err)
+			return
+		}
+		n.logger.Debugf("Blocks below height %d are pruned", n.chain.PrunedBelow())
+	}()
+}
+
+// verifyChain re-validates every stored block before the node serves anything
+func (n *Node) verifyChain() error {
+	n.logger.Infof("Verifying chain from genesis to height %d...", n.chain.GetHeight())
+	if err := n.chain.VerifyChain(); err != nil {
+		return err
+	}
+	n.logger.Info("Chain verification passed")
+	return nil
+}
+
+// startMempool creates the mempool
+func (n *Node) startMempool() error {
 	n.logger.Info("Initializing mempool...")
 	n.mempool = network.NewMempool()
+	n.mempool.SetLimits(n.chain.GetConsensusParams())
+	if n.GetConfig().MempoolBalanceCheck {
+		n.mempool.SetBalanceCheck(n.chain.GetBalance, func(tx *blockchain.Transaction) *big.Int {
+			return blockchain.TransactionCost(tx, n.chain.GetGasConfig())
+		})
+	}
+
+	// Remember recently mined transactions so replays are rejected after a restart too
+	n.mempool.SetDedupWindow(n.GetConfig().MempoolDedupWindow)
+	height := n.chain.GetHeight()
+	from := uint64(0)
+	if window := uint64(n.GetConfig().MempoolDedupWindow); height+1 >= window {
+		from = height + 1 - window
+	}
+	from = max(from, n.chain.PrunedBelow())
+	for h := from; h <= height; h++ {
+		block, err := n.chain.GetBlockByHeight(h)
+		if err != nil {
+			return fmt.Errorf("failed to load block %d for mempool dedup window: %w", h, err)
+		}
+		n.mempool.RecordMinedBlock(block)
+	}
+
+	// Drop transactions that expire while pending
+	if n.GetConfig().MempoolExpirySweepInterval > 0 {
+		n.loops.Add(1)
+		go n.expirySweepLoop(n.GetConfig().MempoolExpirySweepInterval)
+	}
+	return nil
+}
 
-	// Initialize P2P server
+// expirySweepLoop periodically drops transactions past their valid_until from the mempool
+func (n *Node) expirySweepLoop(interval time.Duration) {
+	defer n.loops.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.stopChan:
+			return
+		case <-ticker.C:
+			expired := n.mempool.RemoveExpired(time.Now().Unix())
+			if len(expired) == 0 {
+				continue
+			}
+			n.logger.Debugf("Dropped %d expired transactions from mempool", len(expired))
+			n.metrics.MempoolSize.Set(float64(n.mempool.Count()))
+			n.mpUpdates.changed()
+		}
+	}
+}
+
+// startP2P starts the P2P server and dials the bootstrap peers
+func (n *Node) startP2P() error {
 	n.logger.Info("Initializing P2P network...")
-	n.p2pServer = network.NewP2PServer(n.config.P2PBindAddr, n.config.P2PPort, n.logger)
+	n.p2pServer = network.NewP2PServer(n.GetConfig().P2PBindAddr, n.GetConfig().P2PPort, n.logger)
+
+	nodeKey, err := network.LoadOrCreateNodeKey(filepath.Join(n.GetConfig().DataDir, nodeKeyFile))
+	if err != nil {
+		return err
+	}
+	n.p2pServer.SetNodeKey(nodeKey)
+	n.logger.Infof("Node ID: %s", n.p2pServer.NodeID())
+
+	if codec := network.CodecByName(n.GetConfig().P2PCodec); codec != nil {
+		n.p2pServer.SetCodec(codec)
+	}
+	n.p2pServer.SetPeerChangeHandler(func(count int) {
+		n.metrics.PeerCount.Set(float64(count))
+	})
+	n.p2pServer.SetHelloFunc(n.localHello)
+	n.p2pServer.SetMaxPeers(n.GetConfig().MaxPeers)
+	n.p2pServer.SetBanPolicy(n.GetConfig().PeerBanThreshold, n.GetConfig().PeerBanDuration)
 	n.registerP2PHandlers()
 
 	if err := n.p2pServer.Start(); err != nil {
 		return fmt.Errorf("failed to start P2P server: %w", err)
 	}
 
-	// Connect to bootstrap peers
+	// Connect to bootstrap peers; they are re-dialed with backoff whenever they drop
 	n.logger.Info("Connecting to bootstrap peers...")
-	for _, peer := range n.config.BootstrapPeers {
-		if err := n.p2pServer.ConnectToPeer(peer); err != nil {
-			n.logger.Warnf("Failed to connect to bootstrap peer %s: %v", peer, err)
-		}
+	for _, peer := range n.GetConfig().BootstrapPeers {
+		n.p2pServer.AddPersistentPeer(peer)
+	}
+
+	n.p2pServer.StartDiscovery(n.GetConfig().PeerDiscoveryInterval)
+	return nil
+}
+
+// startSync starts catching up with peers
+func (n *Node) startSync() error {
+	if n.GetConfig().IsLight() {
+		return n.startLightSync()
 	}
 
-	// Initialize syncer
 	n.logger.Info("Initializing syncer...")
 	n.syncer = network.NewSyncer(n.chain, n.p2pServer, n.mempool, n.logger)
 
@@ -120,24 +406,63 @@ func (n *Node) Start() error {
 	n.logger.Info("Starting auto-sync...")
 	n.syncer.StartAutoSync()
 
-	// Start block production if this is a producer node
-	if n.config.IsProducer() {
-		n.logger.Info("Starting block production...")
-		go n.blockProductionLoop()
-	}
+	// Announce our tip so peers learn of new blocks between auto-syncs
+	n.syncer.StartTipAnnouncements(n.GetConfig().TipAnnounceInterval)
 
-	n.logger.Info("Node started successfully")
+	// Re-announce transactions stuck in the mempool
+	n.syncer.StartRebroadcast(n.GetConfig().MempoolRebroadcastInterval, n.GetConfig().MempoolMaxRebroadcasts)
+	return nil
+}
+
+// startProduction starts the block production loop
+func (n *Node) startProduction() error {
+	n.logger.Info("Starting block production...")
+	n.loops.Add(1)
+	go n.blockProductionLoop()
 	return nil
 }
 
+// GetStartupStatus returns how far node startup got and how long each stage took
+func (n *Node) GetStartupStatus() *StartupStatus {
+	return n.startup.snapshot()
+}
+
 // initializeChain initializes the blockchain (load or create genesis)
 func (n *Node) initializeChain() error {
 	// Load genesis config for gas and token configuration
-	genesisConfig, err := blockchain.LoadGenesisConfig(n.config.GenesisPath)
+	genesisConfig, err := blockchain.LoadGenesisConfig(n.GetConfig().GenesisPath)
 	if err != nil {
 		return fmt.Errorf("failed to load genesis config: %w", err)
 	}
 
+	n.chainID = genesisConfig.ChainID
+	if n.GetConfig().ChainID != "" {
+		if n.chainID != "" && n.chainID != n.GetConfig().ChainID {
+			return fmt.Errorf("chain_id %q does not match genesis chain_id %q", n.GetConfig().ChainID, n.chainID)
+		}
+		n.chainID = n.GetConfig().ChainID
+	}
+	n.chain.SetChainID(n.chainID)
+
+	// Block and mempool limits come from genesis so every node enforces the same ones
+	params := genesisConfig.GetConsensusParams()
+	n.chain.SetConsensusParams(params)
+
+	// So does the slot timeout, since it decides which authority may produce a block;
+	// without one in genesis it follows block_time
+	slotTimeout := params.SlotTimeoutFor(n.GetConfig().BlockTime)
+	if n.GetConfig().BlockTime > slotTimeout {
+		return fmt.Errorf("block_time %v exceeds the genesis slot_timeout %v; raise consensus_params.slot_timeout or lower block_time",
+			n.GetConfig().BlockTime, slotTimeout)
+	}
+	n.consensus.SetSlotTimeout(slotTimeout)
+	if n.GetConfig().BlockSizeSoftLimit > params.MaxBlockSize {
+		softLimit := params.MaxBlockSize * 9 / 10
+		n.logger.Warnf("block_size_soft_limit %d exceeds the genesis max_block_size %d, using %d",
+			n.GetConfig().BlockSizeSoftLimit, params.MaxBlockSize, softLimit)
+		n.GetConfig().BlockSizeSoftLimit = softLimit
+	}
+
 	// Set gas and token configuration
 	if genesisConfig.GasConfig != nil {
 		gasConfig := genesisConfig.GetGasConfig()
@@ -148,6 +473,11 @@ func (n *Node) initializeChain() error {
 		}
 	}
 
+	if genesisConfig.KeyPolicy != nil {
+		n.chain.SetKeyPolicy(genesisConfig.KeyPolicy)
+		n.logger.Infof("Key policy enabled: %s<address>: keys are writable by their owner only", blockchain.UserKeyPrefix)
+	}
+
 	if genesisConfig.TokenConfig != nil {
 		n.chain.SetTokenConfig(genesisConfig.TokenConfig)
 		n.logger.Infof("Token configured: %s (%s), decimals=%d",
@@ -175,13 +505,40 @@ func (n *Node) initializeChain() error {
 		}
 	} else {
 		n.logger.Infof("Loaded blockchain from storage (height: %d)", n.chain.GetHeight())
+
+		// Refuse to run against a genesis config that differs from the one the chain was created with
+		if err := n.chain.VerifyGenesis(genesisConfig); err != nil {
+			return fmt.Errorf("genesis config %s does not match stored chain (restore the original file or clear %s): %w",
+				n.GetConfig().GenesisPath, n.GetConfig().DataDir, err)
+		}
+	}
+
+	genesisBlock, err := n.chain.GetBlockByHeight(0)
+	if err != nil {
+		return fmt.Errorf("failed to load genesis block: %w", err)
 	}
+	n.genesisHash = genesisBlock.Hash()
 
 	return nil
 }
 
+// localHello builds the handshake message sent to new peers
+func (n *Node) localHello() *network.HelloMessage {
+	return &network.HelloMessage{
+		ChainID:     n.chainID,
+		Version:     network.ProtocolVersion,
+		GenesisHash: n.genesisHash,
+		Height:      n.chain.GetHeight(),
+	}
+}
+
 // registerP2PHandlers registers message handlers for P2P network
 func (n *Node) registerP2PHandlers() {
+	if n.GetConfig().IsLight() {
+		n.registerLightP2PHandlers()
+		return
+	}
+
 	// Handle new block messages
 	n.p2pServer.RegisterHandler(network.MsgTypeNewBlock, n.handleNewBlock)
 
@@ -194,6 +551,13 @@ func (n *Node) registerP2PHandlers() {
 	// Handle get height messages
 	n.p2pServer.RegisterHandler(network.MsgTypeGetHeight, n.handleGetHeight)
 
+	// Serve headers and state proofs to light nodes
+	n.p2pServer.RegisterHandler(network.MsgTypeGetHeaders, n.handleGetHeaders)
+	n.p2pServer.RegisterHandler(network.MsgTypeGetStateProof, n.handleGetStateProof)
+
+	// Handle tip announcements
+	n.p2pServer.RegisterHandler(network.MsgTypeTipAnnounce, n.handleTipAnnounce)
+
 	// Handle ping messages
 	n.p2pServer.RegisterHandler(network.MsgTypePing, n.handlePing)
 }
@@ -219,48 +583,113 @@ func (n *Node) handleNewBlock(peer *network.Peer, msg *network.Message) error {
 
 	currentBlock := n.chain.GetCurrentBlock()
 	currentHeight := currentBlock.Header.Height
+	expectedHeight := currentHeight + 1
 
-	// Check if block is already processed (stale)
-	if block.Header.Height <= currentHeight {
-		n.logger.Debugf("Ignoring block at height %d (current: %d)", block.Header.Height, currentHeight)
+	// Block is too far ahead - trigger sync instead of rejecting
+	if block.Header.Height > expectedHeight {
+		n.logger.Warnf("Block %d is ahead of current height %d, triggering sync...",
+			block.Header.Height, currentHeight)
+
+		// Trigger sync in background (non-blocking)
+		n.syncer.TriggerSync()
+
+		// Don't return error - this is expected behavior for catching up
 		return nil
 	}
 
-	// Check if block is the NEXT expected block
-	expectedHeight := currentHeight + 1
-	if block.Header.Height == expectedHeight {
-		// This is the next block - add it normally
-		if err := n.chain.AddBlock(block); err != nil {
-			n.logger.Errorf("Failed to add received block: %v", err)
-			return err
+	// The next block on our tip, or a competing block that may win the fork choice
+	if _, err := n.chain.ProcessBlock(block); err != nil {
+		switch {
+		case errors.Is(err, blockchain.ErrUnknownParent):
+			// Built on a branch we haven't seen yet; sync to fetch it
+			n.logger.Warnf("Block %d builds on an unknown parent, triggering sync...", block.Header.Height)
+			n.syncer.TriggerSync()
+			return nil
+		case errors.Is(err, blockchain.ErrForkTooDeep):
+			n.logger.Debugf("Ignoring block at height %d (current: %d)", block.Header.Height, currentHeight)
+			return nil
 		}
-		n.logger.Infof("Added block %d from peer (txs: %d)", block.Header.Height, len(block.Transactions))
-		n.mempool.RemoveTransactions(block.Transactions)
 
-		// Broadcast block event via WebSocket
-		n.broadcastBlockEvent(block)
+		n.logger.Errorf("Failed to add received block: %v", err)
+		return err
+	}
 
+	if !bytes.Equal(n.chain.GetCurrentBlock().Hash(), block.Hash()) {
+		n.logger.Debugf("Block %d from peer is not on the best chain (current: %d)", block.Header.Height, currentHeight)
 		return nil
 	}
 
-	// Block is too far ahead - trigger sync instead of rejecting
-	if block.Header.Height > expectedHeight {
-		n.logger.Warnf("Block %d is ahead of current height %d, triggering sync...",
-			block.Header.Height, currentHeight)
+	n.logger.Infof("Added block %d from peer (txs: %d)", block.Header.Height, len(block.Transactions))
+	n.mempool.RemoveTransactions(block.Transactions)
+	if len(block.Transactions) > 0 {
+		n.mpUpdates.changed()
+	}
 
-		// Trigger sync in background (non-blocking)
-		n.syncer.TriggerSync()
+	n.metrics.BlocksReceived.Inc()
+	n.metrics.TransactionsApplied.Add(float64(len(block.Transactions)))
+	n.metrics.ChainHeight.Set(float64(block.Header.Height))
+	n.metrics.MempoolSize.Set(float64(n.mempool.Count()))
 
-		// Don't return error - this is expected behavior for catching up
+	// Broadcast block event via WebSocket
+	n.broadcastBlockEvent(block)
+
+	// Let peers that haven't seen the block yet know our tip moved
+	n.syncer.AnnounceTip()
+
+	return nil
+}
+
+// handleReorg returns the transactions of blocks dropped from the canonical chain to the
+// mempool, skipping those whose nonce the new branch has used. Those the new branch also
+// includes are removed again as its blocks commit.
+func (n *Node) handleReorg(reorg *blockchain.Reorg) {
+	n.logger.Warnf("Chain reorganized at height %d: replaced %d block(s) with %d, new tip %d",
+		reorg.ForkHeight, len(reorg.Removed), len(reorg.Added),
+		reorg.Added[len(reorg.Added)-1].Header.Height)
+	n.metrics.ChainReorgs.Inc()
+
+	if n.mempool == nil {
+		return
+	}
+
+	for _, block := range reorg.Removed {
+		n.mempool.ForgetMinedBlock(block)
+		for _, tx := range block.Transactions {
+			if tx.IsGenesisTransaction() || tx.Nonce < n.chain.GetNonce(tx.From) {
+				continue
+			}
+			if err := n.mempool.AddTransaction(tx); err != nil {
+				n.logger.Debugf("Dropped transaction %x from orphaned block %d: %v", tx.ID, block.Header.Height, err)
+			}
+		}
+	}
+}
+
+// handleTipAnnounce syncs from a peer that announces a chain tip ahead of ours
+func (n *Node) handleTipAnnounce(peer *network.Peer, msg *network.Message) error {
+	var tipMsg network.TipAnnounceMessage
+	payloadBytes, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	if err := json.Unmarshal(payloadBytes, &tipMsg); err != nil {
+		return fmt.Errorf("failed to unmarshal tip announce message: %w", err)
+	}
+
+	currentHeight := n.chain.GetHeight()
+	if tipMsg.Height <= currentHeight {
 		return nil
 	}
 
+	n.logger.Infof("Peer %s announced tip %d (current: %d), syncing...", peer.ID, tipMsg.Height, currentHeight)
+	n.syncer.TriggerSyncFromPeer(peer, tipMsg.Height)
+
 	return nil
 }
 
-// handleNewTransaction handles incoming new transaction messages
+// handleNewTransaction adds a transaction gossiped by a peer to the mempool and
+// relays it to the other peers the first time it is accepted
 func (n *Node) handleNewTransaction(peer *network.Peer, msg *network.Message) error {
-	n.logger.Info("Received new transaction from peer")
 
 	// Convert payload to correct type (JSON unmarshaling creates map[string]interface{})
 	var newTxMsg network.NewTransactionMessage
@@ -277,6 +706,29 @@ func (n *Node) handleNewTransaction(peer *network.Peer, msg *network.Message) er
 		return fmt.Errorf("transaction is nil")
 	}
 
+	// The mempool and the seen cache are keyed by ID, so it has to be the hash
+	if err := tx.SetID(); err != nil {
+		n.logger.Debugf("Rejected transaction from peer %s: %v", peer.ID, err)
+		return nil
+	}
+
+	// Already accepted and relayed: this is an echo from another peer
+	if n.seenTxs.Has(string(tx.ID)) {
+		return nil
+	}
+	n.logger.Infof("Received new transaction %x from peer %s", tx.ID, peer.ID)
+
+	// Reject transactions signed for another chain
+	if err := blockchain.CheckTransactionChainID(tx, n.chainID); err != nil {
+		n.logger.Debugf("Rejected transaction %x: %v", tx.ID, err)
+		return nil
+	}
+
+	// Ignore transactions that are already confirmed
+	if included, err := n.chain.IsTransactionIncluded(tx.ID); err != nil || included {
+		return nil
+	}
+
 	// Validate balance for gas fees and transfers
 	if !tx.IsGenesisTransaction() {
 		senderBalance, err := n.chain.GetBalance(tx.From)
@@ -292,7 +744,7 @@ func (n *Node) handleNewTransaction(peer *network.Peer, msg *network.Message) er
 			}
 		}
 
-		if tx.HasTransferOperations() {
+		if tx.HasTransferOperations() || tx.HasBurnOperations() {
 			if err := blockchain.ValidateTransferBalance(tx, senderBalance, n.chain.GetGasConfig()); err != nil {
 				n.logger.Debugf("Transfer balance validation failed: %v", err)
 				return nil
@@ -302,7 +754,7 @@ func (n *Node) handleNewTransaction(peer *network.Peer, msg *network.Message) er
 
 	// Validate MINT operations
 	if tx.HasMintOperations() {
-		if err := blockchain.ValidateMintOperation(tx, n.config.Authorities); err != nil {
+		if err := blockchain.ValidateMintOperation(tx, n.chain.GetAuthoritySet()); err != nil {
 			n.logger.Debugf("MINT validation failed: %v", err)
 			return nil
 		}
@@ -315,9 +767,19 @@ func (n *Node) handleNewTransaction(peer *network.Peer, msg *network.Message) er
 	}
 
 	n.logger.Infof("Added transaction %x to mempool", tx.ID)
+	n.metrics.MempoolSize.Set(float64(n.mempool.Count()))
+
+	// Relay to everyone but the sender; the seen check stops it from looping back
+	if n.seenTxs.MarkSeen(string(tx.ID)) {
+		n.p2pServer.BroadcastMessageExcept(&network.Message{
+			Type:    network.MsgTypeNewTransaction,
+			Payload: &network.NewTransactionMessage{Transaction: tx},
+		}, peer)
+	}
 
-	// Broadcast transaction event via WebSocket
+	// Broadcast transaction and mempool events via WebSocket
 	n.broadcastTransactionEvent(tx, "pending")
+	n.mpUpdates.added(tx)
 
 	return nil
 }
@@ -337,22 +799,44 @@ func (n *Node) handleGetBlocks(peer *network.Peer, msg *network.Message) error {
 		return err
 	}
 
-	// Retrieve blocks
-	blocks := make([]*blockchain.Block, 0, req.ToHeight-req.FromHeight+1)
-	for h := req.FromHeight; h <= req.ToHeight; h++ {
+	// Clamp the range so one request cannot make us walk the whole chain
+	toHeight := req.ToHeight
+	if req.FromHeight <= toHeight && toHeight-req.FromHeight >= network.MaxBlocksPerRequest {
+		toHeight = req.FromHeight + network.MaxBlocksPerRequest - 1
+	}
+
+	// Stream the blocks in chunks that fit in a message, loading them as they are sent.
+	// A reversed range gets just the empty final chunk.
+	var chunk []*blockchain.Block
+	chunkSize, sent := 0, 0
+	for h := req.FromHeight; h <= toHeight; h++ {
 		block, err := n.chain.GetBlockByHeight(h)
 		if err != nil {
 			break // No more blocks
 		}
-		blocks = append(blocks, block)
+
+		size := block.Size()
+		if len(chunk) > 0 && chunkSize+size > network.MaxBlocksChunkSize {
+			if err := n.p2pServer.SendMessage(peer, &network.Message{
+				Type:    network.MsgTypeBlocks,
+				Payload: &network.BlocksMessage{Blocks: chunk, More: true},
+			}); err != nil {
+				return err
+			}
+			sent += len(chunk)
+			chunk, chunkSize = nil, 0
+		}
+		chunk = append(chunk, block)
+		chunkSize += size
 	}
+	sent += len(chunk)
 
-	n.logger.Infof("Sending %d blocks (height %d to %d) to peer %s", len(blocks), req.FromHeight, req.ToHeight, peer.ID)
+	n.logger.Infof("Sending %d blocks (height %d to %d) to peer %s", sent, req.FromHeight, toHeight, peer.ID)
 
-	// Send response
+	// The last chunk ends the response, even if it is empty
 	response := &network.Message{
 		Type:    network.MsgTypeBlocks,
-		Payload: &network.BlocksMessage{Blocks: blocks},
+		Payload: &network.BlocksMessage{Blocks: chunk},
 	}
 
 	return n.p2pServer.SendMessage(peer, response)
@@ -381,32 +865,71 @@ func (n *Node) handlePing(peer *network.Peer, msg *network.Message) error {
 	return n.p2pServer.SendMessage(peer, pong)
 }
 
-// blockProductionLoop runs the block production loop for producer nodes
+// minProductionDelay bounds how often the production loop re-evaluates the schedule
+const minProductionDelay = 100 * time.Millisecond
+
+// blockProductionLoop runs the block production loop for producer nodes.
+// Each wake-up is scheduled from the latest block's timestamp and the authority
+// rotation instead of a free-running ticker, so cadence does not drift.
 func (n *Node) blockProductionLoop() {
-	ticker := time.NewTicker(n.config.BlockTime)
-	defer ticker.Stop()
+	defer n.loops.Done()
+	n.producing.Store(true)
+	defer n.producing.Store(false)
+	timer := time.NewTimer(n.nextProductionDelay())
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-n.stopChan:
 			return
-		case <-ticker.C:
+		case <-timer.C:
 			if err := n.produceBlock(); err != nil {
 				n.logger.Errorf("Failed to produce block: %v", err)
 			}
+			timer.Reset(n.nextProductionDelay())
 		}
 	}
 }
 
+// nextProductionDelay returns how long to wait before trying to produce the next block,
+// for the producer key whose turn comes first. The wait is capped at one block time so
+// blocks received from peers in the meantime are taken into account.
+func (n *Node) nextProductionDelay() time.Duration {
+	currentBlock := n.chain.GetCurrentBlock()
+	now := time.Now()
+
+	var next time.Time
+	for i, signer := range n.signers {
+		t := n.consensus.NextProductionTime(currentBlock.Header.Height+1, signer.Address(),
+			currentBlock.Header.Timestamp, now)
+		if i == 0 || t.Before(next) {
+			next = t
+		}
+	}
+
+	delay := next.Sub(now)
+	if blockTime := n.consensus.GetBlockTime(); delay > blockTime {
+		delay = blockTime
+	}
+	if delay < minProductionDelay {
+		delay = minProductionDelay
+	}
+	return delay
+}
+
 // produceBlock produces a new block
 func (n *Node) produceBlock() error {
 	currentBlock := n.chain.GetCurrentBlock()
 	nextHeight := currentBlock.Header.Height + 1
 
-	// Check if it's our turn to produce
-	if !n.consensus.CanProduceBlock(nextHeight, n.config.Address) {
+	// Check if it's our turn to produce (a backup may take over a missed slot). The
+	// check uses the block's own second-resolution timestamp, as peers validating it will.
+	now := time.Unix(time.Now().Unix(), 0)
+	signer := n.slotSigner(nextHeight, currentBlock.Header.Timestamp, now)
+	if signer == nil {
 		return nil // Not our turn
 	}
+	producer := signer.Address()
 
 	// Check if enough time has passed
 	if !n.consensus.ShouldProduceBlock(currentBlock.Header.Timestamp) {
@@ -414,36 +937,54 @@ func (n *Node) produceBlock() error {
 	}
 
 	n.logger.Infof("Producing block at height %d...", nextHeight)
+	start := time.Now()
+
+	// Get pending transactions from mempool in canonical order, packing only up to the soft size limit
+	transactions := n.mempool.GetPendingTransactions(n.chain.GetConsensusParams().MaxTransactionsPerBlock)
+	transactions = dropExpired(transactions, now.Unix())
+	blockchain.SortTransactions(transactions)
+
+	// Leave out transactions the block couldn't apply, so one bad transaction doesn't
+	// stall production, and evict the ones that will never apply
+	transactions, rejected := n.chain.FilterApplicableTransactions(transactions)
+	for _, r := range rejected {
+		n.logger.Debugf("Evicting transaction %x from mempool: %v", r.Tx.ID, r.Err)
+		n.mempool.RemoveTransaction(r.Tx.ID)
+	}
+	if len(rejected) > 0 {
+		n.mpUpdates.changed()
+	}
 
-	// Get pending transactions from mempool
-	transactions := n.mempool.GetPendingTransactions(blockchain.MaxTransactionsPerBlock)
+	transactions = blockchain.SelectTransactionsBySize(transactions, n.GetConfig().BlockSizeSoftLimit)
 
-	// Calculate merkle root
-	merkleRoot := blockchain.CalculateMerkleRoot(transactions)
+	// Calculate merkle root with the tree of the chain's block version
+	version := n.chain.GetConsensusParams().BlockVersion
+	merkleRoot := blockchain.CalculateMerkleRoot(transactions, version)
 
 	// Calculate state root AFTER applying transactions
-	stateRoot, err := n.chain.CalculateStateRootWithTransactions(transactions)
+	stateRoot, err := n.chain.CalculateStateRootWithTransactions(transactions, producer)
 	if err != nil {
 		return fmt.Errorf("failed to calculate state root: %w", err)
 	}
 
 	// Create block header
 	header := &blockchain.BlockHeader{
-		Version:      1,
+		Version:      version,
 		Height:       nextHeight,
 		PreviousHash: currentBlock.Hash(),
-		Timestamp:    time.Now().Unix(),
+		Timestamp:    now.Unix(),
 		MerkleRoot:   merkleRoot,
 		StateRoot:    stateRoot,
-		ProducerAddr: n.config.Address,
+		ProducerAddr: producer,
 		Nonce:        0,
+		ChainID:      n.chainID,
 	}
 
 	// Create block
 	block := blockchain.NewBlock(header, transactions)
 
 	// Sign block
-	if err := block.Sign(n.privateKey); err != nil {
+	if err := block.Sign(signer); err != nil {
 		return fmt.Errorf("failed to sign block: %w", err)
 	}
 
@@ -454,6 +995,15 @@ func (n *Node) produceBlock() error {
 
 	// Remove transactions from mempool
 	n.mempool.RemoveTransactions(transactions)
+	if len(transactions) > 0 {
+		n.mpUpdates.changed()
+	}
+
+	n.metrics.BlockProductionSeconds.Observe(time.Since(start).Seconds())
+	n.metrics.BlocksProduced.Inc()
+	n.metrics.TransactionsApplied.Add(float64(len(transactions)))
+	n.metrics.ChainHeight.Set(float64(nextHeight))
+	n.metrics.MempoolSize.Set(float64(n.mempool.Count()))
 
 	// Broadcast block to peers
 	msg := &network.Message{
@@ -487,13 +1037,55 @@ func (n *Node) produceBlock() error {
 	return nil
 }
 
+// slotSigner returns the signer of the loaded producer key allowed to produce the
+// block at height, or nil if none is
+func (n *Node) slotSigner(height uint64, lastBlockTime int64, now time.Time) crypto.Signer {
+	for _, signer := range n.signers {
+		if n.consensus.CanProduceBlockAt(height, signer.Address(), lastBlockTime, now) {
+			return signer
+		}
+	}
+	return nil
+}
+
+// dropExpired filters out the transactions that would be expired in a block with the
+// given timestamp; the mempool sweeper removes them later
+func dropExpired(transactions []*blockchain.Transaction, timestamp int64) []*blockchain.Transaction {
+	live := transactions[:0]
+	for _, tx := range transactions {
+		if !tx.IsExpiredAt(timestamp) {
+			live = append(live, tx)
+		}
+	}
+	return live
+}
+
 // SubmitTransaction submits a transaction to the mempool
 func (n *Node) SubmitTransaction(tx *blockchain.Transaction) error {
+	// Index the transaction by its hash, never by a client-supplied ID
+	if err := tx.SetID(); err != nil {
+		return fmt.Errorf("invalid transaction: %w", err)
+	}
+
 	// Validate transaction
 	if err := tx.Validate(); err != nil {
 		return fmt.Errorf("invalid transaction: %w", err)
 	}
 
+	// Reject transactions signed for another chain
+	if err := blockchain.CheckTransactionChainID(tx, n.chainID); err != nil {
+		return fmt.Errorf("invalid transaction: %w", err)
+	}
+
+	// Reject resubmissions of confirmed transactions
+	included, err := n.chain.IsTransactionIncluded(tx.ID)
+	if err != nil {
+		return fmt.Errorf("failed to look up transaction: %w", err)
+	}
+	if included {
+		return fmt.Errorf("invalid transaction: %w", blockchain.ErrDuplicateTransaction)
+	}
+
 	// Validate balance if gas fees are enabled or if transaction has transfers
 	if !tx.IsGenesisTransaction() {
 		senderBalance, err := n.chain.GetBalance(tx.From)
@@ -508,8 +1100,8 @@ func (n *Node) SubmitTransaction(tx *blockchain.Transaction) error {
 			}
 		}
 
-		// Validate transfer balance (if any transfers)
-		if tx.HasTransferOperations() {
+		// Validate transfer balance (if any transfers or burns)
+		if tx.HasTransferOperations() || tx.HasBurnOperations() {
 			if err := blockchain.ValidateTransferBalance(tx, senderBalance, n.chain.GetGasConfig()); err != nil {
 				return err
 			}
@@ -518,7 +1110,7 @@ func (n *Node) SubmitTransaction(tx *blockchain.Transaction) error {
 
 	// Validate MINT operations
 	if tx.HasMintOperations() {
-		if err := blockchain.ValidateMintOperation(tx, n.config.Authorities); err != nil {
+		if err := blockchain.ValidateMintOperation(tx, n.chain.GetAuthoritySet()); err != nil {
 			return err
 		}
 	}
@@ -528,19 +1120,67 @@ func (n *Node) SubmitTransaction(tx *blockchain.Transaction) error {
 		return fmt.Errorf("failed to add to mempool: %w", err)
 	}
 
+	n.metrics.TransactionsSubmitted.Inc()
+	n.metrics.MempoolSize.Set(float64(n.mempool.Count()))
+
 	// Broadcast to peers
+	n.seenTxs.MarkSeen(string(tx.ID))
 	msg := &network.Message{
 		Type:    network.MsgTypeNewTransaction,
 		Payload: &network.NewTransactionMessage{Transaction: tx},
 	}
 	n.p2pServer.BroadcastMessage(msg)
 
-	// Broadcast transaction event via WebSocket
+	// Broadcast transaction and mempool events via WebSocket
 	n.broadcastTransactionEvent(tx, "pending")
+	n.mpUpdates.added(tx)
 
 	return nil
 }
 
+// GetConfig returns the node configuration. Reload swaps in a new copy instead of
+// changing it, so the returned config must be treated as read-only.
+func (n *Node) GetConfig() *Config {
+	return n.config.Load()
+}
+
+// NodeType returns the type of the node
+func (n *Node) NodeType() NodeType {
+	return n.GetConfig().NodeType
+}
+
+// Address returns the producer address of the node, or "" if it doesn't produce blocks
+func (n *Node) Address() string {
+	if !n.GetConfig().IsProducer() {
+		return ""
+	}
+	return n.GetConfig().Address
+}
+
+// Height returns the height of the node's chain, or of its header chain on a light node
+func (n *Node) Height() uint64 {
+	if n.headers != nil {
+		return n.headers.Height()
+	}
+	if n.chain == nil {
+		return 0
+	}
+	return n.chain.GetHeight()
+}
+
+// IsProducing reports whether the block production loop is running
+func (n *Node) IsProducing() bool {
+	return n.producing.Load()
+}
+
+// ResponseSigner returns the signer for API responses, or nil unless sign_responses is set
+func (n *Node) ResponseSigner() crypto.Signer {
+	if !n.GetConfig().SignResponses || len(n.signers) == 0 {
+		return nil
+	}
+	return n.signers[0]
+}
+
 // GetChain returns the blockchain
 func (n *Node) GetChain() *blockchain.Chain {
 	return n.chain
@@ -551,6 +1191,26 @@ func (n *Node) GetMempool() *network.Mempool {
 	return n.mempool
 }
 
+// GetMetrics returns the node metrics
+func (n *Node) GetMetrics() *Metrics {
+	return n.metrics
+}
+
+// MetricsHandler serves the node metrics, first refreshing the gauges that can
+// also change outside the instrumented paths (e.g. blocks added by the syncer)
+func (n *Node) MetricsHandler() http.Handler {
+	handler := n.metrics.Handler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n.chain != nil {
+			n.metrics.ChainHeight.Set(float64(n.chain.GetHeight()))
+		}
+		if n.mempool != nil {
+			n.metrics.MempoolSize.Set(float64(n.mempool.Count()))
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
 // GetP2PServer returns the P2P server
 func (n *Node) GetP2PServer() *network.P2PServer {
 	return n.p2pServer
@@ -561,11 +1221,23 @@ func (n *Node) SetWebSocketHub(hub *websocket.Hub) {
 	n.wsHub = hub
 }
 
-// broadcastBlockEvent broadcasts a new block event via WebSocket
+// broadcastBlockEvent broadcasts a new block event, a confirmed transaction event
+// for each transaction in it and a chain update event for the new tip via WebSocket
 func (n *Node) broadcastBlockEvent(block *blockchain.Block) {
 	if n.wsHub != nil {
 		event := websocket.NewBlockEvent(block)
 		n.wsHub.Broadcast(event)
+
+		for _, tx := range block.Transactions {
+			n.broadcastTransactionEvent(tx, "confirmed")
+		}
+
+		info, err := n.chain.GetChainInfo()
+		if err != nil {
+			n.logger.Warnf("Failed to get chain info for chain update event: %v", err)
+			return
+		}
+		n.wsHub.Broadcast(websocket.NewChainUpdateEvent(info.Height, info.CurrentHash, info.Authorities))
 	}
 }
 
@@ -582,6 +1254,10 @@ func (n *Node) Stop() error {
 	n.logger.Info("Stopping node...")
 
 	close(n.stopChan)
+	n.mpUpdates.stop()
+
+	// Let a block being produced finish committing before storage goes away
+	n.loops.Wait()
 
 	// Stop P2P server
 	if n.p2pServer != nil {
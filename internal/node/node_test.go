@@ -0,0 +1,816 @@
+package node
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+	"github.com/podoru/podoru-chain/internal/crypto"
+	"github.com/podoru/podoru-chain/internal/network"
+	"github.com/podoru/podoru-chain/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// freePort returns a local TCP port that was free a moment ago
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// testProducer is the key of the single authority of a test network
+type testProducer struct {
+	key     *ecdsa.PrivateKey
+	address string
+}
+
+func newTestProducer(t *testing.T) *testProducer {
+	t.Helper()
+
+	key, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := crypto.AddressFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testProducer{key: key, address: address}
+}
+
+// testGenesis returns a genesis config naming producer the only authority
+func testGenesis(producer *testProducer) *blockchain.GenesisConfig {
+	return &blockchain.GenesisConfig{
+		Timestamp:    time.Now().Add(-time.Hour).Unix(),
+		Authorities:  []string{producer.address},
+		InitialState: map[string]string{"greeting": "hello"},
+	}
+}
+
+// producerConfig writes producer's key, the genesis file and a producer config on
+// badger storage with the given extra YAML lines into a temporary directory, and
+// loads the config
+func producerConfig(t *testing.T, producer *testProducer, genesis *blockchain.GenesisConfig, extra string) *Config {
+	t.Helper()
+	dir := t.TempDir()
+
+	keyPath := filepath.Join(dir, "producer.key")
+	if err := crypto.SavePrivateKeyToFile(producer.key, keyPath); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesisPath := filepath.Join(dir, "genesis.json")
+	if err := os.WriteFile(genesisPath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	content := fmt.Sprintf("node_type: producer\n"+
+		"address: %q\n"+
+		"private_key: %s\n"+
+		"authorities: [%q]\n"+
+		"genesis_path: %s\n"+
+		"data_dir: %s\n"+
+		"p2p_bind_addr: 127.0.0.1\n"+
+		"p2p_port: %d\n"+
+		"block_time: 1s\n",
+		producer.address, keyPath, producer.address, genesisPath, filepath.Join(dir, "data"), freePort(t))
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(content+extra), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	return config
+}
+
+// newTestNode creates a producer node for config and runs its startup stages up to
+// P2P, leaving out sync and the production loop so tests drive produceBlock themselves
+func newTestNode(t *testing.T, config *Config) *Node {
+	t.Helper()
+
+	n, err := NewNode(config)
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	n.logger.SetOutput(io.Discard)
+	for _, stage := range []func() error{n.startStorage, n.startConsensus, n.startChain, n.startMempool, n.startP2P} {
+		if err := stage(); err != nil {
+			t.Fatalf("starting node: %v", err)
+		}
+	}
+	t.Cleanup(func() { n.Stop() })
+	return n
+}
+
+// signedTx returns a transaction from key with the given operations
+func signedTx(t *testing.T, key *ecdsa.PrivateKey, nonce uint64, ops ...*blockchain.KVOperation) *blockchain.Transaction {
+	t.Helper()
+
+	address, err := crypto.AddressFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := blockchain.NewTransaction(address, time.Now().Unix(), &blockchain.TransactionData{Operations: ops}, nonce)
+	if err := tx.Sign(key); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return tx
+}
+
+// setOp returns a SET operation
+func setOp(key, value string) *blockchain.KVOperation {
+	return &blockchain.KVOperation{Type: blockchain.OpTypeSet, Key: key, Value: []byte(value)}
+}
+
+// TestStopWaitsForBlockProduction stops a producing node and checks that the blocks
+// it committed are all in storage, which reopens as a valid chain at the same height
+func TestStopWaitsForBlockProduction(t *testing.T) {
+	producer := newTestProducer(t)
+	config := producerConfig(t, producer, testGenesis(producer), "")
+	n, err := NewNode(config)
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	n.logger.SetOutput(io.Discard)
+
+	if err := n.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for n.Height() < 2 {
+		if time.Now().After(deadline) {
+			n.Stop()
+			t.Fatalf("node stuck at height %d", n.Height())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Stop as soon as the next block lands, while produceBlock is still broadcasting it
+	height := n.Height()
+	for n.Height() == height && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := n.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if n.IsProducing() {
+		t.Error("production loop still running after Stop")
+	}
+	height = n.Height()
+
+	store, err := storage.Open(config.StorageBackend, config.DataDir)
+	if err != nil {
+		t.Fatalf("reopening storage: %v", err)
+	}
+	defer store.Close()
+
+	reopened := blockchain.NewChain(store, config.Authorities)
+	if err := reopened.LoadFromStorage(); err != nil {
+		t.Fatalf("LoadFromStorage: %v", err)
+	}
+	if reopened.GetHeight() != height {
+		t.Errorf("stored height = %d, want %d", reopened.GetHeight(), height)
+	}
+	if err := reopened.VerifyChain(); err != nil {
+		t.Errorf("stored chain does not verify: %v", err)
+	}
+}
+
+// testChain returns a chain of empty blocks up to height, produced by a single authority
+func testChain(t *testing.T, height uint64) *blockchain.Chain {
+	t.Helper()
+
+	key, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := crypto.NewKeySigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &blockchain.GenesisConfig{
+		Timestamp:   time.Now().Add(-time.Hour).Unix(),
+		Authorities: []string{signer.Address()},
+	}
+	chain := blockchain.NewChain(storage.NewMemoryStore(), config.Authorities)
+	chain.SetConsensusParams(config.GetConsensusParams())
+	if err := chain.Initialize(blockchain.CreateGenesisBlock(config)); err != nil {
+		t.Fatal(err)
+	}
+
+	version := chain.GetConsensusParams().BlockVersion
+	for chain.GetHeight() < height {
+		parent := chain.GetCurrentBlock()
+		stateRoot, err := chain.CalculateStateRootWithTransactions(nil, signer.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		block := blockchain.NewBlock(&blockchain.BlockHeader{
+			Version:      version,
+			Height:       parent.Header.Height + 1,
+			PreviousHash: parent.Hash(),
+			Timestamp:    parent.Header.Timestamp + 1,
+			MerkleRoot:   blockchain.CalculateMerkleRoot(nil, version),
+			StateRoot:    stateRoot,
+			ProducerAddr: signer.Address(),
+		}, nil)
+		if err := block.Sign(signer); err != nil {
+			t.Fatal(err)
+		}
+		if err := chain.AddBlock(block); err != nil {
+			t.Fatalf("AddBlock at height %d: %v", block.Header.Height, err)
+		}
+	}
+	return chain
+}
+
+// connectClient connects a fresh P2P server to addr and returns it with the peer
+func connectClient(t *testing.T, addr string) (*network.P2PServer, *network.Peer) {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	client := network.NewP2PServer("127.0.0.1", 0, logger)
+	t.Cleanup(client.Stop)
+	if err := client.ConnectToPeer(addr); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for client.PeerCount() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("peer never connected")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return client, client.GetPeers()[0]
+}
+
+// requestBlocks sends a GetBlocks request to addr from a fresh P2P server and returns
+// the heights of the blocks streamed back
+func requestBlocks(t *testing.T, addr string, from, to uint64) []uint64 {
+	t.Helper()
+
+	client, peer := connectClient(t, addr)
+	var heights []uint64
+	msg := &network.Message{
+		Type:    network.MsgTypeGetBlocks,
+		Payload: &network.GetBlocksMessage{FromHeight: from, ToHeight: to},
+	}
+	err := client.SendAndStreamResponses(peer, msg, network.MsgTypeBlocks, 5*time.Second, func(response *network.Message) (bool, error) {
+		payload, err := json.Marshal(response.Payload)
+		if err != nil {
+			return false, err
+		}
+		var blocks network.BlocksMessage
+		if err := json.Unmarshal(payload, &blocks); err != nil {
+			return false, err
+		}
+		for _, block := range blocks.Blocks {
+			heights = append(heights, block.Header.Height)
+		}
+		return !blocks.More, nil
+	})
+	if err != nil {
+		t.Fatalf("GetBlocks %d-%d: %v", from, to, err)
+	}
+	return heights
+}
+
+func TestHandleGetBlocksBoundsRange(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	port := freePort(t)
+	n := &Node{
+		logger:    logger,
+		chain:     testChain(t, network.MaxBlocksPerRequest+50),
+		p2pServer: network.NewP2PServer("127.0.0.1", port, logger),
+	}
+	n.p2pServer.RegisterHandler(network.MsgTypeGetBlocks, n.handleGetBlocks)
+	if err := n.p2pServer.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer n.p2pServer.Stop()
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+
+	tests := []struct {
+		name     string
+		from, to uint64
+		first    uint64
+		count    int
+	}{
+		{name: "within limit", from: 5, to: 14, first: 5, count: 10},
+		// The JSON codec carries heights as float64, so use one it represents exactly
+		{name: "oversized range", from: 0, to: 1 << 62, first: 0, count: network.MaxBlocksPerRequest},
+		{name: "oversized range past the tip", from: 100, to: 100 + 10*network.MaxBlocksPerRequest, first: 100, count: network.MaxBlocksPerRequest - 50 + 1},
+		{name: "reversed range", from: 20, to: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			heights := requestBlocks(t, addr, tt.from, tt.to)
+			if len(heights) != tt.count {
+				t.Fatalf("got %d blocks, want %d", len(heights), tt.count)
+			}
+			for i, height := range heights {
+				if height != tt.first+uint64(i) {
+					t.Fatalf("block %d has height %d, want %d", i, height, tt.first+uint64(i))
+				}
+			}
+		})
+	}
+}
+
+// TestSyncLargeBlocks syncs a range of blocks that together are far over the message
+// size limit, which the serving node streams in chunks that each fit in a message
+func TestSyncLargeBlocks(t *testing.T) {
+	key, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := crypto.NewKeySigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &blockchain.GenesisConfig{
+		Timestamp:       time.Now().Add(-time.Hour).Unix(),
+		Authorities:     []string{signer.Address()},
+		ConsensusParams: &blockchain.ConsensusParams{MaxBlockSize: blockchain.MaxBlockSizeLimit},
+	}
+	newChain := func() *blockchain.Chain {
+		chain := blockchain.NewChain(storage.NewMemoryStore(), config.Authorities)
+		chain.SetConsensusParams(config.GetConsensusParams())
+		if err := chain.Initialize(blockchain.CreateGenesisBlock(config)); err != nil {
+			t.Fatal(err)
+		}
+		return chain
+	}
+	source, target := newChain(), newChain()
+
+	const height = 5
+	value := strings.Repeat("x", blockchain.MaxValueSize)
+	version := source.GetConsensusParams().BlockVersion
+	total := 0
+	for source.GetHeight() < height {
+		parent := source.GetCurrentBlock()
+		txs := []*blockchain.Transaction{signedTx(t, key, parent.Header.Height, setOp("a", value), setOp("b", value))}
+		stateRoot, err := source.CalculateStateRootWithTransactions(txs, signer.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		block := blockchain.NewBlock(&blockchain.BlockHeader{
+			Version:      version,
+			Height:       parent.Header.Height + 1,
+			PreviousHash: parent.Hash(),
+			Timestamp:    parent.Header.Timestamp + 1,
+			MerkleRoot:   blockchain.CalculateMerkleRoot(txs, version),
+			StateRoot:    stateRoot,
+			ProducerAddr: signer.Address(),
+		}, txs)
+		if err := block.Sign(signer); err != nil {
+			t.Fatal(err)
+		}
+		if err := source.AddBlock(block); err != nil {
+			t.Fatalf("AddBlock at height %d: %v", block.Header.Height, err)
+		}
+		total += block.Size()
+	}
+	if total <= network.MaxMessageSize {
+		t.Fatalf("blocks total %d bytes, want more than one message holds", total)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	port := freePort(t)
+	n := &Node{
+		logger:    logger,
+		chain:     source,
+		p2pServer: network.NewP2PServer("127.0.0.1", port, logger),
+	}
+	n.p2pServer.RegisterHandler(network.MsgTypeGetBlocks, n.handleGetBlocks)
+	if err := n.p2pServer.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer n.p2pServer.Stop()
+
+	client, peer := connectClient(t, fmt.Sprintf("127.0.0.1:%d", port))
+	syncer := network.NewSyncer(target, client, network.NewMempool(), logger)
+	if err := syncer.SyncFromPeer(peer, height); err != nil {
+		t.Fatalf("SyncFromPeer: %v", err)
+	}
+	if target.GetHeight() != height {
+		t.Fatalf("synced to height %d, want %d", target.GetHeight(), height)
+	}
+	if !bytes.Equal(target.GetStateRoot(), source.GetStateRoot()) {
+		t.Error("synced state root differs from the source's")
+	}
+}
+
+func TestSubmitTransactionUsesHashAsID(t *testing.T) {
+	producer := newTestProducer(t)
+	n := newTestNode(t, producerConfig(t, producer, testGenesis(producer), ""))
+
+	tests := []struct {
+		name    string
+		id      func(tx *blockchain.Transaction) []byte
+		wantErr error
+	}{
+		{name: "matching ID", id: func(tx *blockchain.Transaction) []byte { return tx.ID }},
+		{name: "no ID", id: func(*blockchain.Transaction) []byte { return nil }},
+		{name: "tampered ID", id: func(*blockchain.Transaction) []byte { return bytes.Repeat([]byte{0xab}, 32) }, wantErr: blockchain.ErrTransactionIDMismatch},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := signedTx(t, producer.key, uint64(i), setOp(fmt.Sprintf("key/%d", i), "v"))
+			hash := tx.Hash()
+			tx.ID = tt.id(tx)
+
+			err := n.SubmitTransaction(tx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SubmitTransaction = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if n.mempool.HasTransaction(tx.ID) || n.mempool.HasTransaction(hash) {
+					t.Error("transaction with a tampered ID entered the mempool")
+				}
+				return
+			}
+			if !bytes.Equal(tx.ID, hash) {
+				t.Errorf("ID = %x, want the hash %x", tx.ID, hash)
+			}
+			if !n.mempool.HasTransaction(hash) {
+				t.Error("transaction not in the mempool under its hash")
+			}
+		})
+	}
+}
+
+func TestProduceBlockSkipsInapplicableTransactions(t *testing.T) {
+	producer := newTestProducer(t)
+	funded, underfunded := newTestProducer(t), newTestProducer(t)
+	genesis := testGenesis(producer)
+	genesis.TokenConfig = &blockchain.TokenConfig{Name: "Podoru", Symbol: "PDR", Decimals: 18, Accounting: true}
+	genesis.GasConfig = &blockchain.GasConfigJSON{BaseFee: "1000", PerByteFee: "10"}
+	genesis.InitialBalances = map[string]string{
+		funded.address:      "1000000000000000000",
+		underfunded.address: "1000000",
+	}
+
+	// Without the mempool balance check an underfunded transaction gets in, as one does
+	// when the sender's balance drops after it was admitted
+	n := newTestNode(t, producerConfig(t, producer, genesis, "mempool_balance_check: false\n"))
+
+	valid := signedTx(t, funded.key, 0, setOp("funded", "v"))
+	unaffordable := signedTx(t, underfunded.key, 0,
+		blockchain.NewTransferOperation(funded.address, big.NewInt(1e18).Bytes()))
+	future := signedTx(t, funded.key, 5, setOp("later", "v"))
+	for _, tx := range []*blockchain.Transaction{valid, unaffordable, future} {
+		if err := n.mempool.AddTransaction(tx); err != nil {
+			t.Fatalf("AddTransaction: %v", err)
+		}
+	}
+
+	if err := n.produceBlock(); err != nil {
+		t.Fatalf("produceBlock: %v", err)
+	}
+	if n.chain.GetHeight() != 1 {
+		t.Fatalf("height = %d, want a block produced", n.chain.GetHeight())
+	}
+	block := n.chain.GetCurrentBlock()
+	if len(block.Transactions) != 1 || !bytes.Equal(block.Transactions[0].ID, valid.ID) {
+		t.Errorf("block has %d transactions, want only the valid one", len(block.Transactions))
+	}
+
+	if n.mempool.HasTransaction(unaffordable.ID) {
+		t.Error("underfunded transaction was not evicted from the mempool")
+	}
+	if !n.mempool.HasTransaction(future.ID) {
+		t.Error("transaction with a future nonce was evicted instead of left waiting")
+	}
+}
+
+func TestSubmitTransactionRejectsConfirmed(t *testing.T) {
+	producer := newTestProducer(t)
+	n := newTestNode(t, producerConfig(t, producer, testGenesis(producer), ""))
+
+	tx := signedTx(t, producer.key, 0, setOp("key", "v"))
+	if err := n.SubmitTransaction(tx); err != nil {
+		t.Fatalf("SubmitTransaction: %v", err)
+	}
+	if err := n.produceBlock(); err != nil {
+		t.Fatalf("produceBlock: %v", err)
+	}
+	if len(n.chain.GetCurrentBlock().Transactions) != 1 {
+		t.Fatal("transaction was not included")
+	}
+
+	resubmitted := *tx
+	if err := n.SubmitTransaction(&resubmitted); !errors.Is(err, blockchain.ErrDuplicateTransaction) {
+		t.Errorf("resubmitting a confirmed transaction = %v, want ErrDuplicateTransaction", err)
+	}
+	if n.mempool.HasTransaction(tx.ID) {
+		t.Error("confirmed transaction re-entered the mempool")
+	}
+}
+
+func TestSlotSignerWithSeveralProducerKeys(t *testing.T) {
+	first, second := newTestProducer(t), newTestProducer(t)
+	genesis := testGenesis(first)
+	genesis.Authorities = []string{first.address, second.address}
+
+	single := producerConfig(t, first, genesis, "")
+	single.Authorities = genesis.Authorities
+	both := producerConfig(t, first, genesis, "")
+	both.Authorities = genesis.Authorities
+	keyPath := filepath.Join(t.TempDir(), "second.key")
+	if err := crypto.SavePrivateKeyToFile(second.key, keyPath); err != nil {
+		t.Fatal(err)
+	}
+	both.ProducerKeys = []string{keyPath}
+
+	singleNode, n := newTestNode(t, single), newTestNode(t, both)
+
+	// With no slots missed, each height goes to its scheduled authority, whose key
+	// signs it; a node with only the first key sits out the second's slots
+	now := time.Now()
+	last := now.Unix()
+	var previous string
+	for height := uint64(1); height <= 4; height++ {
+		scheduled := n.consensus.GetBlockProducer(height)
+
+		signer := n.slotSigner(height, last, now)
+		if signer == nil {
+			t.Fatalf("height %d: no signer with both keys loaded", height)
+		}
+		if crypto.NormalizeAddress(signer.Address()) != crypto.NormalizeAddress(scheduled) {
+			t.Errorf("height %d: signer %s, want the scheduled %s", height, signer.Address(), scheduled)
+		}
+		if signer.Address() == previous {
+			t.Errorf("height %d: %s signed two heights in a row", height, previous)
+		}
+		previous = signer.Address()
+
+		signer = singleNode.slotSigner(height, last, now)
+		if ours := crypto.NormalizeAddress(scheduled) == crypto.NormalizeAddress(first.address); (signer != nil) != ours {
+			t.Errorf("height %d: single-key node signer = %v, want one only in its own slots", height, signer)
+		}
+	}
+
+	// Produced blocks are signed by the key of the authority whose slot it is
+	deadline := time.Now().Add(10 * time.Second)
+	for n.chain.GetHeight() < 2 && time.Now().Before(deadline) {
+		parent := n.chain.GetCurrentBlock()
+		if err := n.produceBlock(); err != nil {
+			t.Fatalf("produceBlock: %v", err)
+		}
+		if n.chain.GetHeight() == parent.Header.Height {
+			time.Sleep(50 * time.Millisecond) // Too soon after the parent
+			continue
+		}
+
+		block := n.chain.GetCurrentBlock()
+		want := n.consensus.GetBlockProducerAt(block.Header.Height, parent.Header.Timestamp, time.Unix(block.Header.Timestamp, 0))
+		if crypto.NormalizeAddress(block.Header.ProducerAddr) != crypto.NormalizeAddress(want) {
+			t.Errorf("block %d produced by %s, want %s", block.Header.Height, block.Header.ProducerAddr, want)
+		}
+		if err := block.Verify(); err != nil {
+			t.Errorf("block %d signature: %v", block.Header.Height, err)
+		}
+	}
+	if n.chain.GetHeight() < 2 {
+		t.Fatalf("node stuck at height %d", n.chain.GetHeight())
+	}
+}
+
+func TestExpirySweepDropsExpiredTransactions(t *testing.T) {
+	producer := newTestProducer(t)
+	n := newTestNode(t, producerConfig(t, producer, testGenesis(producer), "mempool_expiry_sweep_interval: 50ms\n"))
+
+	expiring := blockchain.NewTransaction(producer.address, time.Now().Unix(),
+		&blockchain.TransactionData{Operations: []*blockchain.KVOperation{setOp("soon", "v")}}, 0)
+	expiring.ValidUntil = time.Now().Unix() + 1
+	expiring.ID = expiring.Hash()
+	if err := expiring.Sign(producer.key); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	lasting := signedTx(t, producer.key, 1, setOp("later", "v"))
+	for _, tx := range []*blockchain.Transaction{expiring, lasting} {
+		if err := n.SubmitTransaction(tx); err != nil {
+			t.Fatalf("SubmitTransaction: %v", err)
+		}
+	}
+
+	// The sweeper drops the transaction once its valid_until passes, while pending
+	deadline := time.Now().Add(5 * time.Second)
+	for n.mempool.HasTransaction(expiring.ID) {
+		if time.Now().After(deadline) {
+			t.Fatal("expired transaction still in the mempool")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !n.mempool.HasTransaction(lasting.ID) {
+		t.Error("sweeper dropped a transaction without valid_until")
+	}
+}
+
+func TestNextProductionDelayFollowsTip(t *testing.T) {
+	producer := newTestProducer(t)
+	n := newTestNode(t, producerConfig(t, producer, testGenesis(producer), ""))
+
+	// The genesis block is an hour old, so the next block is due at once
+	if delay := n.nextProductionDelay(); delay != minProductionDelay {
+		t.Errorf("delay after an old tip = %v, want %v", delay, minProductionDelay)
+	}
+
+	// After a block the wait runs from its timestamp, not from when the loop last woke
+	if err := n.produceBlock(); err != nil {
+		t.Fatalf("produceBlock: %v", err)
+	}
+	tip := time.Unix(n.chain.GetCurrentBlock().Header.Timestamp, 0)
+	due := tip.Add(n.consensus.GetBlockTime())
+	delay := n.nextProductionDelay()
+	want := max(time.Until(due), minProductionDelay)
+	if diff := delay - want; diff < 0 || diff > 50*time.Millisecond {
+		t.Errorf("delay after a block at %v = %v, want about %v", tip, delay, want)
+	}
+}
+
+func TestProduceBlockWithRemoteSigner(t *testing.T) {
+	producer := newTestProducer(t)
+
+	var requests atomic.Int32
+	signer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var req crypto.RemoteSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		hash, err := hex.DecodeString(strings.TrimPrefix(req.Hash, "0x"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		signature, err := crypto.Sign(hash, producer.key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(&crypto.RemoteSignResponse{Signature: "0x" + hex.EncodeToString(signature)})
+	}))
+	defer signer.Close()
+
+	// The key file is left in place but unused: blocks are signed by the remote signer
+	n := newTestNode(t, producerConfig(t, producer, testGenesis(producer),
+		"signer: remote\nremote_signer_url: "+signer.URL+"\n"))
+	if err := n.produceBlock(); err != nil {
+		t.Fatalf("produceBlock: %v", err)
+	}
+
+	block := n.chain.GetCurrentBlock()
+	if block.Header.Height != 1 {
+		t.Fatalf("height = %d, want a block produced", block.Header.Height)
+	}
+	if requests.Load() == 0 {
+		t.Error("block was not signed by the remote signer")
+	}
+	if err := block.Verify(); err != nil {
+		t.Errorf("remotely signed block does not verify: %v", err)
+	}
+}
+
+func TestDedupWindowSurvivesRestart(t *testing.T) {
+	producer := newTestProducer(t)
+	config := producerConfig(t, producer, testGenesis(producer), "mempool_dedup_window: 1\n")
+
+	n, err := NewNode(config)
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	n.logger.SetOutput(io.Discard)
+	for _, stage := range []func() error{n.startStorage, n.startConsensus, n.startChain, n.startMempool, n.startP2P} {
+		if err := stage(); err != nil {
+			t.Fatalf("starting node: %v", err)
+		}
+	}
+	var mined []*blockchain.Transaction
+	deadline := time.Now().Add(10 * time.Second)
+	for nonce := range uint64(2) {
+		tx := signedTx(t, producer.key, nonce, setOp("key", fmt.Sprint(nonce)))
+		if err := n.SubmitTransaction(tx); err != nil {
+			t.Fatalf("SubmitTransaction: %v", err)
+		}
+		for n.chain.GetHeight() == nonce {
+			if time.Now().After(deadline) {
+				t.Fatalf("node stuck at height %d", n.chain.GetHeight())
+			}
+			if err := n.produceBlock(); err != nil {
+				t.Fatalf("produceBlock: %v", err)
+			}
+			time.Sleep(50 * time.Millisecond) // Too soon after the parent
+		}
+		mined = append(mined, tx)
+	}
+	if err := n.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	// The restarted mempool still rejects the transaction of the last block
+	restarted := newTestNode(t, config)
+	if err := restarted.mempool.AddTransaction(mined[1]); !errors.Is(err, network.ErrAlreadyMined) {
+		t.Errorf("re-adding a mined transaction after restart = %v, want ErrAlreadyMined", err)
+	}
+	if restarted.mempool.IsRecentlyMined(mined[0].ID) {
+		t.Error("transaction from a block outside the dedup window is remembered after restart")
+	}
+}
+
+// gossipPeer connects a P2P server on n's chain to n, counting the transactions n relays to it
+func gossipPeer(t *testing.T, n *Node) (*network.P2PServer, *atomic.Int32) {
+	t.Helper()
+
+	key, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	peer := network.NewP2PServer("127.0.0.1", 0, logger)
+	peer.SetNodeKey(key)
+	peer.SetHelloFunc(n.localHello)
+
+	var received atomic.Int32
+	peer.RegisterHandler(network.MsgTypeNewTransaction, func(*network.Peer, *network.Message) error {
+		received.Add(1)
+		return nil
+	})
+	if err := peer.ConnectToPeer(fmt.Sprintf("127.0.0.1:%d", n.GetConfig().P2PPort)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(peer.Stop)
+	return peer, &received
+}
+
+func TestTransactionGossip(t *testing.T) {
+	producer := newTestProducer(t)
+	n := newTestNode(t, producerConfig(t, producer, testGenesis(producer), ""))
+	origin, toOrigin := gossipPeer(t, n)
+	next, toNext := gossipPeer(t, n)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for n.p2pServer.PeerCount() < 2 || origin.PeerCount() < 1 || next.PeerCount() < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("peers did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A new transaction is relayed to the second hop, but not back to its sender
+	msg := &network.Message{
+		Type:    network.MsgTypeNewTransaction,
+		Payload: &network.NewTransactionMessage{Transaction: signedTx(t, producer.key, 0, setOp("key", "v"))},
+	}
+	origin.BroadcastMessage(msg)
+	for toNext.Load() < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("transaction was not relayed to the second hop")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Echoes of a seen transaction, from either side, are not relayed again
+	origin.BroadcastMessage(msg)
+	next.BroadcastMessage(msg)
+	time.Sleep(200 * time.Millisecond)
+	if got := toNext.Load(); got != 1 {
+		t.Errorf("second hop received the transaction %d times, want 1", got)
+	}
+	if got := toOrigin.Load(); got != 0 {
+		t.Errorf("sender received its own transaction back %d times", got)
+	}
+}
@@ -0,0 +1,132 @@
+package node
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+// ErrReceiptTimeout is returned when a transaction isn't mined before the wait times out
+var ErrReceiptTimeout = errors.New("timed out waiting for transaction to be mined")
+
+// TransactionReceipt describes where a transaction was mined and what it paid
+type TransactionReceipt struct {
+	TransactionHash string `json:"transaction_hash"`
+	Status          string `json:"status"`
+	BlockHeight     uint64 `json:"block_height"`
+	BlockHash       string `json:"block_hash"`
+	GasCharged      string `json:"gas_charged"` // In wei
+}
+
+// receiptWaiters notifies callers waiting for specific transactions to be mined
+type receiptWaiters struct {
+	mu      sync.Mutex
+	waiters map[string][]chan *TransactionReceipt
+}
+
+// newReceiptWaiters creates an empty waiter registry
+func newReceiptWaiters() *receiptWaiters {
+	return &receiptWaiters{
+		waiters: make(map[string][]chan *TransactionReceipt),
+	}
+}
+
+// add registers a waiter for a transaction hash. The returned function unregisters it.
+func (rw *receiptWaiters) add(hash string) (<-chan *TransactionReceipt, func()) {
+	ch := make(chan *TransactionReceipt, 1)
+
+	rw.mu.Lock()
+	rw.waiters[hash] = append(rw.waiters[hash], ch)
+	rw.mu.Unlock()
+
+	return ch, func() {
+		rw.mu.Lock()
+		defer rw.mu.Unlock()
+
+		chans := rw.waiters[hash]
+		for i, c := range chans {
+			if c == ch {
+				chans = append(chans[:i], chans[i+1:]...)
+				break
+			}
+		}
+		if len(chans) == 0 {
+			delete(rw.waiters, hash)
+		} else {
+			rw.waiters[hash] = chans
+		}
+	}
+}
+
+// notify sends receipts to everyone waiting on a transaction in the committed block
+func (rw *receiptWaiters) notify(block *blockchain.Block, gasConfig *blockchain.GasConfig) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if len(rw.waiters) == 0 {
+		return
+	}
+
+	for _, tx := range block.Transactions {
+		hash := hex.EncodeToString(tx.ID)
+		chans, exists := rw.waiters[hash]
+		if !exists {
+			continue
+		}
+
+		receipt := newReceipt(tx, block, gasConfig)
+		for _, ch := range chans {
+			ch <- receipt
+		}
+		delete(rw.waiters, hash)
+	}
+}
+
+// newReceipt builds the receipt of a transaction mined in block
+func newReceipt(tx *blockchain.Transaction, block *blockchain.Block, gasConfig *blockchain.GasConfig) *TransactionReceipt {
+	gas := "0"
+	if gasConfig != nil && !gasConfig.IsZeroFee() && !tx.IsGenesisTransaction() {
+		gas = gasConfig.CalculateGasFee(tx.Size()).String()
+	}
+
+	return &TransactionReceipt{
+		TransactionHash: fmt.Sprintf("0x%x", tx.ID),
+		Status:          "mined",
+		BlockHeight:     block.Header.Height,
+		BlockHash:       fmt.Sprintf("0x%x", block.Hash()),
+		GasCharged:      gas,
+	}
+}
+
+// SubmitTransactionAndWait submits a transaction and waits up to timeout for the
+// block that includes it. Returns ErrReceiptTimeout if it isn't mined in time.
+func (n *Node) SubmitTransactionAndWait(ctx context.Context, tx *blockchain.Transaction, timeout time.Duration) (*TransactionReceipt, error) {
+	if err := tx.SetID(); err != nil {
+		return nil, fmt.Errorf("invalid transaction: %w", err)
+	}
+
+	// Register before submitting so a block committed right away isn't missed
+	receiptChan, cancel := n.receipts.add(hex.EncodeToString(tx.ID))
+	defer cancel()
+
+	if err := n.SubmitTransaction(tx); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case receipt := <-receiptChan:
+		return receipt, nil
+	case <-timer.C:
+		return nil, ErrReceiptTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,95 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSubmitTransactionAndWaitMined(t *testing.T) {
+	producer := newTestProducer(t)
+	n := newTestNode(t, producerConfig(t, producer, testGenesis(producer), ""))
+
+	tx := signedTx(t, producer.key, 0, setOp("key", "v"))
+	if err := tx.SetID(); err != nil {
+		t.Fatal(err)
+	}
+	type result struct {
+		receipt *TransactionReceipt
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		receipt, err := n.SubmitTransactionAndWait(context.Background(), tx, 5*time.Second)
+		done <- result{receipt, err}
+	}()
+
+	// Produce once the transaction is in the mempool
+	deadline := time.Now().Add(5 * time.Second)
+	for !n.mempool.HasTransaction(tx.ID) {
+		if time.Now().After(deadline) {
+			t.Fatal("transaction never reached the mempool")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := n.produceBlock(); err != nil {
+		t.Fatalf("produceBlock: %v", err)
+	}
+
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("SubmitTransactionAndWait: %v", res.err)
+	}
+	block := n.chain.GetCurrentBlock()
+	if res.receipt.TransactionHash != fmt.Sprintf("0x%x", tx.ID) {
+		t.Errorf("transaction hash = %s, want 0x%x", res.receipt.TransactionHash, tx.ID)
+	}
+	if res.receipt.Status != "mined" {
+		t.Errorf("status = %q, want mined", res.receipt.Status)
+	}
+	if res.receipt.BlockHeight != block.Header.Height {
+		t.Errorf("block height = %d, want %d", res.receipt.BlockHeight, block.Header.Height)
+	}
+	if res.receipt.BlockHash != fmt.Sprintf("0x%x", block.Hash()) {
+		t.Errorf("block hash = %s, want 0x%x", res.receipt.BlockHash, block.Hash())
+	}
+	if res.receipt.GasCharged != "0" {
+		t.Errorf("gas charged = %s on a zero-fee chain, want 0", res.receipt.GasCharged)
+	}
+	if len(n.receipts.waiters) != 0 {
+		t.Errorf("%d waiters left registered", len(n.receipts.waiters))
+	}
+}
+
+func TestSubmitTransactionAndWaitTimesOut(t *testing.T) {
+	producer := newTestProducer(t)
+	n := newTestNode(t, producerConfig(t, producer, testGenesis(producer), ""))
+
+	tx := signedTx(t, producer.key, 0, setOp("key", "v"))
+	start := time.Now()
+	receipt, err := n.SubmitTransactionAndWait(context.Background(), tx, 100*time.Millisecond)
+	if !errors.Is(err, ErrReceiptTimeout) {
+		t.Fatalf("err = %v, want ErrReceiptTimeout", err)
+	}
+	if receipt != nil {
+		t.Errorf("receipt = %+v, want nil", receipt)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("returned after %s, before the timeout", elapsed)
+	}
+
+	// The transaction stays pending and the waiter is gone
+	if !n.mempool.HasTransaction(tx.ID) {
+		t.Error("timed-out transaction is not in the mempool")
+	}
+	if len(n.receipts.waiters) != 0 {
+		t.Errorf("%d waiters left registered", len(n.receipts.waiters))
+	}
+
+	// A rejected submission returns its error instead of waiting
+	if _, err := n.SubmitTransactionAndWait(context.Background(), tx, time.Minute); err == nil {
+		t.Error("resubmitting a pending transaction succeeded")
+	}
+}
@@ -0,0 +1,98 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+// restartOnlyFields returns the settings of a config that can't change while the node
+// runs, by config key
+func restartOnlyFields(c *Config) map[string]any {
+	return map[string]any{
+		"node_type":       c.NodeType,
+		"address":         c.Address,
+		"chain_id":        c.ChainID,
+		"p2p_port":        c.P2PPort,
+		"p2p_bind_addr":   c.P2PBindAddr,
+		"api_port":        c.APIPort,
+		"api_bind_addr":   c.APIBindAddr,
+		"storage_backend": c.StorageBackend,
+		"data_dir":        c.DataDir,
+		"genesis_path":    c.GenesisPath,
+	}
+}
+
+// Reload applies the hot-reloadable settings of a re-read config to the running node:
+// block_time (with the slot timeout that follows it when genesis sets none) and
+// authorities. Changes to restart-only settings such as data_dir or the ports are
+// logged and ignored. Every address the node produces for
+// must stay in the new authority list, otherwise nothing is applied.
+//
+// The authority change is not coordinated with other nodes: every node must be
+// reloaded with the same list, or they reject each other's blocks from then on.
+func (n *Node) Reload(config *Config) error {
+	n.reloadMu.Lock()
+	defer n.reloadMu.Unlock()
+
+	if n.consensus == nil || n.chain == nil {
+		return errors.New("node is not started")
+	}
+	if config.BlockTime <= 0 {
+		return errors.New("block_time must be positive")
+	}
+	slotTimeout := n.chain.GetConsensusParams().SlotTimeoutFor(config.BlockTime)
+	if config.BlockTime > slotTimeout {
+		return fmt.Errorf("block_time %v exceeds the genesis slot_timeout %v", config.BlockTime, slotTimeout)
+	}
+	if len(config.Authorities) == 0 {
+		return errors.New("no authorities specified")
+	}
+	if dup := blockchain.FindDuplicateAuthority(config.Authorities); dup != "" {
+		return fmt.Errorf("duplicate authority: %s", dup)
+	}
+	authorities := blockchain.NewAuthoritySet(config.Authorities)
+	for _, address := range n.producerAddresses() {
+		if !authorities.Contains(address) {
+			return fmt.Errorf("producer address %s is not in the new authority list", address)
+		}
+	}
+
+	running := n.GetConfig()
+	current := restartOnlyFields(running)
+	for key, value := range restartOnlyFields(config) {
+		if value != current[key] {
+			n.logger.Warnf("Ignoring change to %s on reload; it takes effect after a restart", key)
+		}
+	}
+	if !slices.Equal(config.ProducerKeys, running.ProducerKeys) {
+		n.logger.Warn("Ignoring change to producer_keys on reload; it takes effect after a restart")
+	}
+
+	if err := n.consensus.UpdateAuthorities(config.Authorities); err != nil {
+		return fmt.Errorf("failed to update authorities: %w", err)
+	}
+	n.chain.SetAuthorities(config.Authorities)
+	if n.headers != nil {
+		n.headers.SetAuthorities(config.Authorities)
+	}
+
+	n.consensus.SetBlockTime(config.BlockTime)
+	n.consensus.SetSlotTimeout(slotTimeout)
+
+	// Readers may hold the running config, so it is replaced rather than changed
+	updated := *running
+	updated.Authorities = slices.Clone(config.Authorities)
+	updated.BlockTime = config.BlockTime
+	n.config.Store(&updated)
+
+	if !slices.Equal(config.Authorities, running.Authorities) {
+		n.logger.Warnf("Authorities changed to %v; every node must reload the same list, or they will reject each other's blocks",
+			config.Authorities)
+	}
+
+	n.logger.Infof("Reloaded config: block time %v, %d authorities", config.BlockTime, len(config.Authorities))
+	return nil
+}
@@ -0,0 +1,74 @@
+package node
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReloadBlockTime(t *testing.T) {
+	producer := newTestProducer(t)
+	config := producerConfig(t, producer, testGenesis(producer), "")
+	n := newTestNode(t, config)
+
+	// Readers of the config run alongside the reload, as the API and production loop do
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					_ = n.GetConfig().BlockTime
+					_ = len(n.GetConfig().Authorities)
+				}
+			}
+		}()
+	}
+
+	before := n.GetConfig()
+	reloaded := *config
+	reloaded.BlockTime = 1500 * time.Millisecond
+	err := n.Reload(&reloaded)
+	close(stop)
+	readers.Wait()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	if got := n.GetConfig().BlockTime; got != reloaded.BlockTime {
+		t.Errorf("config block time = %v, want %v", got, reloaded.BlockTime)
+	}
+	if got := n.consensus.GetBlockTime(); got != reloaded.BlockTime {
+		t.Errorf("consensus block time = %v, want %v", got, reloaded.BlockTime)
+	}
+	if before.BlockTime != time.Second {
+		t.Errorf("config held before the reload changed to block time %v", before.BlockTime)
+	}
+
+	// Rejected reloads leave the running config alone
+	invalid := []struct {
+		name   string
+		modify func(c *Config)
+	}{
+		{name: "zero block time", modify: func(c *Config) { c.BlockTime = 0 }},
+		{name: "producer not an authority", modify: func(c *Config) {
+			c.BlockTime = 3 * time.Second
+			c.Authorities = []string{newTestProducer(t).address}
+		}},
+	}
+	for _, tt := range invalid {
+		bad := *config
+		tt.modify(&bad)
+		if err := n.Reload(&bad); err == nil {
+			t.Errorf("%s: Reload succeeded", tt.name)
+		}
+		if got := n.GetConfig().BlockTime; got != reloaded.BlockTime {
+			t.Errorf("%s: block time = %v after a rejected reload, want %v", tt.name, got, reloaded.BlockTime)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package node
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStartupReportsFailedStage(t *testing.T) {
+	producer := newTestProducer(t)
+	config := producerConfig(t, producer, testGenesis(producer), "")
+
+	// The genesis file goes bad after the config was validated, failing the chain stage
+	if err := os.WriteFile(config.GenesisPath, []byte("not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewNode(config)
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	n.logger.SetOutput(io.Discard)
+	t.Cleanup(func() { n.Stop() })
+
+	err = n.Start()
+	if err == nil || !strings.Contains(err.Error(), "startup stage "+StageChain+" failed") {
+		t.Fatalf("Start = %v, want the chain stage named", err)
+	}
+
+	status := n.GetStartupStatus()
+	if status.Ready {
+		t.Error("node reported ready after a failed stage")
+	}
+	if status.FailedStage != StageChain {
+		t.Errorf("failed stage = %q, want %q", status.FailedStage, StageChain)
+	}
+
+	want := map[string]string{
+		StageStorage:    StageStateOK,
+		StageConsensus:  StageStateOK,
+		StageChain:      StageStateFailed,
+		StageMempool:    StageStatePending,
+		StageProduction: StageStatePending,
+	}
+	for _, stage := range status.Stages {
+		if state, ok := want[stage.Name]; ok && stage.State != state {
+			t.Errorf("stage %s = %s, want %s", stage.Name, stage.State, state)
+		}
+		if stage.Name == StageChain && stage.Error == "" {
+			t.Error("failed stage has no error")
+		}
+	}
+}
+
+func TestStartupTracker(t *testing.T) {
+	tracker := newStartupTracker()
+	if err := tracker.run(StageStorage, func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	tracker.skip(StageVerify)
+	cause := errors.New("port in use")
+	err := tracker.run(StageP2P, func() error { return cause })
+	if !errors.Is(err, cause) || !strings.Contains(err.Error(), StageP2P) {
+		t.Errorf("run = %v, want the cause wrapped with the stage name", err)
+	}
+
+	// The snapshot is a copy, unaffected by later updates
+	status := tracker.snapshot()
+	tracker.markReady()
+	if status.Ready || status.FailedStage != StageP2P {
+		t.Errorf("snapshot: ready %v, failed stage %q", status.Ready, status.FailedStage)
+	}
+	states := make(map[string]*StageStatus)
+	for _, stage := range status.Stages {
+		states[stage.Name] = stage
+	}
+	if len(status.Stages) != len(StartupStages) {
+		t.Errorf("%d stages reported, want %d", len(status.Stages), len(StartupStages))
+	}
+	if states[StageStorage].State != StageStateOK || states[StageVerify].State != StageStateSkipped ||
+		states[StageSync].State != StageStatePending {
+		t.Errorf("stage states: storage %s, verify %s, sync %s",
+			states[StageStorage].State, states[StageVerify].State, states[StageSync].State)
+	}
+	if states[StageP2P].State != StageStateFailed || states[StageP2P].Error != cause.Error() {
+		t.Errorf("p2p stage = %s (%q), want failed with the cause", states[StageP2P].State, states[StageP2P].Error)
+	}
+}
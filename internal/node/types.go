@@ -9,6 +9,10 @@ const (
 
 	// NodeTypeProducer is a block producer node (authority)
 	NodeTypeProducer NodeType = "producer"
+
+	// NodeTypeLight is a light node that follows block headers only and reads state
+	// through Merkle proofs from full peers
+	NodeTypeLight NodeType = "light"
 )
 
 // String returns the string representation of node type
@@ -18,5 +22,21 @@ func (nt NodeType) String() string {
 
 // IsValid checks if the node type is valid
 func (nt NodeType) IsValid() bool {
-	return nt == NodeTypeFull || nt == NodeTypeProducer
+	return nt == NodeTypeFull || nt == NodeTypeProducer || nt == NodeTypeLight
+}
+
+// SignerType defines how a producer signs blocks
+type SignerType string
+
+const (
+	// SignerTypeFile signs with the private key file in private_key
+	SignerTypeFile SignerType = "file"
+
+	// SignerTypeRemote signs through an HTTP remote signer (HSM gateway, etc.)
+	SignerTypeRemote SignerType = "remote"
+)
+
+// String returns the string representation of signer type
+func (st SignerType) String() string {
+	return string(st)
 }
@@ -0,0 +1,16 @@
+package node
+
+import "testing"
+
+func TestNodeTypeIsValid(t *testing.T) {
+	for _, nt := range []NodeType{NodeTypeFull, NodeTypeProducer, NodeTypeLight} {
+		if !nt.IsValid() {
+			t.Errorf("%s is not valid", nt)
+		}
+	}
+	for _, nt := range []NodeType{"", "archive", "Light"} {
+		if nt.IsValid() {
+			t.Errorf("%q is valid", nt)
+		}
+	}
+}
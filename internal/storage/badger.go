@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/dgraph-io/badger/v3"
 	"github.com/podoru/podoru-chain/internal/blockchain"
@@ -13,17 +14,25 @@ import (
 
 // Key prefixes for different data types
 const (
-	blockPrefix       = "blk:"       // Block by hash
-	blockHeightPrefix = "blh:"       // Block hash by height
-	txPrefix          = "tx:"        // Transaction by hash
-	statePrefix       = "st:"        // State key-value pairs
-	metaPrefix        = "meta:"      // Metadata
-	metaHeightKey     = "meta:height" // Current block height
+	blockPrefix       = "blk:"                // Block by hash
+	blockHeightPrefix = "blh:"                // Block hash by height
+	txPrefix          = "tx:"                 // Transaction by hash
+	statePrefix       = "st:"                 // State key-value pairs
+	metaPrefix        = "meta:"               // Metadata
+	metaHeightKey     = "meta:height"         // Current block height
+	metaPrunedKey     = "meta:pruned_below"   // Blocks below this height (except genesis) are pruned
+	metaSnapshotKey   = "meta:state_snapshot" // State snapshot the pruned blocks are replaced by
+	txAddrPrefix      = "txaddr:"             // Tx hash by sender address and height
+	txKeyPrefix       = "txkey:"              // Tx hash by touched state key (hex) and height
+	txTransferPrefix  = "txxfer:"             // Tx hash by transfer party and height
+	blockWeightPrefix = "blw:"                // Cumulative chain weight by block hash
+	receiptPrefix     = "receipt:"            // Transaction receipt by tx hash
 )
 
 // BadgerStore implements blockchain.Storage using BadgerDB
 type BadgerStore struct {
-	db *badger.DB
+	db          *badger.DB
+	indexConfig *blockchain.IndexConfig
 }
 
 // NewBadgerStore creates a new BadgerDB storage
@@ -41,33 +50,84 @@ func NewBadgerStore(dataDir string) (*BadgerStore, error) {
 		return nil, fmt.Errorf("failed to open badger db: %w", err)
 	}
 
-	return &BadgerStore{db: db}, nil
+	return &BadgerStore{
+		db:          db,
+		indexConfig: blockchain.DefaultIndexConfig(),
+	}, nil
 }
 
-// SaveBlock saves a block to storage
-func (bs *BadgerStore) SaveBlock(block *blockchain.Block) error {
-	return bs.db.Update(func(txn *badger.Txn) error {
-		// Serialize block
-		blockBytes, err := json.Marshal(block)
+// SetIndexConfig sets which secondary transaction indexes are written
+func (bs *BadgerStore) SetIndexConfig(config *blockchain.IndexConfig) {
+	if config == nil {
+		config = blockchain.DefaultIndexConfig()
+	}
+	bs.indexConfig = config
+}
+
+// CommitBlock writes a block with its transactions, their index entries and receipts,
+// and the block's state changes in a single write batch, so a crash can't leave the
+// block half-indexed
+func (bs *BadgerStore) CommitBlock(commit *blockchain.BlockCommit) error {
+	wb := bs.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	block := commit.Block
+	blockBytes, err := json.Marshal(block)
+	if err != nil {
+		return fmt.Errorf("failed to marshal block: %w", err)
+	}
+
+	// Save by hash, and the height -> hash mapping
+	blockHash := block.Hash()
+	if err := wb.Set([]byte(blockPrefix+hex.EncodeToString(blockHash)), blockBytes); err != nil {
+		return fmt.Errorf("failed to save block by hash: %w", err)
+	}
+	heightKey := fmt.Sprintf("%s%020d", blockHeightPrefix, block.Header.Height)
+	if err := wb.Set([]byte(heightKey), blockHash); err != nil {
+		return fmt.Errorf("failed to save block height index: %w", err)
+	}
+
+	for _, tx := range block.Transactions {
+		txBytes, err := json.Marshal(tx)
 		if err != nil {
-			return fmt.Errorf("failed to marshal block: %w", err)
+			return fmt.Errorf("failed to marshal transaction: %w", err)
+		}
+		if err := wb.Set([]byte(txPrefix+hex.EncodeToString(tx.ID)), txBytes); err != nil {
+			return fmt.Errorf("failed to save transaction: %w", err)
 		}
 
-		// Save by hash
-		blockHash := block.Hash()
-		hashKey := blockPrefix + hex.EncodeToString(blockHash)
-		if err := txn.Set([]byte(hashKey), blockBytes); err != nil {
-			return fmt.Errorf("failed to save block by hash: %w", err)
+		for _, key := range bs.transactionIndexKeys(tx, block.Header.Height) {
+			if err := wb.Set([]byte(key), tx.ID); err != nil {
+				return fmt.Errorf("failed to save transaction index: %w", err)
+			}
 		}
+	}
 
-		// Save height -> hash mapping
-		heightKey := fmt.Sprintf("%s%020d", blockHeightPrefix, block.Header.Height)
-		if err := txn.Set([]byte(heightKey), blockHash); err != nil {
-			return fmt.Errorf("failed to save block height index: %w", err)
+	for _, receipt := range commit.Receipts {
+		data, err := json.Marshal(receipt)
+		if err != nil {
+			return fmt.Errorf("failed to marshal receipt: %w", err)
+		}
+		if err := wb.Set([]byte(receiptPrefix+hex.EncodeToString(receipt.TransactionHash)), data); err != nil {
+			return fmt.Errorf("failed to save receipt: %w", err)
 		}
+	}
 
-		return nil
-	})
+	for key, value := range commit.Sets {
+		if err := wb.Set([]byte(statePrefix+key), value); err != nil {
+			return fmt.Errorf("failed to save state: %w", err)
+		}
+	}
+	for _, key := range commit.Deletes {
+		if err := wb.Delete([]byte(statePrefix + key)); err != nil {
+			return fmt.Errorf("failed to delete state: %w", err)
+		}
+	}
+
+	if err := wb.Flush(); err != nil {
+		return fmt.Errorf("failed to flush block batch: %w", err)
+	}
+	return nil
 }
 
 // GetBlock retrieves a block by hash
@@ -116,6 +176,9 @@ func (bs *BadgerStore) GetBlockByHeight(height uint64) (*blockchain.Block, error
 	})
 
 	if err == badger.ErrKeyNotFound {
+		if prunedBelow, perr := bs.prunedBelow(); perr == nil && height > 0 && height < prunedBelow {
+			return nil, fmt.Errorf("%w: height %d (blocks below %d are pruned)", blockchain.ErrBlockPruned, height, prunedBelow)
+		}
 		return nil, fmt.Errorf("block at height %d not found", height)
 	}
 
@@ -127,25 +190,6 @@ func (bs *BadgerStore) GetBlockByHeight(height uint64) (*blockchain.Block, error
 	return bs.GetBlock(blockHash)
 }
 
-// SaveTransaction saves a transaction to storage
-func (bs *BadgerStore) SaveTransaction(tx *blockchain.Transaction) error {
-	return bs.db.Update(func(txn *badger.Txn) error {
-		// Serialize transaction
-		txBytes, err := json.Marshal(tx)
-		if err != nil {
-			return fmt.Errorf("failed to marshal transaction: %w", err)
-		}
-
-		// Save by hash
-		key := txPrefix + hex.EncodeToString(tx.ID)
-		if err := txn.Set([]byte(key), txBytes); err != nil {
-			return fmt.Errorf("failed to save transaction: %w", err)
-		}
-
-		return nil
-	})
-}
-
 // GetTransaction retrieves a transaction by hash
 func (bs *BadgerStore) GetTransaction(hash []byte) (*blockchain.Transaction, error) {
 	var tx blockchain.Transaction
@@ -173,6 +217,124 @@ func (bs *BadgerStore) GetTransaction(hash []byte) (*blockchain.Transaction, err
 	return &tx, nil
 }
 
+// UnindexTransaction removes the index entries written by CommitBlock, for a
+// transaction whose block at height is no longer canonical
+func (bs *BadgerStore) UnindexTransaction(tx *blockchain.Transaction, height uint64) error {
+	keys := bs.transactionIndexKeys(tx, height)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return bs.db.Update(func(txn *badger.Txn) error {
+		for _, key := range keys {
+			if err := txn.Delete([]byte(key)); err != nil {
+				return fmt.Errorf("failed to delete transaction index: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// transactionIndexKeys returns the enabled secondary index keys for a transaction included at height
+func (bs *BadgerStore) transactionIndexKeys(tx *blockchain.Transaction, height uint64) []string {
+	return indexKeys(tx, height, bs.indexConfig)
+}
+
+// indexKeys returns the secondary index keys config enables for a transaction included at height
+func indexKeys(tx *blockchain.Transaction, height uint64, config *blockchain.IndexConfig) []string {
+	if tx.Data == nil {
+		return nil
+	}
+
+	var keys []string
+	suffix := fmt.Sprintf(":%020d:%s", height, hex.EncodeToString(tx.ID))
+
+	if config.Sender {
+		keys = append(keys, txAddrPrefix+strings.ToLower(tx.From)+suffix)
+	}
+
+	if config.Key {
+		for _, op := range tx.Data.Operations {
+			keys = append(keys, txKeyPrefix+hex.EncodeToString([]byte(op.Key))+suffix)
+		}
+	}
+
+	if config.Transfer {
+		for _, addr := range tx.TransferParties() {
+			keys = append(keys, txTransferPrefix+addr+suffix)
+		}
+	}
+
+	return keys
+}
+
+// GetTransactionsByAddress returns transactions sent from an address, newest first
+func (bs *BadgerStore) GetTransactionsByAddress(address string, limit int) ([]*blockchain.Transaction, error) {
+	if !bs.indexConfig.Sender {
+		return nil, blockchain.ErrIndexingDisabled
+	}
+	return bs.scanTransactionIndex(txAddrPrefix+strings.ToLower(address)+":", limit)
+}
+
+// GetTransactionsByKey returns transactions that touched a state key, newest first
+func (bs *BadgerStore) GetTransactionsByKey(key string, limit int) ([]*blockchain.Transaction, error) {
+	if !bs.indexConfig.Key {
+		return nil, blockchain.ErrIndexingDisabled
+	}
+	return bs.scanTransactionIndex(txKeyPrefix+hex.EncodeToString([]byte(key))+":", limit)
+}
+
+// GetTransfersByAddress returns transactions that moved tokens to or from an address, newest first
+func (bs *BadgerStore) GetTransfersByAddress(address string, limit int) ([]*blockchain.Transaction, error) {
+	if !bs.indexConfig.Transfer {
+		return nil, blockchain.ErrIndexingDisabled
+	}
+	return bs.scanTransactionIndex(txTransferPrefix+strings.ToLower(address)+":", limit)
+}
+
+// scanTransactionIndex loads the transactions referenced by an index prefix in reverse order
+func (bs *BadgerStore) scanTransactionIndex(prefix string, limit int) ([]*blockchain.Transaction, error) {
+	var hashes [][]byte
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(prefix)
+		opts.Reverse = true
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(append([]byte(prefix), 0xff)); it.ValidForPrefix([]byte(prefix)); it.Next() {
+			if limit > 0 && len(hashes) >= limit {
+				break
+			}
+
+			hash, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			hashes = append(hashes, hash)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan transaction index: %w", err)
+	}
+
+	transactions := make([]*blockchain.Transaction, 0, len(hashes))
+	for _, hash := range hashes {
+		tx, err := bs.GetTransaction(hash)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, tx)
+	}
+
+	return transactions, nil
+}
+
 // SaveState saves a state key-value pair
 func (bs *BadgerStore) SaveState(key string, value []byte) error {
 	return bs.db.Update(func(txn *badger.Txn) error {
@@ -181,6 +343,28 @@ func (bs *BadgerStore) SaveState(key string, value []byte) error {
 	})
 }
 
+// SaveStateBatch sets and deletes state keys in a single write batch
+func (bs *BadgerStore) SaveStateBatch(sets map[string][]byte, deletes []string) error {
+	wb := bs.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for key, value := range sets {
+		if err := wb.Set([]byte(statePrefix+key), value); err != nil {
+			return fmt.Errorf("failed to save state: %w", err)
+		}
+	}
+	for _, key := range deletes {
+		if err := wb.Delete([]byte(statePrefix + key)); err != nil {
+			return fmt.Errorf("failed to delete state: %w", err)
+		}
+	}
+
+	if err := wb.Flush(); err != nil {
+		return fmt.Errorf("failed to flush state batch: %w", err)
+	}
+	return nil
+}
+
 // GetState retrieves a state value by key
 func (bs *BadgerStore) GetState(key string) ([]byte, error) {
 	var value []byte
@@ -217,6 +401,14 @@ func (bs *BadgerStore) DeleteState(key string) error {
 	})
 }
 
+// DeleteBlockHeight removes the height -> hash mapping, leaving the block itself stored by hash
+func (bs *BadgerStore) DeleteBlockHeight(height uint64) error {
+	return bs.db.Update(func(txn *badger.Txn) error {
+		heightKey := fmt.Sprintf("%s%020d", blockHeightPrefix, height)
+		return txn.Delete([]byte(heightKey))
+	})
+}
+
 // SaveBlockHeight saves the current block height
 func (bs *BadgerStore) SaveBlockHeight(height uint64) error {
 	return bs.db.Update(func(txn *badger.Txn) error {
@@ -252,6 +444,188 @@ func (bs *BadgerStore) GetLatestBlockHeight() (uint64, error) {
 	return height, nil
 }
 
+// PruneBelow deletes the blocks below height except genesis, together with their
+// transactions, weights and height and transaction index entries. GetBlockByHeight
+// returns blockchain.ErrBlockPruned for the pruned heights afterwards.
+func (bs *BadgerStore) PruneBelow(height uint64) error {
+	from, err := bs.prunedBelow()
+	if err != nil {
+		return err
+	}
+	from = max(from, 1) // Genesis is never pruned
+	if height <= from {
+		return nil
+	}
+
+	// Indexes may have been enabled when the blocks were written, so remove them all
+	allIndexes := blockchain.DefaultIndexConfig()
+
+	wb := bs.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for h := from; h < height; h++ {
+		block, err := bs.GetBlockByHeight(h)
+		if err != nil {
+			continue // Already removed by an interrupted earlier pruning
+		}
+
+		keys := []string{
+			blockPrefix + hex.EncodeToString(block.Hash()),
+			fmt.Sprintf("%s%020d", blockHeightPrefix, h),
+			blockWeightPrefix + hex.EncodeToString(block.Hash()),
+		}
+		for _, tx := range block.Transactions {
+			keys = append(keys, txPrefix+hex.EncodeToString(tx.ID))
+			keys = append(keys, receiptPrefix+hex.EncodeToString(tx.ID))
+			keys = append(keys, indexKeys(tx, h, allIndexes)...)
+		}
+
+		for _, key := range keys {
+			if err := wb.Delete([]byte(key)); err != nil {
+				return fmt.Errorf("failed to prune block at height %d: %w", h, err)
+			}
+		}
+	}
+
+	if err := wb.Set([]byte(metaPrunedKey), []byte(fmt.Sprintf("%d", height))); err != nil {
+		return fmt.Errorf("failed to save pruned height: %w", err)
+	}
+
+	if err := wb.Flush(); err != nil {
+		return fmt.Errorf("failed to prune blocks: %w", err)
+	}
+	return nil
+}
+
+// prunedBelow returns the height below which blocks have been pruned, or 0 if none have
+func (bs *BadgerStore) prunedBelow() (uint64, error) {
+	var height uint64
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(metaPrunedKey))
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			_, err := fmt.Sscanf(string(val), "%d", &height)
+			return err
+		})
+	})
+
+	if err == badger.ErrKeyNotFound {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to get pruned height: %w", err)
+	}
+
+	return height, nil
+}
+
+// SaveStateSnapshot saves the encoded state snapshot that replaces the pruned blocks
+func (bs *BadgerStore) SaveStateSnapshot(data []byte) error {
+	return bs.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(metaSnapshotKey), data)
+	})
+}
+
+// GetStateSnapshot retrieves the saved state snapshot, or nil if there is none
+func (bs *BadgerStore) GetStateSnapshot() ([]byte, error) {
+	var data []byte
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(metaSnapshotKey))
+		if err != nil {
+			return err
+		}
+
+		data, err = item.ValueCopy(nil)
+		return err
+	})
+
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state snapshot: %w", err)
+	}
+
+	return data, nil
+}
+
+// SaveBlockWeight saves the cumulative chain weight up to a block
+func (bs *BadgerStore) SaveBlockWeight(hash []byte, weight uint64) error {
+	return bs.db.Update(func(txn *badger.Txn) error {
+		key := blockWeightPrefix + hex.EncodeToString(hash)
+		return txn.Set([]byte(key), []byte(fmt.Sprintf("%d", weight)))
+	})
+}
+
+// GetBlockWeight retrieves the cumulative chain weight up to a block
+func (bs *BadgerStore) GetBlockWeight(hash []byte) (uint64, error) {
+	var weight uint64
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		key := blockWeightPrefix + hex.EncodeToString(hash)
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			_, err := fmt.Sscanf(string(val), "%d", &weight)
+			return err
+		})
+	})
+
+	if err == badger.ErrKeyNotFound {
+		return 0, errors.New("block weight not found")
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to get block weight: %w", err)
+	}
+
+	return weight, nil
+}
+
+// GetReceipt retrieves the receipt of a transaction by its hash
+func (bs *BadgerStore) GetReceipt(hash []byte) (*blockchain.TransactionReceipt, error) {
+	var receipt blockchain.TransactionReceipt
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		key := receiptPrefix + hex.EncodeToString(hash)
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &receipt)
+		})
+	})
+
+	if err == badger.ErrKeyNotFound {
+		return nil, blockchain.ErrReceiptNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipt: %w", err)
+	}
+
+	return &receipt, nil
+}
+
+// DeleteReceipt removes the receipt of a transaction whose block is no longer canonical
+func (bs *BadgerStore) DeleteReceipt(hash []byte) error {
+	return bs.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(receiptPrefix + hex.EncodeToString(hash)))
+	})
+}
+
 // Close closes the database
 func (bs *BadgerStore) Close() error {
 	return bs.db.Close()
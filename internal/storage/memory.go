@@ -0,0 +1,456 @@
+package storage
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+// MemoryStore implements blockchain.Storage in memory, for tests and CI runs that
+// don't need data to survive a restart. Blocks and transactions are stored
+// serialized, like in BadgerStore, so callers never share them with the store.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	blocks      map[string][]byte // Block JSON by hex hash
+	heights     map[uint64][]byte // Block hash by height
+	txs         map[string][]byte // Transaction JSON by hex hash
+	index       map[string][]byte // Tx hash by secondary index key (BadgerStore layout)
+	state       map[string][]byte
+	weights     map[string]uint64 // Cumulative chain weight by hex block hash
+	receipts    map[string][]byte // Receipt JSON by hex transaction hash
+	height      uint64
+	hasHeight   bool
+	prunedBelow uint64
+	snapshot    []byte
+	indexConfig *blockchain.IndexConfig
+}
+
+// NewMemoryStore creates an empty in-memory storage
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		blocks:      make(map[string][]byte),
+		heights:     make(map[uint64][]byte),
+		txs:         make(map[string][]byte),
+		index:       make(map[string][]byte),
+		state:       make(map[string][]byte),
+		weights:     make(map[string]uint64),
+		receipts:    make(map[string][]byte),
+		indexConfig: blockchain.DefaultIndexConfig(),
+	}
+}
+
+// SetIndexConfig sets which secondary transaction indexes are written
+func (ms *MemoryStore) SetIndexConfig(config *blockchain.IndexConfig) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if config == nil {
+		config = blockchain.DefaultIndexConfig()
+	}
+	ms.indexConfig = config
+}
+
+// CommitBlock writes a block with its transactions, their index entries and receipts,
+// and the block's state changes in one step
+func (ms *MemoryStore) CommitBlock(commit *blockchain.BlockCommit) error {
+	block := commit.Block
+	blockBytes, err := json.Marshal(block)
+	if err != nil {
+		return fmt.Errorf("failed to marshal block: %w", err)
+	}
+	txs := make(map[string][]byte, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		txBytes, err := json.Marshal(tx)
+		if err != nil {
+			return fmt.Errorf("failed to marshal transaction: %w", err)
+		}
+		txs[hex.EncodeToString(tx.ID)] = txBytes
+	}
+	receipts := make(map[string][]byte, len(commit.Receipts))
+	for _, receipt := range commit.Receipts {
+		data, err := json.Marshal(receipt)
+		if err != nil {
+			return fmt.Errorf("failed to marshal receipt: %w", err)
+		}
+		receipts[hex.EncodeToString(receipt.TransactionHash)] = data
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	blockHash := block.Hash()
+	ms.blocks[hex.EncodeToString(blockHash)] = blockBytes
+	ms.heights[block.Header.Height] = blockHash
+	maps.Copy(ms.txs, txs)
+	for _, tx := range block.Transactions {
+		for _, key := range indexKeys(tx, block.Header.Height, ms.indexConfig) {
+			ms.index[key] = append([]byte{}, tx.ID...)
+		}
+	}
+	maps.Copy(ms.receipts, receipts)
+	for key, value := range commit.Sets {
+		ms.state[key] = append([]byte{}, value...)
+	}
+	for _, key := range commit.Deletes {
+		delete(ms.state, key)
+	}
+	return nil
+}
+
+// GetBlock retrieves a block by hash
+func (ms *MemoryStore) GetBlock(hash []byte) (*blockchain.Block, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.getBlock(hash)
+}
+
+// getBlock retrieves a block by hash (caller must hold ms.mu)
+func (ms *MemoryStore) getBlock(hash []byte) (*blockchain.Block, error) {
+	data, exists := ms.blocks[hex.EncodeToString(hash)]
+	if !exists {
+		return nil, errors.New("block not found")
+	}
+
+	var block blockchain.Block
+	if err := json.Unmarshal(data, &block); err != nil {
+		return nil, fmt.Errorf("failed to get block: %w", err)
+	}
+	return &block, nil
+}
+
+// GetBlockByHeight retrieves a block by height
+func (ms *MemoryStore) GetBlockByHeight(height uint64) (*blockchain.Block, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.getBlockByHeight(height)
+}
+
+// getBlockByHeight retrieves a block by height (caller must hold ms.mu)
+func (ms *MemoryStore) getBlockByHeight(height uint64) (*blockchain.Block, error) {
+	blockHash, exists := ms.heights[height]
+	if !exists {
+		if height > 0 && height < ms.prunedBelow {
+			return nil, fmt.Errorf("%w: height %d (blocks below %d are pruned)", blockchain.ErrBlockPruned, height, ms.prunedBelow)
+		}
+		return nil, fmt.Errorf("block at height %d not found", height)
+	}
+
+	return ms.getBlock(blockHash)
+}
+
+// GetTransaction retrieves a transaction by hash
+func (ms *MemoryStore) GetTransaction(hash []byte) (*blockchain.Transaction, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.getTransaction(hash)
+}
+
+// getTransaction retrieves a transaction by hash (caller must hold ms.mu)
+func (ms *MemoryStore) getTransaction(hash []byte) (*blockchain.Transaction, error) {
+	data, exists := ms.txs[hex.EncodeToString(hash)]
+	if !exists {
+		return nil, errors.New("transaction not found")
+	}
+
+	var tx blockchain.Transaction
+	if err := json.Unmarshal(data, &tx); err != nil {
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
+	}
+	return &tx, nil
+}
+
+// UnindexTransaction removes the index entries written by CommitBlock
+func (ms *MemoryStore) UnindexTransaction(tx *blockchain.Transaction, height uint64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for _, key := range indexKeys(tx, height, ms.indexConfig) {
+		delete(ms.index, key)
+	}
+	return nil
+}
+
+// GetTransactionsByAddress returns transactions sent from an address, newest first
+func (ms *MemoryStore) GetTransactionsByAddress(address string, limit int) ([]*blockchain.Transaction, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if !ms.indexConfig.Sender {
+		return nil, blockchain.ErrIndexingDisabled
+	}
+	return ms.scanTransactionIndex(txAddrPrefix+strings.ToLower(address)+":", limit)
+}
+
+// GetTransactionsByKey returns transactions that touched a state key, newest first
+func (ms *MemoryStore) GetTransactionsByKey(key string, limit int) ([]*blockchain.Transaction, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if !ms.indexConfig.Key {
+		return nil, blockchain.ErrIndexingDisabled
+	}
+	return ms.scanTransactionIndex(txKeyPrefix+hex.EncodeToString([]byte(key))+":", limit)
+}
+
+// GetTransfersByAddress returns transactions that moved tokens to or from an address, newest first
+func (ms *MemoryStore) GetTransfersByAddress(address string, limit int) ([]*blockchain.Transaction, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if !ms.indexConfig.Transfer {
+		return nil, blockchain.ErrIndexingDisabled
+	}
+	return ms.scanTransactionIndex(txTransferPrefix+strings.ToLower(address)+":", limit)
+}
+
+// scanTransactionIndex loads the transactions referenced by an index prefix in
+// reverse key order (caller must hold ms.mu)
+func (ms *MemoryStore) scanTransactionIndex(prefix string, limit int) ([]*blockchain.Transaction, error) {
+	keys := make([]string, 0)
+	for key := range ms.index {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	transactions := make([]*blockchain.Transaction, 0, len(keys))
+	for _, key := range keys {
+		tx, err := ms.getTransaction(ms.index[key])
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, tx)
+	}
+
+	return transactions, nil
+}
+
+// SaveState saves a state key-value pair
+func (ms *MemoryStore) SaveState(key string, value []byte) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.state[key] = append([]byte{}, value...)
+	return nil
+}
+
+// SaveStateBatch sets and deletes state keys in one step
+func (ms *MemoryStore) SaveStateBatch(sets map[string][]byte, deletes []string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for key, value := range sets {
+		ms.state[key] = append([]byte{}, value...)
+	}
+	for _, key := range deletes {
+		delete(ms.state, key)
+	}
+	return nil
+}
+
+// GetState retrieves a state value by key
+func (ms *MemoryStore) GetState(key string) ([]byte, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	value, exists := ms.state[key]
+	if !exists {
+		return nil, errors.New("state key not found")
+	}
+	return append([]byte{}, value...), nil
+}
+
+// DeleteState deletes a state key
+func (ms *MemoryStore) DeleteState(key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.state, key)
+	return nil
+}
+
+// DeleteBlockHeight removes the height -> hash mapping, leaving the block itself stored by hash
+func (ms *MemoryStore) DeleteBlockHeight(height uint64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.heights, height)
+	return nil
+}
+
+// SaveBlockHeight saves the current block height
+func (ms *MemoryStore) SaveBlockHeight(height uint64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.height = height
+	ms.hasHeight = true
+	return nil
+}
+
+// GetLatestBlockHeight retrieves the latest block height
+func (ms *MemoryStore) GetLatestBlockHeight() (uint64, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if !ms.hasHeight {
+		return 0, errors.New("height not found")
+	}
+	return ms.height, nil
+}
+
+// PruneBelow deletes the blocks below height except genesis, together with their
+// transactions, weights and height and transaction index entries
+func (ms *MemoryStore) PruneBelow(height uint64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	from := max(ms.prunedBelow, 1) // Genesis is never pruned
+	if height <= from {
+		return nil
+	}
+
+	allIndexes := blockchain.DefaultIndexConfig()
+	for h := from; h < height; h++ {
+		block, err := ms.getBlockByHeight(h)
+		if err != nil {
+			continue
+		}
+
+		hash := hex.EncodeToString(block.Hash())
+		delete(ms.blocks, hash)
+		delete(ms.weights, hash)
+		delete(ms.heights, h)
+		for _, tx := range block.Transactions {
+			delete(ms.txs, hex.EncodeToString(tx.ID))
+			delete(ms.receipts, hex.EncodeToString(tx.ID))
+			for _, key := range indexKeys(tx, h, allIndexes) {
+				delete(ms.index, key)
+			}
+		}
+	}
+
+	ms.prunedBelow = height
+	return nil
+}
+
+// SaveStateSnapshot saves the encoded state snapshot that replaces the pruned blocks
+func (ms *MemoryStore) SaveStateSnapshot(data []byte) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.snapshot = append([]byte{}, data...)
+	return nil
+}
+
+// GetStateSnapshot retrieves the saved state snapshot, or nil if there is none
+func (ms *MemoryStore) GetStateSnapshot() ([]byte, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if ms.snapshot == nil {
+		return nil, nil
+	}
+	return append([]byte{}, ms.snapshot...), nil
+}
+
+// SaveBlockWeight saves the cumulative chain weight up to a block
+func (ms *MemoryStore) SaveBlockWeight(hash []byte, weight uint64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.weights[hex.EncodeToString(hash)] = weight
+	return nil
+}
+
+// GetBlockWeight retrieves the cumulative chain weight up to a block
+func (ms *MemoryStore) GetBlockWeight(hash []byte) (uint64, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	weight, exists := ms.weights[hex.EncodeToString(hash)]
+	if !exists {
+		return 0, errors.New("block weight not found")
+	}
+	return weight, nil
+}
+
+// GetReceipt retrieves the receipt of a transaction by its hash
+func (ms *MemoryStore) GetReceipt(hash []byte) (*blockchain.TransactionReceipt, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	data, exists := ms.receipts[hex.EncodeToString(hash)]
+	if !exists {
+		return nil, blockchain.ErrReceiptNotFound
+	}
+
+	var receipt blockchain.TransactionReceipt
+	if err := json.Unmarshal(data, &receipt); err != nil {
+		return nil, fmt.Errorf("failed to get receipt: %w", err)
+	}
+	return &receipt, nil
+}
+
+// DeleteReceipt removes the receipt of a transaction whose block is no longer canonical
+func (ms *MemoryStore) DeleteReceipt(hash []byte) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.receipts, hex.EncodeToString(hash))
+	return nil
+}
+
+// ScanStateByPrefix scans all state keys with a given prefix, in key order
+func (ms *MemoryStore) ScanStateByPrefix(prefix string, limit int) (map[string][]byte, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	results := make(map[string][]byte)
+	for _, key := range ms.sortedStateKeys(prefix, limit) {
+		results[key] = append([]byte{}, ms.state[key]...)
+	}
+	return results, nil
+}
+
+// GetAllStateKeys returns all state keys in key order
+func (ms *MemoryStore) GetAllStateKeys(limit int) ([]string, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.sortedStateKeys("", limit), nil
+}
+
+// sortedStateKeys returns the first limit state keys with prefix (caller must hold ms.mu)
+func (ms *MemoryStore) sortedStateKeys(prefix string, limit int) []string {
+	var keys []string
+	for key := range ms.state {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys
+}
+
+// Close releases the stored data
+func (ms *MemoryStore) Close() error {
+	return nil
+}
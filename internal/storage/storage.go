@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+// Storage backends selectable with Open
+const (
+	BackendBadger = "badger" // BadgerDB under the data directory (default)
+	BackendMemory = "memory" // In memory, lost on shutdown
+)
+
+// IndexConfigurable is a storage whose secondary transaction indexes can be toggled
+type IndexConfigurable interface {
+	SetIndexConfig(config *blockchain.IndexConfig)
+}
+
+// Open opens the storage backend by name. The data directory is only used by
+// backends that persist to disk.
+func Open(backend, dataDir string) (blockchain.Storage, error) {
+	switch backend {
+	case BackendBadger, "":
+		return NewBadgerStore(dataDir)
+	case BackendMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
+	}
+}
+
+// IsValidBackend reports whether Open supports backend
+func IsValidBackend(backend string) bool {
+	return backend == BackendBadger || backend == BackendMemory
+}
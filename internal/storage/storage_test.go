@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/podoru/podoru-chain/internal/blockchain"
+)
+
+// testStores returns an empty store of each backend
+func testStores(t *testing.T) map[string]blockchain.Storage {
+	t.Helper()
+	badger, err := NewBadgerStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { badger.Close() })
+
+	return map[string]blockchain.Storage{
+		BackendBadger: badger,
+		BackendMemory: NewMemoryStore(),
+	}
+}
+
+// testCommit returns the commit of a block at height 1 with one transaction from
+// 0xabc setting "k" and transferring to 0xdef
+func testCommit() *blockchain.BlockCommit {
+	tx := &blockchain.Transaction{
+		From: "0xabc",
+		Data: &blockchain.TransactionData{Operations: []*blockchain.KVOperation{
+			{Type: blockchain.OpTypeSet, Key: "k", Value: []byte("v")},
+			{Type: blockchain.OpTypeTransfer, Key: blockchain.BalanceKey("0xdef"), Value: big.NewInt(5).Bytes()},
+		}},
+	}
+	tx.ID = tx.Hash()
+
+	block := &blockchain.Block{
+		Header:       &blockchain.BlockHeader{Height: 1, PreviousHash: make([]byte, 32)},
+		Transactions: []*blockchain.Transaction{tx},
+	}
+	return &blockchain.BlockCommit{
+		Block:    block,
+		Receipts: []*blockchain.TransactionReceipt{{TransactionHash: tx.ID, BlockHeight: 1, Success: true}},
+		Sets:     map[string][]byte{"k": []byte("v")},
+		Deletes:  []string{"gone"},
+	}
+}
+
+func TestCommitBlockWritesIndexesWithBlock(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := store.SaveState("gone", []byte("x")); err != nil {
+				t.Fatal(err)
+			}
+
+			commit := testCommit()
+			if err := store.CommitBlock(commit); err != nil {
+				t.Fatal(err)
+			}
+			tx := commit.Block.Transactions[0]
+
+			if block, err := store.GetBlockByHeight(1); err != nil || block.HashString() != commit.Block.HashString() {
+				t.Fatalf("block not stored by height: %v", err)
+			}
+			if _, err := store.GetReceipt(tx.ID); err != nil {
+				t.Fatalf("receipt not stored: %v", err)
+			}
+			if value, err := store.GetState("k"); err != nil || string(value) != "v" {
+				t.Fatalf("state set not stored: %q, %v", value, err)
+			}
+			if _, err := store.GetState("gone"); err == nil {
+				t.Fatal("state delete not applied")
+			}
+
+			for index, lookup := range map[string]func() ([]*blockchain.Transaction, error){
+				"sender":   func() ([]*blockchain.Transaction, error) { return store.GetTransactionsByAddress("0xabc", 0) },
+				"key":      func() ([]*blockchain.Transaction, error) { return store.GetTransactionsByKey("k", 0) },
+				"transfer": func() ([]*blockchain.Transaction, error) { return store.GetTransfersByAddress("0xdef", 0) },
+			} {
+				txs, err := lookup()
+				if err != nil || len(txs) != 1 || txs[0].HashString() != tx.HashString() {
+					t.Fatalf("%s index: got %d transactions, %v", index, len(txs), err)
+				}
+			}
+		})
+	}
+}
+
+func TestCommitBlockSkipsDisabledIndexes(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store.(IndexConfigurable).SetIndexConfig(&blockchain.IndexConfig{Sender: true})
+			if err := store.CommitBlock(testCommit()); err != nil {
+				t.Fatal(err)
+			}
+
+			if txs, err := store.GetTransactionsByAddress("0xabc", 0); err != nil || len(txs) != 1 {
+				t.Fatalf("sender index: got %d transactions, %v", len(txs), err)
+			}
+			if _, err := store.GetTransactionsByKey("k", 0); !errors.Is(err, blockchain.ErrIndexingDisabled) {
+				t.Fatalf("disabled key index: got %v, want ErrIndexingDisabled", err)
+			}
+		})
+	}
+}
+
+func TestGetTransactionsByAddress(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			// 0xabc sends one transaction in each of three blocks, 0xdef one in the second
+			var sent []*blockchain.Transaction
+			for height := uint64(1); height <= 3; height++ {
+				txs := []*blockchain.Transaction{{From: "0xabc", Nonce: height - 1}}
+				if height == 2 {
+					txs = append(txs, &blockchain.Transaction{From: "0xdef"})
+				}
+				for _, tx := range txs {
+					tx.Data = &blockchain.TransactionData{Operations: []*blockchain.KVOperation{
+						{Type: blockchain.OpTypeSet, Key: "k", Value: []byte{byte(height)}},
+					}}
+					tx.ID = tx.Hash()
+				}
+				sent = append(sent, txs[0])
+
+				block := &blockchain.Block{Header: &blockchain.BlockHeader{Height: height, PreviousHash: make([]byte, 32)}, Transactions: txs}
+				if err := store.CommitBlock(&blockchain.BlockCommit{Block: block}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			txs, err := store.GetTransactionsByAddress("0xABC", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(txs) != len(sent) {
+				t.Fatalf("got %d transactions from 0xabc, want %d", len(txs), len(sent))
+			}
+			for i, tx := range txs {
+				if want := sent[len(sent)-1-i]; tx.HashString() != want.HashString() {
+					t.Errorf("transaction %d is nonce %d, want newest first", i, tx.Nonce)
+				}
+			}
+
+			if txs, err := store.GetTransactionsByAddress("0xabc", 2); err != nil || len(txs) != 2 || txs[0].Nonce != 2 {
+				t.Errorf("limit 2: got %d transactions, %v", len(txs), err)
+			}
+			if txs, err := store.GetTransactionsByAddress("0xdef", 0); err != nil || len(txs) != 1 || txs[0].From != "0xdef" {
+				t.Errorf("0xdef: got %d transactions, %v", len(txs), err)
+			}
+			if txs, err := store.GetTransactionsByAddress("0x123", 0); err != nil || len(txs) != 0 {
+				t.Errorf("unknown address: got %d transactions, %v", len(txs), err)
+			}
+		})
+	}
+}
+
+func TestPruneBelow(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			var blocks []*blockchain.Block
+			for height := uint64(0); height <= 5; height++ {
+				tx := &blockchain.Transaction{From: "0xabc", Nonce: height, Data: &blockchain.TransactionData{
+					Operations: []*blockchain.KVOperation{{Type: blockchain.OpTypeSet, Key: "k", Value: []byte{byte(height)}}},
+				}}
+				tx.ID = tx.Hash()
+				block := &blockchain.Block{
+					Header:       &blockchain.BlockHeader{Height: height, PreviousHash: make([]byte, 32)},
+					Transactions: []*blockchain.Transaction{tx},
+				}
+				commit := &blockchain.BlockCommit{
+					Block:    block,
+					Receipts: []*blockchain.TransactionReceipt{{TransactionHash: tx.ID, BlockHeight: height, Success: true}},
+				}
+				if err := store.CommitBlock(commit); err != nil {
+					t.Fatal(err)
+				}
+				blocks = append(blocks, block)
+			}
+
+			if err := store.PruneBelow(3); err != nil {
+				t.Fatalf("PruneBelow: %v", err)
+			}
+
+			for _, block := range blocks {
+				height := block.Header.Height
+				tx := block.Transactions[0]
+				pruned := height > 0 && height < 3 // Genesis is kept
+
+				_, err := store.GetBlockByHeight(height)
+				if pruned {
+					if !errors.Is(err, blockchain.ErrBlockPruned) {
+						t.Errorf("GetBlockByHeight(%d) = %v, want ErrBlockPruned", height, err)
+					}
+				} else if err != nil {
+					t.Errorf("GetBlockByHeight(%d) of a retained block: %v", height, err)
+				}
+
+				_, blockErr := store.GetBlock(block.Hash())
+				_, txErr := store.GetTransaction(tx.ID)
+				_, receiptErr := store.GetReceipt(tx.ID)
+				for what, err := range map[string]error{"block": blockErr, "transaction": txErr, "receipt": receiptErr} {
+					if pruned && err == nil {
+						t.Errorf("%s at height %d is still stored after pruning", what, height)
+					}
+					if !pruned && err != nil {
+						t.Errorf("%s at height %d: %v", what, height, err)
+					}
+				}
+			}
+
+			// The address index no longer lists the pruned transactions
+			txs, err := store.GetTransactionsByAddress("0xabc", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(txs) != 4 {
+				t.Errorf("address index lists %d transactions, want the 4 retained", len(txs))
+			}
+
+			// Pruning at or below an earlier pruning height is a no-op
+			if err := store.PruneBelow(2); err != nil {
+				t.Fatalf("PruneBelow below the pruned height: %v", err)
+			}
+			if _, err := store.GetBlockByHeight(3); err != nil {
+				t.Errorf("block 3 after a no-op prune: %v", err)
+			}
+		})
+	}
+}
+
+// BenchmarkBadgerStateWrites compares writing the state changes of a 500-operation
+// block one key at a time with writing them in one batch
+func BenchmarkBadgerStateWrites(b *testing.B) {
+	const ops = 500
+	sets := make(map[string][]byte, ops)
+	for i := range ops {
+		sets[fmt.Sprintf("key:%d", i)] = []byte(fmt.Sprintf("value:%d", i))
+	}
+
+	store, err := NewBadgerStore(b.TempDir())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { store.Close() })
+
+	b.Run("per-key", func(b *testing.B) {
+		for b.Loop() {
+			for key, value := range sets {
+				if err := store.SaveState(key, value); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("batched", func(b *testing.B) {
+		for b.Loop() {
+			if err := store.SaveStateBatch(sets, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -40,6 +40,7 @@ usage() {
     echo "  --supply AMOUNT      Initial supply in wei (default: $INITIAL_SUPPLY)"
     echo "  --base-fee FEE       Base fee in wei (default: $BASE_FEE)"
     echo "  --per-byte-fee FEE   Per-byte fee in wei (default: $PER_BYTE_FEE)"
+    echo "  --accounting         Charge gas fees and track supply (new chains only)"
     echo ""
     echo "Examples:"
     echo "  $0                                    # Patch config/genesis.json in place"
@@ -52,6 +53,7 @@ usage() {
 # Parse options
 DRY_RUN=false
 NO_BALANCES=false
+ACCOUNTING=false
 
 while [[ $# -gt 0 ]]; do
     case $1 in
@@ -66,6 +68,10 @@ while [[ $# -gt 0 ]]; do
             NO_BALANCES=true
             shift
             ;;
+        --accounting)
+            ACCOUNTING=true
+            shift
+            ;;
         --supply)
             INITIAL_SUPPLY="$2"
             shift 2
@@ -171,13 +177,14 @@ PATCHED_GENESIS=$(echo "$GENESIS" | jq --argjson balances "$BALANCE_JSON" \
     --arg base_fee "$BASE_FEE" \
     --arg per_byte_fee "$PER_BYTE_FEE" \
     --argjson no_balances "$NO_BALANCES" \
+    --argjson accounting "$ACCOUNTING" \
     '. + {
         token_config: {
             name: $token_name,
             symbol: $token_symbol,
             decimals: $token_decimals,
             initial_supply: $initial_supply
-        },
+        } + (if $accounting then {accounting: true} else {} end),
         gas_config: {
             base_fee: $base_fee,
             per_byte_fee: $per_byte_fee